@@ -0,0 +1,67 @@
+package main
+
+import "unicode"
+
+// accelerators gives every title without an access key one that its siblings do not use yet, with
+// the "&" Explorer reads in front of it. It is used with accelerators in the config. Keys already
+// in titles are kept, and titles left without a free letter or digit get none.
+func (c ContextMenus) accelerators() (menus ContextMenus) {
+	var used = make(map[rune]bool)
+	for _, entry := range c {
+		if key, ok := accelerator(entry.Menu.Title); ok {
+			used[key] = true
+		}
+	}
+	for _, entry := range c {
+		var item = *entry.Menu
+		if _, ok := accelerator(item.Title); !ok {
+			item.Title = addAccelerator(item.Title, used)
+		}
+		if item.Type == ContextMenuType_Folder {
+			item.Items = item.Items.accelerators()
+		}
+		menus = append(menus, ContextMenuEntry{ID: entry.ID, Menu: &item})
+	}
+	return
+}
+
+// accelerator is the access key of a title, the character after a single "&", in lower case.
+func accelerator(title string) (key rune, ok bool) {
+	var runes = []rune(title)
+	for i := 0; i < len(runes)-1; i++ {
+		if runes[i] != '&' {
+			continue
+		}
+		if runes[i+1] != '&' {
+			return unicode.ToLower(runes[i+1]), true
+		}
+		i++
+	}
+	return
+}
+
+// addAccelerator marks the first character of a title that is free in used as its access key,
+// trying the first letters of its words before the others, and adds it to used.
+func addAccelerator(title string, used map[rune]bool) string {
+	var (
+		runes      = []rune(title)
+		candidates []int
+		rest       []int
+	)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+		case i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]):
+			candidates = append(candidates, i)
+		default:
+			rest = append(rest, i)
+		}
+	}
+	for _, i := range append(candidates, rest...) {
+		if key := unicode.ToLower(runes[i]); !used[key] {
+			used[key] = true
+			return string(runes[:i]) + "&" + string(runes[i:])
+		}
+	}
+	return title
+}
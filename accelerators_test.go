@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccelerator(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		want   rune
+		wantOK bool
+	}{
+		{title: "Open"},
+		{title: "&Open", want: 'o', wantOK: true},
+		{title: "Save &As", want: 'a', wantOK: true},
+		{title: "Tom && Jerry"},
+		{title: "Tom && &Jerry", want: 'j', wantOK: true},
+		{title: "Trailing &"},
+		{title: "&打开", want: '打', wantOK: true},
+	} {
+		if got, ok := accelerator(test.title); got != test.want || ok != test.wantOK {
+			t.Errorf("accelerator(%q) = %q, %t, expected %q, %t", test.title, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+// TestAccelerators checks the access keys given to titles: keys already taken among siblings are
+// kept and skipped, first letters of words come before the others, and folders get their own.
+func TestAccelerators(t *testing.T) {
+	var (
+		menus = testMenus(t, `{
+			"open": {"type": "item", "title": "Open", "command": ["a"]},
+			"options": {"type": "item", "title": "&Options", "command": ["a"]},
+			"open-here": {"type": "item", "title": "Open here", "command": ["a"]},
+			"oo": {"type": "item", "title": "OO", "command": ["a"]},
+			"tom": {"type": "item", "title": "Tom && Jerry", "command": ["a"]},
+			"tools": {"type": "folder", "title": "Tools", "items": {
+				"open": {"type": "item", "title": "Open", "command": ["a"]},
+				"old": {"type": "item", "title": "2 old", "command": ["a"]}
+			}}
+		}`)
+		titles func(menus ContextMenus) []string
+	)
+	titles = func(menus ContextMenus) (list []string) {
+		for _, entry := range menus {
+			list = append(append(list, entry.Menu.Title), titles(entry.Menu.Items)...)
+		}
+		return
+	}
+	want := []string{"O&pen", "&Options", "Open &here", "OO", "&Tom && Jerry", "Too&ls", "&Open", "&2 old"}
+	if got := titles(menus.accelerators()); !reflect.DeepEqual(got, want) {
+		t.Errorf("titles are %q, expected %q", got, want)
+	}
+	if got := menus.Get("open").Title; got != "Open" {
+		t.Errorf("changed the title of the manifest to %q", got)
+	}
+}
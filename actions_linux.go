@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// actionsPrefix starts the names of the files written for items, which share the folder with
+// actions from other tools.
+const actionsPrefix = "context-menu-manager-"
+
+// planActions renders the manifest into file manager actions, the desktop files PCManFM,
+// PCManFM-Qt and Caja with caja-actions read from the file-manager/actions folder. Each item
+// and folder gets a file; folders list their children, which then only show inside them.
+func planActions(manifest *Manifest, manifestDir string) (files []managedFile, err error) {
+	var (
+		dir   string
+		globs map[string]string
+		names = make(map[string]bool)
+		add   func(prefix string, menus ContextMenus, targets []string) (ids []string)
+	)
+	if dir, err = dataHome(); err != nil {
+		return
+	}
+	globs = mimeGlobs()
+	add = func(prefix string, menus ContextMenus, targets []string) (ids []string) {
+		for _, entry := range menus {
+			var (
+				b    strings.Builder
+				item = entry.Menu
+				id   = prefix + entry.ID
+				name = actionsPrefix + desktopActionName.ReplaceAllString(id, "_")
+			)
+			for base, n := name, 2; names[name]; n++ {
+				name = fmt.Sprintf("%s_%d", base, n)
+			}
+			names[name] = true
+			ids = append(ids, name)
+			if prefix == "" {
+				targets = manifest.Targets(id)
+			}
+			fmt.Fprintf(&b, "# Written by context-menu-manager for %q, changes are lost on the next apply.\n", id)
+			b.WriteString("[Desktop Entry]\n")
+			fmt.Fprintf(&b, "Name=%s\n", desktopEscape(plainTitle(item.Title)))
+			if item.Description != "" {
+				fmt.Fprintf(&b, "Tooltip=%s\n", desktopEscape(item.Description))
+			}
+			if icon := themeIcon(item, manifestDir); icon != "" {
+				fmt.Fprintf(&b, "Icon=%s\n", desktopEscape(icon))
+			}
+			// Children are planned first to learn their names, but written after their folder.
+			var children []managedFile
+			if item.Type == ContextMenuType_Folder {
+				var (
+					start    = len(files)
+					childIDs = add(id+"/", item.Items, targets)
+				)
+				children = append(children, files[start:]...)
+				files = files[:start]
+				fmt.Fprintf(&b, "Type=Menu\nItemsList=%s;\n", strings.Join(childIDs, ";"))
+			} else {
+				b.WriteString("Type=Action\nProfiles=main;\n\n[X-Action-Profile main]\n")
+				fmt.Fprintf(&b, "MimeTypes=%s;\n", strings.Join(mimeTypes(targets, globs), ";"))
+				fmt.Fprintf(&b, "Exec=%s\n", desktopExec(item, targets, manifestDir))
+			}
+			files = append(files, managedFile{ID: id, Path: filepath.Join(dir, "file-manager", "actions", name+".desktop"), Data: b.String()})
+			files = append(files, children...)
+		}
+		return
+	}
+	add("", manifest.Items, nil)
+	return
+}
+
+// actionState reports an action as enabled unless its file says otherwise.
+func actionState(path, id string) (installed, enabled bool, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); errors.Is(err, os.ErrNotExist) {
+		err = nil
+		return
+	} else if err != nil {
+		err = errorf("failed to read %s: %w", path, err)
+		return
+	}
+	installed = true
+	enabled = !strings.Contains(string(data), "\nEnabled=false\n")
+	return
+}
+
+// actionSetEnabled hides an action with Enabled=false at the start of its desktop entry, or drops
+// that line again.
+func actionSetEnabled(path, id string, enabled bool) (err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		err = errorf("failed to read %s: %w", path, err)
+		return
+	}
+	text := strings.Replace(string(data), "\nEnabled=false\n", "\n", 1)
+	if !enabled {
+		text = strings.Replace(text, "\n[Desktop Entry]\n", "\n[Desktop Entry]\nEnabled=false\n", 1)
+	}
+	if err = os.WriteFile(path, []byte(text), 0o644); err != nil {
+		err = errorf("failed to update %s: %w", path, err)
+	}
+	return
+}
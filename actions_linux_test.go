@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPlanActions checks that folders are written before the items they list, by names that stay
+// distinct, and that hiding an action leaves the rest of its file as it was.
+func TestPlanActions(t *testing.T) {
+	isolateState(t)
+	t.Setenv("XDG_DATA_DIRS", t.TempDir())
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.Targets = []string{"directory"}
+	var (
+		dir      = filepath.Join(os.Getenv("HOME"), ".local", "share", "file-manager", "actions")
+		manifest = &Manifest{Items: testMenus(t, `{
+			"tools": {"type": "folder", "title": "&Tools", "items": {
+				"a.b": {"type": "item", "title": "A", "command": ["a"]},
+				"sub": {"type": "folder", "title": "Sub", "items": {"c": {"type": "item", "title": "C", "command": ["c"]}}}
+			}},
+			"tools_a_b": {"type": "item", "title": "B", "description": "Runs b", "command": ["b", "%V"]}
+		}`)}
+		got  [][2]string
+		want = [][2]string{
+			{"tools", "context-menu-manager-tools"},
+			{"tools/a.b", "context-menu-manager-tools_a_b"},
+			{"tools/sub", "context-menu-manager-tools_sub"},
+			{"tools/sub/c", "context-menu-manager-tools_sub_c"},
+			{"tools_a_b", "context-menu-manager-tools_a_b_2"},
+		}
+	)
+	files, err := planActions(manifest, "/menus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if filepath.Dir(file.Path) != dir {
+			t.Errorf("%s is not in %s", file.Path, dir)
+		}
+		got = append(got, [2]string{file.ID, strings.TrimSuffix(filepath.Base(file.Path), ".desktop")})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("planned %q, expected %q", got, want)
+	}
+	for i, line := range map[int]string{
+		0: "Name=Tools\nType=Menu\nItemsList=context-menu-manager-tools_a_b;context-menu-manager-tools_sub;\n",
+		2: "Type=Menu\nItemsList=context-menu-manager-tools_sub_c;\n",
+		4: "Name=B\nTooltip=Runs b\nType=Action\nProfiles=main;\n\n[X-Action-Profile main]\nMimeTypes=inode/directory;\n",
+	} {
+		if !strings.Contains(files[i].Data, line) {
+			t.Errorf("%s has no\n%s\nin\n%s", files[i].ID, line, files[i].Data)
+		}
+	}
+
+	var path = filepath.Join(t.TempDir(), "action.desktop")
+	if installed, _, err := actionState(path, "tools_a_b"); err != nil || installed {
+		t.Errorf("missing action is installed: %v", err)
+	}
+	if err = os.WriteFile(path, []byte(files[4].Data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, enabled := range []bool{false, false, true} {
+		if err = actionSetEnabled(path, "tools_a_b", enabled); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		if installed, gotEnabled, err := actionState(path, "tools_a_b"); err != nil || !installed || gotEnabled != enabled {
+			t.Errorf("action set to enabled %t reads back as installed %t, enabled %t, %v:\n%s", enabled, installed, gotEnabled, err, data)
+		}
+		if enabled && string(data) != files[4].Data {
+			t.Errorf("enabled again, action is\n%s\nexpected\n%s", data, files[4].Data)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "strings"
+
+// applyJob writes one top-level item to one target. Jobs write disjoint subtrees, so they run in
+// parallel, while each writes its own keys in order.
+type applyJob struct {
+	id     string
+	target string
+	key    string
+	keys   []RegistryKey
+	hash   string
+	skip   bool
+	// err is why the item could not be written, when access to its keys was denied.
+	err error
+}
+
+// replacedVerbs describes the jobs that would replace a verb of other software: their key exists
+// in the hive applied to, which exists tells by its path in the hive, and the state does not list
+// it among the keys this tool wrote.
+func replacedVerbs(jobs []applyJob, state State, exists func(keyPath string) bool) (conflicts []string) {
+	var ours = make(map[string]bool)
+	for _, key := range state.Keys {
+		ours[strings.ToLower(key)] = true
+	}
+	for _, job := range jobs {
+		if !ours[strings.ToLower(job.key)] && exists(itemKeyPath(job.target, job.id)) {
+			conflicts = append(conflicts, sprintf("item ID %q on target %q would replace %s", job.id, job.target, job.key))
+		}
+	}
+	return
+}
@@ -0,0 +1,39 @@
+//go:build !windows && !linux && !darwin
+
+package main
+
+import (
+	"context"
+	"runtime"
+)
+
+func errNoBackend() error {
+	return errorf("no file manager of %s is supported yet", runtime.GOOS)
+}
+
+// unsupportedBackend fails every operation, leaving --backend memory to try things out.
+type unsupportedBackend struct{}
+
+func platformBackend() Backend {
+	return unsupportedBackend{}
+}
+
+func (unsupportedBackend) Apply(ctx context.Context, manifest *Manifest, manifestDir string) error {
+	return errNoBackend()
+}
+
+func (unsupportedBackend) Remove(ctx context.Context) error {
+	return errNoBackend()
+}
+
+func (unsupportedBackend) List(manifest *Manifest) ([]ItemStatus, error) {
+	return nil, errNoBackend()
+}
+
+func (unsupportedBackend) Diff(manifest *Manifest, manifestDir string) ([]Change, error) {
+	return nil, errNoBackend()
+}
+
+func (unsupportedBackend) Toggle(manifest *Manifest, id string, enable *bool) (bool, error) {
+	return false, errNoBackend()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestReplacedVerbs checks which items an apply refuses to write over a verb of other software:
+// those whose key exists without the state listing it, whatever the case of its name.
+func TestReplacedVerbs(t *testing.T) {
+	var (
+		job = func(target, id string) applyJob {
+			return applyJob{id: id, target: target, key: `HKCU\` + itemKeyPath(target, id)}
+		}
+		jobs = []applyJob{job("background", "terminal"), job("*", "editor"), job(".txt", "notepad")}
+	)
+	for _, test := range []struct {
+		name     string
+		existing []string
+		ours     []string
+		want     []string
+	}{
+		{name: "nothing there", want: nil},
+		{
+			name:     "ours",
+			existing: []string{itemKeyPath("background", "terminal"), itemKeyPath("*", "editor")},
+			ours:     []string{jobs[0].key, strings.ToUpper(jobs[1].key)},
+			want:     nil,
+		},
+		{
+			name:     "other software",
+			existing: []string{itemKeyPath("*", "editor"), itemKeyPath(".txt", "notepad")},
+			ours:     []string{jobs[0].key},
+			want: []string{
+				`item ID "editor" on target "*" would replace ` + jobs[1].key,
+				`item ID "notepad" on target ".txt" would replace ` + jobs[2].key,
+			},
+		},
+		{
+			name:     "ours in another hive",
+			existing: []string{itemKeyPath("background", "terminal")},
+			ours:     []string{`HKLM\` + itemKeyPath("background", "terminal")},
+			want:     []string{`item ID "terminal" on target "background" would replace ` + jobs[0].key},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var existing = make(map[string]bool)
+			for _, keyPath := range test.existing {
+				existing[keyPath] = true
+			}
+			got := replacedVerbs(jobs, State{Keys: test.ours}, func(keyPath string) bool {
+				return existing[keyPath]
+			})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("reported %q, expected %q", got, test.want)
+			}
+		})
+	}
+}
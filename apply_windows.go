@@ -0,0 +1,577 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	procRegDeleteTreeW = advapi32.NewProc("RegDeleteTreeW")
+	procRegSetValueExW = advapi32.NewProc("RegSetValueExW")
+)
+
+// savedKey is a key as it was before an apply, with its values of any type, to put it back.
+type savedKey struct {
+	path   string
+	values []savedValue
+}
+
+type savedValue struct {
+	name      string
+	valueType uint32
+	data      []byte
+}
+
+// saveKeyTree reads the key at path under k and everything below it, parents first. It is empty
+// when the key is missing.
+func saveKeyTree(k registry.Key, path string) (keys []savedKey, err error) {
+	var (
+		key   registry.Key
+		names []string
+		saved = savedKey{path: path}
+	)
+	if key, err = registry.OpenKey(k, path, registry.READ); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			err = nil
+			return
+		}
+		err = errorf("failed to open registry key %q: %w", path, err)
+		return
+	}
+	defer key.Close()
+	if names, err = key.ReadValueNames(-1); err != nil {
+		err = errorf("failed to read values of registry key %q: %w", path, err)
+		return
+	}
+	for _, name := range names {
+		var (
+			size      int
+			valueType uint32
+			data      []byte
+		)
+		if size, _, err = key.GetValue(name, nil); err != nil {
+			err = errorf("failed to read value %q of registry key %q: %w", name, path, err)
+			return
+		}
+		data = make([]byte, size)
+		if size, valueType, err = key.GetValue(name, data); err != nil {
+			err = errorf("failed to read value %q of registry key %q: %w", name, path, err)
+			return
+		}
+		saved.values = append(saved.values, savedValue{name: name, valueType: valueType, data: data[:size]})
+	}
+	keys = append(keys, saved)
+	if names, err = key.ReadSubKeyNames(-1); err != nil {
+		err = errorf("failed to read subkeys of registry key %q: %w", path, err)
+		return
+	}
+	for _, name := range names {
+		var subkeys []savedKey
+		if subkeys, err = saveKeyTree(k, path+`\`+name); err != nil {
+			return
+		}
+		keys = append(keys, subkeys...)
+	}
+	return
+}
+
+// restoreKeyTree replaces the subtree at path under k with the keys saveKeyTree read from it.
+func restoreKeyTree(k registry.Key, path string, keys []savedKey) (err error) {
+	if err = deleteRegKeyRecursive(k, path); err != nil {
+		err = errorf("failed to delete registry key %q: %w", path, err)
+		return
+	}
+	for _, saved := range keys {
+		var key registry.Key
+		if key, _, err = registry.CreateKey(k, saved.path, registry.ALL_ACCESS); err != nil {
+			err = errorf("failed to create registry key %q: %w", saved.path, err)
+			return
+		}
+		for _, value := range saved.values {
+			var (
+				name, _ = windows.UTF16PtrFromString(value.name)
+				data    *byte
+			)
+			if len(value.data) > 0 {
+				data = &value.data[0]
+			}
+			if r, _, _ := procRegSetValueExW.Call(uintptr(key), uintptr(unsafe.Pointer(name)), 0, uintptr(value.valueType), uintptr(unsafe.Pointer(data)), uintptr(len(value.data))); r != 0 {
+				key.Close()
+				err = errorf("failed to set value %q of registry key %q: %w", value.name, saved.path, syscall.Errno(r))
+				return
+			}
+		}
+		key.Close()
+	}
+	return
+}
+
+// createContextMenu replaces the subtree at keyPath with the planned keys. When ctx is cancelled
+// halfway, the keys saved from before are put back rather than leaving half an item.
+func createContextMenu(ctx context.Context, keyPath string, keys []RegistryKey, saved []savedKey) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = deleteRegKeyRecursive(config.Hive.Root(), keyPath); err != nil {
+		err = errorf("failed to delete registry key %q: %w", keyPath, err)
+		return
+	}
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			if restoreErr := restoreKeyTree(config.Hive.Root(), keyPath, saved); restoreErr != nil {
+				err = restoreErr
+			}
+			return
+		}
+		logf(LogLevel_Debug, "writing %s\\%s", config.Hive, key.Path)
+		if err = writeRegistryKey(config.Hive.Root(), key); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeRegistryKey(k registry.Key, regKey RegistryKey) (err error) {
+	var key registry.Key
+	if key, _, err = registry.CreateKey(k, regKey.Path, registry.ALL_ACCESS); err != nil {
+		err = errorf("failed to create registry key %q: %w", regKey.Path, err)
+		return
+	}
+	defer key.Close()
+	for _, value := range regKey.Values {
+		if value.Type == RegistryValueType_ExpandString {
+			err = key.SetExpandStringValue(value.Name, value.Data)
+		} else {
+			err = key.SetStringValue(value.Name, value.Data)
+		}
+		if err != nil {
+			err = errorf("failed to set value %q of registry key %q: %w", value.Name, regKey.Path, err)
+			return
+		}
+	}
+	return
+}
+
+// Antivirus and Explorer hold keys open for a moment now and then, which makes writes fail with
+// a sharing violation or as denied. Those are retried a few times, waiting longer each time.
+const (
+	registryRetries    = 4
+	registryRetryDelay = 50 * time.Millisecond
+)
+
+func isTransientRegistryError(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) ||
+		errors.Is(err, windows.ERROR_LOCK_VIOLATION) ||
+		errors.Is(err, windows.ERROR_ACCESS_DENIED) ||
+		errors.Is(err, windows.ERROR_KEY_DELETED) ||
+		errors.Is(err, windows.ERROR_BUSY)
+}
+
+// retryRegistry runs do until it succeeds, fails for good or runs out of retries. do has to be
+// safe to run again after failing halfway, as replacing an item's subtree is.
+func retryRegistry(do func() error) (err error) {
+	var delay = registryRetryDelay
+	for attempt := 1; ; attempt++ {
+		if err = do(); err == nil || attempt > registryRetries || !isTransientRegistryError(err) {
+			return
+		}
+		logf(LogLevel_Debug, "retrying in %v: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// applyWorkers bounds how many items are written at once. Writes mostly wait on the registry, so
+// this is not tied to the number of CPUs.
+const applyWorkers = 8
+
+// Apply writes every item to each of its targets, then records the written keys in the state so
+// that, with prune enabled, keys of items dropped from the manifest are deleted. Items planned
+// the same as in the last apply are skipped while the registry still holds them as planned, unless
+// forced. Policies that keep them from showing are logged first.
+func (registryBackend) Apply(ctx context.Context, manifest *Manifest, manifestDir string) (err error) {
+	var (
+		state   State
+		written = make(map[string]bool)
+		ours    = make(map[string]bool)
+		keys    []string
+		hashes  = make(map[string]string)
+		jobs    []applyJob
+		errs    []error
+		failed  []string
+		jobErr  error
+		applied int
+		logFile *os.File
+		undo    = newUndoFile()
+	)
+	if logFile, err = openApplyLog(); err != nil {
+		return
+	}
+	defer func() {
+		if undoErr := undo.save(); err == nil {
+			err = undoErr
+		}
+		closeApplyLog(logFile, err)
+	}()
+	// The items are written either way, so that they show once the policy is lifted.
+	for _, problem := range policyProblems() {
+		if !problem.Warning {
+			logf(LogLevel_Warn, "the items will not show: %s", problem)
+		}
+	}
+	if state, err = loadState(); err != nil {
+		return
+	}
+	for _, key := range state.Keys {
+		ours[strings.ToLower(key)] = true
+	}
+	for _, entry := range manifest.Items {
+		for _, target := range manifest.RegistryTargets(entry.ID) {
+			key := config.Hive.String() + `\` + itemKeyPath(target, entry.ID)
+			// A target listed twice, or by alias and by class, would have two jobs write the same keys.
+			if written[strings.ToLower(key)] {
+				continue
+			}
+			written[strings.ToLower(key)] = true
+			job := applyJob{id: entry.ID, target: target, key: key}
+			if job.keys, err = planTarget(target, entry.ID, entry.Menu, manifestDir); err != nil {
+				err = errorf("failed to create context menu ID %q for target %q: %w", entry.ID, target, err)
+				return
+			}
+			if job.hash, err = planHash(job.keys); err != nil {
+				return
+			}
+			job.skip = unchangedSinceApply(state, key, job.hash, func() bool {
+				return registryUnchanged(job.keys)
+			})
+			jobs = append(jobs, job)
+		}
+	}
+	if err = checkConflicts(jobs, state); err != nil {
+		return
+	}
+	if err = checkAccess(jobs); err != nil {
+		return
+	}
+	// The undo file is captured before anything is written.
+	for _, job := range jobs {
+		if job.skip || job.err != nil {
+			continue
+		}
+		if err = undo.capture(job.key); err != nil {
+			return
+		}
+	}
+	errs = forEachParallel(ctx, len(jobs), applyWorkers, func(i int) (err error) {
+		var saved []savedKey
+		if jobs[i].skip || jobs[i].err != nil {
+			return
+		}
+		// Saved once, as a retry would find the keys of the failed attempt.
+		if saved, err = saveKeyTree(config.Hive.Root(), itemKeyPath(jobs[i].target, jobs[i].id)); err != nil {
+			return
+		}
+		err = retryRegistry(func() error {
+			return createContextMenu(ctx, itemKeyPath(jobs[i].target, jobs[i].id), jobs[i].keys, saved)
+		})
+		if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+			// A key locked down by policy only fails its own item.
+			jobs[i].err, err = err, nil
+		}
+		return
+	})
+	// Results are reported in manifest order. No job after a failed one was started, but the keys
+	// of those that were are still recorded below, so that they stay ours.
+	for i, job := range jobs {
+		if errors.Is(errs[i], context.Canceled) || errors.Is(errs[i], errNotStarted) {
+			// Kept in the state without a hash, like a denied item.
+			if ours[strings.ToLower(job.key)] {
+				keys = append(keys, job.key)
+			}
+			continue
+		}
+		if errs[i] != nil {
+			// The key was replaced in part, so it is recorded as ours whether or not it was before.
+			if jobErr == nil {
+				jobErr = errorf("failed to create context menu ID %q for target %q: %w", job.id, job.target, errs[i])
+			}
+			keys = append(keys, job.key)
+			continue
+		}
+		switch {
+		case job.err != nil:
+			failed = append(failed, sprintf("item ID %q on target %q: %v", job.id, job.target, job.err))
+			// Without a hash, the next apply writes the item again, and prune can still remove it.
+			if ours[strings.ToLower(job.key)] {
+				keys = append(keys, job.key)
+			}
+			continue
+		case job.skip:
+			logf(LogLevel_Debug, "%s is unchanged on %s", job.id, job.target)
+		default:
+			logf(LogLevel_Info, "applied %s to %s", job.id, job.target)
+		}
+		applied++
+		keys = append(keys, job.key)
+		hashes[job.key] = job.hash
+	}
+	for _, key := range state.Keys {
+		if written[strings.ToLower(key)] {
+			continue
+		}
+		keyPath, ok := cutPrefixFold(key, config.Hive.String()+`\`)
+		if !config.Prune || !ok || ctx.Err() != nil || jobErr != nil {
+			keys = append(keys, key)
+			continue
+		}
+		if err = undo.capture(key); err != nil {
+			return
+		}
+		if err = retryRegistry(func() error { return deleteRegKeyRecursive(config.Hive.Root(), keyPath) }); errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+			// Kept in the state, so the next apply tries again.
+			failed = append(failed, sprintf("pruning %s: %v", key, err))
+			keys, err = append(keys, key), nil
+		} else if err != nil {
+			err = errorf("failed to prune registry key %q: %w", key, err)
+			return
+		} else {
+			logf(LogLevel_Info, "pruned %s", key)
+		}
+	}
+	if err = saveState(State{Keys: keys, Hashes: hashes}); err != nil {
+		return
+	}
+	if jobErr != nil {
+		err = jobErr
+		return
+	}
+	if ctx.Err() != nil {
+		err = errorf("apply was interrupted after %d of %d item(s), undo.reg restores the keys it changed: %w", applied, len(jobs), ctx.Err())
+		return
+	}
+	if len(failed) > 0 {
+		err = errorf("%d item(s) could not be applied, the others were applied:\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return
+}
+
+// deleteRegKeyRecursive deletes the key at path under k with everything below it. RegDeleteTree
+// does so in one call; where it is missing, the subkeys are walked one by one.
+func deleteRegKeyRecursive(k registry.Key, path string) (err error) {
+	var subkey *uint16
+	if procRegDeleteTreeW.Find() != nil {
+		return deleteRegKeyWalk(k, path)
+	}
+	if subkey, err = windows.UTF16PtrFromString(path); err != nil {
+		return
+	}
+	if r, _, _ := procRegDeleteTreeW.Call(uintptr(k), uintptr(unsafe.Pointer(subkey))); r != 0 {
+		if err = syscall.Errno(r); errors.Is(err, syscall.ENOENT) {
+			err = nil
+			return
+		}
+		err = errorf("deleteRegKeyRecursive failed to delete key path %q: %w", path, err)
+	}
+	return
+}
+
+func deleteRegKeyWalk(k registry.Key, path string) (err error) {
+	var (
+		key, emptyKey registry.Key
+		subKeyNames   []string
+	)
+	if key, err = registry.OpenKey(k, path, registry.ALL_ACCESS); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			err = nil
+			return
+		}
+		err = errorf("deleteRegKeyRecursive failed to open key path %q: %w", path, err)
+		return
+	}
+	defer func() {
+		if key != emptyKey {
+			key.Close()
+		}
+	}()
+	if subKeyNames, err = key.ReadSubKeyNames(0); err != nil {
+		err = errorf("deleteRegKeyRecursive failed to get subkeys of path %q: %w", path, err)
+		return
+	}
+	for _, subKeyName := range subKeyNames {
+		if err = deleteRegKeyWalk(key, subKeyName); err != nil {
+			err = errorf("deleteRegKeyRecursive failed to delete subkey %q of path %q: %w", subKeyName, path, err)
+			return
+		}
+	}
+	key.Close()
+	key = emptyKey
+	if err = registry.DeleteKey(k, path); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			err = nil
+			return
+		}
+		err = errorf("deleteRegKeyRecursive failed to delete key path %q: %w", path, err)
+		return
+	}
+	return
+}
+
+// errNotStarted is the error forEachParallel reports for the calls it did not start after one
+// failed.
+var errNotStarted = errors.New("not started after an earlier item failed")
+
+// forEachParallel calls do with 0 to n-1 on up to workers goroutines, in order, and returns the
+// error of each. Once one fails or ctx is cancelled, no further calls are started, and those are
+// reported with errNotStarted or the error of ctx.
+func forEachParallel(ctx context.Context, n, workers int, do func(i int) error) (errs []error) {
+	var (
+		wg      sync.WaitGroup
+		next    = make(chan int)
+		failed  int32
+		started int
+	)
+	errs = make([]error, n)
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if errs[i] = do(i); errs[i] != nil {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	for ; started < n && atomic.LoadInt32(&failed) == 0; started++ {
+		select {
+		case next <- started:
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	close(next)
+	wg.Wait()
+	for i := started; i < n; i++ {
+		if errs[i] = ctx.Err(); errs[i] == nil {
+			errs[i] = errNotStarted
+		}
+	}
+	return
+}
+
+// registryUnchanged tells whether the registry holds keys as planned, apart from LegacyDisable, so
+// that an item changed since the last apply, by hand or by other software, is written again.
+func registryUnchanged(keys []RegistryKey) bool {
+	changes, err := diffRegistryKeys(config.Hive.Root(), keys)
+	return err == nil && len(changes) == 0
+}
+
+func keyExists(keyPath string) bool {
+	return keyExistsIn(config.Hive.Root(), keyPath)
+}
+
+func keyExistsIn(k registry.Key, keyPath string) bool {
+	key, err := registry.OpenKey(k, keyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	key.Close()
+	return true
+}
+
+// checkConflicts looks for verbs of other software with the IDs of the items before anything is
+// written. A key in the hive applied to that this tool did not write would be replaced, so those
+// are reported together as an error unless forced. A verb with the same name in the other hive is
+// merged with the item by Explorer, where the one of HKCU wins, so that is only logged.
+func checkConflicts(jobs []applyJob, state State) (err error) {
+	var (
+		conflicts = replacedVerbs(jobs, state, keyExists)
+		other     = registry.CURRENT_USER
+		otherName = Hive_User.String()
+	)
+	if config.Hive != Hive_Machine {
+		other, otherName = registry.LOCAL_MACHINE, Hive_Machine.String()
+	}
+	// Another user's hive or an offline one is not merged with this machine's.
+	for _, job := range jobs {
+		var keyPath = itemKeyPath(job.target, job.id)
+		if config.User == "" && config.HiveFile == "" && keyExistsIn(other, keyPath) {
+			logf(LogLevel_Warn, "item ID %q on target %q has the same name as %s\\%s of other software, which Explorer merges with it", job.id, job.target, otherName, keyPath)
+		}
+	}
+	if len(conflicts) > 0 && !config.Force {
+		err = errorf("%d item(s) have the name of a verb that was not applied from the manifest, rename them or replace the verbs with --force:\n%s", len(conflicts), strings.Join(conflicts, "\n"))
+	}
+	return
+}
+
+// Replacing or pruning a key takes the rights RegDeleteTree needs on it, and adding one takes the
+// right to create subkeys of the nearest parent that exists.
+const (
+	deleteAccess = windows.DELETE | registry.ENUMERATE_SUB_KEYS | registry.QUERY_VALUE | registry.SET_VALUE
+	createAccess = registry.CREATE_SUB_KEY
+)
+
+// checkAccess opens the key of every item an apply is going to write with the rights that takes,
+// before anything is written. Items whose keys are denied, e.g. by policy or because they belong
+// to TrustedInstaller, are left out and reported at the end. When all are denied, as in HKLM
+// without administrator rights, nothing is written at all.
+func checkAccess(jobs []applyJob) (err error) {
+	var (
+		checked = make(map[string]error)
+		denied  []string
+		pending int
+	)
+	for i := range jobs {
+		if jobs[i].skip {
+			continue
+		}
+		pending++
+		if jobs[i].err = keyAccess(itemKeyPath(jobs[i].target, jobs[i].id), deleteAccess, checked); jobs[i].err != nil {
+			denied = append(denied, jobs[i].err.Error())
+		}
+	}
+	if len(denied) > 0 && len(denied) == pending {
+		err = errorf("access to %d registry key(s) was denied, nothing was written; run as administrator or apply to another hive:\n%s", len(denied), strings.Join(denied, "\n"))
+	}
+	return
+}
+
+// keyAccess opens the key at keyPath with access, or when it does not exist, the nearest parent
+// that does with the right to create subkeys. Results are kept in checked, since items share
+// parents.
+func keyAccess(keyPath string, access uint32, checked map[string]error) (err error) {
+	var (
+		lower = strings.ToLower(keyPath)
+		ok    bool
+		key   registry.Key
+	)
+	if err, ok = checked[lower]; ok {
+		return
+	}
+	key, err = registry.OpenKey(config.Hive.Root(), keyPath, access)
+	switch {
+	case err == nil:
+		key.Close()
+	case errors.Is(err, syscall.ENOENT):
+		err = nil
+		if i := strings.LastIndex(keyPath, `\`); i >= 0 {
+			err = keyAccess(keyPath[:i], createAccess, checked)
+		}
+	default:
+		err = errorf("%s\\%s: %w", config.Hive, keyPath, err)
+	}
+	checked[lower] = err
+	return
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// TestForEachParallel checks that every call is made once on no more than the given workers, and
+// that no calls are started once one failed or the context is cancelled.
+func TestForEachParallel(t *testing.T) {
+	var (
+		calls   [20]int32
+		running int32
+		most    int32
+	)
+	errs := forEachParallel(context.Background(), len(calls), 3, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for m := atomic.LoadInt32(&most); n > m && !atomic.CompareAndSwapInt32(&most, m, n); m = atomic.LoadInt32(&most) {
+		}
+		atomic.AddInt32(&calls[i], 1)
+		return nil
+	})
+	for i, err := range errs {
+		if err != nil || calls[i] != 1 {
+			t.Errorf("call %d made %d times with %v", i, calls[i], err)
+		}
+	}
+	if most > 3 {
+		t.Errorf("%d calls ran at once on 3 workers", most)
+	}
+
+	var failure = errors.New("failed")
+	errs = forEachParallel(context.Background(), 5, 1, func(i int) error {
+		if i == 2 {
+			return failure
+		}
+		return nil
+	})
+	for i, want := range []error{nil, nil, failure, errNotStarted, errNotStarted} {
+		if errs[i] != want {
+			t.Errorf("call %d after a failure gave %v, expected %v", i, errs[i], want)
+		}
+	}
+
+	// The only worker is still busy when the context is cancelled, so nothing else can start.
+	ctx, cancel := context.WithCancel(context.Background())
+	errs = forEachParallel(ctx, 5, 1, func(i int) error {
+		if i == 0 {
+			cancel()
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	})
+	for i, want := range []error{nil, context.Canceled, context.Canceled, context.Canceled, context.Canceled} {
+		if errs[i] != want {
+			t.Errorf("call %d after cancelling gave %v, expected %v", i, errs[i], want)
+		}
+	}
+}
+
+// TestKeyAccess checks that a key that does not exist yet is checked by the nearest parent that
+// does, and that results are kept for the keys items share, whatever their case.
+func TestKeyAccess(t *testing.T) {
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.Hive = Hive_User
+	var (
+		denied  = errors.New("denied")
+		parent  = `Software\context-menu-manager-test-denied`
+		checked = map[string]error{strings.ToLower(parent): denied}
+	)
+	if err := keyAccess(`Software`, createAccess, checked); err != nil {
+		t.Errorf("HKCU\\Software cannot be written to: %v", err)
+	}
+	if err := keyAccess(`SOFTWARE\context-menu-manager-test-denied\Shell\item`, deleteAccess, checked); err != denied {
+		t.Errorf("key below a denied parent gave %v, expected %v", err, denied)
+	}
+	for _, keyPath := range []string{parent + `\shell`, parent + `\shell\item`} {
+		if err, ok := checked[strings.ToLower(keyPath)]; !ok || err != denied {
+			t.Errorf("%s is kept as %v, %t", keyPath, err, ok)
+		}
+	}
+}
+
+// TestCheckAccess checks that items are left out by the error of their keys, and that nothing is
+// written when all are denied, as in HKEY_LOCAL_MACHINE without administrator rights.
+func TestCheckAccess(t *testing.T) {
+	if windows.GetCurrentProcessToken().IsElevated() {
+		t.Skip("runs as administrator, which may write to HKEY_LOCAL_MACHINE")
+	}
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.Hive = Hive_Machine
+	var jobs = []applyJob{
+		{id: "cmm-test-a", target: "directory"},
+		{id: "cmm-test-skipped", target: "directory", skip: true},
+		{id: "cmm-test-b", target: ".txt"},
+	}
+	err := checkAccess(jobs)
+	if err == nil || !strings.Contains(err.Error(), "nothing was written") {
+		t.Errorf("all keys denied gave %v", err)
+	}
+	for _, job := range jobs {
+		if (job.err != nil) == job.skip {
+			t.Errorf("item %s, skipped %t, is left out for %v", job.id, job.skip, job.err)
+		}
+	}
+}
+
+// TestRetryRegistry checks that only errors of keys held by another process are retried, and
+// only so many times.
+func TestRetryRegistry(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "succeeds", errs: []error{nil}, wantCalls: 1},
+		{name: "held for a moment", errs: []error{windows.ERROR_SHARING_VIOLATION, windows.ERROR_ACCESS_DENIED, nil}, wantCalls: 3},
+		{name: "fails for good", errs: []error{windows.ERROR_FILE_NOT_FOUND}, wantCalls: 1, wantErr: windows.ERROR_FILE_NOT_FOUND},
+		{name: "held too long", errs: []error{windows.ERROR_LOCK_VIOLATION}, wantCalls: registryRetries + 1, wantErr: windows.ERROR_LOCK_VIOLATION},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			err := retryRegistry(func() (err error) {
+				err = test.errs[len(test.errs)-1]
+				if calls < len(test.errs) {
+					err = test.errs[calls]
+				}
+				calls++
+				return
+			})
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("retrying gave %v, expected %v", err, test.wantErr)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("tried %d times, expected %d", calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+// TestDeleteRegKeyRecursive writes a tree of keys below a key of its own in HKEY_CURRENT_USER and
+// deletes it again, with RegDeleteTree and by walking it.
+func TestDeleteRegKeyRecursive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes to HKEY_CURRENT_USER")
+	}
+	const root = `Software\context-menu-manager-test`
+	t.Cleanup(func() {
+		deleteRegKeyWalk(registry.CURRENT_USER, root)
+	})
+	for _, test := range []struct {
+		name   string
+		delete func(k registry.Key, path string) error
+	}{
+		{name: "RegDeleteTree", delete: deleteRegKeyRecursive},
+		{name: "walk", delete: deleteRegKeyWalk},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var path = root + `\Delete`
+			for _, keyPath := range []string{path + `\shell\a\command`, path + `\shell\b`, path + `\DefaultIcon`} {
+				key, _, err := registry.CreateKey(registry.CURRENT_USER, keyPath, registry.SET_VALUE)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = key.SetStringValue("", keyPath)
+				key.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := test.delete(registry.CURRENT_USER, path); err != nil {
+				t.Fatal(err)
+			}
+			if keyExistsIn(registry.CURRENT_USER, path) {
+				t.Errorf("%s is left after deleting it", path)
+			}
+			if !keyExistsIn(registry.CURRENT_USER, root) {
+				t.Errorf("%s is gone with the key below it", root)
+			}
+			if err := test.delete(registry.CURRENT_USER, path); err != nil {
+				t.Errorf("deleting a missing key: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "context"
+
+// Backend writes the manifest to where the context menus of a platform come from: the registry on
+// Windows, the files a file manager reads elsewhere. Commands only go through the current backend.
+type Backend interface {
+	// Apply writes every item of the manifest, and prunes what earlier applies wrote as configured.
+	// When ctx is cancelled, it stops between items and records what it has written.
+	Apply(ctx context.Context, manifest *Manifest, manifestDir string) error
+	// Remove deletes everything applied before.
+	Remove(ctx context.Context) error
+	// List reports the state of every item of the manifest, parents before children.
+	List(manifest *Manifest) ([]ItemStatus, error)
+	// Diff reports what Apply would change.
+	Diff(manifest *Manifest, manifestDir string) ([]Change, error)
+	// Toggle hides or shows an applied item, or sets its state when enable is not nil.
+	Toggle(manifest *Manifest, id string, enable *bool) (enabled bool, err error)
+}
+
+const Backend_Memory = "memory"
+
+// currentBackend is the backend of the platform, or the one chosen with --backend.
+func currentBackend() Backend {
+	switch config.Backend {
+	case Backend_Memory:
+		return memory
+	case Backend_WSL:
+		return wslBackend{}
+	}
+	return platformBackend()
+}
+
+// removeApplied has b apply an empty manifest with prune enabled, which deletes everything applied
+// before.
+func removeApplied(ctx context.Context, b Backend) (err error) {
+	var prune = config.Prune
+	config.Prune = true
+	defer func() {
+		config.Prune = prune
+	}()
+	err = b.Apply(ctx, &Manifest{}, "")
+	return
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCurrentBackend(t *testing.T) {
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	for _, test := range []struct {
+		backend string
+		want    Backend
+	}{
+		{backend: "", want: platformBackend()},
+		{backend: Backend_Memory, want: memory},
+		{backend: Backend_WSL, want: wslBackend{}},
+	} {
+		config.Backend = test.backend
+		if got := currentBackend(); reflect.TypeOf(got) != reflect.TypeOf(test.want) {
+			t.Errorf("backend %q is %T, expected %T", test.backend, got, test.want)
+		}
+	}
+}
+
+// TestRemoveApplied checks that removing what was applied prunes everything without leaving
+// pruning on.
+func TestRemoveApplied(t *testing.T) {
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.Prune, config.Targets = false, []string{"directory"}
+	var (
+		b        = &memoryBackend{applied: make(map[string]string), disabled: make(map[string]bool)}
+		manifest = &Manifest{Items: testMenus(t, `{"f": {"type": "folder", "title": "F", "items": {"a": {"type": "item", "title": "A", "command": ["a"]}}}}`)}
+	)
+	if err := b.Apply(context.Background(), manifest, ""); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _, _ := memoryItems(manifest); !reflect.DeepEqual(ids, []string{"f", "f/a"}) || len(b.applied) != 2 {
+		t.Fatalf("applied %q as %q", ids, b.applied)
+	}
+	if err := removeApplied(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.applied) != 0 {
+		t.Errorf("%q are left after removing", b.applied)
+	}
+	if config.Prune {
+		t.Error("pruning is left on after removing")
+	}
+}
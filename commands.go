@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const usage = `Usage: context-menu-manager [options] [command] [arguments]
+
+Commands:
+  apply              write all manifest items to the registry (default)
+  list               list manifest items and their state
+  diff [A B]         show differences between the manifest and the registry, or between two manifests
+  toggle ID          enable or disable an applied item
+  remove             delete every applied item, e.g. before uninstalling
+  serve              expose the commands above over a local HTTP API
+  ui                 preview and reorder the menus in a local web app
+  edit [PATH]        edit the manifest in an interactive terminal UI
+  tray               show a notification area icon to toggle items and re-apply the manifest
+  config             print the effective configuration
+  generate KIND      render the manifest for other tools, e.g. installer scripts
+  export --format F  render the manifest as another tool's config, e.g. Nilesoft Shell
+  import --from-...  add items converted from another tool's export to the manifest
+  docs               render the menu hierarchy as a Markdown or HTML page for review
+  preview            print the menu tree as it would look, from the manifest alone
+  validate           check the manifest without applying it, on any platform
+  schema             write the manifest JSON Schema for editors
+  migrate            rewrite the manifest in the layout of the newest schema version
+  convert --to F     rewrite the manifest as JSON, YAML or TOML
+  fmt                rewrite the manifest in its canonical form
+  copy-path PATH...  copy paths to the clipboard, what copyPath items run
+  run-elevated CMD   run a command as administrator, what admin items run with runas
+  merge [BASE] A B   merge the items of two manifests and report conflicts
+  report             write a zip with diagnostics to attach to bug reports
+  doctor             report policies and permissions that keep the menus from showing
+  self-update        download and install the latest release
+  version            print the version
+
+Nested item IDs are joined with "/", e.g. "open-msvc/VS2022 MSVC 17 COM x86".
+
+Options override config.json or config.yaml, found next to the executable or in
+%APPDATA%\context-menu-manager:
+`
+
+func run(args []string) (err error) {
+	var (
+		flags          = flag.NewFlagSet("context-menu-manager", flag.ContinueOnError)
+		hive           = flags.String("hive", "", `registry hive to write to, "user" or "machine"`)
+		targets        = flags.String("targets", "", `comma separated default targets, e.g. "background,directory,.txt"`)
+		elevation      = flags.String("elevation", "", `how admin items are elevated, "nircmd" or "runas"`)
+		prune          = flags.Bool("prune", false, "delete registry keys of items removed from the manifest")
+		logLevel       = flags.String("log-level", "", `one of "error", "warn", "info" or "debug"`)
+		user           = flags.String("user", "", "SID or account name of another signed in user whose hive to use instead of your own")
+		hiveFile       = flags.String("hive-file", "", `offline hive to load and work on instead, e.g. "C:\Users\Default\NTUSER.DAT"`)
+		portable       = flags.Bool("portable", false, "keep the config and all state next to the executable and never touch %APPDATA% or %LOCALAPPDATA%")
+		language       = flags.String("language", "", `language of messages, e.g. "en" or "zh" (default: Windows UI language)`)
+		fileManager    = flags.String("file-manager", "", `on Linux, "nautilus", "dolphin", "thunar" or "actions" (default: the one of the desktop)`)
+		backend        = flags.String("backend", "", `"memory" to apply to memory only, for trying out commands without changing anything, or "wsl" to apply to Windows from inside WSL (default there)`)
+		splitFolders   = flags.Bool("split-folders", false, `move the items of folders past the 16 some Windows versions show into "More…" folders`)
+		bulkExtensions = flags.Bool("bulk-extensions", false, `write an item for several extensions once, under "*" limited to them, rather than for each`)
+		accelerators   = flags.Bool("accelerators", false, `give titles without an "&" access key one that their siblings do not use`)
+		command        = "apply"
+	)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), tr(usage))
+		printDefaults(flags)
+	}
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	config.Portable = *portable
+	if err = loadConfig(); err != nil {
+		return
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "hive":
+			config.Hive = Hive(*hive)
+		case "targets":
+			config.Targets = strings.Split(*targets, ",")
+		case "elevation":
+			config.Elevation = ElevationBackend(*elevation)
+		case "prune":
+			config.Prune = *prune
+		case "log-level":
+			config.LogLevel = LogLevel(*logLevel)
+		case "language":
+			config.Language = *language
+		case "user":
+			config.User = *user
+		case "portable":
+			config.Portable = *portable
+		case "hive-file":
+			config.HiveFile = *hiveFile
+		case "file-manager":
+			config.FileManager = FileManager(*fileManager)
+		case "backend":
+			config.Backend = *backend
+		case "split-folders":
+			config.SplitFolders = *splitFolders
+		case "bulk-extensions":
+			config.BulkExtensions = *bulkExtensions
+		case "accelerators":
+			config.Accelerators = *accelerators
+		}
+	})
+	if config.Backend == "" && config.FileManager == "" && inWSL() {
+		// Inside WSL the Windows menus are what there is to apply, unless a file manager is asked for.
+		config.Backend = Backend_WSL
+	}
+	if err = config.Validate(); err != nil {
+		return
+	}
+	// The wsl backend passes these on to the Windows build.
+	if config.User != "" && config.Backend != Backend_WSL {
+		if err = openUserHive(config.User); err != nil {
+			return
+		}
+		defer closeUserHive()
+	}
+	if config.HiveFile != "" && config.Backend != Backend_WSL {
+		if err = loadHiveFile(config.HiveFile); err != nil {
+			return
+		}
+		defer unloadHiveFile()
+	}
+	if args = flags.Args(); len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "apply":
+		err = runApply(args)
+	case "list":
+		err = runList(args)
+	case "diff":
+		err = runDiff(args)
+	case "toggle":
+		err = runToggle(args)
+	case "remove":
+		err = runRemove(args)
+	case "serve":
+		err = runServe(args)
+	case "tray":
+		err = runTray(args)
+	case "ui":
+		err = runUI(args)
+	case "edit":
+		err = runEdit(args)
+	case "config":
+		err = runConfig(args)
+	case "generate":
+		err = runGenerate(args)
+	case "export":
+		err = runExport(args)
+	case "import":
+		err = runImport(args)
+	case "docs":
+		err = runDocs(args)
+	case "preview":
+		err = runPreview(args)
+	case "validate":
+		err = runValidate(args)
+	case "schema":
+		err = runSchema(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "convert":
+		err = runConvert(args)
+	case "fmt":
+		err = runFmt(args)
+	case "copy-path":
+		err = runCopyPath(args)
+	case "run-elevated":
+		err = runElevated(args)
+	case "merge":
+		err = runMerge(args)
+	case "report":
+		err = runReport(args)
+	case "doctor":
+		err = runDoctor(args)
+	case "self-update":
+		err = runSelfUpdate(args)
+	case "version":
+		fmt.Printf(tr("%s (manifest schema version %d)\n"), version, manifestSchemaVersion)
+	case "help":
+		flags.SetOutput(os.Stdout)
+		flags.Usage()
+	default:
+		flags.Usage()
+		err = errorf("unknown command %q", command)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		err = nil
+	}
+	return
+}
+
+func newFlagSet(name string) (flags *flag.FlagSet) {
+	flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), tr("Usage of %s:\n"), name)
+		printDefaults(flags)
+	}
+	return
+}
+
+func runApply(args []string) (err error) {
+	var (
+		flags       = newFlagSet("apply")
+		force       = flags.Bool("force", false, "rewrite every item, also those unchanged since the last apply, and replace verbs of other software with the same ID")
+		manifest    *Manifest
+		manifestDir string
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	config.Force = *force
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		return
+	}
+	// The Windows build the wsl backend runs warns about them itself.
+	if config.Backend != Backend_WSL {
+		warnLayout(manifest.Items, "")
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	err = currentBackend().Apply(ctx, manifest, manifestDir)
+	return
+}
+
+func runRemove(args []string) (err error) {
+	var flags = newFlagSet("remove")
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	err = currentBackend().Remove(ctx)
+	return
+}
+
+// interruptContext is cancelled by the first Ctrl+C, so that an apply stops cleanly between
+// items and records what it got to. A second Ctrl+C ends the process at once, as usual.
+func interruptContext() (ctx context.Context, stop func()) {
+	var (
+		cancel  context.CancelFunc
+		signals = make(chan os.Signal, 1)
+	)
+	ctx, cancel = context.WithCancel(context.Background())
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		if _, ok := <-signals; ok {
+			signal.Stop(signals)
+			logf(LogLevel_Warn, "interrupted, stopping after the items being written; press Ctrl+C again to quit at once")
+			cancel()
+		}
+	}()
+	stop = func() {
+		signal.Stop(signals)
+		close(signals)
+		cancel()
+	}
+	return
+}
+
+func runList(args []string) (err error) {
+	var (
+		flags    = newFlagSet("list")
+		asJSON   = flags.Bool("json", false, "print the items as JSON")
+		manifest *Manifest
+		items    []ItemStatus
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if manifest, _, err = loadManifest(); err != nil {
+		return
+	}
+	if items, err = currentBackend().List(manifest); err != nil {
+		return
+	}
+	if *asJSON {
+		err = printJSON(items)
+		return
+	}
+	for _, item := range items {
+		var state string
+		switch {
+		case !item.Installed:
+			state = tr("not applied")
+		case !item.Enabled:
+			state = tr("disabled")
+		default:
+			state = tr("enabled")
+		}
+		fmt.Printf("%s%s\t%q\t%s\n", strings.Repeat("  ", strings.Count(item.ID, "/")), item.ID, item.Title, state)
+	}
+	return
+}
+
+func runDiff(args []string) (err error) {
+	var (
+		flags       = newFlagSet("diff")
+		asJSON      = flags.Bool("json", false, "print the changes as JSON")
+		manifest    *Manifest
+		manifestDir string
+		changes     []Change
+		itemChanges []ItemChange
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	switch flags.NArg() {
+	case 0:
+	case 2:
+		if itemChanges, err = diffManifestFiles(flags.Arg(0), flags.Arg(1)); err != nil {
+			return
+		}
+		if *asJSON {
+			err = printJSON(itemChanges)
+			return
+		}
+		if len(itemChanges) == 0 {
+			fmt.Println(tr("No differences."))
+		}
+		for _, change := range itemChanges {
+			fmt.Println(change)
+		}
+		return
+	default:
+		err = errorf("diff expects no arguments, or two manifests to compare")
+		return
+	}
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		return
+	}
+	if changes, err = currentBackend().Diff(manifest, manifestDir); err != nil {
+		return
+	}
+	if *asJSON {
+		err = printJSON(changes)
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Println(tr("No differences."))
+		return
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return
+}
+
+func runToggle(args []string) (err error) {
+	var (
+		flags    = newFlagSet("toggle")
+		manifest *Manifest
+		enabled  bool
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if flags.NArg() != 1 {
+		err = errorf("toggle expects exactly one item ID")
+		return
+	}
+	if manifest, _, err = loadManifest(); err != nil {
+		return
+	}
+	if enabled, err = currentBackend().Toggle(manifest, flags.Arg(0), nil); err != nil {
+		return
+	}
+	if enabled {
+		fmt.Printf(tr("%s enabled\n"), flags.Arg(0))
+	} else {
+		fmt.Printf(tr("%s disabled\n"), flags.Arg(0))
+	}
+	return
+}
+
+// printJSON writes v to standard output for scripts, with an empty list rather than null.
+func printJSON(v interface{}) (err error) {
+	var data []byte
+	if data, err = marshalJSON(v, "  "); err != nil {
+		return
+	}
+	if string(data) == "null" {
+		data = []byte("[]")
+	}
+	fmt.Println(string(data))
+	return
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Hive string
+
+const (
+	Hive_User    Hive = "user"
+	Hive_Machine Hive = "machine"
+)
+
+// usersSubkey is set by --user and --hive-file to a hive under HKEY_USERS, which then replaces the
+// one selected by --hive.
+var usersSubkey string
+
+func (h Hive) String() string {
+	switch {
+	case usersSubkey != "":
+		return `HKU\` + usersSubkey
+	case h == Hive_Machine:
+		return "HKLM"
+	}
+	return "HKCU"
+}
+
+type ElevationBackend string
+
+const (
+	ElevationBackend_Nircmd ElevationBackend = "nircmd"
+	ElevationBackend_RunAs  ElevationBackend = "runas"
+)
+
+// FileManager selects what the Linux build writes the manifest for. It is detected from the
+// desktop when not set.
+type FileManager string
+
+const (
+	FileManager_Nautilus FileManager = "nautilus"
+	FileManager_Dolphin  FileManager = "dolphin"
+	FileManager_Thunar   FileManager = "thunar"
+	FileManager_Actions  FileManager = "actions" // the file manager actions of PCManFM, PCManFM-Qt and Caja
+)
+
+func (f FileManager) Valid() bool {
+	switch f {
+	case FileManager_Nautilus, FileManager_Dolphin, FileManager_Thunar, FileManager_Actions:
+		return true
+	}
+	return false
+}
+
+type Config struct {
+	Hive        Hive             `json:"hive"`
+	Targets     []string         `json:"targets"`
+	Elevation   ElevationBackend `json:"elevation"`
+	Prune       bool             `json:"prune"`
+	LogLevel    LogLevel         `json:"logLevel"`
+	Language    string           `json:"language,omitempty"`
+	User        string           `json:"user,omitempty"`
+	HiveFile    string           `json:"hiveFile,omitempty"`
+	Portable    bool             `json:"portable,omitempty"`
+	FileManager FileManager      `json:"fileManager,omitempty"`
+	Backend     string           `json:"backend,omitempty"`
+	// SplitFolders moves the items past what a submenu shows into "More…" folders.
+	SplitFolders bool `json:"splitFolders,omitempty"`
+	// BulkExtensions writes an item for several extensions once, under "*" with AppliesTo.
+	BulkExtensions bool `json:"bulkExtensions,omitempty"`
+	// Accelerators gives titles without an access key one that is unique among their siblings.
+	Accelerators bool `json:"accelerators,omitempty"`
+	// Force is set by apply --force to rewrite items that are unchanged since the last apply.
+	Force bool `json:"-"`
+}
+
+var defaultConfig = Config{
+	Hive:      Hive_User,
+	Targets:   []string{"background"},
+	Elevation: ElevationBackend_Nircmd,
+	LogLevel:  LogLevel_Warn,
+}
+
+// config holds the effective settings: defaults, then the config file, then command line flags.
+var (
+	config     = defaultConfig
+	configPath string
+)
+
+var configFilenames = []string{"config.json", "config.yaml", "config.yml"}
+
+// findConfig looks next to the executable first, then in %APPDATA%\context-menu-manager unless portable.
+func findConfig() (path string, err error) {
+	var (
+		dirs []string
+		fp   string
+		fi   fs.FileInfo
+	)
+	if fp, err = os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(fp))
+	}
+	if fp, err = os.UserConfigDir(); err == nil && !config.Portable {
+		dirs = append(dirs, filepath.Join(fp, "context-menu-manager"))
+	}
+	err = nil
+	for _, dir := range dirs {
+		for _, name := range configFilenames {
+			path = filepath.Join(dir, name)
+			if fi, err = os.Stat(path); err == nil && !fi.IsDir() {
+				return
+			}
+		}
+	}
+	path, err = "", nil
+	return
+}
+
+func loadConfig() (err error) {
+	var data []byte
+	if configPath, err = findConfig(); err != nil || configPath == "" {
+		return
+	}
+	if data, err = os.ReadFile(configPath); err != nil {
+		err = errorf("failed to read %s: %w", configPath, err)
+		return
+	}
+	if ext := strings.ToLower(filepath.Ext(configPath)); ext == ".yaml" || ext == ".yml" {
+		if data, err = toJSON("yaml", data); err != nil {
+			err = errorf("failed to parse %s: %w", configPath, err)
+			return
+		}
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		err = errorf("failed to parse %s: %w", configPath, err)
+		return
+	}
+	return
+}
+
+func (c Config) Validate() (err error) {
+	switch {
+	case c.Hive != Hive_User && c.Hive != Hive_Machine:
+		err = errorf("invalid hive %q, expected %q or %q", string(c.Hive), string(Hive_User), string(Hive_Machine))
+	case c.Elevation != ElevationBackend_Nircmd && c.Elevation != ElevationBackend_RunAs:
+		err = errorf("invalid elevation backend %q, expected %q or %q", c.Elevation, ElevationBackend_Nircmd, ElevationBackend_RunAs)
+	case c.FileManager != "" && !c.FileManager.Valid():
+		err = errorf("invalid file manager %q", c.FileManager)
+	case c.Backend != "" && c.Backend != Backend_Memory && c.Backend != Backend_WSL:
+		err = errorf("invalid backend %q, expected %q or %q", c.Backend, Backend_Memory, Backend_WSL)
+	case c.Backend == Backend_WSL && !inWSL():
+		err = errorf("the %q backend only works inside WSL", Backend_WSL)
+	case !c.LogLevel.Valid():
+		err = errorf("invalid log level %q", c.LogLevel)
+	case c.User != "" && c.HiveFile != "":
+		err = errorf("--user and --hive-file cannot be combined")
+	case c.User != "" && c.Hive != Hive_User:
+		err = errorf("--user only applies to the %q hive", string(Hive_User))
+	case c.Language != "" && catalogs[c.Language] == nil:
+		err = errorf("invalid language %q", c.Language)
+	case len(c.Targets) == 0:
+		err = errorf("at least one target is required")
+	}
+	return
+}
+
+func runConfig(args []string) (err error) {
+	var (
+		flags = newFlagSet("config")
+		data  []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if configPath == "" {
+		fmt.Println(tr("# no config file found, using defaults"))
+	} else {
+		fmt.Printf("# %s\n", configPath)
+	}
+	if data, err = marshalJSON(config, "    "); err != nil {
+		return
+	}
+	fmt.Println(string(data))
+	return
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		change  func(c *Config)
+		wantErr string
+	}{
+		{name: "defaults", change: func(c *Config) {}},
+		{name: "machine hive", change: func(c *Config) { c.Hive = Hive_Machine }},
+		{name: "unknown hive", change: func(c *Config) { c.Hive = "HKCU" }, wantErr: `invalid hive "HKCU", expected "user" or "machine"`},
+		{name: "runas", change: func(c *Config) { c.Elevation = ElevationBackend_RunAs }},
+		{name: "unknown elevation", change: func(c *Config) { c.Elevation = "sudo" }, wantErr: `invalid elevation backend "sudo"`},
+		{name: "unknown log level", change: func(c *Config) { c.LogLevel = "verbose" }, wantErr: `invalid log level "verbose"`},
+		{name: "no targets", change: func(c *Config) { c.Targets = nil }, wantErr: "at least one target is required"},
+		{name: "user", change: func(c *Config) { c.User = "S-1-5-21-1-2-3-1001" }},
+		{name: "user with machine hive", change: func(c *Config) { c.User, c.Hive = "alex", Hive_Machine }, wantErr: `--user only applies to the "user" hive`},
+		{name: "hive file", change: func(c *Config) { c.HiveFile, c.Hive = `C:\Users\Default\NTUSER.DAT`, Hive_Machine }},
+		{name: "user and hive file", change: func(c *Config) { c.User, c.HiveFile = "alex", "NTUSER.DAT" }, wantErr: "--user and --hive-file cannot be combined"},
+	} {
+		var c = defaultConfig
+		test.change(&c)
+		if err := c.Validate(); test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: got error %v, expected %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+// TestHiveString checks the names keys are recorded and exported by, which --user changes to the
+// user's hive under HKEY_USERS whichever hive is selected.
+func TestHiveString(t *testing.T) {
+	defer func() {
+		usersSubkey = ""
+	}()
+	for _, test := range []struct {
+		hive        Hive
+		usersSubkey string
+		want        string
+		wantLong    string
+	}{
+		{hive: Hive_User, want: "HKCU", wantLong: "HKEY_CURRENT_USER"},
+		{hive: Hive_Machine, want: "HKLM", wantLong: "HKEY_LOCAL_MACHINE"},
+		{hive: Hive_User, usersSubkey: "S-1-5-21-1-2-3-1001", want: `HKU\S-1-5-21-1-2-3-1001`, wantLong: `HKEY_USERS\S-1-5-21-1-2-3-1001`},
+	} {
+		usersSubkey = test.usersSubkey
+		if got := test.hive.String(); got != test.want {
+			t.Errorf("%s with %q is %q, expected %q", test.hive, test.usersSubkey, got, test.want)
+		}
+		if got := longKeyName(test.hive.String() + `\Software\Classes`); got != test.wantLong+`\Software\Classes` {
+			t.Errorf("long name of a key in %s is %q, expected %q", test.want, got, test.wantLong+`\Software\Classes`)
+		}
+	}
+}
+
+// TestLoadConfig checks that a config file in the user's config folder, in JSON or YAML, changes
+// only the settings it has, and is left alone when portable.
+func TestLoadConfig(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		portable bool
+		file     string
+		data     string
+		want     Config
+		wantErr  string
+	}{
+		{
+			name: "none",
+			want: defaultConfig,
+		},
+		{
+			name: "JSON",
+			file: "config.json",
+			data: `{"hive": "machine", "targets": ["directory", "background"], "prune": true}`,
+			want: Config{Hive: Hive_Machine, Targets: []string{"directory", "background"}, Elevation: ElevationBackend_Nircmd, Prune: true, LogLevel: LogLevel_Warn},
+		},
+		{
+			name: "YAML",
+			file: "config.yaml",
+			data: "# defaults for this machine\nelevation: runas\nlogLevel: error\n",
+			want: Config{Hive: Hive_User, Targets: []string{"background"}, Elevation: ElevationBackend_RunAs, LogLevel: LogLevel_Error},
+		},
+		{
+			name:    "invalid",
+			file:    "config.json",
+			data:    `{"prune": "yes"}`,
+			wantErr: "failed to parse",
+		},
+		{
+			name:     "portable",
+			portable: true,
+			file:     "config.json",
+			data:     `{"hive": "machine"}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var saved = config
+			defer func() {
+				config, configPath = saved, ""
+			}()
+			config = defaultConfig
+			config.Targets = append([]string(nil), defaultConfig.Targets...)
+			config.Portable = test.portable
+			isolateState(t)
+			if test.file != "" {
+				dir, err := os.UserConfigDir()
+				if err != nil {
+					t.Fatal(err)
+				}
+				dir = filepath.Join(dir, "context-menu-manager")
+				if err = os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(filepath.Join(dir, test.file), []byte(test.data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := loadConfig()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, expected %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.portable {
+				if configPath != "" || config.Hive != Hive_User {
+					t.Errorf("portable loaded %q from %q", config.Hive, configPath)
+				}
+				return
+			}
+			if !reflect.DeepEqual(config, test.want) {
+				t.Errorf("loaded %+v, expected %+v", config, test.want)
+			}
+			if (configPath != "") != (test.file != "") || test.file != "" && filepath.Base(configPath) != test.file {
+				t.Errorf("loaded from %q, expected %q", configPath, test.file)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// usersKey is the hive under HKEY_USERS named by usersSubkey.
+var usersKey registry.Key
+
+func (h Hive) Root() registry.Key {
+	switch {
+	case usersSubkey != "":
+		return usersKey
+	case h == Hive_Machine:
+		return registry.LOCAL_MACHINE
+	}
+	return registry.CURRENT_USER
+}
+
+// openUserHive resolves user, a SID or an account name, and opens its hive, which is only loaded
+// while the user is signed in. Other users' hives need administrator rights.
+func openUserHive(user string) (err error) {
+	var sid *windows.SID
+	if strings.HasPrefix(strings.ToUpper(user), "S-1-") {
+		sid, err = windows.StringToSid(user)
+	} else {
+		sid, _, _, err = windows.LookupSID("", user)
+	}
+	if err != nil {
+		err = errorf("unknown user %q: %w", user, err)
+		return
+	}
+	if usersKey, err = registry.OpenKey(registry.USERS, sid.String(), registry.ALL_ACCESS); err != nil {
+		switch {
+		case errors.Is(err, syscall.ENOENT):
+			err = errorf("the registry hive of %s (%s) is not loaded, the user has to be signed in", user, sid)
+		case errors.Is(err, windows.ERROR_ACCESS_DENIED):
+			err = errorf("access to the registry hive of %s (%s) was denied, run as administrator", user, sid)
+		default:
+			err = errorf("failed to open the registry hive of %s (%s): %w", user, sid, err)
+		}
+		return
+	}
+	usersSubkey = sid.String()
+	return
+}
+
+func closeUserHive() {
+	usersKey.Close()
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// conformanceManifest covers what every backend has to handle: a plain item, a folder with a
+// separator and a nested folder, and an item with targets of its own.
+const conformanceManifest = `{
+	"schemaVersion": 2,
+	"items": [
+		{"id": "cmm-conformance-item", "type": "item", "title": "Conformance item", "command": {"windows": ["cmd.exe", "/c", "echo", "%V"], "linux": ["echo", "%V"], "darwin": ["echo", "%V"]}},
+		{"id": "cmm-conformance-folder", "type": "folder", "title": "Conformance folder", "items": [
+			{"id": "first", "type": "item", "title": "First", "command": ["echo", "first"], "separatorAfter": true},
+			{"id": "nested", "type": "folder", "title": "Nested", "items": [
+				{"id": "second", "type": "item", "title": "Second", "command": ["echo", "second"]}
+			]}
+		]},
+		{"id": "cmm-conformance-text", "type": "item", "title": "Conformance text file", "targets": [".txt"], "command": ["echo", "%1"]}
+	]
+}`
+
+// testConformance runs the conformance manifest through a backend and checks that it behaves like
+// the others: what Apply writes is listed and no longer differs, an item changed behind its back is
+// put back by the next Apply, Toggle hides and shows items, an interrupted Apply keeps what was
+// applied before, a prune removes items dropped from the manifest, and Remove leaves nothing
+// behind. Every backend's test runs it. A check is skipped when the backend does not offer what it
+// checks.
+func testConformance(t *testing.T, backend Backend) {
+	var (
+		ctx         = context.Background()
+		manifest    = new(Manifest)
+		manifestDir = t.TempDir()
+	)
+	if err := json.Unmarshal([]byte(conformanceManifest), manifest); err != nil {
+		t.Fatal(err)
+	}
+	manifest.Items = manifest.Items.forPlatform(runtime.GOOS)
+	var (
+		first    = manifest.Items[0].ID
+		last     = manifest.Items[len(manifest.Items)-1].ID
+		ids      = manifestIDs(manifest)
+		pruned   = &Manifest{SchemaVersion: manifest.SchemaVersion, Items: manifest.Items[:len(manifest.Items)-1]}
+		retitled = &Manifest{SchemaVersion: manifest.SchemaVersion}
+		states   = func(want func(status ItemStatus) error) (err error) {
+			var items []ItemStatus
+			if items, err = backend.List(manifest); err != nil {
+				return
+			}
+			if len(items) != len(ids) {
+				return fmt.Errorf("listed %d items, expected %d", len(items), len(ids))
+			}
+			for i, status := range items {
+				if status.ID != ids[i] {
+					return fmt.Errorf("listed %q at %d, expected %q", status.ID, i+1, ids[i])
+				}
+				if err = want(status); err != nil {
+					return
+				}
+			}
+			return
+		}
+		state = func(id string) (status ItemStatus, err error) {
+			var items []ItemStatus
+			if items, err = backend.List(manifest); err != nil {
+				return
+			}
+			for _, status = range items {
+				if status.ID == id {
+					return
+				}
+			}
+			err = fmt.Errorf("item ID %q is not listed", id)
+			return
+		}
+		noChanges = func(manifest *Manifest) (err error) {
+			var changes []Change
+			if changes, err = backend.Diff(manifest, manifestDir); err == nil && len(changes) > 0 {
+				err = fmt.Errorf("%d change(s) left, e.g. %s", len(changes), changes[0])
+			}
+			return
+		}
+		toggle = func(enable bool) func() (string, error) {
+			return func() (skipped string, err error) {
+				var (
+					enabled bool
+					status  ItemStatus
+				)
+				if enabled, err = backend.Toggle(manifest, first, &enable); err != nil {
+					if status, _ = state(first); status.Enabled {
+						// Backends that cannot hide items refuse and leave them as they are.
+						return err.Error(), nil
+					}
+					return
+				}
+				if enabled != enable {
+					return "", fmt.Errorf("Toggle reported enabled=%v, expected %v", enabled, enable)
+				}
+				if status, err = state(first); err == nil && status.Enabled != enable {
+					err = fmt.Errorf("listed as enabled=%v after Toggle, expected %v", status.Enabled, enable)
+				}
+				return
+			}
+		}
+		prune = config.Prune
+	)
+	for _, entry := range manifest.Items {
+		var item = *entry.Menu
+		if entry.ID == first {
+			item.Title += " (changed)"
+		}
+		retitled.Items = append(retitled.Items, ContextMenuEntry{ID: entry.ID, Menu: &item})
+	}
+	checks := []struct {
+		name string
+		run  func() (skipped string, err error)
+	}{
+		{"remove what was applied before", func() (string, error) { return "", backend.Remove(ctx) }},
+		{"list before apply", func() (string, error) {
+			return "", states(func(status ItemStatus) error {
+				if status.Installed {
+					return fmt.Errorf("item ID %q is listed as applied", status.ID)
+				}
+				return nil
+			})
+		}},
+		{"diff before apply", func() (skipped string, err error) {
+			var changes []Change
+			if changes, err = backend.Diff(manifest, manifestDir); err == nil && len(changes) == 0 {
+				err = fmt.Errorf("no changes reported")
+			}
+			return
+		}},
+		{"apply", func() (string, error) { return "", backend.Apply(ctx, manifest, manifestDir) }},
+		{"list after apply", func() (string, error) {
+			return "", states(func(status ItemStatus) error {
+				if !status.Installed || !status.Enabled {
+					return fmt.Errorf("item ID %q is listed with installed=%v enabled=%v", status.ID, status.Installed, status.Enabled)
+				}
+				return nil
+			})
+		}},
+		{"diff after apply", func() (string, error) { return "", noChanges(manifest) }},
+		{"apply again", func() (skipped string, err error) {
+			if err = backend.Apply(ctx, manifest, manifestDir); err == nil {
+				err = noChanges(manifest)
+			}
+			return
+		}},
+		{"repair a change made behind its back", func() (skipped string, err error) {
+			var changes []Change
+			changer, ok := backend.(tamperer)
+			if !ok {
+				return "items of this backend are not changed behind its back", nil
+			}
+			if err = changer.tamper(manifest, first); err != nil {
+				return
+			}
+			if changes, err = backend.Diff(manifest, manifestDir); err == nil && len(changes) == 0 {
+				err = fmt.Errorf("no changes reported after item ID %q was changed", first)
+			}
+			if err == nil {
+				if err = backend.Apply(ctx, manifest, manifestDir); err == nil {
+					err = noChanges(manifest)
+				}
+			}
+			return
+		}},
+		{"toggle off", toggle(false)},
+		{"toggle off again", toggle(false)},
+		{"toggle on", toggle(true)},
+		{"toggle unknown item", func() (skipped string, err error) {
+			if _, toggleErr := backend.Toggle(manifest, first+"/cmm-conformance-missing", nil); toggleErr == nil {
+				err = fmt.Errorf("no error for an item ID not in the manifest")
+			}
+			return
+		}},
+		{"diff after a change", func() (skipped string, err error) {
+			var changes []Change
+			if changes, err = backend.Diff(retitled, manifestDir); err == nil && len(changes) == 0 {
+				err = fmt.Errorf("no changes reported for a changed title")
+			}
+			return
+		}},
+		{"apply a change", func() (skipped string, err error) {
+			if err = backend.Apply(ctx, retitled, manifestDir); err == nil {
+				err = noChanges(retitled)
+			}
+			return
+		}},
+		{"apply interrupted", func() (skipped string, err error) {
+			var (
+				status    ItemStatus
+				cancelled context.Context
+				cancel    context.CancelFunc
+			)
+			cancelled, cancel = context.WithCancel(ctx)
+			cancel()
+			config.Prune = true
+			defer func() {
+				config.Prune = prune
+			}()
+			if err = backend.Apply(cancelled, pruned, manifestDir); err == nil {
+				return "this backend applies at once rather than item by item", nil
+			}
+			if !errors.Is(err, context.Canceled) {
+				return
+			}
+			if status, err = state(last); err == nil && !status.Installed {
+				err = fmt.Errorf("item ID %q was pruned by an interrupted apply", last)
+			}
+			return
+		}},
+		{"prune", func() (skipped string, err error) {
+			var status ItemStatus
+			config.Prune = true
+			defer func() {
+				config.Prune = prune
+			}()
+			if err = backend.Apply(ctx, pruned, manifestDir); err != nil {
+				return
+			}
+			if status, err = state(last); err == nil && status.Installed {
+				err = fmt.Errorf("item ID %q is still applied", last)
+			}
+			return
+		}},
+		{"remove", func() (string, error) {
+			if err := backend.Remove(ctx); err != nil {
+				return "", err
+			}
+			return "", states(func(status ItemStatus) error {
+				if status.Installed {
+					return fmt.Errorf("item ID %q is still applied", status.ID)
+				}
+				return nil
+			})
+		}},
+	}
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			if skipped, err := check.run(); err != nil {
+				t.Error(err)
+			} else if skipped != "" {
+				t.Skip(skipped)
+			}
+		})
+	}
+}
+
+// tamperer is implemented by the tests of backends whose items can be changed behind their back,
+// as by hand or by other software, to check that the next apply puts them back.
+type tamperer interface {
+	tamper(manifest *Manifest, id string) error
+}
+
+// isolateState keeps the state, logs and files a backend writes in a temporary home folder.
+func isolateState(t *testing.T) {
+	home := t.TempDir()
+	for _, name := range []string{"HOME", "USERPROFILE", "LOCALAPPDATA", "APPDATA"} {
+		t.Setenv(name, home)
+	}
+	for _, name := range []string{"XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_STATE_HOME"} {
+		t.Setenv(name, "")
+	}
+}
+
+// manifestIDs lists the IDs of all items, parents before children, as List reports them.
+func manifestIDs(manifest *Manifest) (ids []string) {
+	var walk func(prefix string, menus ContextMenus)
+	walk = func(prefix string, menus ContextMenus) {
+		for _, entry := range menus {
+			ids = append(ids, prefix+entry.ID)
+			if entry.Menu.Type == ContextMenuType_Folder {
+				walk(prefix+entry.ID+"/", entry.Menu.Items)
+			}
+		}
+	}
+	walk("", manifest.Items)
+	return
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// console puts the terminal into raw mode with stty for full screen UIs.
+type console struct {
+	mode string
+}
+
+func openConsole() (c *console, err error) {
+	var out []byte
+	c = new(console)
+	if out, err = c.stty("-g"); err != nil {
+		err = errorf("standard input is not a terminal: %w", err)
+		return
+	}
+	c.mode = strings.TrimSpace(string(out))
+	if _, err = c.stty("raw", "-echo"); err != nil {
+		err = errorf("failed to set terminal mode: %w", err)
+		return
+	}
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	return
+}
+
+func (c *console) stty(args ...string) ([]byte, error) {
+	var cmd = exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	return cmd.Output()
+}
+
+func (c *console) restore() {
+	fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	c.stty(c.mode)
+}
+
+func (c *console) size() (width, height int) {
+	var out, err = c.stty("size")
+	if _, err = fmt.Sscan(string(out), &height, &width); err != nil || width == 0 || height == 0 {
+		return 80, 25
+	}
+	return
+}
+
+// readKey returns the name of a special key, or the typed text.
+func (c *console) readKey() (key string, err error) {
+	var (
+		buf [64]byte
+		n   int
+	)
+	if n, err = os.Stdin.Read(buf[:]); err != nil {
+		err = errorf("failed to read console input: %w", err)
+		return
+	}
+	key = string(buf[:n])
+	if name, ok := vtKeys[key]; ok {
+		key = name
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// console switches the Windows console into raw virtual terminal mode for full screen UIs.
+type console struct {
+	in, out         windows.Handle
+	inMode, outMode uint32
+}
+
+func openConsole() (c *console, err error) {
+	c = new(console)
+	if c.in, err = windows.GetStdHandle(windows.STD_INPUT_HANDLE); err != nil {
+		return
+	}
+	if c.out, err = windows.GetStdHandle(windows.STD_OUTPUT_HANDLE); err != nil {
+		return
+	}
+	if err = windows.GetConsoleMode(c.in, &c.inMode); err != nil {
+		err = errorf("standard input is not a console: %w", err)
+		return
+	}
+	if err = windows.GetConsoleMode(c.out, &c.outMode); err != nil {
+		err = errorf("standard output is not a console: %w", err)
+		return
+	}
+	if err = windows.SetConsoleMode(c.in, windows.ENABLE_VIRTUAL_TERMINAL_INPUT|windows.ENABLE_EXTENDED_FLAGS); err != nil {
+		err = errorf("failed to set console input mode: %w", err)
+		return
+	}
+	if err = windows.SetConsoleMode(c.out, c.outMode|windows.ENABLE_PROCESSED_OUTPUT|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		windows.SetConsoleMode(c.in, c.inMode)
+		err = errorf("failed to set console output mode: %w", err)
+		return
+	}
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	return
+}
+
+func (c *console) restore() {
+	fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	windows.SetConsoleMode(c.in, c.inMode)
+	windows.SetConsoleMode(c.out, c.outMode)
+}
+
+func (c *console) size() (width, height int) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(c.out, &info); err != nil {
+		return 80, 25
+	}
+	width = int(info.Window.Right-info.Window.Left) + 1
+	height = int(info.Window.Bottom-info.Window.Top) + 1
+	return
+}
+
+// readKey returns the name of a special key, or the typed text.
+func (c *console) readKey() (key string, err error) {
+	var (
+		buf  [64]uint16
+		read uint32
+	)
+	if err = windows.ReadConsole(c.in, &buf[0], uint32(len(buf)), &read, nil); err != nil {
+		err = errorf("failed to read console input: %w", err)
+		return
+	}
+	key = string(utf16.Decode(buf[:read]))
+	if name, ok := vtKeys[key]; ok {
+		key = name
+	}
+	return
+}
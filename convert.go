@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runConvert rewrites a manifest in another format. Everything the manifest holds, including item
+// order and descriptions, carries over; comments in YAML and TOML files do not.
+func runConvert(args []string) (err error) {
+	var (
+		flags        = newFlagSet("convert")
+		to           = flags.String("to", "", `format to convert to, "json", "yaml" or "toml"`)
+		manifestPath = flags.String("manifest", "", "manifest to convert (default: the manifest found by apply)")
+		output       = flags.String("output", "", "file to write (default: the manifest with the extension of the format)")
+		force        = flags.Bool("force", false, "overwrite the output file if it exists")
+		manifest     *Manifest
+		data         []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	switch *to {
+	case "json", "yaml", "toml":
+	default:
+		flags.Usage()
+		err = errorf("unknown format %q, expected %q, %q or %q", *to, "json", "yaml", "toml")
+		return
+	}
+	if *manifestPath == "" {
+		if *manifestPath, err = findManifest(); err != nil {
+			return
+		}
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(*manifestPath, filepath.Ext(*manifestPath)) + "." + *to
+	}
+	if manifestFormat(*output) != *to {
+		err = errorf("%s does not have the extension of %s files", *output, *to)
+		return
+	}
+	if _, statErr := os.Stat(*output); !*force && !errors.Is(statErr, os.ErrNotExist) {
+		err = errorf("%s already exists, use --force to overwrite it", *output)
+		return
+	}
+	if manifest, err = readManifest(*manifestPath); err != nil {
+		return
+	}
+	if data, err = encodeManifest(*output, manifest); err != nil {
+		return
+	}
+	if err = os.WriteFile(*output, data, 0o644); err != nil {
+		err = errorf("failed to write %s: %w", *output, err)
+		return
+	}
+	fmt.Printf(tr("Wrote %s. Remove %s if it is in the same folder, since only one manifest is used.\n"), *output, *manifestPath)
+	return
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// PathFormat is how a copyPath item writes the path it copies.
+type PathFormat string
+
+const (
+	PathFormat_Windows      PathFormat = "windows"
+	PathFormat_ForwardSlash PathFormat = "forwardSlash"
+	PathFormat_WSL          PathFormat = "wsl"
+	PathFormat_UNC          PathFormat = "unc"
+)
+
+var pathFormats = []PathFormat{PathFormat_Windows, PathFormat_ForwardSlash, PathFormat_WSL, PathFormat_UNC}
+
+func (f PathFormat) valid() bool {
+	for _, format := range pathFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// copyPathCommand runs the copy-path command of this executable on the folder or file clicked on,
+// so that copying a path needs no script of its own.
+func copyPathCommand(format PathFormat) []string {
+	self, err := os.Executable()
+	if err != nil {
+		logf(LogLevel_Warn, "failed to find this executable for copy-path: %v", err)
+		return nil
+	}
+	if format == "" {
+		format = PathFormat_Windows
+	}
+	return []string{self, "copy-path", "--format", string(format), "%V"}
+}
+
+// runCopyPath copies the paths given, one per line, to the clipboard in a format. It is what
+// copyPath items run, from Explorer, which opens a console window for it that is closed right away,
+// so failures are shown in a message box. Explorer runs it once per selected file, so the paths of
+// a selection are gathered first.
+func runCopyPath(args []string) (err error) {
+	var (
+		flags  = newFlagSet("copy-path")
+		format = flags.String("format", string(PathFormat_Windows), `"windows", "forwardSlash", "wsl" or "unc"`)
+		paths  []string
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	detachConsole()
+	defer func() {
+		if err != nil {
+			showError(err)
+		}
+	}()
+	if !PathFormat(*format).valid() {
+		err = errorf("unknown path format %q, expected one of %s", *format, strings.Join(pathFormatNames(), ", "))
+		return
+	}
+	if flags.NArg() == 0 {
+		err = errorf("no path to copy")
+		return
+	}
+	if paths, err = gatherSelection(*format, flags.Args()); err != nil || len(paths) == 0 {
+		return
+	}
+	for i, path := range paths {
+		paths[i] = formatPath(path, PathFormat(*format))
+	}
+	lineBreak := "\n"
+	if runtime.GOOS == "windows" {
+		lineBreak = "\r\n"
+	}
+	if err = copyToClipboard(strings.Join(paths, lineBreak)); err != nil {
+		err = errorf("failed to copy to the clipboard: %w", err)
+	}
+	return
+}
+
+func pathFormatNames() (names []string) {
+	for _, format := range pathFormats {
+		names = append(names, string(format))
+	}
+	return
+}
+
+// formatPath writes a Windows path in a format. Paths of other platforms have no drive or share to
+// translate, so they come out unchanged.
+func formatPath(path string, format PathFormat) string {
+	switch format {
+	case PathFormat_ForwardSlash:
+		return strings.ReplaceAll(path, `\`, "/")
+	case PathFormat_WSL:
+		return wslPath(path)
+	case PathFormat_UNC:
+		return uncPath(path)
+	}
+	return path
+}
+
+// wslPath is where WSL sees a Windows path: drives below /mnt, and the files of a distribution,
+// shared as \\wsl.localhost\NAME or \\wsl$\NAME, at their own path. Other shares keep forward
+// slashes, which is how WSL tools take them.
+func wslPath(path string) string {
+	if len(path) >= 2 && path[1] == ':' && ('a' <= path[0]|0x20 && path[0]|0x20 <= 'z') {
+		return "/mnt/" + string(path[0]|0x20) + strings.ReplaceAll(path[2:], `\`, "/")
+	}
+	for _, prefix := range []string{`\\wsl.localhost\`, `\\wsl$\`} {
+		if rest, ok := cutPrefixFold(path, prefix); ok {
+			if i := strings.IndexByte(rest, '\\'); i >= 0 {
+				return strings.ReplaceAll(rest[i:], `\`, "/")
+			}
+			return "/"
+		}
+	}
+	return strings.ReplaceAll(path, `\`, "/")
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// clipboardCommands are the programs that put standard input on the clipboard, for macOS, Wayland
+// and X11, tried in turn.
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+func detachConsole() {}
+
+// gatherSelection has nothing to gather, as the file managers pass all paths of a selection to one
+// process.
+func gatherSelection(format string, paths []string) ([]string, error) {
+	return paths, nil
+}
+
+// showError leaves failures on standard error, which is where the file managers log them.
+func showError(err error) {}
+
+func copyToClipboard(text string) (err error) {
+	err = errorf("none of %s found", "pbcopy, wl-copy, xclip, xsel")
+	for _, command := range clipboardCommands {
+		if _, lookErr := exec.LookPath(command[0]); lookErr != nil {
+			continue
+		}
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err = cmd.Run(); err == nil {
+			return
+		}
+		err = errorf("%s failed: %w", command[0], err)
+	}
+	return
+}
+
+// uncPath leaves paths alone, there being no shares to map them to off Windows.
+func uncPath(path string) string {
+	return path
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+// TestFormatPath checks the formats copyPath items write paths in, WSL paths taking drives below
+// /mnt and the files of distributions at their own path.
+func TestFormatPath(t *testing.T) {
+	for _, test := range []struct {
+		path   string
+		format PathFormat
+		want   string
+	}{
+		{path: `C:\Users\me\a.txt`, format: PathFormat_Windows, want: `C:\Users\me\a.txt`},
+		{path: `C:\Users\me\a.txt`, format: PathFormat_ForwardSlash, want: "C:/Users/me/a.txt"},
+		{path: `\\server\share\a.txt`, format: PathFormat_ForwardSlash, want: "//server/share/a.txt"},
+		{path: `C:\Users\me\a.txt`, format: PathFormat_WSL, want: "/mnt/c/Users/me/a.txt"},
+		{path: `d:\`, format: PathFormat_WSL, want: "/mnt/d/"},
+		{path: `E:`, format: PathFormat_WSL, want: "/mnt/e"},
+		{path: `\\wsl.localhost\Ubuntu\home\me`, format: PathFormat_WSL, want: "/home/me"},
+		{path: `\\WSL$\Ubuntu\etc\hosts`, format: PathFormat_WSL, want: "/etc/hosts"},
+		{path: `\\wsl$\Ubuntu`, format: PathFormat_WSL, want: "/"},
+		{path: `\\server\share\a.txt`, format: PathFormat_WSL, want: "//server/share/a.txt"},
+		{path: "/home/me/a.txt", format: PathFormat_WSL, want: "/home/me/a.txt"},
+		{path: `1:\a`, format: PathFormat_WSL, want: "1:/a"},
+		{path: `\\server\share\a.txt`, format: PathFormat_UNC, want: `\\server\share\a.txt`},
+	} {
+		if got := formatPath(test.path, test.format); got != test.want {
+			t.Errorf("formatPath(%q, %q) = %q, expected %q", test.path, test.format, got, test.want)
+		}
+	}
+}
+
+func TestPathFormatValid(t *testing.T) {
+	for _, format := range pathFormats {
+		if !format.valid() {
+			t.Errorf("path format %q is not valid", format)
+		}
+	}
+	for _, format := range []PathFormat{"", "Windows", "posix"} {
+		if format.valid() {
+			t.Errorf("path format %q is valid", format)
+		}
+	}
+}
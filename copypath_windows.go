@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32 = windows.NewLazySystemDLL("kernel32.dll")
+
+	procOpenClipboard      = user32.NewProc("OpenClipboard")
+	procCloseClipboard     = user32.NewProc("CloseClipboard")
+	procEmptyClipboard     = user32.NewProc("EmptyClipboard")
+	procSetClipboardData   = user32.NewProc("SetClipboardData")
+	procGlobalAlloc        = kernel32.NewProc("GlobalAlloc")
+	procGlobalFree         = kernel32.NewProc("GlobalFree")
+	procGlobalLock         = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock       = kernel32.NewProc("GlobalUnlock")
+	procRtlMoveMemory      = kernel32.NewProc("RtlMoveMemory")
+	procWNetGetConnectionW = windows.NewLazySystemDLL("mpr.dll").NewProc("WNetGetConnectionW")
+)
+
+const (
+	cfUnicodeText = 13
+	gmemMoveable  = 0x2
+	// clipboardAttempts is how often opening the clipboard is tried, as another program may hold
+	// it for a moment.
+	clipboardAttempts = 10
+	// selectionQuiet is how long the process gathering a selection waits for more paths after the
+	// last one arrived. Explorer starts the processes of a selection in quick succession.
+	selectionQuiet = 500 * time.Millisecond
+	// selectionStale is how long a lock goes untouched before its process is taken to have died.
+	selectionStale = 10 * time.Second
+)
+
+func detachConsole() {
+	procFreeConsole.Call()
+}
+
+// gatherSelection collects the paths of a selection, for which Explorer starts a process per file,
+// so that they are copied at once rather than each process replacing the clipboard. Each process
+// leaves its paths in a folder; the one that takes the lock waits until no more arrive and returns
+// them all in the order they came, the others return none. A path that arrives as the lock is
+// released is picked up by another round.
+func gatherSelection(format string, paths []string) (all []string, err error) {
+	var (
+		dir  = filepath.Join(os.TempDir(), "context-menu-manager-copy-path-"+format)
+		lock = filepath.Join(dir, "lock")
+		name = filepath.Join(dir, fmt.Sprintf("%020d-%d", time.Now().UnixNano(), os.Getpid()))
+		f    *os.File
+	)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		err = errorf("failed to create %s: %w", dir, err)
+		return
+	}
+	if err = os.WriteFile(name, []byte(strings.Join(paths, "\n")), 0o600); err != nil {
+		err = errorf("failed to write %s: %w", name, err)
+		return
+	}
+	for {
+		if f, err = os.OpenFile(lock, os.O_CREATE|os.O_EXCL, 0o600); errors.Is(err, fs.ErrExist) {
+			if fi, statErr := os.Stat(lock); statErr == nil && time.Since(fi.ModTime()) > selectionStale {
+				os.Remove(lock)
+				continue
+			}
+			// The process holding the lock copies these paths too.
+			err = nil
+			return
+		} else if err != nil {
+			err = errorf("failed to create %s: %w", lock, err)
+			return
+		}
+		f.Close()
+		var (
+			count   = -1
+			changed time.Time
+			names   []string
+		)
+		for {
+			if names, err = selectionFiles(dir); err != nil {
+				os.Remove(lock)
+				return
+			}
+			if len(names) != count {
+				count, changed = len(names), time.Now()
+				os.Chtimes(lock, changed, changed)
+			} else if time.Since(changed) >= selectionQuiet {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		for _, name := range names {
+			if data, readErr := os.ReadFile(name); readErr == nil && len(data) > 0 {
+				all = append(all, strings.Split(string(data), "\n")...)
+			}
+			os.Remove(name)
+		}
+		os.Remove(lock)
+		if names, err = selectionFiles(dir); err != nil || len(names) == 0 {
+			return
+		}
+	}
+}
+
+// selectionFiles lists the files processes left their paths in, oldest first.
+func selectionFiles(dir string) (names []string, err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(dir); err != nil {
+		err = errorf("failed to read %s: %w", dir, err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name() != "lock" {
+			names = append(names, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return
+}
+
+func copyToClipboard(text string) (err error) {
+	var (
+		data = utf16.Encode([]rune(text + "\x00"))
+		size = uintptr(len(data) * 2)
+		mem  uintptr
+		ptr  uintptr
+		r    uintptr
+	)
+	for i := 1; ; i++ {
+		if r, _, err = procOpenClipboard.Call(0); r != 0 {
+			break
+		} else if i == clipboardAttempts {
+			return errorf("failed to open the clipboard: %w", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer procCloseClipboard.Call()
+	if r, _, err = procEmptyClipboard.Call(); r == 0 {
+		return errorf("failed to empty the clipboard: %w", err)
+	}
+	if mem, _, err = procGlobalAlloc.Call(gmemMoveable, size); mem == 0 {
+		return errorf("failed to allocate memory: %w", err)
+	}
+	if ptr, _, err = procGlobalLock.Call(mem); ptr == 0 {
+		procGlobalFree.Call(mem)
+		return errorf("failed to lock memory: %w", err)
+	}
+	procRtlMoveMemory.Call(ptr, uintptr(unsafe.Pointer(&data[0])), size)
+	procGlobalUnlock.Call(mem)
+	// The clipboard owns the memory once it has taken it.
+	if r, _, err = procSetClipboardData.Call(cfUnicodeText, mem); r == 0 {
+		procGlobalFree.Call(mem)
+		return errorf("failed to set the clipboard text: %w", err)
+	}
+	return nil
+}
+
+// uncPath is the path on its share: the share a network drive is mapped to, or the administrative
+// share of a local drive, like \\HOST\C$, which only administrators can open from elsewhere.
+func uncPath(path string) string {
+	if len(path) < 2 || path[1] != ':' || strings.HasPrefix(path, `\\`) {
+		return path
+	}
+	var (
+		drive  = strings.ToUpper(path[:2])
+		remote = make([]uint16, windows.MAX_LONG_PATH)
+		size   = uint32(len(remote))
+	)
+	if r, _, _ := procWNetGetConnectionW.Call(
+		uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(drive))),
+		uintptr(unsafe.Pointer(&remote[0])),
+		uintptr(unsafe.Pointer(&size)),
+	); r == 0 {
+		return windows.UTF16ToString(remote) + path[2:]
+	}
+	host, err := windows.ComputerName()
+	if err != nil {
+		return path
+	}
+	return `\\` + host + `\` + drive[:1] + "$" + path[2:]
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// desktopActionName matches what may not appear in the name of a desktop action or in the ID of
+// a desktop file.
+var desktopActionName = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+
+// iconName matches freedesktop icon names, which are looked up in the icon theme.
+var iconName = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+// desktopExec is the Exec line of a desktop entry that runs selectionScript for the selected files.
+func desktopExec(item *ContextMenu, targets []string, manifestDir string) string {
+	// Exec arguments are double quoted with \ escapes, and the value as a whole unescaped once
+	// more; % starts a field code.
+	return `sh -c "` + desktopEscape(execQuote(selectionScript(item, targets, manifestDir))) + `" sh %F`
+}
+
+func execQuote(s string) string {
+	return strings.NewReplacer(`"`, `\"`, "`", "\\`", `$`, `\$`, `\`, `\\`, `%`, `%%`).Replace(s)
+}
+
+// desktopEscape escapes a value of a desktop entry.
+func desktopEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(s)
+}
+
+// themeIcon is the icon name or image file of item, or empty for the icons of Windows
+// executables and .ico files, which Linux file managers cannot show. Presets use the matching
+// icon of the theme.
+func themeIcon(item *ContextMenu, manifestDir string) string {
+	var icon = item.IconFile(manifestDir)
+	if preset, ok := lookupShellIcon(icon); ok {
+		if preset == nil {
+			return ""
+		}
+		return preset.theme
+	}
+	switch strings.ToLower(filepath.Ext(icon)) {
+	case ".png", ".svg", ".svgz", ".xpm":
+		return icon
+	case "":
+		if iconName.MatchString(icon) {
+			return icon
+		}
+	}
+	return ""
+}
+
+// mimeTypes maps targets to the MIME types a menu is offered for. Extensions are
+// looked up in the shared MIME database, and fall back to all files when it does not know them.
+func mimeTypes(targets []string, globs map[string]string) (types []string) {
+	var (
+		seen = make(map[string]bool)
+		add  = func(t string) {
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+	)
+	for _, target := range targets {
+		switch target = strings.ToLower(target); {
+		case target == "file":
+			add("all/allfiles")
+		case strings.HasPrefix(target, "."):
+			if t, ok := globs[target]; ok {
+				add(t)
+			} else {
+				add("all/allfiles")
+			}
+		default:
+			add("inode/directory")
+		}
+	}
+	return
+}
+
+// mimeGlobs maps lowercased extensions to MIME types from the globs2 files of the shared MIME
+// database; earlier data folders take precedence.
+func mimeGlobs() (globs map[string]string) {
+	var dirs = filepath.SplitList(os.Getenv("XDG_DATA_DIRS"))
+	globs = make(map[string]string)
+	if len(dirs) == 0 {
+		dirs = []string{"/usr/local/share", "/usr/share"}
+	}
+	if dir, err := dataHome(); err == nil {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		f, err := os.Open(filepath.Join(dirs[i], "mime", "globs2"))
+		if err != nil {
+			continue
+		}
+		var (
+			scanner = bufio.NewScanner(f)
+			seen    = make(map[string]bool)
+		)
+		// Lines are weight:type:glob[:flags], by weight; the first match of an extension wins.
+		for scanner.Scan() {
+			var fields = strings.Split(scanner.Text(), ":")
+			if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			if ext := strings.ToLower(strings.TrimPrefix(fields[2], "*")); strings.HasPrefix(ext, ".") && !strings.ContainsAny(ext[1:], "*?[.") && !seen[ext] {
+				seen[ext] = true
+				globs[ext] = fields[1]
+			}
+		}
+		f.Close()
+	}
+	return
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMimeTypes(t *testing.T) {
+	var globs = map[string]string{".txt": "text/plain", ".md": "text/markdown"}
+	for _, test := range []struct {
+		targets []string
+		want    []string
+	}{
+		{targets: []string{"directory", "background", "drive"}, want: []string{"inode/directory"}},
+		{targets: []string{"file", ".TXT"}, want: []string{"all/allfiles", "text/plain"}},
+		{targets: []string{".md", ".unknown", ".txt", ".other"}, want: []string{"text/markdown", "all/allfiles", "text/plain"}},
+	} {
+		if got := mimeTypes(test.targets, globs); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("mimeTypes(%q) = %q, expected %q", test.targets, got, test.want)
+		}
+	}
+}
+
+// TestMimeGlobs checks that extensions are looked up in the MIME database of the user before
+// those of the system, by the first line of each that matches them, and that globs other than
+// plain extensions are left out.
+func TestMimeGlobs(t *testing.T) {
+	isolateState(t)
+	var (
+		home   = filepath.Join(os.Getenv("HOME"), ".local", "share")
+		system = t.TempDir()
+		write  = func(dir, globs2 string) {
+			if err := os.MkdirAll(filepath.Join(dir, "mime"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "mime", "globs2"), []byte(globs2), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	)
+	t.Setenv("XDG_DATA_DIRS", system)
+	write(system, "# weight:type:glob\n"+
+		"55:text/x-markdown:*.md\n"+
+		"50:text/markdown:*.md\n"+
+		"50:text/plain:*.TXT\n"+
+		"50:application/x-compressed-tar:*.tar.gz\n"+
+		"50:text/x-makefile:Makefile\n"+
+		"50:image/x-any:*.[ch]\n"+
+		"broken line\n")
+	write(home, "60:text/x-notes:*.txt:cs\n")
+	var want = map[string]string{".md": "text/x-markdown", ".txt": "text/x-notes"}
+	if got := mimeGlobs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("globs are %q, expected %q", got, want)
+	}
+}
+
+// TestDesktopExec reads back the Exec line of a desktop entry as file managers do, unescaping the
+// value and then the quoted argument, and runs it on a file whose name needs every escape.
+func TestDesktopExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip(err)
+	}
+	var (
+		dir  = t.TempDir()
+		file = filepath.Join(dir, "a \"$b\" `c` \\d 100%.txt")
+		item = &ContextMenu{Command: []string{"printf", "%s|%s\n", "$HOME", "%V"}}
+		line = desktopExec(item, []string{".txt"}, dir)
+	)
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var value = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t", `\r`, "\r", `\s`, " ").Replace(line)
+	if !strings.HasPrefix(value, `sh -c "`) || !strings.HasSuffix(value, `" sh %F`) {
+		t.Fatalf("Exec=%s does not run sh -c on the files", line)
+	}
+	var (
+		quoted = strings.TrimSuffix(strings.TrimPrefix(value, `sh -c "`), `" sh %F`)
+		script strings.Builder
+	)
+	for i := 0; i < len(quoted); i++ {
+		if quoted[i] == '\\' && i+1 < len(quoted) {
+			i++
+		} else if quoted[i] == '"' {
+			t.Fatalf("Exec=%s has an unescaped quote inside its argument", line)
+		}
+		script.WriteByte(quoted[i])
+	}
+	out, err := exec.Command("sh", "-c", strings.ReplaceAll(script.String(), "%%", "%"), "sh", file).Output()
+	if err != nil {
+		t.Fatalf("Exec=%s: %v", line, err)
+	}
+	if want := "$HOME|" + file + "\n"; string(out) != want {
+		t.Errorf("Exec=%s printed %q, expected %q", line, out, want)
+	}
+}
+
+func TestThemeIcon(t *testing.T) {
+	for _, test := range []struct {
+		iconPath string
+		want     string
+	}{
+		{iconPath: "utilities-terminal", want: "utilities-terminal"},
+		{iconPath: "${manifestFolder}/icons/app.svg", want: "/menus/icons/app.svg"},
+		{iconPath: "/usr/share/pixmaps/app.PNG", want: "/usr/share/pixmaps/app.PNG"},
+		{iconPath: `C:\Tools\app.ico`, want: ""},
+		{iconPath: `C:\Tools\app.exe`, want: ""},
+		{iconPath: "shell:folder", want: "folder"},
+		{iconPath: "shell:bogus", want: ""},
+		{iconPath: "", want: ""},
+	} {
+		if got := themeIcon(&ContextMenu{IconPath: test.iconPath}, "/menus"); got != test.want {
+			t.Errorf("themeIcon(%q) = %q, expected %q", test.iconPath, got, test.want)
+		}
+	}
+}
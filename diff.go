@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+)
+
+type ChangeKind string
+
+const (
+	ChangeKind_Add    ChangeKind = "add"
+	ChangeKind_Remove ChangeKind = "remove"
+	ChangeKind_Modify ChangeKind = "modify"
+)
+
+type Change struct {
+	Kind  ChangeKind `json:"kind"`
+	Key   string     `json:"key"`
+	Value *string    `json:"value,omitempty"`
+	Want  string     `json:"want,omitempty"`
+	Have  string     `json:"have,omitempty"`
+}
+
+func (c Change) String() string {
+	var prefix = map[ChangeKind]string{ChangeKind_Add: "+", ChangeKind_Remove: "-", ChangeKind_Modify: "~"}[c.Kind]
+	if c.Value == nil {
+		return fmt.Sprintf("%s %s", prefix, c.Key)
+	}
+	var name = *c.Value
+	if name == "" {
+		name = "(Default)"
+	}
+	switch c.Kind {
+	case ChangeKind_Add:
+		return fmt.Sprintf("%s %s [%s] = %q", prefix, c.Key, name, c.Want)
+	case ChangeKind_Remove:
+		return fmt.Sprintf("%s %s [%s] = %q", prefix, c.Key, name, c.Have)
+	default:
+		return fmt.Sprintf("%s %s [%s] %q -> %q", prefix, c.Key, name, c.Have, c.Want)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Diff reports what applying the manifest would change in the registry.
+// LegacyDisable is left out since it is managed by toggle rather than the manifest.
+func (registryBackend) Diff(manifest *Manifest, manifestDir string) (changes []Change, err error) {
+	var (
+		keys       []RegistryKey
+		keyChanges []Change
+	)
+	for _, entry := range manifest.Items {
+		for _, target := range manifest.RegistryTargets(entry.ID) {
+			if keys, err = planTarget(target, entry.ID, entry.Menu, manifestDir); err != nil {
+				err = errorf("failed to plan context menu ID %q: %w", entry.ID, err)
+				return
+			}
+			if keyChanges, err = diffRegistryKeys(config.Hive.Root(), keys); err != nil {
+				return
+			}
+			changes = append(changes, keyChanges...)
+		}
+	}
+	return
+}
+
+func diffRegistryKeys(k registry.Key, keys []RegistryKey) (changes []Change, err error) {
+	var (
+		planned    = make(map[string]bool, len(keys))
+		keyChanges []Change
+	)
+	for _, regKey := range keys {
+		planned[strings.ToLower(regKey.Path)] = true
+	}
+	for _, regKey := range keys {
+		if keyChanges, err = diffRegistryKey(k, regKey, planned); err != nil {
+			return
+		}
+		changes = append(changes, keyChanges...)
+	}
+	return
+}
+
+func diffRegistryKey(k registry.Key, regKey RegistryKey, planned map[string]bool) (changes []Change, err error) {
+	var (
+		key         registry.Key
+		have        string
+		valueType   uint32
+		valueNames  []string
+		subKeyNames []string
+		wanted      = make(map[string]bool, len(regKey.Values))
+	)
+	if key, err = registry.OpenKey(k, regKey.Path, registry.QUERY_VALUE|registry.ENUMERATE_SUB_KEYS); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			err = nil
+			changes = append(changes, Change{Kind: ChangeKind_Add, Key: regKey.Path})
+			return
+		}
+		err = errorf("failed to open registry key %q: %w", regKey.Path, err)
+		return
+	}
+	defer key.Close()
+	for _, value := range regKey.Values {
+		var name = value.Name
+		wanted[strings.ToLower(name)] = true
+		if have, valueType, err = key.GetStringValue(name); err != nil {
+			if errors.Is(err, syscall.ENOENT) || errors.Is(err, registry.ErrUnexpectedType) {
+				err = nil
+				changes = append(changes, Change{Kind: ChangeKind_Add, Key: regKey.Path, Value: &name, Want: value.Data})
+				continue
+			}
+			err = errorf("failed to read value %q of registry key %q: %w", name, regKey.Path, err)
+			return
+		}
+		if have != value.Data || registryValueType(valueType) != value.Type {
+			changes = append(changes, Change{Kind: ChangeKind_Modify, Key: regKey.Path, Value: &name, Want: value.Data, Have: have})
+		}
+	}
+	if valueNames, err = key.ReadValueNames(0); err != nil {
+		err = errorf("failed to read values of registry key %q: %w", regKey.Path, err)
+		return
+	}
+	for _, name := range valueNames {
+		if name := name; !wanted[strings.ToLower(name)] && !strings.EqualFold(name, "LegacyDisable") {
+			have, _, _ = key.GetStringValue(name)
+			changes = append(changes, Change{Kind: ChangeKind_Remove, Key: regKey.Path, Value: &name, Have: have})
+		}
+	}
+	if subKeyNames, err = key.ReadSubKeyNames(0); err != nil {
+		err = errorf("failed to read subkeys of registry key %q: %w", regKey.Path, err)
+		return
+	}
+	for _, name := range subKeyNames {
+		if subKeyPath := regKey.Path + `\` + name; !planned[strings.ToLower(subKeyPath)] {
+			changes = append(changes, Change{Kind: ChangeKind_Remove, Key: subKeyPath})
+		}
+	}
+	return
+}
+
+func registryValueType(valueType uint32) RegistryValueType {
+	if valueType == registry.EXPAND_SZ {
+		return RegistryValueType_ExpandString
+	}
+	return RegistryValueType_String
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+// docsNode is an item as the docs show it, with ${manifestFolder} and the icon resolved.
+type docsNode struct {
+	ID              string
+	Title           string
+	Description     string
+	Notes           []string
+	Command         string
+	Icon            string
+	IconData        template.URL
+	SeparatorBefore bool
+	SeparatorAfter  bool
+	Items           []docsNode
+}
+
+type docsSection struct {
+	Target string
+	Items  []docsNode
+}
+
+type docsPage struct {
+	Title    string
+	Source   string
+	Empty    string
+	Sections []docsSection
+}
+
+func runDocs(args []string) (err error) {
+	var (
+		flags        = newFlagSet("docs")
+		format       = flags.String("format", "", `"markdown" or "html" (default: from the --output extension, else markdown)`)
+		output       = flags.String("output", "", "file to write (default: standard output)")
+		fromReg      = flags.Bool("registry", false, "render what is applied in the registry instead of the manifest")
+		manifest     *Manifest
+		manifestPath string
+		manifestDir  string
+		page         docsPage
+		text         string
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(*output)) {
+		case ".html", ".htm":
+			*format = "html"
+		default:
+			*format = "markdown"
+		}
+	}
+	if *fromReg {
+		if manifest, err = appliedManifest(); err != nil {
+			return
+		}
+		page.Source = sprintf("Applied in %s, as recorded by the last apply.", config.Hive.String())
+	} else {
+		if manifestPath, err = findManifest(); err != nil {
+			return
+		}
+		if manifest, err = readManifest(manifestPath); err != nil {
+			return
+		}
+		manifestDir = filepath.Dir(manifestPath)
+		page.Source = sprintf("Rendered from %s.", manifestPath)
+	}
+	page.Title, page.Empty = tr("Context menus"), tr("No items.")
+	page.Sections = docsSections(manifest, manifestDir, "windows", *format == "html")
+	switch *format {
+	case "markdown", "md":
+		text = docsMarkdown(page)
+	case "html":
+		var buf strings.Builder
+		if err = docsTemplate.Execute(&buf, page); err != nil {
+			err = errorf("failed to render docs: %w", err)
+			return
+		}
+		text = buf.String()
+	default:
+		err = errorf("unknown format %q, expected %q or %q", *format, "markdown", "html")
+		return
+	}
+	err = writeOutput(*output, text)
+	return
+}
+
+// appliedManifest reads the keys recorded in the state back from the registry, the same way
+// import reads a .reg file.
+func appliedManifest() (manifest *Manifest, err error) {
+	var (
+		state State
+		reg   = newRegFile()
+	)
+	if state, err = loadState(); err != nil {
+		return
+	}
+	for _, key := range state.Keys {
+		if err = reg.exportKey(key); err != nil {
+			return
+		}
+	}
+	manifest = new(Manifest)
+	manifest.Items, _, err = parseRegFile([]byte(reg.String()))
+	return
+}
+
+// docsSections groups the top-level items by target, in the order the targets first appear. On
+// Windows, commands are shown as apply writes them; nircmd.exe is only needed where they are
+// applied, so without one admin items show it by name.
+func docsSections(manifest *Manifest, manifestDir, platform string, icons bool) (sections []docsSection) {
+	var index = make(map[string]int)
+	nircmdFallback = "nircmd.exe"
+	defer func() {
+		nircmdFallback = ""
+	}()
+	for _, entry := range manifest.Items {
+		var node = docsItem(entry.ID, entry.ID, entry.Menu, manifestDir, platform, icons)
+		for _, target := range manifest.Targets(entry.ID) {
+			i, ok := index[strings.ToLower(target)]
+			if !ok {
+				label := target
+				if class, ok := targetAliases[strings.ToLower(target)]; ok {
+					label = fmt.Sprintf(`%s (%s)`, target, class)
+				}
+				i = len(sections)
+				index[strings.ToLower(target)] = i
+				sections = append(sections, docsSection{Target: label})
+			}
+			sections[i].Items = append(sections[i].Items, node)
+		}
+	}
+	return
+}
+
+func docsItem(id, name string, item *ContextMenu, manifestDir, platform string, icons bool) (node docsNode) {
+	node = docsNode{
+		ID:              name,
+		Title:           item.Title,
+		Description:     item.Description,
+		SeparatorBefore: item.SeparatorBefore,
+		SeparatorAfter:  item.SeparatorAfter,
+	}
+	// Icons elsewhere are plain paths, with none of the quoting and index Windows reads.
+	if platform != "windows" {
+		node.Icon = item.IconFile(manifestDir)
+	} else {
+		node.Icon = item.Icon(manifestDir)
+	}
+	if item.Admin {
+		node.Notes = append(node.Notes, tr("runs as administrator"))
+	}
+	if item.Extended {
+		node.Notes = append(node.Notes, tr("only with Shift held"))
+	}
+	if item.Type == ContextMenuType_Folder {
+		for _, entry := range item.Items {
+			node.Items = append(node.Items, docsItem(id+"/"+entry.ID, entry.ID, entry.Menu, manifestDir, platform, icons))
+		}
+	} else if platform != "windows" {
+		node.Command = strings.ReplaceAll(joinCommandLine(item.Command), "${manifestFolder}", manifestDir)
+	} else if command, err := item.CommandString(manifestDir); err != nil {
+		logf(LogLevel_Debug, "no command for %s: %v", id, err)
+	} else {
+		node.Command = command
+	}
+	if icons && item.IconPath != "" {
+		var (
+			index               int
+			iconFile, iconIndex = item.IconLocation(manifestDir)
+		)
+		if iconIndex != nil {
+			index = *iconIndex
+		}
+		if data, err := extractIconPNG(iconFile, index); err != nil {
+			logf(LogLevel_Debug, "no icon for %s: %v", id, err)
+		} else {
+			node.IconData = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+		}
+	}
+	return
+}
+
+func docsMarkdown(page docsPage) string {
+	var (
+		b    strings.Builder
+		list func(nodes []docsNode, indent string)
+	)
+	list = func(nodes []docsNode, indent string) {
+		for _, node := range nodes {
+			if node.SeparatorBefore {
+				fmt.Fprintf(&b, "%s- ───\n", indent)
+			}
+			fmt.Fprintf(&b, "%s- **%s** %s", indent, markdownEscape(node.Title), markdownCode(node.ID))
+			if len(node.Notes) > 0 {
+				fmt.Fprintf(&b, " — %s", strings.Join(node.Notes, ", "))
+			}
+			b.WriteString("\n")
+			if node.Description != "" {
+				fmt.Fprintf(&b, "%s  *%s*\n", indent, markdownEscape(node.Description))
+			}
+			if node.Command != "" {
+				fmt.Fprintf(&b, "%s  %s\n", indent, markdownCode(node.Command))
+			}
+			if node.Icon != "" {
+				fmt.Fprintf(&b, "%s  %s %s\n", indent, tr("Icon:"), markdownCode(node.Icon))
+			}
+			list(node.Items, indent+"  ")
+			if node.SeparatorAfter {
+				fmt.Fprintf(&b, "%s- ───\n", indent)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "# %s\n\n%s\n", page.Title, page.Source)
+	if len(page.Sections) == 0 {
+		fmt.Fprintf(&b, "\n%s\n", page.Empty)
+	}
+	for _, section := range page.Sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", markdownEscape(section.Target))
+		list(section.Items, "")
+	}
+	return b.String()
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`).Replace(s)
+}
+
+// markdownCode wraps s in a code span, with enough backticks that any in s do not end it.
+func markdownCode(s string) string {
+	var fence = "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: "Segoe UI", sans-serif; margin: 2em; }
+ul { list-style: none; padding-left: 1.5em; }
+li { margin: 0.4em 0; }
+img { width: 16px; height: 16px; vertical-align: middle; margin-right: 0.4em; }
+code { color: #555; }
+.notes { color: #a60; font-size: 0.9em; }
+hr { width: 12em; margin: 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Source}}</p>
+{{- range .Sections}}
+<h2>{{.Target}}</h2>
+{{template "items" .Items}}
+{{- else}}
+<p>{{.Empty}}</p>
+{{- end}}
+</body>
+</html>
+{{define "items"}}<ul>
+{{- range .}}
+{{- if .SeparatorBefore}}<li><hr></li>{{end}}
+<li>{{if .IconData}}<img src="{{.IconData}}" alt="">{{end}}<strong>{{.Title}}</strong> <code>{{.ID}}</code>
+{{- range .Notes}} <span class="notes">{{.}}</span>{{end}}
+{{- if .Description}}<br><em>{{.Description}}</em>{{end}}
+{{- if .Command}}<br><code>{{.Command}}</code>{{end}}
+{{- if .Icon}}<br><small>{{.Icon}}</small>{{end}}
+{{- if .Items}}{{template "items" .Items}}{{end}}</li>
+{{- if .SeparatorAfter}}<li><hr></li>{{end}}
+{{- end}}
+</ul>{{end}}
+`))
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownCode(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{s: "wt.exe -d %V", want: "`wt.exe -d %V`"},
+		{s: "echo `date`", want: "`` echo `date` ``"},
+		{s: "a``b", want: "```a``b```"},
+		{s: `C:\*_[x]_*`, want: "`C:\\*_[x]_*`"},
+	} {
+		if got := markdownCode(test.s); got != test.want {
+			t.Errorf("markdownCode(%q) = %q, expected %q", test.s, got, test.want)
+		}
+	}
+}
+
+// TestDocsMarkdown checks the Markdown rendered for a manifest: sections by target in the order
+// they first appear, folders as nested lists, and titles that could be read as Markdown escaped.
+func TestDocsMarkdown(t *testing.T) {
+	var manifest = &Manifest{Items: testMenus(t, `{
+		"terminal": {"type": "item", "title": "Open *Terminal*", "command": ["wt.exe", "-d", "%V"], "extended": true, "targets": ["background", "directory"]},
+		"tools": {"type": "folder", "title": "Tools", "description": "Things [we] use", "targets": ["directory"], "items": {
+			"hash": {"type": "item", "title": "Hash", "command": ["${manifestFolder}/hash.sh", "%V"], "admin": true, "separatorBefore": true},
+			"edit": {"type": "item", "title": "<Edit>", "command": ["code", "%V"], "iconPath": "code.ico"}
+		}}
+	}`)}
+	page := docsPage{Title: "Context menus", Source: "Rendered from m.json.", Empty: "No items."}
+	page.Sections = docsSections(manifest, "/menus", "linux", false)
+	want := "# Context menus\n\nRendered from m.json.\n" +
+		"\n## background (Directory\\\\Background)\n\n" +
+		"- **Open \\*Terminal\\*** `terminal` — only with Shift held\n" +
+		"  `wt.exe -d %V`\n" +
+		"\n## directory (Directory)\n\n" +
+		"- **Open \\*Terminal\\*** `terminal` — only with Shift held\n" +
+		"  `wt.exe -d %V`\n" +
+		"- **Tools** `tools`\n" +
+		"  *Things \\[we\\] use*\n" +
+		"  - ───\n" +
+		"  - **Hash** `hash` — runs as administrator\n" +
+		"    `/menus/hash.sh %V`\n" +
+		"  - **\\<Edit\\>** `edit`\n" +
+		"    `code %V`\n" +
+		"    Icon: `code.ico`\n"
+	if got := docsMarkdown(page); got != want {
+		t.Errorf("rendered\n%s\nexpected\n%s", got, want)
+	}
+	page.Sections = nil
+	if got := docsMarkdown(page); !strings.HasSuffix(got, "\nNo items.\n") {
+		t.Errorf("rendered without items\n%s", got)
+	}
+}
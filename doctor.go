@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+// runDoctor reports what keeps applied items from showing, such as policies that turn the context
+// menu off or permissions that deny writing its keys, so that they do not vanish without a trace.
+func runDoctor(args []string) (err error) {
+	var (
+		flags    = newFlagSet("doctor")
+		problems []Problem
+		failed   int
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	problems = policyProblems()
+	for _, problem := range problems {
+		fmt.Println(problem)
+		if !problem.Warning {
+			failed++
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Println(tr("No policies found that keep the menus from showing."))
+	}
+	if failed > 0 {
+		err = errorf("%d problem(s) keep the menus from showing", failed)
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// planDolphin renders each top-level item into a service menu under KDE's servicemenus folder.
+// Folders become submenus; Dolphin does not nest them, so nested folders are flattened into
+// their parent between separators. Extended items show like any other.
+func planDolphin(manifest *Manifest, manifestDir string) (files []managedFile, err error) {
+	var (
+		dir   string
+		globs map[string]string
+	)
+	if dir, err = dataHome(); err != nil {
+		return
+	}
+	globs = mimeGlobs()
+	for _, entry := range manifest.Items {
+		files = append(files, managedFile{
+			ID:   entry.ID,
+			Path: filepath.Join(dir, "kio", "servicemenus", entry.ID+".desktop"),
+			Data: dolphinServiceMenu(entry.ID, entry.Menu, manifest.Targets(entry.ID), manifestDir, globs),
+		})
+	}
+	return
+}
+
+func dolphinServiceMenu(id string, item *ContextMenu, targets []string, manifestDir string, globs map[string]string) string {
+	var (
+		b       strings.Builder
+		actions []string
+		names   = make(map[string]bool)
+		bodies  strings.Builder
+		add     func(prefix string, menus ContextMenus)
+		action  = func(id string, item *ContextMenu) {
+			var name = desktopActionName.ReplaceAllString(id, "_")
+			for base, n := name, 2; names[name]; n++ {
+				name = fmt.Sprintf("%s_%d", base, n)
+			}
+			names[name] = true
+			actions = append(actions, name)
+			fmt.Fprintf(&bodies, "\n[Desktop Action %s]\nName=%s\n", name, desktopEscape(plainTitle(item.Title)))
+			if icon := themeIcon(item, manifestDir); icon != "" {
+				fmt.Fprintf(&bodies, "Icon=%s\n", desktopEscape(icon))
+			}
+			fmt.Fprintf(&bodies, "Exec=%s\n", desktopExec(item, targets, manifestDir))
+		}
+		separator = func() {
+			if len(actions) > 0 && actions[len(actions)-1] != "_SEPARATOR_" {
+				actions = append(actions, "_SEPARATOR_")
+			}
+		}
+	)
+	add = func(prefix string, menus ContextMenus) {
+		for _, entry := range menus {
+			if entry.Menu.SeparatorBefore {
+				separator()
+			}
+			if entry.Menu.Type == ContextMenuType_Folder {
+				separator()
+				add(prefix+entry.ID+"/", entry.Menu.Items)
+				separator()
+			} else {
+				action(prefix+entry.ID, entry.Menu)
+			}
+			if entry.Menu.SeparatorAfter {
+				separator()
+			}
+		}
+		if len(actions) > 0 && actions[len(actions)-1] == "_SEPARATOR_" {
+			actions = actions[:len(actions)-1]
+		}
+	}
+	fmt.Fprintf(&b, "# Written by context-menu-manager for %q, changes are lost on the next apply.\n", id)
+	b.WriteString("[Desktop Entry]\nType=Service\nX-KDE-ServiceTypes=KonqPopupMenu/Plugin\n")
+	fmt.Fprintf(&b, "MimeType=%s;\n", strings.Join(mimeTypes(targets, globs), ";"))
+	b.WriteString("X-KDE-Priority=TopLevel\n")
+	if item.Type == ContextMenuType_Folder {
+		fmt.Fprintf(&b, "X-KDE-Submenu=%s\n", desktopEscape(plainTitle(item.Title)))
+		if icon := themeIcon(item, manifestDir); icon != "" {
+			fmt.Fprintf(&b, "Icon=%s\n", desktopEscape(icon))
+		}
+		add(id+"/", item.Items)
+	} else {
+		action(id, item)
+	}
+	fmt.Fprintf(&b, "Actions=%s;\n", strings.Join(actions, ";"))
+	b.WriteString(bodies.String())
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDolphinServiceMenu checks that folders nested in a service menu are flattened between
+// separators, and that action names stay distinct once IDs are cut down to what names may hold.
+func TestDolphinServiceMenu(t *testing.T) {
+	var (
+		manifest = Manifest{Items: testMenus(t, `{"tools": {"type": "folder", "title": "&Tools", "iconPath": "utilities-terminal", "items": {
+			"hash": {"type": "item", "title": "Hash", "command": ["sha256sum", "%V"], "iconPath": "C:\\Tools\\hash.ico"},
+			"more": {"type": "folder", "title": "More", "separatorBefore": true, "items": {
+				"a-b": {"type": "item", "title": "A&&B", "command": ["ab"], "iconPath": "/usr/share/icons/ab.png"}
+			}},
+			"more_a-b": {"type": "item", "title": "Tab\there", "command": ["x"], "separatorAfter": true}
+		}}}`)}
+		folder  = manifest.Find("tools")
+		targets = []string{".txt", "directory", ".unknown"}
+		exec    = func(id string) string {
+			return desktopExec(manifest.Find("tools/"+id), targets, "/menus")
+		}
+		want = `# Written by context-menu-manager for "tools", changes are lost on the next apply.
+[Desktop Entry]
+Type=Service
+X-KDE-ServiceTypes=KonqPopupMenu/Plugin
+MimeType=text/plain;inode/directory;all/allfiles;
+X-KDE-Priority=TopLevel
+X-KDE-Submenu=Tools
+Icon=utilities-terminal
+Actions=tools_hash;_SEPARATOR_;tools_more_a-b;_SEPARATOR_;tools_more_a-b_2;
+
+[Desktop Action tools_hash]
+Name=Hash
+Exec=` + exec("hash") + `
+
+[Desktop Action tools_more_a-b]
+Name=A&B
+Icon=/usr/share/icons/ab.png
+Exec=` + exec("more/a-b") + `
+
+[Desktop Action tools_more_a-b_2]
+Name=Tab\there
+Exec=` + exec("more_a-b") + "\n"
+	)
+	got := dolphinServiceMenu("tools", folder, targets, "/menus", map[string]string{".txt": "text/plain"})
+	if got != want {
+		t.Errorf("service menu is\n%s\nexpected\n%s", got, want)
+	}
+	if strings.Contains(got, "\t") {
+		t.Error("service menu has a tab left unescaped")
+	}
+}
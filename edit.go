@@ -0,0 +1,581 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type editorRow struct {
+	id    string
+	depth int
+	list  *ContextMenus
+	index int
+}
+
+func (r editorRow) menu() *ContextMenu {
+	return (*r.list)[r.index].Menu
+}
+
+type editor struct {
+	console  *console
+	path     string
+	manifest *Manifest
+	expanded map[*ContextMenu]bool
+	rows     []editorRow
+	current  *ContextMenu
+	cursor   int
+	offset   int
+	modified bool
+	message  string
+}
+
+var errPromptCancelled = errors.New("cancelled")
+
+func runEdit(args []string) (err error) {
+	var (
+		flags   = newFlagSet("edit")
+		e       = &editor{expanded: make(map[*ContextMenu]bool)}
+		created bool
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if e.path, e.manifest, created, err = openManifest(flags.Arg(0)); err != nil {
+		return
+	}
+	if created {
+		e.message = tr("New manifest, press s to save it.")
+	}
+	if e.console, err = openConsole(); err != nil {
+		return
+	}
+	defer e.console.restore()
+	err = e.loop()
+	return
+}
+
+func (e *editor) loop() (err error) {
+	var key string
+	for {
+		e.refresh()
+		e.draw()
+		if key, err = e.console.readKey(); err != nil {
+			return
+		}
+		e.message = ""
+		switch key {
+		case keyUp, "k":
+			e.moveCursor(-1)
+		case keyDown, "j":
+			e.moveCursor(1)
+		case keyPageUp:
+			e.moveCursor(-10)
+		case keyPageDown:
+			e.moveCursor(10)
+		case keyHome:
+			e.moveCursor(-len(e.rows))
+		case keyEnd:
+			e.moveCursor(len(e.rows))
+		case keyRight, "l":
+			if menu := e.selected(); menu != nil && menu.Type == ContextMenuType_Folder {
+				e.expanded[menu] = true
+			}
+		case keyLeft, "h":
+			e.collapse()
+		case keyEnter:
+			if menu := e.selected(); menu != nil && menu.Type == ContextMenuType_Folder {
+				e.expanded[menu] = !e.expanded[menu]
+			} else {
+				e.edit()
+			}
+		case "e":
+			e.edit()
+		case "a":
+			e.add(false)
+		case "c":
+			e.add(true)
+		case "d", "x":
+			e.remove()
+		case "K":
+			e.move(-1)
+		case "J":
+			e.move(1)
+		case "i":
+			e.pickIcon()
+		case "s":
+			e.save()
+		case "q", keyEscape, keyInterrupt:
+			if !e.modified || e.confirm(tr("Discard unsaved changes?")) {
+				return
+			}
+		}
+	}
+}
+
+// refresh rebuilds the visible rows and keeps the cursor on the current item.
+func (e *editor) refresh() {
+	var walk func(prefix string, depth int, list *ContextMenus)
+	e.rows = e.rows[:0]
+	walk = func(prefix string, depth int, list *ContextMenus) {
+		for i, entry := range *list {
+			e.rows = append(e.rows, editorRow{id: prefix + entry.ID, depth: depth, list: list, index: i})
+			if entry.Menu.Type == ContextMenuType_Folder && e.expanded[entry.Menu] {
+				walk(prefix+entry.ID+"/", depth+1, &entry.Menu.Items)
+			}
+		}
+	}
+	walk("", 0, &e.manifest.Items)
+	for i, row := range e.rows {
+		if row.menu() == e.current {
+			e.cursor = i
+			return
+		}
+	}
+	e.moveCursor(0)
+}
+
+func (e *editor) moveCursor(delta int) {
+	e.cursor += delta
+	if e.cursor >= len(e.rows) {
+		e.cursor = len(e.rows) - 1
+	}
+	if e.cursor < 0 {
+		e.cursor = 0
+	}
+	e.current = e.selected()
+}
+
+func (e *editor) selected() *ContextMenu {
+	if e.cursor >= len(e.rows) {
+		return nil
+	}
+	return e.rows[e.cursor].menu()
+}
+
+func (e *editor) collapse() {
+	if len(e.rows) == 0 {
+		return
+	}
+	var row = e.rows[e.cursor]
+	if menu := row.menu(); menu.Type == ContextMenuType_Folder && e.expanded[menu] {
+		e.expanded[menu] = false
+		return
+	}
+	for i := e.cursor - 1; i >= 0; i-- {
+		if e.rows[i].depth < row.depth {
+			e.cursor = i
+			e.current = e.selected()
+			return
+		}
+	}
+}
+
+func (e *editor) add(child bool) {
+	var (
+		err   error
+		list  = &e.manifest.Items
+		index = len(e.manifest.Items)
+		entry = ContextMenuEntry{Menu: new(ContextMenu)}
+		kind  string
+	)
+	if len(e.rows) > 0 {
+		var row = e.rows[e.cursor]
+		list, index = row.list, row.index+1
+		if child {
+			var menu = row.menu()
+			if menu.Type != ContextMenuType_Folder {
+				e.message = tr("Only folders can have children.")
+				return
+			}
+			list, index = &menu.Items, len(menu.Items)
+			e.expanded[menu] = true
+		}
+	}
+	if entry.ID, err = e.promptID(tr("New item ID: "), "", *list); err != nil {
+		return
+	}
+	if kind, err = e.prompt(tr("Type (item/folder): "), string(ContextMenuType_Item)); err != nil {
+		return
+	}
+	entry.Menu.Type = ContextMenuType(kind)
+	if entry.Menu.Title, err = e.prompt(tr("Title: "), entry.ID); err != nil {
+		return
+	}
+	if entry.Menu.Type != ContextMenuType_Folder {
+		var command string
+		if command, err = e.prompt(tr("Command: "), ""); err != nil {
+			return
+		}
+		entry.Menu.Command = splitCommandLine(command)
+	}
+	*list = append(*list, ContextMenuEntry{})
+	copy((*list)[index+1:], (*list)[index:])
+	(*list)[index] = entry
+	e.current = entry.Menu
+	e.modified = true
+}
+
+func (e *editor) remove() {
+	if len(e.rows) == 0 {
+		return
+	}
+	var row = e.rows[e.cursor]
+	if !e.confirm(sprintf("Delete %q?", row.id)) {
+		return
+	}
+	*row.list = append((*row.list)[:row.index], (*row.list)[row.index+1:]...)
+	e.current = nil
+	e.modified = true
+}
+
+func (e *editor) move(delta int) {
+	if len(e.rows) == 0 {
+		return
+	}
+	var (
+		row   = e.rows[e.cursor]
+		list  = *row.list
+		index = row.index + delta
+	)
+	if index < 0 || index >= len(list) {
+		return
+	}
+	list[row.index], list[index] = list[index], list[row.index]
+	e.modified = true
+}
+
+func (e *editor) edit() {
+	if len(e.rows) == 0 {
+		return
+	}
+	var (
+		err   error
+		row   = e.rows[e.cursor]
+		entry = &(*row.list)[row.index]
+		menu  = entry.Menu
+		key   string
+		value string
+	)
+	e.message = tr("Edit: [t]itle [c]ommand [p] icon path [n] icon index [y]type [r]ename [x] extended [a]dmin")
+	e.draw()
+	if key, err = e.console.readKey(); err != nil {
+		return
+	}
+	e.message = ""
+	switch key {
+	case "t":
+		if value, err = e.prompt(tr("Title: "), menu.Title); err == nil {
+			menu.Title = value
+		}
+	case "c":
+		if value, err = e.prompt(tr("Command: "), joinCommandLine(menu.Command)); err == nil {
+			menu.Command = splitCommandLine(value)
+		}
+	case "p":
+		if value, err = e.prompt(tr("Icon path: "), menu.IconPath); err == nil {
+			menu.IconPath = value
+		}
+	case "n":
+		if menu.IconIndex != nil {
+			value = strconv.Itoa(*menu.IconIndex)
+		}
+		if value, err = e.prompt(tr("Icon index (empty for none): "), value); err == nil {
+			if value == "" {
+				menu.IconIndex = nil
+			} else if index, convErr := strconv.Atoi(value); convErr != nil {
+				e.message, err = sprintf("Invalid icon index %q.", value), convErr
+			} else {
+				menu.IconIndex = &index
+			}
+		}
+	case "y":
+		if value, err = e.prompt(tr("Type (item/folder): "), string(menu.Type)); err == nil {
+			menu.Type = ContextMenuType(value)
+		}
+	case "r":
+		if value, err = e.promptID(tr("ID: "), entry.ID, *row.list); err == nil {
+			entry.ID = value
+		}
+	case "x":
+		menu.Extended = !menu.Extended
+	case "a":
+		menu.Admin = !menu.Admin
+	default:
+		return
+	}
+	if err == nil {
+		e.modified = true
+	}
+}
+
+func (e *editor) pickIcon() {
+	var (
+		err    error
+		menu   = e.selected()
+		names  = shellIconNames()
+		cursor int
+		key    string
+	)
+	if menu == nil {
+		return
+	}
+	for {
+		var (
+			b      strings.Builder
+			_, h   = e.console.size()
+			offset = 0
+		)
+		if cursor >= h-3 {
+			offset = cursor - (h - 4)
+		}
+		b.WriteString("\x1b[H\x1b[2J" + tr(" Pick an icon preset (Enter to choose, Esc to cancel)") + "\r\n\r\n")
+		for i := offset; i < len(names) && i-offset < h-3; i++ {
+			line := fmt.Sprintf(" %-14s %s", names[i], shellIconPresets[names[i]].title)
+			if i == cursor {
+				line = "\x1b[7m" + line + "\x1b[0m"
+			}
+			b.WriteString(line + "\r\n")
+		}
+		fmt.Print(b.String())
+		if key, err = e.console.readKey(); err != nil {
+			return
+		}
+		switch key {
+		case keyUp, "k":
+			if cursor > 0 {
+				cursor--
+			}
+		case keyDown, "j":
+			if cursor < len(names)-1 {
+				cursor++
+			}
+		case keyEnter:
+			menu.IconPath, menu.IconIndex = shellIconPrefix+names[cursor], nil
+			e.modified = true
+			return
+		case keyEscape, "q":
+			return
+		}
+	}
+}
+
+func (e *editor) save() {
+	if err := writeManifest(e.path, e.manifest); err != nil {
+		e.message = err.Error()
+		return
+	}
+	e.modified = false
+	if problems := validateManifest(e.manifest); len(problems) > 0 {
+		e.message = sprintf("Saved with %d problem(s).", len(problems))
+	} else {
+		e.message = tr("Saved.")
+	}
+}
+
+func (e *editor) promptID(label, value string, siblings ContextMenus) (id string, err error) {
+	for {
+		if id, err = e.prompt(label, value); err != nil {
+			return
+		}
+		switch {
+		case id == "" || strings.ContainsAny(id, `/\`):
+			e.message = tr(`IDs must be non-empty and must not contain "/" or "\".`)
+		case id != value && siblings.Get(id) != nil:
+			e.message = sprintf("ID %q is already used at this level.", id)
+		default:
+			e.message = ""
+			return
+		}
+		value = id
+	}
+}
+
+func (e *editor) confirm(question string) bool {
+	var answer, err = e.prompt(question+" (y/n) ", "")
+	return err == nil && strings.EqualFold(answer, "y")
+}
+
+// prompt reads a line on the bottom row of the screen, starting from value.
+func (e *editor) prompt(label, value string) (line string, err error) {
+	var (
+		buf = []rune(value)
+		key string
+	)
+	e.draw()
+	fmt.Print("\x1b[?25h")
+	defer fmt.Print("\x1b[?25l")
+	for {
+		_, h := e.console.size()
+		fmt.Printf("\x1b[%d;1H\x1b[2K%s%s", h, label, string(buf))
+		if key, err = e.console.readKey(); err != nil {
+			return
+		}
+		switch key {
+		case keyEnter:
+			line = strings.TrimSpace(string(buf))
+			return
+		case keyEscape, keyInterrupt:
+			err = errPromptCancelled
+			return
+		case keyBackspace:
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+		default:
+			if _, special := vtKeys[key]; special || strings.HasPrefix(key, "\x1b") {
+				continue
+			}
+			for _, r := range key {
+				if unicode.IsPrint(r) {
+					buf = append(buf, r)
+				}
+			}
+		}
+	}
+}
+
+func (e *editor) draw() {
+	var (
+		b          strings.Builder
+		w, h       = e.console.size()
+		problems   = validateManifest(e.manifest)
+		treeHeight = h - 14
+		modified   string
+	)
+	if treeHeight < 3 {
+		treeHeight = 3
+	}
+	if e.cursor < e.offset {
+		e.offset = e.cursor
+	}
+	if e.cursor >= e.offset+treeHeight {
+		e.offset = e.cursor - treeHeight + 1
+	}
+	if e.modified {
+		modified = tr(" (modified)")
+	}
+	rule := strings.Repeat("-", w) + "\r\n"
+	b.WriteString("\x1b[H\x1b[2J")
+	b.WriteString(fitLine(" Context Menu Manager - "+e.path+modified, w) + "\r\n")
+	b.WriteString(rule)
+	for i := e.offset; i < e.offset+treeHeight; i++ {
+		if i >= len(e.rows) {
+			b.WriteString("\r\n")
+			continue
+		}
+		line := fitLine(e.rowText(e.rows[i]), w)
+		if i == e.cursor {
+			line = "\x1b[7m" + line + strings.Repeat(" ", w-len([]rune(line))) + "\x1b[0m"
+		}
+		b.WriteString(line + "\r\n")
+	}
+	b.WriteString(rule)
+	for _, line := range e.details() {
+		b.WriteString(fitLine(line, w) + "\r\n")
+	}
+	b.WriteString(rule)
+	switch len(problems) {
+	case 0:
+		b.WriteString("\x1b[32m" + tr(" No problems found.") + "\x1b[0m\r\n\r\n")
+	default:
+		b.WriteString("\x1b[33m" + fitLine(sprintf(" %d problem(s): %s", len(problems), problems[0]), w) + "\r\n")
+		if len(problems) > 1 {
+			b.WriteString(fitLine("   "+problems[1].String(), w))
+		}
+		b.WriteString("\x1b[0m\r\n")
+	}
+	b.WriteString(fitLine(tr(" arrows move/expand  a add  c add child  e edit  i icon  d delete  K/J reorder  s save  q quit"), w) + "\r\n")
+	b.WriteString(fitLine(" "+e.message, w))
+	fmt.Print(b.String())
+}
+
+func (e *editor) rowText(row editorRow) string {
+	var (
+		menu   = row.menu()
+		marker = "   "
+		flags  string
+	)
+	if menu.Type == ContextMenuType_Folder {
+		if e.expanded[menu] {
+			marker = "[-]"
+		} else {
+			marker = "[+]"
+		}
+	}
+	if menu.Extended {
+		flags += tr(" (extended)")
+	}
+	if menu.Admin {
+		flags += tr(" (admin)")
+	}
+	return fmt.Sprintf(" %s%s %s  %q%s", strings.Repeat("    ", row.depth), marker, (*row.list)[row.index].ID, menu.Title, flags)
+}
+
+func (e *editor) details() []string {
+	var menu = e.selected()
+	if menu == nil {
+		return []string{tr(" No items, press a to add one."), "", "", "", ""}
+	}
+	var icon = menu.IconPath
+	if menu.IconIndex != nil {
+		icon = fmt.Sprintf("%s,%d", icon, *menu.IconIndex)
+	}
+	return []string{
+		" ID:       " + e.rows[e.cursor].id,
+		tr(" Title:    ") + menu.Title,
+		tr(" Type:     ") + string(menu.Type),
+		tr(" Command:  ") + joinCommandLine(menu.Command),
+		tr(" Icon:     ") + icon,
+	}
+}
+
+func fitLine(line string, width int) string {
+	var runes = []rune(line)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
+
+// splitCommandLine splits on spaces except inside double quotes. Backslashes are kept as is
+// since they are path separators on Windows.
+func splitCommandLine(line string) (args []string) {
+	var (
+		arg     strings.Builder
+		quoted  bool
+		started bool
+	)
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted, started = !quoted, true
+		case r == ' ' && !quoted:
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return
+}
+
+func joinCommandLine(args []string) string {
+	var parts = make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.Contains(arg, " ") {
+			arg = `"` + arg + `"`
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// FuzzSplitCommandLine checks that a command typed in the editor splits the same once joined
+// back, which is how the editor shows it again.
+func FuzzSplitCommandLine(f *testing.F) {
+	for _, line := range []string{
+		`notepad.exe "%1"`,
+		`"C:\Program Files\Git\git-bash.exe" "--cd=%V"`,
+		`code  --new-window ""  "%V"`,
+		`wt.exe -d "%V\"`,
+		`"unterminated quote`,
+		"tab\tinside \"打开 终端\"",
+	} {
+		f.Add(line)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		var (
+			args  = splitCommandLine(line)
+			again = splitCommandLine(joinCommandLine(args))
+		)
+		if len(args) == 0 && len(again) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(again, args) {
+			t.Fatalf("%q splits into %q, but joined back as %q it splits into %q", line, args, joinCommandLine(args), again)
+		}
+	})
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		want []string
+	}{
+		{line: "", want: nil},
+		{line: `notepad.exe "%1"`, want: []string{"notepad.exe", "%1"}},
+		{line: `"C:\Program Files\Git\git-bash.exe" "--cd=%V"`, want: []string{`C:\Program Files\Git\git-bash.exe`, "--cd=%V"}},
+		{line: `code  --new-window ""  "%V"`, want: []string{"code", "--new-window", "", "%V"}},
+		{line: `wt.exe -d "%V\"`, want: []string{"wt.exe", "-d", `%V\`}},
+		{line: `--cd="C:\a b"`, want: []string{`--cd=C:\a b`}},
+		{line: `"unterminated quote`, want: []string{"unterminated quote"}},
+	} {
+		if got := splitCommandLine(test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitCommandLine(%q) = %q, expected %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestJoinCommandLine(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{args: nil, want: ""},
+		{args: []string{"notepad.exe", "%1"}, want: "notepad.exe %1"},
+		{args: []string{`C:\Program Files\app.exe`, "", "%V"}, want: `"C:\Program Files\app.exe" "" %V`},
+	} {
+		if got := joinCommandLine(test.args); got != test.want {
+			t.Errorf("joinCommandLine(%q) = %q, expected %q", test.args, got, test.want)
+		}
+	}
+}
+
+// TestEditorRows checks the rows the editor lists as folders are expanded and collapsed, and that
+// the cursor stays on the item it is on when items are moved.
+func TestEditorRows(t *testing.T) {
+	var (
+		e = &editor{
+			manifest: &Manifest{Items: testMenus(t, `{
+				"a": {"type": "item", "title": "A", "command": ["a.exe"]},
+				"f": {"type": "folder", "title": "F", "items": {
+					"x": {"type": "item", "title": "X", "command": ["x.exe"]},
+					"y": {"type": "item", "title": "Y", "command": ["y.exe"]}
+				}},
+				"b": {"type": "item", "title": "B", "command": ["b.exe"]}
+			}`)},
+			expanded: make(map[*ContextMenu]bool),
+		}
+		ids = func() (ids []string) {
+			for _, row := range e.rows {
+				ids = append(ids, row.id)
+			}
+			return
+		}
+		check = func(step string, wantIDs []string, wantCursor string) {
+			t.Helper()
+			if got := ids(); !reflect.DeepEqual(got, wantIDs) {
+				t.Errorf("%s: rows are %q, expected %q", step, got, wantIDs)
+			}
+			if got := e.rows[e.cursor].id; got != wantCursor {
+				t.Errorf("%s: cursor is on %q, expected %q", step, got, wantCursor)
+			}
+		}
+	)
+	e.refresh()
+	check("start", []string{"a", "f", "b"}, "a")
+	e.moveCursor(1)
+	e.expanded[e.selected()] = true
+	e.refresh()
+	check("expanded", []string{"a", "f", "f/x", "f/y", "b"}, "f")
+	e.moveCursor(2)
+	e.move(-1)
+	e.refresh()
+	check("moved up", []string{"a", "f", "f/y", "f/x", "b"}, "f/y")
+	e.move(-1)
+	e.refresh()
+	check("moved past the first", []string{"a", "f", "f/y", "f/x", "b"}, "f/y")
+	e.collapse()
+	check("collapsed to the folder", []string{"a", "f", "f/y", "f/x", "b"}, "f")
+	e.collapse()
+	e.refresh()
+	check("collapsed", []string{"a", "f", "b"}, "f")
+	e.moveCursor(10)
+	check("past the end", []string{"a", "f", "b"}, "b")
+	if !e.modified {
+		t.Error("moving items does not mark the manifest modified")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// editorLocation is a glob of where an editor installs on Windows, below the folder of an
+// environment variable.
+type editorLocation struct {
+	env  string
+	glob string
+}
+
+// knownEditor is an editor that generate editors looks for: by the App Paths entries of its
+// executables and where its installers put it on Windows, and by its commands elsewhere.
+type knownEditor struct {
+	id        string
+	name      string
+	appPaths  []string
+	locations []editorLocation
+	commands  []string
+	args      []string
+}
+
+var knownEditors = []knownEditor{
+	{
+		id: "vscode", name: "Visual Studio Code",
+		locations: []editorLocation{
+			{"LOCALAPPDATA", `Programs\Microsoft VS Code\Code.exe`},
+			{"ProgramFiles", `Microsoft VS Code\Code.exe`},
+		},
+		commands: []string{"code"},
+	},
+	{
+		id: "vscode-insiders", name: "Visual Studio Code - Insiders",
+		locations: []editorLocation{
+			{"LOCALAPPDATA", `Programs\Microsoft VS Code Insiders\Code - Insiders.exe`},
+			{"ProgramFiles", `Microsoft VS Code Insiders\Code - Insiders.exe`},
+		},
+		commands: []string{"code-insiders"},
+	},
+	{
+		id: "sublime-text", name: "Sublime Text",
+		appPaths: []string{"sublime_text.exe"},
+		locations: []editorLocation{
+			{"ProgramFiles", `Sublime Text\sublime_text.exe`},
+			{"ProgramFiles", `Sublime Text 3\sublime_text.exe`},
+		},
+		commands: []string{"subl", "sublime_text"},
+	},
+	{
+		id: "notepad-plus-plus", name: "Notepad++",
+		appPaths: []string{"notepad++.exe"},
+		locations: []editorLocation{
+			{"ProgramFiles", `Notepad++\notepad++.exe`},
+			{"ProgramFiles(x86)", `Notepad++\notepad++.exe`},
+		},
+		args: []string{"-openFoldersAsWorkspace"},
+	},
+	jetBrainsEditor("intellij-idea", "IntelliJ IDEA", "idea"),
+	jetBrainsEditor("pycharm", "PyCharm", "pycharm"),
+	jetBrainsEditor("webstorm", "WebStorm", "webstorm"),
+	jetBrainsEditor("goland", "GoLand", "goland"),
+	jetBrainsEditor("clion", "CLion", "clion"),
+	jetBrainsEditor("rider", "Rider", "rider"),
+	jetBrainsEditor("phpstorm", "PhpStorm", "phpstorm"),
+	jetBrainsEditor("rubymine", "RubyMine", "rubymine"),
+	jetBrainsEditor("rustrover", "RustRover", "rustrover"),
+}
+
+// jetBrainsEditor is a JetBrains IDE, installed by its own installer, by the Toolbox App into
+// Programs, or by older Toolbox versions below their apps folder.
+func jetBrainsEditor(id, name, launcher string) knownEditor {
+	var exe = launcher + "64.exe"
+	return knownEditor{
+		id: id, name: name,
+		appPaths: []string{exe},
+		locations: []editorLocation{
+			{"ProgramFiles", `JetBrains\*\bin\` + exe},
+			{"LOCALAPPDATA", `Programs\*\bin\` + exe},
+			{"LOCALAPPDATA", `JetBrains\Toolbox\apps\*\*\*\bin\` + exe},
+		},
+		commands: []string{launcher, launcher + ".sh"},
+	}
+}
+
+// find is the program of the editor, or empty when it is not installed. Of several installs found
+// by a glob, the last by name is taken, which is usually the newest version.
+func (e knownEditor) find() string {
+	if runtime.GOOS != "windows" {
+		for _, command := range e.commands {
+			if path, err := exec.LookPath(command); err == nil {
+				return path
+			}
+		}
+		return ""
+	}
+	for _, exe := range e.appPaths {
+		if path := appPath(exe); path != "" {
+			return path
+		}
+	}
+	for _, location := range e.locations {
+		var dir = os.Getenv(location.env)
+		if dir == "" {
+			continue
+		}
+		if matches, _ := filepath.Glob(filepath.Join(dir, location.glob)); len(matches) > 0 {
+			sort.Strings(matches)
+			return matches[len(matches)-1]
+		}
+	}
+	return ""
+}
+
+// runGenerateEditors writes a manifest with an "Open folder in" item for each editor found on
+// this machine, to merge into the manifest or copy items from.
+func runGenerateEditors(args []string) (err error) {
+	var (
+		flags    = newFlagSet("generate editors")
+		format   = flags.String("format", "json", `"json", "yaml" or "toml", for standard output; --output goes by its extension`)
+		output   = flags.String("output", "", "file to write (default: standard output)")
+		manifest = &Manifest{SchemaVersion: manifestSchemaVersion}
+		data     []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	switch *format {
+	case "json", "yaml", "toml":
+	default:
+		err = errorf("unknown format %q, expected %q, %q or %q", *format, "json", "yaml", "toml")
+		return
+	}
+	for _, editor := range knownEditors {
+		var program = editor.find()
+		if program == "" {
+			logf(LogLevel_Debug, "%s not found", editor.name)
+			continue
+		}
+		item := &ContextMenu{
+			Type:    ContextMenuType_Item,
+			Title:   sprintf("Open folder in %s", editor.name),
+			Command: append(append([]string{program}, editor.args...), "%V"),
+			Targets: []string{"background", "directory"},
+		}
+		// Elsewhere the program is usually a launcher script, which is no icon.
+		if runtime.GOOS == "windows" {
+			item.IconPath = program
+		}
+		manifest.Items = append(manifest.Items, ContextMenuEntry{ID: "open-in-" + editor.id, Menu: item})
+	}
+	if len(manifest.Items) == 0 {
+		err = errorf("no known editors found")
+		return
+	}
+	if path := *output; path != "" {
+		*format = manifestFormat(path)
+	}
+	if data, err = encodeManifest("editors."+*format, manifest); err != nil {
+		return
+	}
+	if err = writeOutput(*output, string(data)); err == nil && *output != "" {
+		fmt.Fprintf(os.Stderr, tr("Wrote %d item(s) to %s. Add them to a manifest with: merge --output MANIFEST MANIFEST %s\n"), len(manifest.Items), *output, *output)
+	}
+	return
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestEditorFind checks that editors are found by the first of their commands on the PATH outside
+// Windows, where App Paths and install folders of this machine would be found instead, and that
+// their items get IDs of their own.
+func TestEditorFind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("finds the editors installed on this machine")
+	}
+	var dir = t.TempDir()
+	for _, name := range []string{"pycharm", "pycharm.sh", "goland.sh", "code-insiders"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "subl"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	for _, test := range []struct {
+		editor knownEditor
+		want   string
+	}{
+		{editor: jetBrainsEditor("pycharm", "PyCharm", "pycharm"), want: filepath.Join(dir, "pycharm")},
+		{editor: jetBrainsEditor("goland", "GoLand", "goland"), want: filepath.Join(dir, "goland.sh")},
+		{editor: jetBrainsEditor("clion", "CLion", "clion"), want: ""},
+		{editor: knownEditors[0], want: ""},
+		{editor: knownEditors[1], want: filepath.Join(dir, "code-insiders")},
+		{editor: knownEditors[2], want: ""},
+	} {
+		if got := test.editor.find(); got != test.want {
+			t.Errorf("found %s at %q, expected %q", test.editor.name, got, test.want)
+		}
+	}
+	var ids = make(map[string]bool)
+	for _, editor := range knownEditors {
+		if ids[editor.id] {
+			t.Errorf("two editors have the ID %q", editor.id)
+		}
+		ids[editor.id] = true
+	}
+}
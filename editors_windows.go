@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// appPathsKey is where installers register their executables for the Run dialog and
+// ShellExecute, by file name, with the full path as the default value.
+const appPathsKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\App Paths\`
+
+// appPath is the program registered for exe in App Paths, of the user before the machine, or
+// empty when there is none.
+func appPath(exe string) string {
+	for _, root := range []registry.Key{registry.CURRENT_USER, registry.LOCAL_MACHINE} {
+		key, err := registry.OpenKey(root, appPathsKey+exe, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		value, valueType, err := key.GetStringValue("")
+		key.Close()
+		if err == nil && valueType == registry.EXPAND_SZ {
+			value, err = registry.ExpandString(value)
+		}
+		if value = strings.Trim(value, `"`); err == nil && value != "" {
+			return value
+		}
+	}
+	return ""
+}
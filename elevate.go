@@ -0,0 +1,37 @@
+package main
+
+import "strings"
+
+// runElevated starts a program as administrator with the arguments it is given. It is what admin
+// items run with the runas elevation backend, from Explorer, which fills in the paths clicked on
+// as separate arguments; they are passed on as they are, so a file name is never run as code.
+func runElevated(args []string) (err error) {
+	var flags = newFlagSet("run-elevated")
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	detachConsole()
+	defer func() {
+		if err != nil {
+			showError(err)
+		}
+	}()
+	if flags.NArg() == 0 {
+		err = errorf("no program to run")
+		return
+	}
+	if err = runAsAdmin(flags.Arg(0), flags.Args()[1:]); err != nil {
+		err = errorf("failed to start %s as administrator: %w", flags.Arg(0), err)
+	}
+	return
+}
+
+// elevatedArgs quotes args for the command line of the program started elevated, so that it reads
+// them back unchanged.
+func elevatedArgs(args []string) string {
+	var params = make([]string, len(args))
+	for i, arg := range args {
+		params[i] = quoteWindowsArg(arg)
+	}
+	return strings.Join(params, " ")
+}
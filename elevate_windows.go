@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// runAsAdmin asks for consent and starts program elevated in the working folder. Declining the
+// prompt is not an error.
+func runAsAdmin(program string, args []string) (err error) {
+	var (
+		dir, _  = os.Getwd()
+		dirPtr  *uint16
+		argsPtr *uint16
+	)
+	if len(args) > 0 {
+		argsPtr = windows.StringToUTF16Ptr(elevatedArgs(args))
+	}
+	if dir != "" {
+		dirPtr = windows.StringToUTF16Ptr(dir)
+	}
+	err = windows.ShellExecute(0, windows.StringToUTF16Ptr("runas"), windows.StringToUTF16Ptr(program), argsPtr, dirPtr, windows.SW_SHOWNORMAL)
+	if errors.Is(err, windows.ERROR_CANCELLED) {
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package main
+
+// exporters render the manifest as the config of tools that draw the menu themselves.
+var exporters = map[string]func(manifest *Manifest, manifestDir string) (text string, err error){
+	"nss": nssConfig,
+}
+
+func runExport(args []string) (err error) {
+	var (
+		flags       = newFlagSet("export")
+		format      = flags.String("format", "", `"nss" for a Nilesoft Shell config`)
+		output      = flags.String("output", "", "file to write (default: standard output)")
+		render      func(manifest *Manifest, manifestDir string) (text string, err error)
+		manifest    *Manifest
+		manifestDir string
+		text        string
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if render = exporters[*format]; render == nil {
+		flags.Usage()
+		err = errorf("unknown format %q, expected %q", *format, "nss")
+		return
+	}
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		return
+	}
+	if text, err = render(manifest, manifestDir); err != nil {
+		return
+	}
+	err = writeOutput(*output, text)
+	return
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var fileManagers = map[FileManager]fileManager{
+	FileManager_Nautilus: {plan: planNautilus},
+	FileManager_Dolphin:  {plan: planDolphin},
+	FileManager_Thunar:   {plan: planThunar, state: thunarState, setEnabled: thunarSetEnabled},
+	FileManager_Actions:  {plan: planActions, state: actionState, setEnabled: actionSetEnabled},
+}
+
+// dataHome is $XDG_DATA_HOME, by default ~/.local/share.
+func dataHome() (dir string, err error) {
+	if dir = os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return
+	}
+	if dir, err = os.UserHomeDir(); err != nil {
+		err = errorf("failed to locate the home folder: %w", err)
+		return
+	}
+	dir = filepath.Join(dir, ".local", "share")
+	return
+}
+
+// desktopFileManagers are the file managers of desktops other than GNOME's, by the names in
+// $XDG_CURRENT_DESKTOP.
+var desktopFileManagers = map[string]FileManager{
+	"KDE":  FileManager_Dolphin,
+	"XFCE": FileManager_Thunar,
+	"LXDE": FileManager_Actions,
+	"LXQT": FileManager_Actions,
+	"MATE": FileManager_Actions,
+}
+
+// currentFileManager is the configured file manager, or the one of the desktop session.
+func currentFileManager() fileManager {
+	if config.FileManager != "" {
+		return fileManagers[config.FileManager]
+	}
+	for _, desktop := range strings.Split(strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP")), ":") {
+		if name, ok := desktopFileManagers[desktop]; ok {
+			return fileManagers[name]
+		}
+	}
+	return fileManagers[FileManager_Nautilus]
+}
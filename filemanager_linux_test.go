@@ -0,0 +1,20 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestFileManagerConformance(t *testing.T) {
+	var names []string
+	for name := range fileManagers {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			isolateState(t)
+			testConformance(t, fileManagers[FileManager(name)])
+		})
+	}
+}
@@ -0,0 +1,367 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// managedFile is a file or folder written for an item. Top-level items own the files written for
+// them, which apply replaces as a whole.
+type managedFile struct {
+	ID   string
+	Path string
+	Data string // empty for folders
+}
+
+// fileManager is the Backend of Linux and macOS. It plans the files that add the manifest to the
+// context menu of a file manager; writing and pruning them works the same for all.
+type fileManager struct {
+	plan func(manifest *Manifest, manifestDir string) (files []managedFile, err error)
+	// state reports whether the item with id shows through the file at path. By default a file
+	// is installed if it exists, and enabled while it is executable.
+	state func(path, id string) (installed, enabled bool, err error)
+	// setEnabled shows or hides the item with id, by default by setting or clearing the
+	// executable bits of the file at path.
+	setEnabled func(path, id string, enabled bool) (err error)
+	// refresh, if set, tells the file manager about the files after apply.
+	refresh func() (err error)
+}
+
+func platformBackend() Backend {
+	return currentFileManager()
+}
+
+func (fm fileManager) List(manifest *Manifest) ([]ItemStatus, error) {
+	return listItems(manifest, fm.itemState)
+}
+
+// Remove deletes the files written for every item applied before, and their entries in the files
+// shared with others.
+func (fm fileManager) Remove(ctx context.Context) error {
+	return removeApplied(ctx, fm)
+}
+
+// Apply writes the files of every item, replacing what was there. Files written for items that
+// are still in the manifest, for example under an old title, are always removed; those of
+// removed items only with prune enabled. When ctx is cancelled, it stops before the next item and
+// keeps the files it has not got to in the state.
+func (fm fileManager) Apply(ctx context.Context, manifest *Manifest, manifestDir string) (err error) {
+	var (
+		state   State
+		files   []managedFile
+		paths   = make(map[string]string)
+		logFile *os.File
+		mode    fs.FileMode = 0o755
+	)
+	if fm.setEnabled != nil {
+		// The executable bits only matter where they show a file.
+		mode = 0o644
+	}
+	if logFile, err = openApplyLog(); err != nil {
+		return
+	}
+	defer func() {
+		closeApplyLog(logFile, err)
+	}()
+	if state, err = loadState(); err != nil {
+		return
+	}
+	if files, err = fm.plan(manifest, manifestDir); err != nil {
+		return
+	}
+	if err = checkFileConflicts(files, state); err != nil {
+		return
+	}
+	for i, file := range files {
+		if !strings.Contains(file.ID, "/") {
+			if ctx.Err() != nil {
+				return fm.interrupted(ctx, state, paths, i, len(files))
+			}
+			if err = os.RemoveAll(file.Path); err != nil {
+				err = errorf("failed to remove %s: %w", file.Path, err)
+				return
+			}
+		}
+		// A file shared by all items is never pruned, it may hold entries of others.
+		if file.ID != "" {
+			paths[file.Path] = file.ID
+		}
+		logf(LogLevel_Debug, "writing %s", file.Path)
+		if file.Data == "" {
+			err = os.MkdirAll(file.Path, 0o755)
+		} else if err = os.MkdirAll(filepath.Dir(file.Path), 0o755); err == nil {
+			err = os.WriteFile(file.Path, []byte(file.Data), mode)
+		}
+		if err != nil {
+			err = errorf("failed to write %s: %w", file.Path, err)
+			return
+		}
+		switch {
+		case file.ID == "":
+			for _, entry := range manifest.Items {
+				logf(LogLevel_Info, "applied %s to %s", entry.ID, file.Path)
+			}
+		case !strings.Contains(file.ID, "/"):
+			logf(LogLevel_Info, "applied %s to %s", file.ID, file.Path)
+		}
+	}
+	if ctx.Err() != nil {
+		return fm.interrupted(ctx, state, paths, len(files), len(files))
+	}
+	for path, id := range state.Paths {
+		if _, ok := paths[path]; ok {
+			continue
+		}
+		if manifest.Find(id) == nil && !config.Prune {
+			paths[path] = id
+			continue
+		}
+		if _, statErr := os.Lstat(path); statErr != nil {
+			continue
+		}
+		if err = os.RemoveAll(path); err != nil {
+			err = errorf("failed to prune %s: %w", path, err)
+			return
+		}
+		logf(LogLevel_Info, "pruned %s", path)
+	}
+	state.Paths = paths
+	if err = saveState(state); err != nil {
+		return
+	}
+	if fm.refresh != nil {
+		err = fm.refresh()
+	}
+	return
+}
+
+// checkFileConflicts looks for files of other software or of the user where items are about to be
+// written, before anything is replaced. A file is ours when the state records it or a folder
+// holding it, since items replace their files as a whole; others are reported together as an
+// error unless forced. Files shared by all items keep the entries of others and are left out.
+func checkFileConflicts(files []managedFile, state State) (err error) {
+	var conflicts []string
+	for _, file := range files {
+		var ours bool
+		if file.ID == "" {
+			continue
+		}
+		for path := file.Path; !ours && path != filepath.Dir(path); path = filepath.Dir(path) {
+			_, ours = state.Paths[path]
+		}
+		if _, statErr := os.Lstat(file.Path); ours || statErr != nil {
+			continue
+		}
+		conflicts = append(conflicts, sprintf("item ID %q would replace %s", file.ID, file.Path))
+	}
+	if len(conflicts) > 0 && !config.Force {
+		err = errorf("%d item(s) would replace files that were not applied from the manifest, rename them or replace the files with --force:\n%s", len(conflicts), strings.Join(conflicts, "\n"))
+	}
+	return
+}
+
+// checkFileName refuses the names filepath.Join would not keep as a file of their folder, such as
+// "..", which would make an item replace the folder above.
+func checkFileName(id, name string) error {
+	if name = strings.TrimSpace(name); name == "" || name == "." || name == ".." {
+		return errorf("item ID %q would be written as %q, which is not a file name, change its title", id, name)
+	}
+	return nil
+}
+
+// interrupted records the files written so far along with those of the last apply, which are
+// still there, and reports how far the apply got.
+func (fm fileManager) interrupted(ctx context.Context, state State, paths map[string]string, written, total int) (err error) {
+	for path, id := range state.Paths {
+		if _, ok := paths[path]; !ok {
+			paths[path] = id
+		}
+	}
+	state.Paths = paths
+	if err = saveState(state); err == nil {
+		err = errorf("apply was interrupted after %d of %d file(s): %w", written, total, ctx.Err())
+	}
+	if fm.refresh != nil {
+		fm.refresh()
+	}
+	return
+}
+
+// Diff reports the files that applying the manifest would write, and the files in its folders
+// that it would remove.
+func (fm fileManager) Diff(manifest *Manifest, manifestDir string) (changes []Change, err error) {
+	var (
+		files   []managedFile
+		planned = make(map[string]bool)
+	)
+	if files, err = fm.plan(manifest, manifestDir); err != nil {
+		return
+	}
+	for _, file := range files {
+		// Folders holding the files of an item are planned as well.
+		for path := file.Path; !planned[path] && path != filepath.Dir(path); path = filepath.Dir(path) {
+			planned[path] = true
+		}
+	}
+	for _, file := range files {
+		var (
+			fi   fs.FileInfo
+			data []byte
+		)
+		if fi, err = os.Stat(file.Path); errors.Is(err, os.ErrNotExist) {
+			err = nil
+			changes = append(changes, Change{Kind: ChangeKind_Add, Key: file.Path})
+			continue
+		} else if err != nil {
+			err = errorf("failed to read %s: %w", file.Path, err)
+			return
+		}
+		if file.Data == "" {
+			var entries []fs.DirEntry
+			if !fi.IsDir() {
+				changes = append(changes, Change{Kind: ChangeKind_Modify, Key: file.Path})
+				continue
+			}
+			if entries, err = os.ReadDir(file.Path); err != nil {
+				err = errorf("failed to read %s: %w", file.Path, err)
+				return
+			}
+			for _, entry := range entries {
+				if path := filepath.Join(file.Path, entry.Name()); !planned[path] {
+					changes = append(changes, Change{Kind: ChangeKind_Remove, Key: path})
+				}
+			}
+			continue
+		}
+		if data, err = os.ReadFile(file.Path); err != nil {
+			err = errorf("failed to read %s: %w", file.Path, err)
+			return
+		}
+		if !bytes.Equal(data, []byte(file.Data)) {
+			changes = append(changes, Change{Kind: ChangeKind_Modify, Key: file.Path})
+		}
+	}
+	return
+}
+
+// itemFiles lists the written files that show the item with id: its own and those of its
+// children, or else the file of its closest parent, which holds it when the file manager writes
+// one file per top-level item or one file for all.
+func (fm fileManager) itemFiles(manifest *Manifest, id string) (files []managedFile, err error) {
+	var (
+		planned []managedFile
+		parent  *managedFile
+	)
+	if planned, err = fm.plan(manifest, ""); err != nil {
+		return
+	}
+	for i, file := range planned {
+		switch {
+		case file.ID == id || strings.HasPrefix(file.ID, id+"/"):
+			files = append(files, file)
+		case file.ID == "" || strings.HasPrefix(id, file.ID+"/"):
+			if parent == nil || len(file.ID) > len(parent.ID) {
+				parent = &planned[i]
+			}
+		}
+	}
+	if len(files) == 0 && parent != nil {
+		files = append(files, *parent)
+	}
+	return
+}
+
+// fileState and fileSetEnabled are the defaults of fileManager.
+func fileState(path, id string) (installed, enabled bool, err error) {
+	var fi fs.FileInfo
+	if fi, err = os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		err = nil
+		return
+	} else if err != nil {
+		err = errorf("failed to read %s: %w", path, err)
+		return
+	}
+	installed = true
+	enabled = !fi.IsDir() && fi.Mode()&0o111 != 0
+	return
+}
+
+func fileSetEnabled(path, id string, enabled bool) (err error) {
+	var mode fs.FileMode = 0o644
+	if enabled {
+		mode = 0o755
+	}
+	if err = os.Chmod(path, mode); err != nil {
+		err = errorf("failed to update %s: %w", path, err)
+	}
+	return
+}
+
+// itemState reports an item as enabled while any of its files shows it.
+func (fm fileManager) itemState(manifest *Manifest, id string) (installed, enabled bool, err error) {
+	var (
+		files []managedFile
+		state = fm.state
+	)
+	if state == nil {
+		state = fileState
+	}
+	if files, err = fm.itemFiles(manifest, id); err != nil {
+		return
+	}
+	for _, file := range files {
+		var fileInstalled, fileEnabled bool
+		if fileInstalled, fileEnabled, err = state(file.Path, id); err != nil {
+			return
+		}
+		installed = installed || fileInstalled
+		enabled = enabled || fileEnabled
+	}
+	return
+}
+
+// Toggle hides or shows the files of an item, or sets their state when enable is not nil.
+func (fm fileManager) Toggle(manifest *Manifest, id string, enable *bool) (enabled bool, err error) {
+	var (
+		files      []managedFile
+		installed  bool
+		setEnabled = fm.setEnabled
+	)
+	if setEnabled == nil {
+		setEnabled = fileSetEnabled
+	}
+	if manifest.Find(id) == nil {
+		err = errorf("item ID %q not found in manifest", id)
+		return
+	}
+	if installed, enabled, err = fm.itemState(manifest, id); err != nil {
+		return
+	}
+	if !installed {
+		err = errorf("item ID %q is not applied", id)
+		return
+	}
+	if enable != nil && *enable == enabled {
+		return
+	}
+	enabled = !enabled
+	if files, err = fm.itemFiles(manifest, id); err != nil {
+		return
+	}
+	for _, file := range files {
+		if file.Data == "" {
+			continue
+		}
+		if err = setEnabled(file.Path, id, enabled); err != nil {
+			return
+		}
+	}
+	return
+}
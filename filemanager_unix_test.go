@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package main
+
+import "os"
+
+// tamper adds a line to the files that show the item, as editing them by hand would.
+func (fm fileManager) tamper(manifest *Manifest, id string) (err error) {
+	var files []managedFile
+	if files, err = fm.itemFiles(manifest, id); err != nil {
+		return
+	}
+	for _, file := range files {
+		if file.Data != "" {
+			if err = os.WriteFile(file.Path, []byte(file.Data+"\n"), 0o644); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
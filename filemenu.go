@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// xmlNode is an element of an XML document, with lower case names and attribute names.
+type xmlNode struct {
+	Name     string
+	Attrs    map[string]string
+	Text     string
+	Children []*xmlNode
+}
+
+func readXML(data []byte) (root *xmlNode, err error) {
+	var (
+		dec   = xml.NewDecoder(bytes.NewReader(data))
+		stack = []*xmlNode{{Attrs: map[string]string{}}}
+		tok   xml.Token
+	)
+	// Exports declare encodings such as windows-1252 but are plain ASCII in practice.
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	for {
+		if tok, err = dec.Token(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &xmlNode{Name: strings.ToLower(t.Name.Local), Attrs: make(map[string]string)}
+			for _, attr := range t.Attr {
+				node.Attrs[strings.ToLower(attr.Name.Local)] = attr.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			stack[len(stack)-1].Text += string(t)
+		}
+	}
+	root = stack[0]
+	return
+}
+
+// field returns the first of names found as an attribute or a child element with text.
+func (n *xmlNode) field(names ...string) string {
+	for _, name := range names {
+		if v, ok := n.Attrs[name]; ok {
+			return strings.TrimSpace(v)
+		}
+		for _, child := range n.Children {
+			if child.Name == name && len(child.Children) == 0 {
+				return strings.TrimSpace(child.Text)
+			}
+		}
+	}
+	return ""
+}
+
+func xmlTrue(s string) bool {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "-1":
+		return true
+	}
+	return false
+}
+
+// fileMenuElementTypes maps the element types a FileMenu Tools command is shown for to targets.
+var fileMenuElementTypes = map[string]string{
+	"file":       "file",
+	"files":      "file",
+	"folder":     "directory",
+	"folders":    "directory",
+	"directory":  "directory",
+	"drive":      "drive",
+	"drives":     "drive",
+	"background": "background",
+	"desktop":    "desktop",
+}
+
+// parseFileMenuTools converts the commands of a FileMenu Tools export (XML), including its
+// submenus. Built-in actions, which have no program, are skipped.
+func parseFileMenuTools(data []byte) (items ContextMenus, skipped int, err error) {
+	var root *xmlNode
+	if root, err = readXML(data); err != nil {
+		return
+	}
+	for _, entry := range fileMenuItems(root, &skipped) {
+		var targets = entry.Menu.Targets
+		entry.Menu.Targets = nil
+		if len(targets) == 0 {
+			entry.ID = uniqueID(items, entry.ID)
+			items = append(items, entry)
+			continue
+		}
+		for _, target := range targets {
+			addImported(&items, entry.ID, entry.Menu, target)
+		}
+	}
+	return
+}
+
+// fileMenuItems converts the commands among the children of parent. Elements that are neither
+// commands, submenus nor separators are searched for them, since exports wrap them differently.
+func fileMenuItems(parent *xmlNode, skipped *int) (menus ContextMenus) {
+	var separator bool
+	for _, node := range parent.Children {
+		var (
+			kind    = strings.ToLower(node.field("type", "kind"))
+			title   = node.field("name", "caption", "menutext", "text", "title")
+			program = node.field("program", "application", "executable", "command", "path")
+			item    = &ContextMenu{Type: ContextMenuType_Item, Title: title}
+		)
+		switch {
+		case node.Name == "separator" || kind == "separator":
+			separator = true
+			continue
+		case program == "" && (strings.Contains(node.Name, "submenu") || kind == "submenu"):
+			if item.Items = fileMenuItems(node, skipped); len(item.Items) == 0 {
+				*skipped++
+				continue
+			}
+			// A submenu is shown wherever any of its commands is.
+			item.Type = ContextMenuType_Folder
+			for _, child := range item.Items {
+				for _, target := range child.Menu.Targets {
+					if !containsFold(item.Targets, target) {
+						item.Targets = append(item.Targets, target)
+					}
+				}
+				child.Menu.Targets = nil
+			}
+		case program != "":
+			item.Command = append([]string{strings.Trim(program, `"`)}, splitCommandLine(node.field("arguments", "parameters", "args"))...)
+		case title != "" && kind != "":
+			// A built-in action such as "Copy Path".
+			*skipped++
+			continue
+		default:
+			menus = append(menus, fileMenuItems(node, skipped)...)
+			continue
+		}
+		if v := node.field("visible", "enabled", "active"); v != "" && !xmlTrue(v) {
+			*skipped++
+			continue
+		}
+		if icon := node.field("icon", "iconpath", "iconfile"); icon != "" {
+			item.IconPath, item.IconIndex = splitIconLocation(icon)
+		}
+		item.Extended = xmlTrue(node.field("extended", "shift", "shiftkey"))
+		item.Admin = xmlTrue(node.field("runasadmin", "admin", "elevated", "runas"))
+		if item.Type == ContextMenuType_Item {
+			item.Targets = fileMenuTargets(node)
+		}
+		if item.Title == "" {
+			item.Title = programName(program)
+		}
+		item.SeparatorBefore, separator = separator, false
+		menus = append(menus, ContextMenuEntry{ID: uniqueID(menus, strings.NewReplacer("&", "", "/", "-", `\`, "-").Replace(item.Title)), Menu: item})
+	}
+	if separator && len(menus) > 0 {
+		menus[len(menus)-1].Menu.SeparatorAfter = true
+	}
+	return
+}
+
+// fileMenuTargets reads the element types, given as a list ("Files, Folders") or as flags
+// (Files="true"), and narrows files down to the listed extensions.
+func fileMenuTargets(node *xmlNode) (targets []string) {
+	var (
+		seen       = make(map[string]bool)
+		extensions = strings.FieldsFunc(node.field("extensions", "filetypes", "fileextensions"), func(r rune) bool { return strings.ContainsRune(";,| ", r) })
+		add        = func(target string) {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	)
+	for _, t := range strings.FieldsFunc(strings.ToLower(node.field("elementtypes", "elements", "types", "appliesto")), func(r rune) bool { return strings.ContainsRune(";,| ", r) }) {
+		if target, ok := fileMenuElementTypes[t]; ok {
+			add(target)
+		}
+	}
+	for _, name := range []string{"files", "folders", "drives", "background", "desktop"} {
+		if xmlTrue(node.field(name)) {
+			add(fileMenuElementTypes[name])
+		}
+	}
+	if len(targets) > 0 && !seen["file"] {
+		return
+	}
+	// A wildcard such as * or *.* stands for all files, and leaves the targets as they are.
+	var narrowed []string
+	for _, ext := range extensions {
+		switch ext = strings.TrimPrefix(strings.TrimPrefix(ext, "*"), "."); ext {
+		case "", "*":
+			return
+		default:
+			narrowed = append(narrowed, "."+strings.ToLower(ext))
+		}
+	}
+	if len(narrowed) == 0 {
+		return
+	}
+	var rest []string
+	for _, target := range targets {
+		if target != "file" {
+			rest = append(rest, target)
+		}
+	}
+	targets = rest
+	for _, ext := range narrowed {
+		add(ext)
+	}
+	return
+}
+
+func programName(path string) string {
+	path = strings.Trim(path, `"`)
+	if i := strings.LastIndexAny(path, `\/`); i >= 0 {
+		path = path[i+1:]
+	}
+	return strings.TrimSuffix(path, ".exe")
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileMenuTargets(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		xml  string
+		want []string
+	}{
+		{name: "list", xml: `<Command ElementTypes="Files, Folders; Drives"/>`, want: []string{"file", "directory", "drive"}},
+		{name: "flags", xml: `<Command><Files>true</Files><Background>1</Background><Drives>false</Drives></Command>`, want: []string{"file", "background"}},
+		{name: "extensions", xml: `<Command ElementTypes="Files,Folders" Extensions="*.TXT;md; .log"/>`, want: []string{"directory", ".txt", ".md", ".log"}},
+		{name: "extensions without types", xml: `<Command Extensions="txt,txt"/>`, want: []string{".txt"}},
+		{name: "extensions of no files", xml: `<Command ElementTypes="Folders" Extensions="txt"/>`, want: []string{"directory"}},
+		{name: "all files", xml: `<Command ElementTypes="Files" Extensions="*.*"/>`, want: []string{"file"}},
+		{name: "all files among extensions", xml: `<Command ElementTypes="Files" Extensions="*.txt;*"/>`, want: []string{"file"}},
+		{name: "none", xml: `<Command/>`},
+	} {
+		root, err := readXML([]byte(test.xml))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fileMenuTargets(root.Children[0]); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: targets %q, expected %q", test.name, got, test.want)
+		}
+	}
+}
+
+// TestParseFileMenuTools checks the items made of a FileMenu Tools export, whichever way it wraps
+// its commands, and that built-in actions and disabled commands are skipped.
+func TestParseFileMenuTools(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		xml         string
+		want        string
+		wantSkipped int
+	}{
+		{
+			name: "commands",
+			xml: `<?xml version="1.0" encoding="windows-1252"?>
+				<FileMenuTools><Commands>
+					<Command Name="Open with &amp;Code" Program="&quot;C:\Code\code.exe&quot;" Arguments="&quot;%1&quot;" Icon="C:\Code\code.exe,0" ElementTypes="Files,Folders" Extended="true"/>
+					<Command Name="Copy Path" Type="CopyPath"/>
+					<Separator/>
+					<Command>
+						<Name>Hash</Name>
+						<Program>certutil.exe</Program>
+						<Arguments>-hashfile "%1"</Arguments>
+						<RunAsAdmin>1</RunAsAdmin>
+						<Extensions>iso;img</Extensions>
+					</Command>
+					<Command Name="Disabled" Program="x.exe" Enabled="false"/>
+					<Command Program="C:\Tools\tool.exe"/>
+				</Commands></FileMenuTools>`,
+			want: `{
+				"Open with Code": {"type": "item", "title": "Open with &Code", "iconPath": "C:\\Code\\code.exe", "iconIndex": 0, "extended": true, "command": ["C:\\Code\\code.exe", "%1"], "targets": ["file", "directory"]},
+				"Hash": {"type": "item", "title": "Hash", "admin": true, "separatorBefore": true, "command": ["certutil.exe", "-hashfile", "%1"], "targets": [".iso", ".img"]},
+				"tool": {"type": "item", "title": "tool", "command": ["C:\\Tools\\tool.exe"]}
+			}`,
+			wantSkipped: 2,
+		},
+		{
+			name: "submenu",
+			xml: `<FileMenuTools>
+					<SubMenu Name="Tools">
+						<Command Name="Edit" Program="notepad.exe" Arguments="%1" ElementTypes="Files"/>
+						<Command Name="Here" Program="cmd.exe" ElementTypes="Background,Files"/>
+						<Separator/>
+					</SubMenu>
+					<SubMenu Name="Empty"><Command Name="Copy Name" Type="CopyName"/></SubMenu>
+				</FileMenuTools>`,
+			want: `{"Tools": {"type": "folder", "title": "Tools", "targets": ["file", "background"], "items": {
+				"Edit": {"type": "item", "title": "Edit", "command": ["notepad.exe", "%1"]},
+				"Here": {"type": "item", "title": "Here", "separatorAfter": true, "command": ["cmd.exe"]}
+			}}}`,
+			wantSkipped: 2,
+		},
+		{
+			name: "same command for several types",
+			xml: `<Commands>
+					<Command Name="Open" Program="open.exe" ElementTypes="Files"/>
+					<Command Name="Open" Program="open.exe" ElementTypes="Drives"/>
+				</Commands>`,
+			want: `{"Open": {"type": "item", "title": "Open", "command": ["open.exe"], "targets": ["file", "drive"]}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			items, skipped, err := parseFileMenuTools([]byte(test.xml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := testMenus(t, test.want); !sameMenus(t, items, want) {
+				got, _ := marshalJSON(items, "")
+				t.Errorf("imported %s, expected %s", got, test.want)
+			}
+			if skipped != test.wantSkipped {
+				t.Errorf("skipped %d commands, expected %d", skipped, test.wantSkipped)
+			}
+		})
+	}
+}
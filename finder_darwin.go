@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Finder is the only file manager on macOS, whatever fileManager says.
+var finder = fileManager{plan: planFinder, state: finderState, setEnabled: finderSetEnabled, refresh: refreshServices}
+
+func currentFileManager() fileManager {
+	return finder
+}
+
+// planFinder renders each item into a Quick Action, an Automator workflow in ~/Library/Services
+// that runs the command through a Run Shell Script action. Finder lists Quick Actions by title
+// without submenus, so items in folders are titled after their folders as well. Quick Actions
+// work on selected files and folders only, have no separators and show no custom icons.
+func planFinder(manifest *Manifest, manifestDir string) (files []managedFile, err error) {
+	var (
+		dir   string
+		names = make(map[string]bool)
+		plan  func(prefix, title string, menus ContextMenus, targets []string)
+	)
+	if dir, err = os.UserHomeDir(); err != nil {
+		err = errorf("failed to locate the home folder: %w", err)
+		return
+	}
+	dir = filepath.Join(dir, "Library", "Services")
+	plan = func(prefix, title string, menus ContextMenus, targets []string) {
+		for _, entry := range menus {
+			var (
+				item      = entry.Menu
+				id        = prefix + entry.ID
+				itemTitle = plainTitle(item.Title)
+			)
+			if prefix == "" {
+				targets = manifest.Targets(id)
+			}
+			if title != "" {
+				itemTitle = title + " › " + itemTitle
+			}
+			if item.Type == ContextMenuType_Folder {
+				plan(id+"/", itemTitle, item.Items, targets)
+				continue
+			}
+			name := strings.ReplaceAll(itemTitle, "/", "-")
+			if name == "" || names[strings.ToLower(name)] {
+				name = strings.TrimSpace(name + " (" + entry.ID + ")")
+			}
+			for base, n := name, 2; names[strings.ToLower(name)]; n++ {
+				name = fmt.Sprintf("%s %d", base, n)
+			}
+			names[strings.ToLower(name)] = true
+			bundle := filepath.Join(dir, name+".workflow")
+			files = append(files,
+				managedFile{ID: id, Path: bundle},
+				managedFile{ID: id, Path: filepath.Join(bundle, "Contents", "Info.plist"), Data: finderInfo(itemTitle, targets)},
+				managedFile{ID: id, Path: filepath.Join(bundle, "Contents", "document.wflow"), Data: finderWorkflow(id, selectionScript(item, targets, manifestDir))},
+			)
+		}
+	}
+	plan("", "", manifest.Items, nil)
+	return
+}
+
+func plistEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// finderInfo declares the Quick Action as a service of Finder for the kinds of items it targets.
+// Extensions have no type of their own here; the script skips other files.
+func finderInfo(title string, targets []string) string {
+	var fileType = "public.folder"
+	for _, target := range targets {
+		if target = strings.ToLower(target); target == "file" || strings.HasPrefix(target, ".") {
+			fileType = "public.item"
+		}
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>NSServices</key>
+	<array>
+		<dict>
+			<key>NSMenuItem</key>
+			<dict>
+				<key>default</key>
+				<string>%s</string>
+			</dict>
+			<key>NSMessage</key>
+			<string>runWorkflowAsService</string>
+			<key>NSRequiredContext</key>
+			<dict>
+				<key>NSApplicationIdentifier</key>
+				<string>com.apple.finder</string>
+			</dict>
+			<key>NSSendFileTypes</key>
+			<array>
+				<string>%s</string>
+			</array>
+		</dict>
+	</array>
+</dict>
+</plist>
+`, plistEscape(title), fileType)
+}
+
+// finderUUID derives the UUIDs Automator keeps in a workflow from the item ID, so that applying
+// again writes the same file.
+func finderUUID(id, name string) string {
+	var sum = sha1.Sum([]byte(id + "\x00" + name))
+	return fmt.Sprintf("%X-%X-%X-%X-%X", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// finderWorkflow is a workflow with a single Run Shell Script action, which gets the selected
+// files as arguments.
+func finderWorkflow(id, script string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>AMApplicationBuild</key>
+	<string>523</string>
+	<key>AMApplicationVersion</key>
+	<string>2.10</string>
+	<key>AMDocumentVersion</key>
+	<string>2</string>
+	<key>actions</key>
+	<array>
+		<dict>
+			<key>action</key>
+			<dict>
+				<key>AMAccepts</key>
+				<dict>
+					<key>Container</key>
+					<string>List</string>
+					<key>Optional</key>
+					<true/>
+					<key>Types</key>
+					<array>
+						<string>com.apple.cocoa.string</string>
+					</array>
+				</dict>
+				<key>AMActionVersion</key>
+				<string>2.0.3</string>
+				<key>AMApplication</key>
+				<array>
+					<string>Automator</string>
+				</array>
+				<key>AMProvides</key>
+				<dict>
+					<key>Container</key>
+					<string>List</string>
+					<key>Types</key>
+					<array>
+						<string>com.apple.cocoa.string</string>
+					</array>
+				</dict>
+				<key>ActionBundlePath</key>
+				<string>/System/Library/Automator/Run Shell Script.action</string>
+				<key>ActionName</key>
+				<string>Run Shell Script</string>
+				<key>ActionParameters</key>
+				<dict>
+					<key>COMMAND_STRING</key>
+					<string>%s</string>
+					<key>CheckedForUserDefaultShell</key>
+					<true/>
+					<key>inputMethod</key>
+					<integer>1</integer>
+					<key>shell</key>
+					<string>/bin/sh</string>
+					<key>source</key>
+					<string></string>
+				</dict>
+				<key>BundleIdentifier</key>
+				<string>com.apple.RunShellScript</string>
+				<key>CFBundleVersion</key>
+				<string>2.0.3</string>
+				<key>CanShowSelectedItemsWhenRun</key>
+				<false/>
+				<key>CanShowWhenRun</key>
+				<true/>
+				<key>Category</key>
+				<array>
+					<string>AMCategoryUtilities</string>
+				</array>
+				<key>Class Name</key>
+				<string>RunShellScriptAction</string>
+				<key>InputUUID</key>
+				<string>%s</string>
+				<key>OutputUUID</key>
+				<string>%s</string>
+				<key>UUID</key>
+				<string>%s</string>
+				<key>UnlocalizedApplications</key>
+				<array>
+					<string>Automator</string>
+				</array>
+				<key>isViewVisible</key>
+				<integer>1</integer>
+			</dict>
+			<key>isViewVisible</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>connectors</key>
+	<dict/>
+	<key>workflowMetaData</key>
+	<dict>
+		<key>serviceApplicationBundleID</key>
+		<string>com.apple.finder</string>
+		<key>serviceApplicationPath</key>
+		<string>/System/Library/CoreServices/Finder.app</string>
+		<key>serviceInputTypeIdentifier</key>
+		<string>com.apple.Automator.fileSystemObject</string>
+		<key>serviceOutputTypeIdentifier</key>
+		<string>com.apple.Automator.nothing</string>
+		<key>serviceProcessesInput</key>
+		<integer>0</integer>
+		<key>workflowTypeIdentifier</key>
+		<string>com.apple.Automator.servicesMenu</string>
+	</dict>
+</dict>
+</plist>
+`, plistEscape(script), finderUUID(id, "input"), finderUUID(id, "output"), finderUUID(id, "action"))
+}
+
+func finderState(path, id string) (installed, enabled bool, err error) {
+	if _, statErr := os.Stat(path); statErr == nil {
+		installed, enabled = true, true
+	}
+	return
+}
+
+func finderSetEnabled(path, id string, enabled bool) error {
+	return errorf("Quick Actions are turned on and off in System Settings, under Extensions")
+}
+
+// refreshServices has the services menu pick up the new Quick Actions without logging out.
+func refreshServices() (err error) {
+	if err = exec.Command("/System/Library/CoreServices/pbs", "-update").Run(); err != nil {
+		err = errorf("failed to refresh the Services menu: %w", err)
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFinderConformance(t *testing.T) {
+	isolateState(t)
+	testConformance(t, finder)
+}
+
+// TestPlanFinder checks that items in folders are titled after them, and that each item gets a
+// Quick Action of its own however their titles collide.
+func TestPlanFinder(t *testing.T) {
+	isolateState(t)
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.Targets = []string{"directory"}
+	var (
+		dir      = filepath.Join(os.Getenv("HOME"), "Library", "Services")
+		manifest = &Manifest{Items: testMenus(t, `{
+			"c": {"type": "item", "title": "Run (b)", "command": ["c"]},
+			"a": {"type": "item", "title": "&Run", "command": ["a"]},
+			"b": {"type": "item", "title": "run", "command": ["b"]},
+			"tools": {"type": "folder", "title": "Tools/More", "items": {"hash": {"type": "item", "title": "Hash", "command": ["shasum", "%V"]}}}
+		}`)}
+		got  []string
+		want = []string{"Run (b)", "Run", "run (b) 2", "Tools-More › Hash"}
+	)
+	files, err := planFinder(manifest, "/menus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if filepath.Dir(file.Path) == dir {
+			got = append(got, strings.TrimSuffix(filepath.Base(file.Path), ".workflow"))
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Quick Actions are %q, expected %q", got, want)
+	}
+}
+
+func TestFinderInfo(t *testing.T) {
+	for _, test := range []struct {
+		targets []string
+		want    string
+	}{
+		{targets: []string{"directory", "background"}, want: "public.folder"},
+		{targets: []string{"directory", ".TXT"}, want: "public.item"},
+		{targets: []string{"file"}, want: "public.item"},
+	} {
+		var info = finderInfo("Copy <path> & more", test.targets)
+		if !strings.Contains(info, "<string>"+test.want+"</string>") {
+			t.Errorf("Info.plist for %q does not send %s:\n%s", test.targets, test.want, info)
+		}
+		if !strings.Contains(info, "<string>Copy &lt;path&gt; &amp; more</string>") {
+			t.Errorf("Info.plist has the title unescaped:\n%s", info)
+		}
+	}
+}
+
+// TestFinderWorkflow checks that a workflow is a property list that gives the script back as it
+// is, with UUIDs that are the same each time it is written.
+func TestFinderWorkflow(t *testing.T) {
+	var (
+		script   = `for f in "$@"; do 'a' "$f" && 'b' <"$f" & done`
+		workflow = finderWorkflow("tools/a", script)
+		values   []string
+		decoder  = xml.NewDecoder(strings.NewReader(workflow))
+		inString bool
+	)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			inString = token.Name.Local == "string"
+		case xml.CharData:
+			if inString {
+				values = append(values, string(token))
+			}
+		case xml.EndElement:
+			inString = false
+		}
+	}
+	var found bool
+	for _, s := range values {
+		found = found || s == script
+	}
+	if !found {
+		t.Errorf("workflow does not hold the script %q:\n%s", script, workflow)
+	}
+	if workflow != finderWorkflow("tools/a", script) {
+		t.Error("workflow changes each time it is written")
+	}
+	if a, b := finderUUID("tools/a", "input"), finderUUID("tools/a", "output"); a == b || len(a) != 36 {
+		t.Errorf("UUIDs are %s and %s", a, b)
+	}
+}
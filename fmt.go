@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runFmt rewrites a manifest in its canonical form: fields in a fixed order, four space indents,
+// backslashes in paths, trimmed titles and sorted targets. Only the form changes, never the menus.
+func runFmt(args []string) (err error) {
+	var (
+		flags        = newFlagSet("fmt")
+		manifestPath = flags.String("manifest", "", "manifest to format (default: the manifest found by apply)")
+		check        = flags.Bool("check", false, "fail if the manifest is not formatted instead of rewriting it")
+		showDiff     = flags.Bool("diff", false, "show the changes instead of rewriting the manifest")
+		manifest     *Manifest
+		before       []byte
+		after        []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if *manifestPath == "" {
+		if *manifestPath, err = findManifest(); err != nil {
+			return
+		}
+	}
+	if manifest, err = readManifest(*manifestPath); err != nil {
+		return
+	}
+	if before, err = os.ReadFile(*manifestPath); err != nil {
+		err = errorf("failed to read manifest.json: %w", err)
+		return
+	}
+	formatItems(manifest.Items)
+	if after, err = encodeManifest(*manifestPath, manifest); err != nil {
+		return
+	}
+	if string(decodeText(before)) == string(after) {
+		return
+	}
+	switch {
+	case *showDiff:
+		fmt.Print(unifiedDiff(*manifestPath, string(decodeText(before)), string(after)))
+	case *check:
+		err = errorf("%s is not formatted, run \"context-menu-manager fmt\"", *manifestPath)
+	default:
+		if err = os.WriteFile(*manifestPath, after, 0o644); err != nil {
+			err = errorf("failed to write manifest.json: %w", err)
+			return
+		}
+		fmt.Printf(tr("Formatted %s.\n"), *manifestPath)
+	}
+	return
+}
+
+func formatItems(items ContextMenus) {
+	for _, entry := range items {
+		var item = entry.Menu
+		item.Title = normalizeTitle(item.Title)
+		// Paths are normalized for Windows, and left alone where given for other platforms.
+		item.setIconPathOn("windows", normalizePath(item.iconPathOn("windows")))
+		fallbacks := item.iconFallbacksOn("windows")
+		for i := range fallbacks {
+			fallbacks[i] = normalizePath(fallbacks[i])
+		}
+		if command := item.commandOn("windows"); len(command) > 0 {
+			command[0] = normalizePath(command[0])
+		}
+		item.Targets = sortTargets(item.Targets)
+		formatItems(item.Items)
+	}
+}
+
+// normalizeTitle trims a title and collapses runs of spaces and line breaks in it, which menus show
+// as they are, and drops byte order marks that editors leave behind.
+func normalizeTitle(title string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(title, "\uFEFF", "")), " ")
+}
+
+// normalizePath uses backslashes and drops repeated ones, keeping the two that start a UNC path.
+// Arguments are left alone, since a slash there is as likely to be an option as a path, and so are
+// URLs, whose scheme is longer than a drive letter.
+func normalizePath(path string) string {
+	if strings.Index(path, "://") > 1 {
+		return path
+	}
+	var (
+		prefix string
+		rest   = strings.ReplaceAll(path, "/", `\`)
+	)
+	if strings.HasPrefix(rest, `\\`) {
+		prefix, rest = `\\`, strings.TrimLeft(rest, `\`)
+	}
+	for strings.Contains(rest, `\\`) {
+		rest = strings.ReplaceAll(rest, `\\`, `\`)
+	}
+	return prefix + rest
+}
+
+// sortTargets lists the named targets, then the extensions, each alphabetically and without
+// duplicates. Extensions are matched case-insensitively, so they are lowercased.
+func sortTargets(targets []string) (sorted []string) {
+	var seen = make(map[string]bool)
+	for _, target := range targets {
+		if strings.HasPrefix(target, ".") {
+			target = strings.ToLower(target)
+		}
+		if !seen[target] {
+			seen[target] = true
+			sorted = append(sorted, target)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if ei, ej := strings.HasPrefix(sorted[i], "."), strings.HasPrefix(sorted[j], "."); ei != ej {
+			return ej
+		}
+		return sorted[i] < sorted[j]
+	})
+	return
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		want  string
+	}{
+		{title: "Open Terminal", want: "Open Terminal"},
+		{title: "  Open   Terminal \n", want: "Open Terminal"},
+		{title: "Open\r\nTerminal", want: "Open Terminal"},
+		{title: "\uFEFFOpen", want: "Open"},
+		{title: "打开 终端", want: "打开 终端"},
+		{title: "", want: ""},
+	} {
+		if got := normalizeTitle(test.title); got != test.want {
+			t.Errorf("normalizeTitle(%q) = %q, expected %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{path: `C:\Program Files\App\app.exe`, want: `C:\Program Files\App\app.exe`},
+		{path: "C:/Program Files/App/app.exe", want: `C:\Program Files\App\app.exe`},
+		{path: `C:\\Tools\\\app.exe`, want: `C:\Tools\app.exe`},
+		{path: "${manifestFolder}/scripts/run.cmd", want: `${manifestFolder}\scripts\run.cmd`},
+		{path: `\\server\share\\tool.exe`, want: `\\server\share\tool.exe`},
+		{path: "//server/share/tool.exe", want: `\\server\share\tool.exe`},
+		{path: `\\\server\share`, want: `\\server\share`},
+		{path: `\\?\C:\long\path.exe`, want: `\\?\C:\long\path.exe`},
+		{path: "https://example.com/icon.ico", want: "https://example.com/icon.ico"},
+		{path: "C://Tools/app.exe", want: `C:\Tools\app.exe`},
+		{path: "notepad.exe", want: "notepad.exe"},
+		{path: "", want: ""},
+	} {
+		if got := normalizePath(test.path); got != test.want {
+			t.Errorf("normalizePath(%q) = %q, expected %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestSortTargets(t *testing.T) {
+	for _, test := range []struct {
+		targets []string
+		want    []string
+	}{
+		{targets: nil, want: nil},
+		{targets: []string{"directory", "background", "*"}, want: []string{"*", "background", "directory"}},
+		{targets: []string{".TXT", "directory", ".md", ".txt"}, want: []string{"directory", ".md", ".txt"}},
+		{targets: []string{"background", "background"}, want: []string{"background"}},
+	} {
+		if got := sortTargets(test.targets); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("sortTargets(%q) = %q, expected %q", test.targets, got, test.want)
+		}
+	}
+}
+
+// TestFormatItems checks that formatting reaches the items of folders and the Windows variants of
+// commands and icons, and leaves arguments and the paths given for other platforms alone.
+func TestFormatItems(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		items string
+		want  string
+	}{
+		{
+			name:  "item",
+			items: `{"a": {"title": " Open  here ", "command": ["C:/Tools/app.exe", "/d", "%V"], "iconPath": "C:/Tools/app.ico", "targets": [".TXT", "directory"]}}`,
+			want:  `{"a": {"title": "Open here", "command": ["C:\\Tools\\app.exe", "/d", "%V"], "iconPath": "C:\\Tools\\app.ico", "targets": ["directory", ".txt"]}}`,
+		},
+		{
+			name:  "in a folder",
+			items: `{"f": {"type": "folder", "title": "F", "items": {"x": {"title": "X\n", "command": ["C:/x.exe"]}}}}`,
+			want:  `{"f": {"type": "folder", "title": "F", "items": {"x": {"title": "X", "command": ["C:\\x.exe"]}}}}`,
+		},
+		{
+			name:  "per platform",
+			items: `{"a": {"title": "A", "command": {"windows": ["C:/a.exe"], "linux": ["/usr/bin/a"]}, "iconPath": {"windows": ["C:/a.ico", "C://b.ico"], "linux": "/usr/share/a.png"}}}`,
+			want:  `{"a": {"title": "A", "command": {"windows": ["C:\\a.exe"], "linux": ["/usr/bin/a"]}, "iconPath": {"windows": ["C:\\a.ico", "C:\\b.ico"], "linux": "/usr/share/a.png"}}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			items := testMenus(t, test.items)
+			formatItems(items)
+			if want := testMenus(t, test.want); !sameMenus(t, items, want) {
+				got, _ := marshalJSON(items, "")
+				t.Errorf("formatted into %s, expected %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// manifestFilenames are looked for in this order. The extension of a manifest selects its format,
+// which is converted to and from JSON with the order of keys kept, so items stay in menu order.
+var manifestFilenames = []string{"manifest.json", "manifest.yaml", "manifest.yml", "manifest.toml"}
+
+func manifestFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// toJSON converts a YAML or TOML document to JSON.
+func toJSON(format string, data []byte) (jsonData []byte, err error) {
+	var buf bytes.Buffer
+	switch format {
+	case "yaml":
+		var node yaml.Node
+		if err = yaml.Unmarshal(data, &node); err != nil {
+			return
+		}
+		err = yamlNodeJSON(&buf, &node, 0)
+	case "toml":
+		var (
+			v     map[string]interface{}
+			md    toml.MetaData
+			order = make(map[string]int)
+		)
+		if md, err = toml.Decode(string(data), &v); err != nil {
+			return
+		}
+		for i, key := range md.Keys() {
+			if _, ok := order[strings.Join(key, "\x00")]; !ok {
+				order[strings.Join(key, "\x00")] = i
+			}
+		}
+		err = tomlValueJSON(&buf, v, nil, order)
+	default:
+		return data, nil
+	}
+	jsonData = buf.Bytes()
+	return
+}
+
+// hasComments tells whether a YAML or TOML document has comments, which writing the manifest back
+// would drop.
+func hasComments(format string, data []byte) bool {
+	switch format {
+	case "yaml":
+		var node yaml.Node
+		return yaml.Unmarshal(data, &node) == nil && yamlNodeHasComments(&node)
+	case "toml":
+		for i := 0; i < len(data); i++ {
+			switch c := data[i]; c {
+			case '#':
+				return true
+			case '"', '\'':
+				// Skip strings, in which "#" is just a character. Only basic strings have escapes.
+				var quote = data[i : i+1]
+				if bytes.HasPrefix(data[i:], bytes.Repeat(quote, 3)) {
+					quote = data[i : i+3]
+				}
+				for i += len(quote); i < len(data) && !bytes.HasPrefix(data[i:], quote); i++ {
+					if c == '"' && data[i] == '\\' {
+						i++
+					}
+				}
+				i += len(quote) - 1
+			}
+		}
+	}
+	return false
+}
+
+func yamlNodeHasComments(node *yaml.Node) bool {
+	if node.HeadComment != "" || node.LineComment != "" || node.FootComment != "" {
+		return true
+	}
+	for _, child := range node.Content {
+		if yamlNodeHasComments(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlNodeJSON writes node as JSON, expanding aliases. depth counts the mappings and sequences
+// node is in, which an alias to one of them would otherwise nest without end.
+func yamlNodeJSON(buf *bytes.Buffer, node *yaml.Node, depth int) (err error) {
+	if buf.Len() > maxManifestSize {
+		// Aliases of aliases grow exponentially as they are expanded.
+		return errorf("manifest is more than %d bytes once its aliases are expanded", maxManifestSize)
+	}
+	if depth > maxManifestDepth {
+		return errorf("manifest nests more than %d levels deep at line %d", maxManifestDepth, node.Line)
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("null")
+			return
+		}
+		return yamlNodeJSON(buf, node.Content[0], depth)
+	case yaml.AliasNode:
+		return yamlNodeJSON(buf, node.Alias, depth)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err = yamlNodeJSON(buf, node.Content[i+1], depth+1); err != nil {
+				return
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err = yamlNodeJSON(buf, item, depth+1); err != nil {
+				return
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		var (
+			v    interface{}
+			data []byte
+		)
+		if err = node.Decode(&v); err != nil {
+			return
+		}
+		if data, err = json.Marshal(v); err != nil {
+			err = errorf("line %d: %w", node.Line, err)
+			return
+		}
+		buf.Write(data)
+	}
+	return
+}
+
+// tomlValueJSON writes v with the keys of each table in the order they appear in the document.
+// Tables in an array of tables share the path, and so the order, of their keys.
+func tomlValueJSON(buf *bytes.Buffer, v interface{}, path []string, order map[string]int) (err error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		var keys []string
+		for key := range v {
+			keys = append(keys, key)
+		}
+		position := func(key string) int {
+			if i, ok := order[strings.Join(append(path, key), "\x00")]; ok {
+				return i
+			}
+			return len(order)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			if pi, pj := position(keys[i]), position(keys[j]); pi != pj {
+				return pi < pj
+			}
+			return keys[i] < keys[j]
+		})
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			data, _ := json.Marshal(key)
+			buf.Write(data)
+			buf.WriteByte(':')
+			if err = tomlValueJSON(buf, v[key], append(path[:len(path):len(path)], key), order); err != nil {
+				return
+			}
+		}
+		buf.WriteByte('}')
+	case []map[string]interface{}:
+		var items = make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return tomlValueJSON(buf, items, path, order)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err = tomlValueJSON(buf, item, path, order); err != nil {
+				return
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		var data []byte
+		if data, err = json.Marshal(v); err != nil {
+			return
+		}
+		buf.Write(data)
+	}
+	return
+}
+
+// fromJSON renders a JSON document as YAML or TOML. JSON is valid YAML, so it is read into a
+// yaml.Node, which keeps the order of keys for either output.
+func fromJSON(format string, data []byte) (out []byte, err error) {
+	var (
+		node yaml.Node
+		buf  bytes.Buffer
+	)
+	if format == "json" {
+		return data, nil
+	}
+	if err = yaml.Unmarshal(data, &node); err != nil {
+		return
+	}
+	switch format {
+	case "yaml":
+		var enc = yaml.NewEncoder(&buf)
+		yamlBlockStyle(&node)
+		enc.SetIndent(2)
+		if err = enc.Encode(&node); err != nil {
+			return
+		}
+		err = enc.Close()
+	case "toml":
+		if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+			err = errorf("a TOML document must be a table")
+			return
+		}
+		tomlTable(&buf, nil, node.Content[0])
+	default:
+		err = errorf("unknown format %q, expected %q, %q or %q", format, "json", "yaml", "toml")
+		return
+	}
+	out = buf.Bytes()
+	return
+}
+
+// yamlBlockStyle drops the flow style and quoting that come from JSON, leaving them to the encoder.
+func yamlBlockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		yamlBlockStyle(child)
+	}
+}
+
+// tomlTable writes the values of a mapping, then its tables and arrays of tables, which TOML
+// requires to come after them.
+func tomlTable(buf *bytes.Buffer, path []string, node *yaml.Node) {
+	var (
+		tables  []int
+		isTable = func(value *yaml.Node) bool {
+			if value.Kind == yaml.MappingNode {
+				return true
+			}
+			if value.Kind != yaml.SequenceNode || len(value.Content) == 0 {
+				return false
+			}
+			for _, item := range value.Content {
+				if item.Kind != yaml.MappingNode {
+					return false
+				}
+			}
+			return true
+		}
+	)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		switch {
+		case isTable(value):
+			tables = append(tables, i)
+		case value.Tag == "!!null":
+		default:
+			fmt.Fprintf(buf, "%s = %s\n", tomlKey(key), tomlInline(value))
+		}
+	}
+	for _, i := range tables {
+		var (
+			key   = node.Content[i].Value
+			value = node.Content[i+1]
+			sub   = append(path[:len(path):len(path)], tomlKey(key))
+		)
+		if value.Kind == yaml.MappingNode {
+			// A table that only holds tables needs no header of its own.
+			var header = len(value.Content) == 0
+			for j := 1; j < len(value.Content); j += 2 {
+				header = header || !isTable(value.Content[j]) && value.Content[j].Tag != "!!null"
+			}
+			if header {
+				fmt.Fprintf(buf, "\n[%s]\n", strings.Join(sub, "."))
+			}
+			tomlTable(buf, sub, value)
+			continue
+		}
+		for _, item := range value.Content {
+			fmt.Fprintf(buf, "\n[[%s]]\n", strings.Join(sub, "."))
+			tomlTable(buf, sub, item)
+		}
+	}
+}
+
+func tomlInline(node *yaml.Node) string {
+	var parts []string
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag != "!!null" {
+				parts = append(parts, fmt.Sprintf("%s = %s", tomlKey(node.Content[i].Value), tomlInline(node.Content[i+1])))
+			}
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			parts = append(parts, tomlInline(item))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	switch node.Tag {
+	case "!!int", "!!float", "!!bool":
+		return node.Value
+	}
+	return tomlString(node.Value)
+}
+
+func tomlKey(key string) string {
+	if key != "" && strings.Trim(key, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-") == "" {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString prefers literal strings, in which backslashes of Windows paths need no escaping.
+func tomlString(s string) string {
+	if !strings.ContainsAny(s, "'\r\n\t") && strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f }) < 0 {
+		return "'" + s + "'"
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package main
+
+import "testing"
+
+func TestHasComments(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		data   string
+		want   bool
+	}{
+		{"yaml", "items: []\n", false},
+		{"yaml", "# team menus\nitems: []\n", true},
+		{"yaml", "items:\n  - id: a # the first\n", true},
+		{"yaml", "items:\n  - id: a\n    title: \"C# tools\"\n", false},
+		{"toml", "schemaVersion = 2\n", false},
+		{"toml", "# team menus\nschemaVersion = 2\n", true},
+		{"toml", "[[items]]\nid = \"a\" # the first\n", true},
+		{"toml", "[[items]]\ntitle = \"C# \\\"tools\\\"\"\n", false},
+		{"toml", "[[items]]\ntitle = 'C#'\ncommand = ['''#''', \"\"\"\n#\n\"\"\"]\n", false},
+		{"toml", "[[items]]\ntitle = \"\"\ncommand = [] # none\n", true},
+	} {
+		if got := hasComments(test.format, []byte(test.data)); got != test.want {
+			t.Errorf("hasComments(%s, %q) = %v, expected %v", test.format, test.data, got, test.want)
+		}
+	}
+}
+
+func TestTomlString(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{s: `C:\Tools\app.exe`, want: `'C:\Tools\app.exe'`},
+		{s: "", want: "''"},
+		{s: "it's", want: `"it's"`},
+		{s: `say "hi" in C:\`, want: `'say "hi" in C:\'`},
+		{s: "it's \"C:\\\"\n\t\x01", want: `"it's \"C:\\\"\n\t\u0001"`},
+	} {
+		if got := tomlString(test.s); got != test.want {
+			t.Errorf("tomlString(%q) = %s, expected %s", test.s, got, test.want)
+		}
+	}
+}
+
+// TestConvertFormats checks the YAML and TOML a manifest is written as, and that reading them
+// back gives the same JSON, with keys in the same order.
+func TestConvertFormats(t *testing.T) {
+	const manifest = `{"$schema":"manifest.schema.json","schemaVersion":2,"items":[` +
+		`{"id":"terminal","type":"item","title":"Open: here","command":["wt.exe","-d","%V"],"targets":["background"],"iconIndex":0},` +
+		`{"id":"tools","type":"folder","title":"yes","items":[{"id":"hash","type":"item","title":"123","command":["C:\\Tools\\hash.exe","it's"],"admin":true}]}` +
+		`]}`
+	for _, test := range []struct {
+		name     string
+		format   string
+		manifest string
+		want     string
+	}{
+		{
+			name:     "yaml",
+			format:   "yaml",
+			manifest: manifest,
+			want: `$schema: manifest.schema.json
+schemaVersion: 2
+items:
+  - id: terminal
+    type: item
+    title: 'Open: here'
+    command:
+      - wt.exe
+      - -d
+      - '%V'
+    targets:
+      - background
+    iconIndex: 0
+  - id: tools
+    type: folder
+    title: yes
+    items:
+      - id: hash
+        type: item
+        title: "123"
+        command:
+          - C:\Tools\hash.exe
+          - it's
+        admin: true
+`,
+		},
+		{
+			name:     "toml",
+			format:   "toml",
+			manifest: manifest,
+			want: `'$schema' = 'manifest.schema.json'
+schemaVersion = 2
+
+[[items]]
+id = 'terminal'
+type = 'item'
+title = 'Open: here'
+command = ['wt.exe', '-d', '%V']
+targets = ['background']
+iconIndex = 0
+
+[[items]]
+id = 'tools'
+type = 'folder'
+title = 'yes'
+
+[[items.items]]
+id = 'hash'
+type = 'item'
+title = '123'
+command = ['C:\Tools\hash.exe', "it's"]
+admin = true
+`,
+		},
+		{
+			name:     "toml with items by ID",
+			format:   "toml",
+			manifest: `{"items":{"tools":{"type":"folder","title":"Tools","items":{"a.b":{"type":"item","title":"A","command":[]}}}}}`,
+			want: `
+[items.tools]
+type = 'folder'
+title = 'Tools'
+
+[items.tools.items.'a.b']
+type = 'item'
+title = 'A'
+command = []
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := fromJSON(test.format, []byte(test.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("wrote\n%s\nexpected\n%s", data, test.want)
+			}
+			if data, err = toJSON(test.format, data); err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.manifest {
+				t.Errorf("read back\n%s\nexpected\n%s", data, test.manifest)
+			}
+		})
+	}
+}
+
+func TestYAMLAliases(t *testing.T) {
+	const yamlData = "items:\n  - &a {id: a, type: item, title: A, command: [a.exe]}\n  - *a\n"
+	data, err := toJSON("yaml", []byte(yamlData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := `{"id":"a","type":"item","title":"A","command":["a.exe"]}`
+	if want := `{"items":[` + item + "," + item + "]}"; string(data) != want {
+		t.Errorf("read %s, expected %s", data, want)
+	}
+	if _, err = toJSON("yaml", []byte("a: &a [*a]\n")); err == nil {
+		t.Error("read an alias that refers to itself")
+	}
+}
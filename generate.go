@@ -0,0 +1,590 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// installFolder stands in for ${manifestFolder} while rendering, and is replaced by the target
+// format's own variable for the installation folder after escaping. It has a space, so that
+// commands using it are quoted for installation folders such as Program Files.
+const installFolder = "\x00install folder\x00"
+
+type generator struct {
+	Name        string
+	Description string
+	Run         func(args []string) error
+}
+
+var generators = []generator{
+	{"installer-script", "Inno Setup [Registry] entries or NSIS sections", runGenerateInstallerScript},
+	{"wix", "WiX fragment with a component holding the registry keys", runGenerateWix},
+	{"dsc", "winget configure document or PowerShell DSC configuration", runGenerateDSC},
+	{"gpo", "Group Policy Preferences Registry XML or registry.pol", runGenerateGPO},
+	{"editors", "Open folder in ... items for the editors installed here", runGenerateEditors},
+}
+
+// plannedItem holds the keys written for one item and target, the first being the item's own key,
+// which is deleted to remove it.
+type plannedItem struct {
+	Keys []RegistryKey
+}
+
+func (p plannedItem) Root() string {
+	return p.Keys[0].Path
+}
+
+func runGenerate(args []string) (err error) {
+	if len(args) > 0 {
+		for _, g := range generators {
+			if g.Name == args[0] {
+				err = g.Run(args[1:])
+				return
+			}
+		}
+	}
+	fmt.Fprint(os.Stderr, tr("Usage: context-menu-manager generate KIND [options]\n\nKinds:\n"))
+	for _, g := range generators {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", g.Name, tr(g.Description))
+	}
+	if len(args) == 0 {
+		err = errorf("generate expects a kind")
+	} else {
+		err = errorf("unknown kind %q", args[0])
+	}
+	return
+}
+
+// planManifest renders every item for each of its targets, with ${manifestFolder} left as installFolder.
+func planManifest(manifest *Manifest) (items []plannedItem, err error) {
+	var (
+		keys  []RegistryKey
+		admin func(menus ContextMenus) bool
+	)
+	admin = func(menus ContextMenus) bool {
+		for _, entry := range menus {
+			if entry.Menu.Admin || admin(entry.Menu.Items) {
+				return true
+			}
+		}
+		return false
+	}
+	if usersSubkey != "" {
+		err = errorf("--user does not apply to generated output")
+		return
+	}
+	if admin(manifest.Items) {
+		helper := "nircmd.exe"
+		if config.Elevation == ElevationBackend_RunAs {
+			helper = "context-menu-manager.exe"
+		}
+		logf(LogLevel_Warn, "admin items refer to %s at its path on this machine, which the target machines need as well", helper)
+	}
+	for _, entry := range manifest.Items {
+		for _, target := range manifest.RegistryTargets(entry.ID) {
+			if keys, err = planTarget(target, entry.ID, entry.Menu, installFolder); err != nil {
+				err = errorf("failed to plan context menu ID %q: %w", entry.ID, err)
+				return
+			}
+			items = append(items, plannedItem{Keys: keys})
+		}
+	}
+	return
+}
+
+// writeOutput writes to path, or to standard output when path is empty.
+func writeOutput(path string, data string) (err error) {
+	if path == "" {
+		_, err = fmt.Print(data)
+		return
+	}
+	if err = os.WriteFile(path, []byte(data), 0o644); err != nil {
+		err = errorf("failed to write %s: %w", path, err)
+	}
+	return
+}
+
+func runGenerateInstallerScript(args []string) (err error) {
+	var (
+		flags    = newFlagSet("generate installer-script")
+		format   = flags.String("format", "inno", `"inno" or "nsis"`)
+		output   = flags.String("output", "", "file to write (default: standard output)")
+		manifest *Manifest
+		items    []plannedItem
+		script   string
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if manifest, _, err = loadManifest(); err != nil {
+		return
+	}
+	if items, err = planManifest(manifest); err != nil {
+		return
+	}
+	switch *format {
+	case "inno":
+		script = innoScript(items)
+	case "nsis":
+		script = nsisScript(items)
+	default:
+		err = errorf("unknown format %q, expected %q or %q", *format, "inno", "nsis")
+		return
+	}
+	if !isASCII(script) {
+		// Inno Setup and NSIS read scripts in the ANSI code page unless they start with a BOM,
+		// which would garble titles in other scripts.
+		script = "\uFEFF" + script
+	}
+	err = writeOutput(*output, script)
+	return
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// innoScript writes a [Registry] section. The item keys are replaced on install and removed on
+// uninstall, like apply and prune do.
+func innoScript(items []plannedItem) string {
+	var (
+		b     strings.Builder
+		quote = func(s string) string {
+			s = strings.NewReplacer(`"`, `""`, "{", "{{").Replace(s)
+			return `"` + strings.ReplaceAll(s, installFolder, "{app}") + `"`
+		}
+		root = config.Hive.String()
+	)
+	b.WriteString("[Registry]\n")
+	for _, item := range items {
+		for i, key := range item.Keys {
+			if i == 0 {
+				fmt.Fprintf(&b, "Root: %s; Subkey: %s; Flags: deletekey uninsdeletekey\n", root, quote(key.Path))
+			} else if len(key.Values) == 0 {
+				fmt.Fprintf(&b, "Root: %s; Subkey: %s\n", root, quote(key.Path))
+			}
+			for _, value := range key.Values {
+				valueType := "string"
+				if value.Type == RegistryValueType_ExpandString {
+					valueType = "expandsz"
+				}
+				fmt.Fprintf(&b, "Root: %s; Subkey: %s; ValueType: %s; ValueName: %s; ValueData: %s\n", root, quote(key.Path), valueType, quote(value.Name), quote(value.Data))
+			}
+		}
+	}
+	return b.String()
+}
+
+// nsisScript writes an install section and the matching uninstall section.
+func nsisScript(items []plannedItem) string {
+	var (
+		b     strings.Builder
+		quote = func(s string) string {
+			s = strings.NewReplacer("$", "$$", `"`, `$\"`).Replace(s)
+			return `"` + strings.ReplaceAll(s, installFolder, "$INSTDIR") + `"`
+		}
+		root = config.Hive.String()
+	)
+	b.WriteString("Section \"Context menus\"\n")
+	for _, item := range items {
+		fmt.Fprintf(&b, "  DeleteRegKey %s %s\n", root, quote(item.Root()))
+		for _, key := range item.Keys {
+			if len(key.Values) == 0 {
+				fmt.Fprintf(&b, "  WriteRegStr %s %s \"\" \"\"\n", root, quote(key.Path))
+			}
+			for _, value := range key.Values {
+				instruction := "WriteRegStr"
+				if value.Type == RegistryValueType_ExpandString {
+					instruction = "WriteRegExpandStr"
+				}
+				fmt.Fprintf(&b, "  %s %s %s %s %s\n", instruction, root, quote(key.Path), quote(value.Name), quote(value.Data))
+			}
+		}
+	}
+	b.WriteString("SectionEnd\n\nSection \"Uninstall\"\n")
+	for _, item := range items {
+		fmt.Fprintf(&b, "  DeleteRegKey %s %s\n", root, quote(item.Root()))
+	}
+	b.WriteString("SectionEnd\n")
+	return b.String()
+}
+
+func runGenerateWix(args []string) (err error) {
+	var (
+		flags     = newFlagSet("generate wix")
+		output    = flags.String("output", "", "file to write (default: standard output)")
+		component = flags.String("component", "ContextMenus", "Id of the generated component")
+		directory = flags.String("directory", "INSTALLFOLDER", "Id of the directory ${manifestFolder} refers to")
+		manifest  *Manifest
+		items     []plannedItem
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if manifest, _, err = loadManifest(); err != nil {
+		return
+	}
+	if items, err = planManifest(manifest); err != nil {
+		return
+	}
+	err = writeOutput(*output, wixFragment(items, *component, *directory))
+	return
+}
+
+// wixFragment writes one component whose keys are created on install and deleted on uninstall. Its
+// first value is the key path, which per-user components require.
+func wixFragment(items []plannedItem, component, directory string) string {
+	var (
+		b    strings.Builder
+		attr = func(s string) string {
+			var buf bytes.Buffer
+			// The preprocessor and the binder expand $(...) and !(...) anywhere in the source.
+			xml.EscapeText(&buf, []byte(strings.NewReplacer("$(", "$$(", "!(", "!!(").Replace(s)))
+			return `"` + buf.String() + `"`
+		}
+		value = func(s string) string {
+			s = strings.NewReplacer("[", `[\[]`, "]", `[\]]`).Replace(s)
+			return attr(strings.ReplaceAll(s, installFolder, "["+directory+"]"))
+		}
+		root    = config.Hive.String()
+		keyPath = true
+	)
+	b.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n")
+	b.WriteString("<Wix xmlns=\"http://schemas.microsoft.com/wix/2006/wi\">\n")
+	b.WriteString("  <Fragment>\n")
+	fmt.Fprintf(&b, "    <DirectoryRef Id=%s>\n", attr(directory))
+	fmt.Fprintf(&b, "      <Component Id=%s Guid=\"*\">\n", attr(component))
+	for _, item := range items {
+		for i, key := range item.Keys {
+			fmt.Fprintf(&b, "        <RegistryKey Root=%s Key=%s", attr(root), attr(key.Path))
+			if i == 0 {
+				b.WriteString(` ForceCreateOnInstall="yes" ForceDeleteOnUninstall="yes"`)
+			} else {
+				b.WriteString(` ForceCreateOnInstall="yes"`)
+			}
+			b.WriteString(">\n")
+			for _, v := range key.Values {
+				valueType := "string"
+				if v.Type == RegistryValueType_ExpandString {
+					valueType = "expandable"
+				}
+				b.WriteString("          <RegistryValue")
+				if v.Name != "" {
+					fmt.Fprintf(&b, " Name=%s", attr(v.Name))
+				}
+				fmt.Fprintf(&b, " Type=%q Value=%s", valueType, value(v.Data))
+				if keyPath {
+					b.WriteString(` KeyPath="yes"`)
+					keyPath = false
+				}
+				b.WriteString(" />\n")
+			}
+			b.WriteString("        </RegistryKey>\n")
+		}
+	}
+	b.WriteString("      </Component>\n")
+	b.WriteString("    </DirectoryRef>\n")
+	b.WriteString("  </Fragment>\n")
+	b.WriteString("</Wix>\n")
+	return b.String()
+}
+
+type dscRegistry struct {
+	Key       string   `yaml:"Key"`
+	ValueName string   `yaml:"ValueName"`
+	ValueData []string `yaml:"ValueData,omitempty"`
+	ValueType string   `yaml:"ValueType,omitempty"`
+	Ensure    string   `yaml:"Ensure"`
+	Force     bool     `yaml:"Force"`
+}
+
+type wingetResource struct {
+	Resource   string            `yaml:"resource"`
+	ID         string            `yaml:"id"`
+	Directives map[string]string `yaml:"directives"`
+	Settings   dscRegistry       `yaml:"settings"`
+}
+
+type wingetConfiguration struct {
+	Properties struct {
+		ConfigurationVersion string           `yaml:"configurationVersion"`
+		Resources            []wingetResource `yaml:"resources"`
+	} `yaml:"properties"`
+}
+
+func runGenerateDSC(args []string) (err error) {
+	var (
+		flags          = newFlagSet("generate dsc")
+		format         = flags.String("format", "winget", `"winget" for a winget configure document, or "dsc" for a PowerShell DSC configuration`)
+		output         = flags.String("output", "", "file to write (default: standard output)")
+		manifestFolder = flags.String("manifest-folder", "", "path ${manifestFolder} refers to on the provisioned machines (default: the manifest's folder)")
+		manifest       *Manifest
+		manifestDir    string
+		items          []plannedItem
+		resources      []dscRegistry
+		data           []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		return
+	}
+	if *manifestFolder == "" {
+		*manifestFolder = manifestDir
+	}
+	if items, err = planManifest(manifest); err != nil {
+		return
+	}
+	resources = dscResources(items, *manifestFolder)
+	switch *format {
+	case "winget":
+		if data, err = wingetDocument(resources); err != nil {
+			return
+		}
+		err = writeOutput(*output, string(data))
+	case "dsc":
+		err = writeOutput(*output, dscConfiguration(resources))
+	default:
+		err = errorf("unknown format %q, expected %q or %q", *format, "winget", "dsc")
+	}
+	return
+}
+
+// dscResources maps every value to a PSDscResources Registry resource. Keys without values get
+// one with an empty ValueName, which only ensures the key exists.
+func dscResources(items []plannedItem, manifestFolder string) (resources []dscRegistry) {
+	for _, item := range items {
+		for _, key := range item.Keys {
+			var path = longKeyName(config.Hive.String() + `\` + key.Path)
+			if len(key.Values) == 0 {
+				resources = append(resources, dscRegistry{Key: path, Ensure: "Present", Force: true})
+			}
+			for _, value := range key.Values {
+				valueType := "String"
+				if value.Type == RegistryValueType_ExpandString {
+					valueType = "ExpandString"
+				}
+				resources = append(resources, dscRegistry{
+					Key:       path,
+					ValueName: value.Name,
+					ValueData: []string{strings.ReplaceAll(value.Data, installFolder, manifestFolder)},
+					ValueType: valueType,
+					Ensure:    "Present",
+					Force:     true,
+				})
+			}
+		}
+	}
+	return
+}
+
+// wingetDocument writes the resources as a winget configure document.
+func wingetDocument(resources []dscRegistry) (data []byte, err error) {
+	var doc wingetConfiguration
+	doc.Properties.ConfigurationVersion = "0.2.0"
+	for i, r := range resources {
+		doc.Properties.Resources = append(doc.Properties.Resources, wingetResource{
+			Resource:   "PSDscResources/Registry",
+			ID:         fmt.Sprintf("context-menu-%d", i+1),
+			Directives: map[string]string{"description": r.Key + `\` + r.ValueName},
+			Settings:   r,
+		})
+	}
+	if data, err = yaml.Marshal(&doc); err != nil {
+		return
+	}
+	data = append([]byte("# yaml-language-server: $schema=https://aka.ms/configuration-dsc-schema/0.2\n"), data...)
+	return
+}
+
+func dscConfiguration(resources []dscRegistry) string {
+	var (
+		b strings.Builder
+		// PowerShell also ends single quoted strings at typographic quotes, and reads any of them
+		// doubled as a quote.
+		quote = func(s string) string {
+			return "'" + strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201A", "\u201A\u201A", "\u201B", "\u201B\u201B").Replace(s) + "'"
+		}
+	)
+	b.WriteString("Configuration ContextMenus {\n")
+	b.WriteString("    Import-DscResource -ModuleName PSDscResources\n\n")
+	b.WriteString("    Node localhost {\n")
+	for i, r := range resources {
+		fmt.Fprintf(&b, "        Registry ContextMenu%d {\n", i+1)
+		fmt.Fprintf(&b, "            Key       = %s\n", quote(r.Key))
+		fmt.Fprintf(&b, "            ValueName = %s\n", quote(r.ValueName))
+		if len(r.ValueData) > 0 {
+			fmt.Fprintf(&b, "            ValueData = %s\n", quote(r.ValueData[0]))
+			fmt.Fprintf(&b, "            ValueType = %s\n", quote(r.ValueType))
+		}
+		b.WriteString("            Ensure    = 'Present'\n")
+		b.WriteString("            Force     = $true\n")
+		b.WriteString("        }\n")
+	}
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func runGenerateGPO(args []string) (err error) {
+	var (
+		flags          = newFlagSet("generate gpo")
+		format         = flags.String("format", "xml", `"xml" for Group Policy Preferences Registry XML, or "pol" for a registry.pol file`)
+		output         = flags.String("output", "", "file to write (default: standard output)")
+		manifestFolder = flags.String("manifest-folder", "", "path ${manifestFolder} refers to on the provisioned machines (default: the manifest's folder)")
+		manifest       *Manifest
+		manifestDir    string
+		items          []plannedItem
+		data           string
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		return
+	}
+	if *manifestFolder == "" {
+		*manifestFolder = manifestDir
+	}
+	if items, err = planManifest(manifest); err != nil {
+		return
+	}
+	switch *format {
+	case "xml":
+		data, err = gppRegistryXML(items, *manifestFolder)
+	case "pol":
+		if *output == "" {
+			err = errorf("--format pol needs --output, as registry.pol is a binary file")
+			return
+		}
+		data = string(registryPol(items, *manifestFolder))
+	default:
+		err = errorf("unknown format %q, expected %q or %q", *format, "xml", "pol")
+	}
+	if err != nil {
+		return
+	}
+	err = writeOutput(*output, data)
+	return
+}
+
+// gppRegistryXML writes Registry preference items that update each value and are removed when
+// the policy no longer applies.
+func gppRegistryXML(items []plannedItem, manifestFolder string) (data string, err error) {
+	var (
+		b       strings.Builder
+		changed = time.Now().UTC().Format("2006-01-02 15:04:05")
+		hive    = hiveNames[config.Hive.String()]
+		attr    = func(s string) string {
+			var buf bytes.Buffer
+			xml.EscapeText(&buf, []byte(strings.ReplaceAll(s, installFolder, manifestFolder)))
+			return `"` + buf.String() + `"`
+		}
+		write = func(keyPath string, value RegistryValue, isDefault bool) (err error) {
+			var (
+				uid         [16]byte
+				valueType   = "REG_SZ"
+				name        = value.Name
+				defaultFlag = "0"
+			)
+			if _, err = rand.Read(uid[:]); err != nil {
+				return
+			}
+			if value.Type == RegistryValueType_ExpandString {
+				valueType = "REG_EXPAND_SZ"
+			}
+			if isDefault {
+				name, defaultFlag = "(Default)", "1"
+			}
+			fmt.Fprintf(&b, "  <Registry clsid=\"{9CD4B2F4-923D-47f5-A062-E897DD1DAD50}\" name=%s status=%s image=\"7\" changed=%q uid=\"{%X-%X-%X-%X-%X}\" removePolicy=\"1\" bypassErrors=\"1\">\n",
+				attr(name), attr(name), changed, uid[0:4], uid[4:6], uid[6:8], uid[8:10], uid[10:16])
+			fmt.Fprintf(&b, "    <Properties action=\"U\" displayDecimal=\"0\" default=%q hive=%q key=%s name=%s type=%q value=%s/>\n",
+				defaultFlag, hive, attr(keyPath), attr(value.Name), valueType, attr(value.Data))
+			b.WriteString("  </Registry>\n")
+			return
+		}
+	)
+	b.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n")
+	b.WriteString("<RegistrySettings clsid=\"{A3CCFC41-DFDB-43a5-8D26-0FE8B954DA51}\">\n")
+	for _, item := range items {
+		for _, key := range item.Keys {
+			if len(key.Values) == 0 {
+				if err = write(key.Path, RegistryValue{Type: RegistryValueType_String}, true); err != nil {
+					return
+				}
+			}
+			for _, value := range key.Values {
+				if err = write(key.Path, value, value.Name == ""); err != nil {
+					return
+				}
+			}
+		}
+	}
+	b.WriteString("</RegistrySettings>\n")
+	data = b.String()
+	return
+}
+
+// registryPol encodes the values in the PReg format of registry.pol, for the User or Machine
+// policy folder matching the hive.
+func registryPol(items []plannedItem, manifestFolder string) []byte {
+	const (
+		regSZ       = 1
+		regExpandSZ = 2
+	)
+	var (
+		buf      = bytes.NewBuffer([]byte{'P', 'R', 'e', 'g', 1, 0, 0, 0})
+		putUTF16 = func(s string) {
+			for _, u := range utf16.Encode([]rune(s)) {
+				buf.WriteByte(byte(u))
+				buf.WriteByte(byte(u >> 8))
+			}
+		}
+		putDWORD = func(d uint32) {
+			binary.Write(buf, binary.LittleEndian, d)
+		}
+		entry = func(keyPath string, value RegistryValue) {
+			var (
+				data      = utf16File(strings.ReplaceAll(value.Data, installFolder, manifestFolder) + "\x00")[2:]
+				valueType = uint32(regSZ)
+			)
+			if value.Type == RegistryValueType_ExpandString {
+				valueType = regExpandSZ
+			}
+			putUTF16("[" + keyPath + "\x00;" + value.Name + "\x00;")
+			putDWORD(valueType)
+			putUTF16(";")
+			putDWORD(uint32(len(data)))
+			putUTF16(";")
+			buf.Write(data)
+			putUTF16("]")
+		}
+	)
+	for _, item := range items {
+		for _, key := range item.Keys {
+			if len(key.Values) == 0 {
+				entry(key.Path, RegistryValue{Type: RegistryValueType_String})
+			}
+			for _, value := range key.Values {
+				entry(key.Path, value)
+			}
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testPlannedItems are a top-level item whose command runs a script from the manifest folder, and
+// a folder whose shell subkey has no values, with titles that need escaping in every format.
+var testPlannedItems = []plannedItem{
+	{Keys: []RegistryKey{
+		{Path: `shell\terminal`, Values: []RegistryValue{{Name: "MUIVerb", Type: RegistryValueType_String, Data: `Say "hi" {now} $HOME [1] <b> 'it's'`}}},
+		{Path: `shell\terminal\command`, Values: []RegistryValue{{Type: RegistryValueType_ExpandString, Data: `"` + installFolder + `\run.cmd" "%V"`}}},
+	}},
+	{Keys: []RegistryKey{
+		{Path: `shell\tools`, Values: []RegistryValue{{Name: "MUIVerb", Type: RegistryValueType_String, Data: "Tools"}, {Name: "SubCommands", Type: RegistryValueType_String}}},
+		{Path: `shell\tools\shell`},
+	}},
+}
+
+func TestInstallerScripts(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		script func(items []plannedItem) string
+		want   string
+	}{
+		{
+			name:   "inno",
+			script: innoScript,
+			want: `[Registry]
+Root: HKCU; Subkey: "shell\terminal"; Flags: deletekey uninsdeletekey
+Root: HKCU; Subkey: "shell\terminal"; ValueType: string; ValueName: "MUIVerb"; ValueData: "Say ""hi"" {{now} $HOME [1] <b> 'it's'"
+Root: HKCU; Subkey: "shell\terminal\command"; ValueType: expandsz; ValueName: ""; ValueData: """{app}\run.cmd"" ""%V"""
+Root: HKCU; Subkey: "shell\tools"; Flags: deletekey uninsdeletekey
+Root: HKCU; Subkey: "shell\tools"; ValueType: string; ValueName: "MUIVerb"; ValueData: "Tools"
+Root: HKCU; Subkey: "shell\tools"; ValueType: string; ValueName: "SubCommands"; ValueData: ""
+Root: HKCU; Subkey: "shell\tools\shell"
+`,
+		},
+		{
+			name:   "nsis",
+			script: nsisScript,
+			want: `Section "Context menus"
+  DeleteRegKey HKCU "shell\terminal"
+  WriteRegStr HKCU "shell\terminal" "MUIVerb" "Say $\"hi$\" {now} $$HOME [1] <b> 'it's'"
+  WriteRegExpandStr HKCU "shell\terminal\command" "" "$\"$INSTDIR\run.cmd$\" $\"%V$\""
+  DeleteRegKey HKCU "shell\tools"
+  WriteRegStr HKCU "shell\tools" "MUIVerb" "Tools"
+  WriteRegStr HKCU "shell\tools" "SubCommands" ""
+  WriteRegStr HKCU "shell\tools\shell" "" ""
+SectionEnd
+
+Section "Uninstall"
+  DeleteRegKey HKCU "shell\terminal"
+  DeleteRegKey HKCU "shell\tools"
+SectionEnd
+`,
+		},
+	} {
+		if got := test.script(testPlannedItems); got != test.want {
+			t.Errorf("%s script is\n%s\nexpected\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+// TestPlanManifest checks that generated output plans every target of every item, with the
+// manifest folder left for each format to fill in.
+func TestPlanManifest(t *testing.T) {
+	var manifest = &Manifest{Items: testMenus(t, `{
+		"run": {"type": "item", "title": "Run", "command": ["${manifestFolder}\\run.cmd", "%V"], "targets": ["background", "directory"]}
+	}`)}
+	items, err := planManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("planned %d items, expected one per target", len(items))
+	}
+	for i, target := range []string{"background", "directory"} {
+		if root := items[i].Root(); !strings.EqualFold(root, itemKeyPath(target, "run")) {
+			t.Errorf("planned %s for %s, expected %s", root, target, itemKeyPath(target, "run"))
+		}
+		var command string
+		for _, key := range items[i].Keys {
+			if strings.HasSuffix(key.Path, `\command`) && len(key.Values) > 0 {
+				command = key.Values[0].Data
+			}
+		}
+		if !strings.Contains(command, installFolder+`\run.cmd`) {
+			t.Errorf("planned command %q for %s, expected it to run run.cmd from the install folder", command, target)
+		}
+	}
+}
+
+func TestWixFragment(t *testing.T) {
+	var (
+		items = append([]plannedItem{{Keys: []RegistryKey{
+			{Path: `shell\ps`, Values: []RegistryValue{{Name: "MUIVerb", Type: RegistryValueType_String, Data: "$(Get-Date) !(loc.Title)"}}},
+		}}}, testPlannedItems...)
+		want = `<?xml version="1.0" encoding="utf-8"?>
+<Wix xmlns="http://schemas.microsoft.com/wix/2006/wi">
+  <Fragment>
+    <DirectoryRef Id="INSTALLFOLDER">
+      <Component Id="ContextMenus" Guid="*">
+        <RegistryKey Root="HKCU" Key="shell\ps" ForceCreateOnInstall="yes" ForceDeleteOnUninstall="yes">
+          <RegistryValue Name="MUIVerb" Type="string" Value="$$(Get-Date) !!(loc.Title)" KeyPath="yes" />
+        </RegistryKey>
+        <RegistryKey Root="HKCU" Key="shell\terminal" ForceCreateOnInstall="yes" ForceDeleteOnUninstall="yes">
+          <RegistryValue Name="MUIVerb" Type="string" Value="Say &#34;hi&#34; {now} $HOME [\[]1[\]] &lt;b&gt; &#39;it&#39;s&#39;" />
+        </RegistryKey>
+        <RegistryKey Root="HKCU" Key="shell\terminal\command" ForceCreateOnInstall="yes">
+          <RegistryValue Type="expandable" Value="&#34;[INSTALLFOLDER]\run.cmd&#34; &#34;%V&#34;" />
+        </RegistryKey>
+        <RegistryKey Root="HKCU" Key="shell\tools" ForceCreateOnInstall="yes" ForceDeleteOnUninstall="yes">
+          <RegistryValue Name="MUIVerb" Type="string" Value="Tools" />
+          <RegistryValue Name="SubCommands" Type="string" Value="" />
+        </RegistryKey>
+        <RegistryKey Root="HKCU" Key="shell\tools\shell" ForceCreateOnInstall="yes">
+        </RegistryKey>
+      </Component>
+    </DirectoryRef>
+  </Fragment>
+</Wix>
+`
+	)
+	if got := wixFragment(items, "ContextMenus", "INSTALLFOLDER"); got != want {
+		t.Errorf("fragment is\n%s\nexpected\n%s", got, want)
+	}
+}
+
+func TestDscResources(t *testing.T) {
+	var (
+		resources = dscResources(testPlannedItems, `C:\Menus`)
+		want      = []dscRegistry{
+			{Key: `HKEY_CURRENT_USER\shell\terminal`, ValueName: "MUIVerb", ValueData: []string{`Say "hi" {now} $HOME [1] <b> 'it's'`}, ValueType: "String", Ensure: "Present", Force: true},
+			{Key: `HKEY_CURRENT_USER\shell\terminal\command`, ValueData: []string{`"C:\Menus\run.cmd" "%V"`}, ValueType: "ExpandString", Ensure: "Present", Force: true},
+			{Key: `HKEY_CURRENT_USER\shell\tools`, ValueName: "MUIVerb", ValueData: []string{"Tools"}, ValueType: "String", Ensure: "Present", Force: true},
+			{Key: `HKEY_CURRENT_USER\shell\tools`, ValueName: "SubCommands", ValueData: []string{""}, ValueType: "String", Ensure: "Present", Force: true},
+			{Key: `HKEY_CURRENT_USER\shell\tools\shell`, Ensure: "Present", Force: true},
+		}
+	)
+	if !reflect.DeepEqual(resources, want) {
+		t.Errorf("resources are %+v, expected %+v", resources, want)
+	}
+}
+
+func TestDscDocuments(t *testing.T) {
+	var resources = []dscRegistry{
+		{Key: `HKEY_CURRENT_USER\shell\ps`, ValueName: "MUIVerb", ValueData: []string{"It's ‘quoted’ $(calc)"}, ValueType: "String", Ensure: "Present", Force: true},
+		{Key: `HKEY_CURRENT_USER\shell\ps\shell`, Ensure: "Present", Force: true},
+	}
+	if got, want := dscConfiguration(resources), `Configuration ContextMenus {
+    Import-DscResource -ModuleName PSDscResources
+
+    Node localhost {
+        Registry ContextMenu1 {
+            Key       = 'HKEY_CURRENT_USER\shell\ps'
+            ValueName = 'MUIVerb'
+            ValueData = 'It''s ‘‘quoted’’ $(calc)'
+            ValueType = 'String'
+            Ensure    = 'Present'
+            Force     = $true
+        }
+        Registry ContextMenu2 {
+            Key       = 'HKEY_CURRENT_USER\shell\ps\shell'
+            ValueName = ''
+            Ensure    = 'Present'
+            Force     = $true
+        }
+    }
+}
+`; got != want {
+		t.Errorf("configuration is\n%s\nexpected\n%s", got, want)
+	}
+	data, err := wingetDocument(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc wingetConfiguration
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Properties.Resources) != len(resources) {
+		t.Fatalf("document has %d resources, expected %d:\n%s", len(doc.Properties.Resources), len(resources), data)
+	}
+	for i, r := range doc.Properties.Resources {
+		if r.Resource != "PSDscResources/Registry" || !reflect.DeepEqual(r.Settings, resources[i]) {
+			t.Errorf("resource %d reads back as %+v, expected the settings %+v", i+1, r, resources[i])
+		}
+	}
+}
+
+// TestGppRegistryXML checks the preference items by reading them back: one per value, and one
+// setting the default value of each key without values, each with its own uid.
+func TestGppRegistryXML(t *testing.T) {
+	type properties struct {
+		Action  string `xml:"action,attr"`
+		Default string `xml:"default,attr"`
+		Hive    string `xml:"hive,attr"`
+		Key     string `xml:"key,attr"`
+		Name    string `xml:"name,attr"`
+		Type    string `xml:"type,attr"`
+		Value   string `xml:"value,attr"`
+	}
+	var (
+		doc struct {
+			Registry []struct {
+				Name       string     `xml:"name,attr"`
+				UID        string     `xml:"uid,attr"`
+				Properties properties `xml:"Properties"`
+			}
+		}
+		want = []properties{
+			{Key: `shell\terminal`, Name: "MUIVerb", Type: "REG_SZ", Value: `Say "hi" {now} $HOME [1] <b> 'it's'`, Default: "0"},
+			{Key: `shell\terminal\command`, Type: "REG_EXPAND_SZ", Value: `"C:\Menus & Tools\run.cmd" "%V"`, Default: "1"},
+			{Key: `shell\tools`, Name: "MUIVerb", Type: "REG_SZ", Value: "Tools", Default: "0"},
+			{Key: `shell\tools`, Name: "SubCommands", Type: "REG_SZ", Default: "0"},
+			{Key: `shell\tools\shell`, Type: "REG_SZ", Default: "1"},
+		}
+		uids = make(map[string]bool)
+	)
+	data, err := gppRegistryXML(testPlannedItems, `C:\Menus & Tools`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = xml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("%v:\n%s", err, data)
+	}
+	if len(doc.Registry) != len(want) {
+		t.Fatalf("wrote %d preference items, expected %d:\n%s", len(doc.Registry), len(want), data)
+	}
+	for i, r := range doc.Registry {
+		want[i].Action, want[i].Hive = "U", "HKEY_CURRENT_USER"
+		if r.Properties != want[i] {
+			t.Errorf("preference item %d is %+v, expected %+v", i+1, r.Properties, want[i])
+		}
+		if name := r.Properties.Name; r.Properties.Default == "1" && r.Name != "(Default)" || r.Properties.Default == "0" && r.Name != name {
+			t.Errorf("preference item %d is named %q", i+1, r.Name)
+		}
+		if len(r.UID) != 38 || uids[r.UID] {
+			t.Errorf("preference item %d has the uid %s, expected a new GUID", i+1, r.UID)
+		}
+		uids[r.UID] = true
+	}
+}
+
+// TestRegistryPol checks the PReg encoding of registry.pol: a header, then for each value its key
+// and name ending in NUL, its type and size as little-endian numbers, and its data, all in UTF-16.
+func TestRegistryPol(t *testing.T) {
+	var (
+		items = []plannedItem{{Keys: []RegistryKey{
+			{Path: `s\k`, Values: []RegistryValue{{Name: "N", Type: RegistryValueType_String, Data: "é"}}},
+			{Path: `c`, Values: []RegistryValue{{Type: RegistryValueType_ExpandString, Data: installFolder}}},
+			{Path: `e`},
+		}}}
+		want = "PReg\x01\x00\x00\x00" +
+			"[\x00s\x00\\\x00k\x00\x00\x00;\x00N\x00\x00\x00;\x00\x01\x00\x00\x00;\x00\x04\x00\x00\x00;\x00\xe9\x00\x00\x00]\x00" +
+			"[\x00c\x00\x00\x00;\x00\x00\x00;\x00\x02\x00\x00\x00;\x00\x06\x00\x00\x00;\x00\xdc\x83\x55\x53\x00\x00]\x00" +
+			"[\x00e\x00\x00\x00;\x00\x00\x00;\x00\x01\x00\x00\x00;\x00\x02\x00\x00\x00;\x00\x00\x00]\x00"
+	)
+	if got := string(registryPol(items, "菜单")); got != want {
+		t.Errorf("registry.pol is\n%q\nexpected\n%q", got, want)
+	}
+}
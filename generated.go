@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+// expandGenerated fills the folders whose items are generated, recent folders, scripts folders,
+// Windows Terminal profiles and WSL distributions, and turns them into plain folders, so that backends only see items
+// and folders. copyPath items get the command that runs copy-path. A folder with nothing in it yet is left out until the next apply finds something.
+func (c ContextMenus) expandGenerated(platform, manifestDir string) (menus ContextMenus) {
+	for _, entry := range c {
+		var item = *entry.Menu
+		switch item.Type {
+		case ContextMenuType_Recent:
+			item.Items = recentItems(&item)
+		case ContextMenuType_ScriptsFolder:
+			item.Items = scriptItems(&item, platform, manifestDir)
+		case ContextMenuType_TerminalProfiles:
+			item.Items = terminalProfileItems()
+		case ContextMenuType_WSLDistros:
+			item.Items = wslDistroItems(platform)
+		case ContextMenuType_CopyPath:
+			item.Type, item.Command, item.Variants.Command = ContextMenuType_Item, copyPathCommand(item.PathFormat), nil
+			if len(item.Command) == 0 {
+				continue
+			}
+		case ContextMenuType_Folder:
+			item.Items = item.Items.expandGenerated(platform, manifestDir)
+		}
+		if item.Type != ContextMenuType_Item && item.Type != ContextMenuType_Folder {
+			if len(item.Items) == 0 {
+				continue
+			}
+			item.Type, item.Command, item.Variants.Command, item.Generated = ContextMenuType_Folder, nil, nil, true
+		}
+		menus = append(menus, ContextMenuEntry{ID: entry.ID, Menu: &item})
+	}
+	return
+}
+
+// generatedSnapshot is the items of the generated folders of menus, to tell when a refresh has
+// something to write.
+func (c ContextMenus) generatedSnapshot() (snapshot string) {
+	for _, entry := range c {
+		switch {
+		case entry.Menu.Generated:
+			for _, item := range entry.Menu.Items {
+				snapshot += entry.ID + "/" + item.ID + "\x00" + strings.Join(item.Menu.Command, "\x00") + "\n"
+			}
+		case entry.Menu.Type == ContextMenuType_Folder:
+			snapshot += entry.Menu.Items.generatedSnapshot()
+		}
+	}
+	return
+}
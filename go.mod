@@ -2,4 +2,8 @@ module github.com/rixtox/context-menu-manager
 
 go 1.18
 
-require golang.org/x/sys v0.0.0-20220817070843-5a390386f1f2
+require (
+	github.com/BurntSushi/toml v1.6.0
+	golang.org/x/sys v0.0.0-20220817070843-5a390386f1f2
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	advapi32          = windows.NewLazySystemDLL("advapi32.dll")
+	procRegLoadKeyW   = advapi32.NewProc("RegLoadKeyW")
+	procRegUnLoadKeyW = advapi32.NewProc("RegUnLoadKeyW")
+)
+
+// hiveFileSubkey is where --hive-file mounts the hive at path under HKEY_USERS. The state records
+// keys by it, so it is the same for every apply to one hive file and differs between hive files,
+// which keeps the keys applied to one profile from being pruned or replaced when applying to
+// another.
+func hiveFileSubkey(path string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(path)))
+	return "context-menu-manager-" + hex.EncodeToString(sum[:4])
+}
+
+// loadHiveFile mounts an offline hive, such as a profile's NTUSER.DAT, to work on it until
+// unloadHiveFile. Classes are at the root of UsrClass.dat and under Classes in a SOFTWARE hive.
+func loadHiveFile(path string) (err error) {
+	var (
+		subkey, file *uint16
+		r            uintptr
+		name         string
+	)
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	name = hiveFileSubkey(path)
+	for _, privilege := range []string{"SeBackupPrivilege", "SeRestorePrivilege"} {
+		if err = enablePrivilege(privilege); err != nil {
+			err = errorf("failed to enable %s, run as administrator: %w", privilege, err)
+			return
+		}
+	}
+	if subkey, err = windows.UTF16PtrFromString(name); err != nil {
+		return
+	}
+	if file, err = windows.UTF16PtrFromString(path); err != nil {
+		return
+	}
+	if r, _, _ = procRegLoadKeyW.Call(uintptr(registry.USERS), uintptr(unsafe.Pointer(subkey)), uintptr(unsafe.Pointer(file))); r != 0 {
+		if err = syscall.Errno(r); errors.Is(err, windows.ERROR_PRIVILEGE_NOT_HELD) {
+			err = errorf("failed to load hive %s, run as administrator: %w", path, err)
+		} else {
+			err = errorf("failed to load hive %s: %w", path, err)
+		}
+		return
+	}
+	if usersKey, err = registry.OpenKey(registry.USERS, name, registry.ALL_ACCESS); err != nil {
+		procRegUnLoadKeyW.Call(uintptr(registry.USERS), uintptr(unsafe.Pointer(subkey)))
+		err = errorf("failed to open hive %s: %w", path, err)
+		return
+	}
+	usersSubkey = name
+	switch {
+	case config.Hive == Hive_Machine:
+		classesKeyPath = "Classes"
+	case strings.EqualFold(filepath.Base(path), "UsrClass.dat"):
+		classesKeyPath = ""
+	}
+	logf(LogLevel_Info, "loaded %s at HKEY_USERS\\%s", path, name)
+	return
+}
+
+func unloadHiveFile() {
+	var subkey, _ = windows.UTF16PtrFromString(usersSubkey)
+	usersKey.Close()
+	if r, _, _ := procRegUnLoadKeyW.Call(uintptr(registry.USERS), uintptr(unsafe.Pointer(subkey))); r != 0 {
+		logf(LogLevel_Warn, "failed to unload HKEY_USERS\\%s: %v", usersSubkey, syscall.Errno(r))
+	}
+}
+
+func enablePrivilege(name string) (err error) {
+	var (
+		token      windows.Token
+		privileges = windows.Tokenprivileges{PrivilegeCount: 1}
+	)
+	if err = windows.LookupPrivilegeValue(nil, windows.StringToUTF16Ptr(name), &privileges.Privileges[0].Luid); err != nil {
+		return
+	}
+	privileges.Privileges[0].Attributes = windows.SE_PRIVILEGE_ENABLED
+	if err = windows.OpenProcessToken(windows.CurrentProcess(), windows.TOKEN_ADJUST_PRIVILEGES|windows.TOKEN_QUERY, &token); err != nil {
+		return
+	}
+	defer token.Close()
+	// This succeeds without enabling anything when the token lacks the privilege, which RegLoadKey
+	// then reports.
+	err = windows.AdjustTokenPrivileges(token, false, &privileges, 0, nil, nil)
+	return
+}
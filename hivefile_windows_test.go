@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHiveFileSubkey checks that a hive file is mounted at the same subkey however its path is
+// cased, and at another one than other hive files.
+func TestHiveFileSubkey(t *testing.T) {
+	var (
+		a = hiveFileSubkey(`C:\Users\Default\NTUSER.DAT`)
+		b = hiveFileSubkey(`c:\users\default\ntuser.dat`)
+		c = hiveFileSubkey(`C:\Users\Default\AppData\Local\Microsoft\Windows\UsrClass.dat`)
+	)
+	if a != b {
+		t.Errorf("paths differing in case are mounted at %q and %q", a, b)
+	}
+	if a == c {
+		t.Errorf("two hive files are mounted at %q", a)
+	}
+	if !strings.HasPrefix(a, "context-menu-manager-") || strings.Contains(a, `\`) {
+		t.Errorf("hive file is mounted at %q", a)
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// catalogs translate the tool's own messages, keyed by the English format string. Messages
+// missing from a catalog are shown in English.
+var catalogs = map[string]map[string]string{
+	"en": {},
+	"zh": catalogZh,
+}
+
+var (
+	detectedLanguage     string
+	detectedLanguageOnce sync.Once
+)
+
+// currentLanguage is the configured language, or the first of the user's UI languages that has a catalog.
+func currentLanguage() string {
+	if config.Language != "" {
+		return config.Language
+	}
+	detectedLanguageOnce.Do(func() {
+		detectedLanguage = "en"
+		for _, language := range userLanguages() {
+			language = strings.ToLower(strings.SplitN(language, "-", 2)[0])
+			if _, ok := catalogs[language]; ok {
+				detectedLanguage = language
+				return
+			}
+		}
+	})
+	return detectedLanguage
+}
+
+func tr(message string) string {
+	if translated, ok := catalogs[currentLanguage()][message]; ok {
+		return translated
+	}
+	return message
+}
+
+func sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+func errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(tr(format), args...)
+}
+
+// printDefaults is flag.PrintDefaults with the flag descriptions translated.
+func printDefaults(flags *flag.FlagSet) {
+	flags.VisitAll(func(f *flag.Flag) {
+		f.Usage = tr(f.Usage)
+	})
+	flags.PrintDefaults()
+}
+
+var catalogZh = map[string]string{
+	// usage and flags
+	usage: `用法: context-menu-manager [选项] [命令] [参数]
+
+命令:
+  apply              将清单中的所有项目写入注册表 (默认)
+  list               列出清单项目及其状态
+  diff [A B]         显示清单与注册表之间的差异, 或两个清单之间的差异
+  toggle ID          启用或禁用已应用的项目
+  remove             删除所有已应用的项目, 例如在卸载之前
+  serve              通过本地 HTTP API 提供上述命令
+  ui                 在本地网页中预览菜单并调整顺序
+  edit [PATH]        在交互式终端界面中编辑清单
+  tray               在通知区域显示图标, 用于切换项目和重新应用清单
+  config             显示当前生效的配置
+  generate KIND      为其他工具生成清单内容, 例如安装程序脚本
+  export --format F  将清单渲染为其他工具的配置, 例如 Nilesoft Shell
+  import --from-...  将其他工具导出的项目转换后添加到清单中
+  docs               将菜单层级渲染为 Markdown 或 HTML 页面, 便于审阅
+  preview            仅根据清单打印菜单的树状预览
+  validate           在任意平台上检查清单而不应用
+  schema             为编辑器写入清单的 JSON Schema
+  migrate            以最新清单架构版本的格式重写清单
+  convert --to F     将清单重写为 JSON、YAML 或 TOML 格式
+  fmt                以规范格式重写清单
+  copy-path PATH...  将路径复制到剪贴板, 供 copyPath 项目调用
+  run-elevated CMD   以管理员身份运行命令, 供 --elevation runas 时的管理员项目调用
+  merge [BASE] A B   合并两个清单的项目并报告冲突
+  report             生成包含诊断信息的 zip 文件, 用于提交问题报告
+  doctor             报告导致菜单无法显示的策略和权限
+  self-update        下载并安装最新版本
+  version            显示版本号
+
+嵌套项目的 ID 用 "/" 连接, 例如 "open-msvc/VS2022 MSVC 17 COM x86"。
+
+选项会覆盖 config.json 或 config.yaml 中的设置, 这些文件位于程序所在目录或
+%APPDATA%\context-menu-manager 中:
+`,
+	"Usage of %s:\n": "%s 的用法:\n",
+	"file to write (default: standard output)":                                   "要写入的文件 (默认: 标准输出)",
+	`"markdown" or "html" (default: from the --output extension, else markdown)`: `"markdown" 或 "html" (默认: 根据 --output 的扩展名, 否则为 markdown)`,
+	"render what is applied in the registry instead of the manifest":             "渲染注册表中已应用的内容, 而不是清单",
+	`"inno" or "nsis"`:                                `"inno" 或 "nsis"`,
+	"Id of the generated component":                   "生成的组件的 Id",
+	"Id of the directory ${manifestFolder} refers to": "${manifestFolder} 所指目录的 Id",
+	`"winget" for a winget configure document, or "dsc" for a PowerShell DSC configuration`:              `"winget" 生成 winget configure 文档, "dsc" 生成 PowerShell DSC 配置`,
+	"path ${manifestFolder} refers to on the provisioned machines (default: the manifest's folder)":      "${manifestFolder} 在目标计算机上所指的路径 (默认: 清单所在文件夹)",
+	`"xml" for Group Policy Preferences Registry XML, or "pol" for a registry.pol file`:                  `"xml" 生成组策略首选项注册表 XML, "pol" 生成 registry.pol 文件`,
+	"manifest to migrate (default: the manifest found by apply)":                                         "要迁移的清单 (默认: apply 使用的清单)",
+	`format to convert to, "json", "yaml" or "toml"`:                                                     `要转换成的格式, "json"、"yaml" 或 "toml"`,
+	"manifest to convert (default: the manifest found by apply)":                                         "要转换的清单 (默认: apply 使用的清单)",
+	"file to write (default: the manifest with the extension of the format)":                             "要写入的文件 (默认: 清单路径换成该格式的扩展名)",
+	"manifest to format (default: the manifest found by apply)":                                          "要格式化的清单 (默认: apply 使用的清单)",
+	"fail if the manifest is not formatted instead of rewriting it":                                      "如果清单未格式化则报错, 而不是重写它",
+	"show the changes instead of rewriting the manifest":                                                 "显示更改, 而不是重写清单",
+	"overwrite the output file if it exists":                                                             "如果输出文件已存在则覆盖",
+	"rewrite the manifest instead of only showing the changes":                                           "直接重写清单, 而不是仅显示更改",
+	"NirSoft ShellMenuView or ShellExView export (text report, or CSV/tab delimited with a header line)": "NirSoft ShellMenuView 或 ShellExView 导出文件 (文本报告, 或带标题行的 CSV/制表符分隔文件)",
+	"Easy Context Menu list (.ecm)":                                                                      "Easy Context Menu 列表 (.ecm)",
+	"registry export (.reg), such as a Right Click Enhancer backup":                                      "注册表导出文件 (.reg), 例如 Right Click Enhancer 备份",
+	"Nilesoft Shell config (.nss), static menu and item definitions only":                                "Nilesoft Shell 配置 (.nss), 仅限静态的菜单和项目定义",
+	`"nss" for a Nilesoft Shell config`:                                                                  `"nss" 生成 Nilesoft Shell 配置`,
+	"FileMenu Tools command list export (.xml)":                                                          "FileMenu Tools 命令列表导出文件 (.xml)",
+	"manifest to add the items to (default: the manifest found by apply)":                                "要添加项目的清单 (默认: apply 使用的清单)",
+	"path of the zip file to write":                                                                      "要写入的 zip 文件路径",
+	"only report whether an update is available":                                                         "仅报告是否有可用更新",
+	"install the latest release even if it is not newer":                                                 "即使最新版本并不更新也进行安装",
+	`file to write, or "-" for standard output`:                                                          `要写入的文件, "-" 表示标准输出`,
+	`registry hive to write to, "user" or "machine"`:                                                     `要写入的注册表配置单元, "user" 或 "machine"`,
+	`comma separated default targets, e.g. "background,directory,.txt"`:                                  `以逗号分隔的默认目标, 例如 "background,directory,.txt"`,
+	`how admin items are elevated, "nircmd" or "runas"`:                                                  `管理员项目的提权方式, "nircmd" 或 "runas"`,
+	"delete registry keys of items removed from the manifest":                                            "删除已从清单中移除的项目的注册表项",
+	`one of "error", "warn", "info" or "debug"`:                                                          `"error"、"warn"、"info" 或 "debug" 之一`,
+	"SID or account name of another signed in user whose hive to use instead of your own":                "使用另一位已登录用户 (SID 或帐户名) 的配置单元, 而不是您自己的",
+	`offline hive to load and work on instead, e.g. "C:\Users\Default\NTUSER.DAT"`:                       `改为加载并操作的离线配置单元, 例如 "C:\Users\Default\NTUSER.DAT"`,
+	"keep the config and all state next to the executable and never touch %APPDATA% or %LOCALAPPDATA%":   "将配置和所有状态保存在可执行文件旁边, 不使用 %APPDATA% 或 %LOCALAPPDATA%",
+	`language of messages, e.g. "en" or "zh" (default: Windows UI language)`:                             `消息语言, 例如 "en" 或 "zh" (默认: Windows 界面语言)`,
+	`"memory" to apply to memory only, for trying out commands without changing anything, or "wsl" to apply to Windows from inside WSL (default there)`: `"memory" 表示仅应用到内存, 用于在不做任何更改的情况下试用命令; "wsl" 表示在 WSL 中应用到 Windows (在 WSL 中为默认)`,
+	"manifest to check (default: the manifest found by apply)":                          "要检查的清单 (默认: apply 找到的清单)",
+	"manifest to preview (default: the manifest found by apply)":                        "要预览的清单 (默认: apply 找到的清单)",
+	`platform whose commands and icons to show, "windows", "linux" or "darwin"`:         `显示哪个平台的命令和图标, "windows"、"linux" 或 "darwin"`,
+	"unknown platform %q, expected %q, %q or %q":                                        "未知平台 %q, 应为 %q、%q 或 %q",
+	"manifest to run through the backend (default: a built-in one)":                     "交给后端处理的清单 (默认: 内置清单)",
+	"run with a backend other than memory, replacing and then removing what is applied": "使用 memory 以外的后端运行, 这会替换并随后删除已应用的内容",
+	"No problems found.":   "未发现问题。",
+	"%s has %d problem(s)": "%s 有 %d 个问题",
+	"rewrite every item, also those unchanged since the last apply, and replace verbs of other software with the same ID": "重写所有项目, 包括自上次应用以来未更改的项目, 并替换其他软件中 ID 相同的命令",
+	"print the items as JSON":   "以 JSON 格式输出项目",
+	"print the changes as JSON": "以 JSON 格式输出更改",
+	`on Linux, "nautilus", "dolphin", "thunar" or "actions" (default: the one of the desktop)`: `在 Linux 上为 "nautilus"、"dolphin"、"thunar" 或 "actions" (默认: 桌面环境所用的)`,
+	"loopback address to listen on":                                                     "要监听的本机回环地址",
+	"access token clients must send as a bearer token (random if empty)":                "客户端必须以 Bearer 令牌发送的访问令牌 (为空时随机生成)",
+	"origin of a browser front-end allowed to call the API, e.g. http://localhost:3000": "允许调用 API 的浏览器前端的源, 例如 http://localhost:3000",
+	"open the UI in the default browser":                                                "在默认浏览器中打开界面",
+	"how often to check the registry for drift from the manifest":                       "检查注册表是否偏离清单的时间间隔",
+
+	// summaries
+	"not applied":                            "未应用",
+	"disabled":                               "已禁用",
+	"enabled":                                "已启用",
+	"No differences.":                        "没有差异。",
+	"%s enabled\n":                           "%s 已启用\n",
+	"%s disabled\n":                          "%s 已禁用\n",
+	"Listening on http://%s\nToken: %s\n":    "正在监听 http://%s\n令牌: %s\n",
+	"Serving the UI on %s\n":                 "界面地址: %s\n",
+	"# no config file found, using defaults": "# 未找到配置文件, 使用默认值",
+	"applied %s to %s":                       "已将 %s 应用到 %s",
+	"pruned %s":                              "已清理 %s",
+	"%s is up to date.\n":                    "%s 已是最新版本。\n",
+	"Update available: %s -> %s\n":           "有可用更新: %s -> %s\n",
+	"%s (manifest schema version %d)\n":      "%s (清单架构版本 %d)\n",
+	"Usage: context-menu-manager generate KIND [options]\n\nKinds:\n": "用法: context-menu-manager generate KIND [选项]\n\n类型:\n",
+	"Context menus":     "右键菜单",
+	"Rendered from %s.": "由 %s 生成。",
+	"Applied in %s, as recorded by the last apply.": "%s 中已应用的内容, 以上次应用的记录为准。",
+	"No items.":                             "没有项目。",
+	"Icon:":                                 "图标:",
+	"runs as administrator":                 "以管理员身份运行",
+	"only with Shift held":                  "仅在按住 Shift 时显示",
+	"%s is already at schema version %d.\n": "%s 已是架构版本 %d。\n",
+	"Run again with --write to save these changes.":                                                 "使用 --write 再次运行以保存这些更改。",
+	"Migrated %s to schema version %d.\n":                                                           "已将 %s 迁移到架构版本 %d。\n",
+	"Wrote %s. Point \"$schema\" in the manifest at it for completion and validation in editors.\n": "已写入 %s。将清单中的 \"$schema\" 指向它, 即可在编辑器中获得补全和校验。\n",
+	"Formatted %s.\n": "已格式化 %s。\n",
+	"Wrote %s. Remove %s if it is in the same folder, since only one manifest is used.\n": "已写入 %s。如果 %s 位于同一文件夹中, 请将其删除, 因为只会使用一个清单。\n",
+	"Wrote %s. Please check it for anything private before attaching it to an issue.\n":   "已写入 %s。附加到问题报告前, 请检查其中是否包含隐私信息。\n",
+	"Imported %d items into %s.\n": "已将 %d 个项目导入 %s。\n",
+	"Skipped %d entries that cannot be imported, such as shell extensions and entries without a command.\n": "已跳过 %d 个无法导入的条目, 例如外壳扩展和没有命令的条目。\n",
+	"Updated to %s.\n":      "已更新到 %s。\n",
+	"writing %s\\%s":        "正在写入 %s\\%s",
+	"writing %s":            "正在写入 %s",
+	"running %s %s":         "正在运行 %s %s",
+	"%s is unchanged on %s": "%s 在 %s 上未更改",
+	"no command for %s: %v": "%s 没有命令: %v",
+	"no icon for %s: %v":    "%s 没有图标: %v",
+
+	// errors
+	"at least one target is required":                                 "至少需要一个目标",
+	"deleteRegKeyRecursive failed to delete key path %q: %w":          "deleteRegKeyRecursive 无法删除注册表项 %q: %w",
+	"deleteRegKeyRecursive failed to delete subkey %q of path %q: %w": "deleteRegKeyRecursive 无法删除 %[2]q 的子项 %[1]q: %[3]w",
+	"deleteRegKeyRecursive failed to get subkeys of path %q: %w":      "deleteRegKeyRecursive 无法获取 %q 的子项: %w",
+	"deleteRegKeyRecursive failed to open key path %q: %w":            "deleteRegKeyRecursive 无法打开注册表项 %q: %w",
+	"duplicate item ID %q":                                            "项目 ID %q 重复",
+	"failed to add tray icon: %w":                                     "无法添加托盘图标: %w",
+	"failed to create context menu ID %q for target %q: %w":           "无法为目标 %[2]q 创建右键菜单 ID %[1]q: %[3]w",
+	"failed to create registry key %q: %w":                            "无法创建注册表项 %q: %w",
+	"failed to create state folder: %w":                               "无法创建状态文件夹: %w",
+	"failed to create window: %w":                                     "无法创建窗口: %w",
+	"failed to delete registry key %q: %w":                            "无法删除注册表项 %q: %w",
+	"failed to encode manifest.json: %w":                              "无法编码 manifest.json: %w",
+	"failed to expand %q: %w":                                         "无法展开 %q: %w",
+	"failed to generate token: %w":                                    "无法生成令牌: %w",
+	"failed to get icon info: %w":                                     "无法获取图标信息: %w",
+	"failed to get module handle: %w":                                 "无法获取模块句柄: %w",
+	"failed to locate the state folder: %w":                           "无法定位状态文件夹: %w",
+	"failed to open registry key %q: %w":                              "无法打开注册表项 %q: %w",
+	"failed to parse %s: %w":                                          "无法解析 %s: %w",
+	"failed to parse manifest.json: %w":                               "无法解析 manifest.json: %w",
+	"failed to parse state: %w":                                       "无法解析状态: %w",
+	"failed to plan context menu ID %q: %w":                           "无法规划右键菜单 ID %q: %w",
+	"failed to prune registry key %q: %w":                             "无法清理注册表项 %q: %w",
+	"failed to read %s: %w":                                           "无法读取 %s: %w",
+	"failed to read LegacyDisable of registry key %q: %w":             "无法读取注册表项 %q 的 LegacyDisable: %w",
+	"failed to read console input: %w":                                "无法读取控制台输入: %w",
+	"failed to read icon bitmap: %w":                                  "无法读取图标位图: %w",
+	"failed to read manifest.json: %w":                                "无法读取 manifest.json: %w",
+	"failed to read state: %w":                                        "无法读取状态: %w",
+	"failed to read subkeys of registry key %q: %w":                   "无法读取注册表项 %q 的子项: %w",
+	"failed to read value %q of registry key %q: %w":                  "无法读取注册表项 %[2]q 的值 %[1]q: %[3]w",
+	"failed to read values of registry key %q: %w":                    "无法读取注册表项 %q 的值: %w",
+	"failed to register window class: %w":                             "无法注册窗口类: %w",
+	"failed to set console input mode: %w":                            "无法设置控制台输入模式: %w",
+	"failed to set console output mode: %w":                           "无法设置控制台输出模式: %w",
+	"failed to set value %q of registry key %q: %w":                   "无法设置注册表项 %[2]q 的值 %[1]q: %[3]w",
+	"failed to update LegacyDisable of registry key %q: %w":           "无法更新注册表项 %q 的 LegacyDisable: %w",
+	"failed to write manifest.json: %w":                               "无法写入 manifest.json: %w",
+	"failed to write state: %w":                                       "无法写入状态: %w",
+	"failed to locate the executable: %w":                             "无法定位可执行文件: %w",
+	"release %s has no %s":                                            "版本 %s 中没有 %s",
+	"failed to parse the releases feed: %w":                           "无法解析发布信息: %w",
+	"failed to download %s: %w":                                       "无法下载 %s: %w",
+	"failed to download %s: %s":                                       "无法下载 %s: %s",
+	"invalid release public key":                                      "无效的发布公钥",
+	"signature of %s does not match":                                  "%s 的签名不匹配",
+	"checksum of %s does not match":                                   "%s 的校验和不匹配",
+	"%s has no checksum for %s":                                       "%s 中没有 %s 的校验和",
+	"failed to write %s: %w":                                          "无法写入 %s: %w",
+	"failed to move %s aside: %w":                                     "无法移走 %s: %w",
+	"failed to replace %s: %w":                                        "无法替换 %s: %w",
+	"manifest.json uses schema version %d, but this build supports up to version %d; run \"context-menu-manager self-update\" to upgrade": "manifest.json 使用的架构版本为 %d, 但此版本最高支持 %d; 请运行 \"context-menu-manager self-update\" 升级",
+	"failed to create %s: %w":                                                               "无法创建 %s: %w",
+	"failed to create apply log: %w":                                                        "无法创建应用日志: %w",
+	"unknown registry hive %q":                                                              "未知的注册表配置单元 %q",
+	"--format pol needs --output, as registry.pol is a binary file":                         "--format pol 需要 --output, 因为 registry.pol 是二进制文件",
+	"failed to read undo.reg: %w":                                                           "无法读取 undo.reg: %w",
+	"failed to write undo.reg: %w":                                                          "无法写入 undo.reg: %w",
+	"--user only applies to the %q hive":                                                    "--user 仅适用于 %q 配置单元",
+	"--user does not apply to generated output":                                             "--user 不适用于生成的输出",
+	"unknown user %q: %w":                                                                   "未知用户 %q: %w",
+	"the registry hive of %s (%s) is not loaded, the user has to be signed in":              "%s (%s) 的注册表配置单元未加载, 该用户必须已登录",
+	"access to the registry hive of %s (%s) was denied, run as administrator":               "访问 %s (%s) 的注册表配置单元被拒绝, 请以管理员身份运行",
+	"failed to open the registry hive of %s (%s): %w":                                       "无法打开 %s (%s) 的注册表配置单元: %w",
+	"--user and --hive-file cannot be combined":                                             "--user 和 --hive-file 不能同时使用",
+	"failed to enable %s, run as administrator: %w":                                         "无法启用 %s, 请以管理员身份运行: %w",
+	"failed to load hive %s, run as administrator: %w":                                      "无法加载配置单元 %s, 请以管理员身份运行: %w",
+	"failed to load hive %s: %w":                                                            "无法加载配置单元 %s: %w",
+	"failed to open hive %s: %w":                                                            "无法打开配置单元 %s: %w",
+	"loaded %s at HKEY_USERS\\%s":                                                           "已将 %s 加载到 HKEY_USERS\\%s",
+	"failed to unload HKEY_USERS\\%s: %v":                                                   "无法卸载 HKEY_USERS\\%s: %v",
+	"generate expects a kind":                                                               "generate 需要指定类型",
+	"unknown kind %q":                                                                       "未知类型 %q",
+	"failed to render docs: %w":                                                             "无法渲染文档: %w",
+	"unknown format %q, expected %q":                                                        "未知格式 %q, 应为 %q",
+	"unknown format %q, expected %q, %q or %q":                                              "未知格式 %q, 应为 %q、%q 或 %q",
+	"%s does not have the extension of %s files":                                            "%s 没有 %s 文件的扩展名",
+	"%s already exists, use --force to overwrite it":                                        "%s 已存在, 使用 --force 覆盖",
+	"%s is not formatted, run \"context-menu-manager fmt\"":                                 "%s 未格式化, 请运行 \"context-menu-manager fmt\"",
+	"diff expects no arguments, or two manifests to compare":                                "diff 不需要参数, 或需要两个要比较的清单",
+	"file to write the merged manifest to (default: standard output)":                       "要写入合并后清单的文件 (默认: 标准输出)",
+	"merge expects [BASE] MINE THEIRS":                                                      "merge 需要参数 [BASE] MINE THEIRS",
+	"%s: changed in mine but removed in theirs, kept it":                                    "%s: 在 mine 中被修改, 但在 theirs 中被删除, 已保留",
+	"%s: removed in mine but changed in theirs, kept it":                                    "%s: 在 mine 中被删除, 但在 theirs 中被修改, 已保留",
+	"%s and %s have the same title %s":                                                      "%s 和 %s 的标题相同, 都是 %s",
+	"%s: %s is %s in mine and %s in theirs":                                                 "%s: %s 在 mine 中为 %s, 在 theirs 中为 %s",
+	"%d conflicts, see above; mine was kept for each":                                       "%d 处冲突, 见上文; 均保留了 mine 的内容",
+	"%s is only available on Windows":                                                       "%s 仅在 Windows 上可用",
+	"no file manager of %s is supported yet":                                                "尚不支持 %s 上的任何文件管理器",
+	"failed to locate the config folder: %w":                                                "无法定位配置文件夹: %w",
+	"Thunar cannot hide single custom actions, remove item ID %q from the manifest instead": "Thunar 无法隐藏单个自定义操作, 请改为从清单中移除项目 ID %q",
+	"failed to refresh the Services menu: %w":                                               "无法刷新服务菜单: %w",
+	"Quick Actions are turned on and off in System Settings, under Extensions":              "快速操作需在系统设置的扩展中开启或关闭",
+	"failed to locate the home folder: %w":                                                  "无法定位主文件夹: %w",
+	"failed to remove %s: %w":                                                               "无法删除 %s: %w",
+	"failed to prune %s: %w":                                                                "无法清理 %s: %w",
+	"failed to update %s: %w":                                                               "无法更新 %s: %w",
+	"standard input is not a terminal: %w":                                                  "标准输入不是终端: %w",
+	"failed to set terminal mode: %w":                                                       "无法设置终端模式: %w",
+	"a TOML document must be a table":                                                       "TOML 文档必须是一个表",
+	"line %d: %w":                                                                           "第 %d 行: %w",
+	"unknown format %q, expected %q or %q":                                                  "未知格式 %q, 应为 %q 或 %q",
+	"import expects one --from-... option":                                                  "import 需要一个 --from-... 选项",
+	"invalid elevation backend %q, expected %q or %q":                                       "无效的提权方式 %q, 应为 %q 或 %q",
+	"unknown platform %q in %s, expected %q, %q or %q":                                      "%[2]s 中的未知平台 %[1]q, 应为 %[3]q、%[4]q 或 %[5]q",
+	"invalid backend %q, expected %q or %q":                                                 "无效的后端 %q, 应为 %q 或 %q",
+	"the %q backend only works inside WSL":                                                  "%q 后端只能在 WSL 中使用",
+	"failed to read the output of %s: %w":                                                   "无法读取 %s 的输出: %w",
+	"%s failed: %w":                                                                         "%s 运行失败: %w",
+	"%s not found next to this executable or on PATH: %w":                                   "在本程序旁边或 PATH 中找不到 %s: %w",
+	"failed to translate %s to a Windows path: %w":                                          "无法将 %s 转换为 Windows 路径: %w",
+	"invalid file manager %q":                                                               "无效的文件管理器 %q",
+	"invalid hive %q, expected %q or %q":                                                    "无效的配置单元 %q, 应为 %q 或 %q",
+	"invalid language %q":                                                                   "无效的语言 %q",
+	"invalid listen address %q: %w":                                                         "无效的监听地址 %q: %w",
+	"invalid log level %q":                                                                  "无效的日志级别 %q",
+	"invalid request body: ":                                                                "无效的请求内容: ",
+	"item ID %q has no icon":                                                                "项目 ID %q 没有图标",
+	"item ID %q is not applied":                                                             "项目 ID %q 尚未应用",
+	"item ID %q not found in manifest":                                                      "清单中找不到项目 ID %q",
+	"item ID %q: %w":                                                                        "项目 ID %q: %w",
+	"items must be a list or an object, got %v":                                             "items 必须是列表或对象, 实际为 %v",
+	"an item must be an object, got %v":                                                     "项目必须是对象, 实际为 %v",
+	"command must be a list of arguments, got %v":                                           "command 必须是参数列表, 实际为 %v",
+	"iconPath must be a path or a list of paths, got %v":                                    "iconPath 必须是路径或路径列表, 实际为 %v",
+	"manifest.json not found: %w":                                                           "找不到 manifest.json: %w",
+	"host %q is not a loopback address":                                                     "主机 %q 不是回环地址",
+	"missing or invalid token":                                                              "令牌缺失或无效",
+	"monochrome icon %d in %q is not supported":                                             "不支持 %[2]q 中的单色图标 %[1]d",
+	"nircmd.exe not found: %w":                                                              "找不到 nircmd.exe: %w",
+	"no icon %d in %q":                                                                      "%[2]q 中没有图标 %[1]d",
+	"no such endpoint: ":                                                                    "没有此接口: ",
+	"item ID %q is not a folder":                                                            "项目 ID %q 不是文件夹",
+	"%s has comments, which saving it would drop; reorder its items in the file instead":    "%s 含有注释, 保存会丢失这些注释; 请直接在文件中调整项目顺序",
+	"order must list every child of %q exactly once":                                        "order 必须恰好列出 %q 的每个子项一次",
+	"refusing to listen on non-loopback address %q":                                         "拒绝监听非回环地址 %q",
+	"standard input is not a console: %w":                                                   "标准输入不是控制台: %w",
+	"standard output is not a console: %w":                                                  "标准输出不是控制台: %w",
+	"toggle expects exactly one item ID":                                                    "toggle 需要且只需要一个项目 ID",
+	"unknown command %q":                                                                    "未知命令 %q",
+
+	// manifest problems
+	"ID is empty":                    "ID 为空",
+	`ID must not contain "/" or "\"`: `ID 不能包含 "/" 或 "\"`,
+	"title is empty":                 "标题为空",
+	"items are nested %d submenus deep, Explorer does not show submenus deeper than %d":                            "项目嵌套了 %d 层子菜单，资源管理器不显示超过 %d 层的子菜单",
+	"items are nested %d submenus deep, which is hard to reach":                                                    "项目嵌套了 %d 层子菜单，很难找到",
+	"folder has %d items, some Windows versions only show %d; splitFolders moves the rest into a \"More…\" folder": "文件夹有 %d 个项目，某些 Windows 版本只显示 %d 个；splitFolders 会把其余项目移到“更多…”文件夹",
+	"More…": "更多…",
+	"move the items of folders past the 16 some Windows versions show into \"More…\" folders":                                       "把文件夹中超出某些 Windows 版本所显示的 16 个的项目移到“更多…”文件夹",
+	"item ID %q on target %q would replace %s":                                                                                      "目标 %[2]q 上的项目 ID %[1]q 会替换 %[3]s",
+	"item ID %q on target %q has the same name as %s\\%s of other software, which Explorer merges with it":                          "目标 %[2]q 上的项目 ID %[1]q 与其他软件的 %[3]s\\%[4]s 同名，资源管理器会把它们合并",
+	"%d item(s) have the name of a verb that was not applied from the manifest, rename them or replace the verbs with --force:\n%s": "%d 个项目与不是从清单应用的命令同名，请重命名它们，或用 --force 替换这些命令：\n%s",
+	"access to %d registry key(s) was denied, nothing was written; run as administrator or apply to another hive:\n%s":              "访问 %d 个注册表项被拒绝, 未写入任何内容; 请以管理员身份运行或应用到其他配置单元:\n%s",
+	"retrying in %v: %v":          "%v 后重试: %v",
+	"item ID %q on target %q: %v": "目标 %[2]q 上的项目 ID %[1]q: %[3]v",
+	"pruning %s: %v":              "清理 %s: %v",
+	"%d item(s) could not be applied, the others were applied:\n%s":                                "%d 个项目无法应用, 其他项目已应用:\n%s",
+	"write an item for several extensions once, under \"*\" limited to them, rather than for each": "把用于多个扩展名的项目只写入一次, 写在 \"*\" 下并限定为这些扩展名, 而不是为每个扩展名各写一次",
+	"line %d, column %d: %s":   "第 %d 行, 第 %d 列: %s",
+	"line %d, column %d: %s\n": "第 %d 行, 第 %d 列: %s\n",
+	"interrupted, stopping after the items being written; press Ctrl+C again to quit at once":                       "已中断, 将在写完正在写入的项目后停止; 再按一次 Ctrl+C 立即退出",
+	"apply was interrupted after %d of %d file(s): %w":                                                              "应用在 %d/%d 个文件后被中断: %w",
+	"apply was interrupted after %d of %d item(s), undo.reg restores the keys it changed: %w":                       "应用在 %d/%d 个项目后被中断, undo.reg 可以恢复已更改的注册表项: %w",
+	"manifest is %d bytes, more than the limit of %d":                                                               "清单有 %d 字节, 超过了 %d 的上限",
+	"manifest nests more than %d levels deep":                                                                       "清单嵌套超过 %d 层",
+	"manifest must be an object, got %v":                                                                            "清单必须是对象, 实际为 %v",
+	"unexpected %v after the end of the manifest":                                                                   "清单结束后出现意外的 %v",
+	"json: unknown field %q":                                                                                        "json: 未知字段 %q",
+	"manifest nests more than %d levels deep at line %d":                                                            "清单在第 %[2]d 行嵌套超过 %[1]d 层",
+	"manifest is more than %d bytes once its aliases are expanded":                                                  "清单展开别名后超过 %d 字节",
+	"unknown icon preset %q, use one of %s":                                                                         "未知的图标预设 %q，请使用以下之一：%s",
+	"iconIndex is set for the icon preset %q, which has an index of its own":                                        "图标预设 %q 自带索引，不能再设置 iconIndex",
+	"give titles without an \"&\" access key one that their siblings do not use":                                    "为没有“&”访问键的标题分配一个同级项目未使用的访问键",
+	"command of a recent folder does not use %s":                                                                    "最近项目文件夹的命令没有使用 %s",
+	"unknown source %q, expected %q":                                                                                "未知来源 %q，应为 %q",
+	"limit must not be negative":                                                                                    "limit 不能为负数",
+	"source and limit are only used for type %q":                                                                    "source 和 limit 只用于类型 %q",
+	"failed to list recent files: %v":                                                                               "无法列出最近使用的文件：%v",
+	"failed to read the recently used files: %w":                                                                    "无法读取最近使用的文件：%w",
+	"failed to parse the recently used files: %w":                                                                   "无法解析最近使用的文件：%w",
+	"how often to update the folders generated from recent files, scripts, terminal profiles and WSL distributions": "更新由最近文件、脚本、终端配置文件和 WSL 发行版生成的文件夹的频率",
+	"Refreshing generated folders failed":                                                                           "更新自动生成的文件夹失败",
+	"path is empty":                                                                                                 "path 为空",
+	"path is only used for type %q":                                                                                 "path 只用于类型 %q",
+	"failed to read scripts folder %s: %v":                                                                          "无法读取脚本文件夹 %s：%v",
+	"Open here with %s":                                                                                             "在此处用 %s 打开",
+	"failed to read the Windows Terminal settings: %v":                                                              "无法读取 Windows Terminal 设置：%v",
+	"failed to parse the Windows Terminal settings: %v":                                                             "无法解析 Windows Terminal 设置：%v",
+	"Open %s shell here":                                                                                            "在此处打开 %s shell",
+	"failed to list the WSL distributions: %v":                                                                      "无法列出 WSL 发行版：%v",
+	"Open folder in ... items for the editors installed here":                                                       "为本机已安装的编辑器生成“在 ... 中打开文件夹”菜单项",
+	"Open folder in %s":                                                                                             "在 %s 中打开文件夹",
+	"no known editors found":                                                                                        "未找到已知的编辑器",
+	"Wrote %d item(s) to %s. Add them to a manifest with: merge --output MANIFEST MANIFEST %s\n":                    "已将 %d 个菜单项写入 %s。可用以下命令将其加入清单：merge --output MANIFEST MANIFEST %s\n",
+	"%s not found": "未找到 %s",
+	`"json", "yaml" or "toml", for standard output; --output goes by its extension`: "输出到标准输出时的格式：\"json\"、\"yaml\" 或 \"toml\"；--output 按其扩展名决定",
+	"failed to find this executable for copy-path: %v":                              "无法找到用于 copy-path 的本程序：%v",
+	"failed to find this executable for run-elevated: %w":                           "无法找到用于 run-elevated 的本程序：%w",
+	"no program to run":                       "没有要运行的程序",
+	"failed to start %s as administrator: %w": "无法以管理员身份启动 %s：%w",
+	"admin items refer to %s at its path on this machine, which the target machines need as well": "管理员项目引用本机路径上的 %s, 目标计算机也需要在该路径上有它",
+	`"windows", "forwardSlash", "wsl" or "unc"`:                                                   `"windows"、"forwardSlash"、"wsl" 或 "unc"`,
+	"unknown path format %q, expected one of %s":                                                  "未知路径格式 %q，应为以下之一：%s",
+	"no path to copy":                      "没有要复制的路径",
+	"failed to copy to the clipboard: %w":  "无法复制到剪贴板：%w",
+	"none of %s found":                     "未找到 %s 中的任何一个",
+	"failed to open the clipboard: %w":     "无法打开剪贴板：%w",
+	"failed to empty the clipboard: %w":    "无法清空剪贴板：%w",
+	"failed to allocate memory: %w":        "无法分配内存：%w",
+	"failed to lock memory: %w":            "无法锁定内存：%w",
+	"failed to set the clipboard text: %w": "无法设置剪贴板文本：%w",
+	"pathFormat is only used for type %q":  "pathFormat 只用于类型 %q",
+	`Explorer shows no context menus at all, by the policy "Remove File Explorer's default context menu"`:                                                             `资源管理器完全不显示上下文菜单，由策略“删除文件资源管理器的默认上下文菜单”导致`,
+	`only approved shell extensions load, by the policy "Allow only per user or approved shell extensions"; static verbs, which are what is applied here, still show`: `由策略“仅允许每个用户或已批准的 Shell 扩展”导致只加载已批准的 Shell 扩展；本工具应用的静态动词仍会显示`,
+	"%d shell extension(s) are blocked, which hides their menus; static verbs, which are what is applied here, still show":                                            "%d 个 Shell 扩展被阻止，其菜单不会显示；本工具应用的静态动词仍会显示",
+	"%v; items cannot be written there. On managed devices a policy usually sets these permissions, ask an administrator or apply to the other hive":                  "%v；无法在此写入菜单项。在受管理的设备上，这些权限通常由策略设置，请联系管理员或应用到另一个配置单元",
+	"No policies found that keep the menus from showing.":                                                                                                             "未发现导致菜单无法显示的策略。",
+	"%d problem(s) keep the menus from showing": "%d 个问题导致菜单无法显示",
+	"the items will not show: %s":               "菜单项将不会显示：%s",
+	"item ID %q would replace %s":               "项目 ID %q 将替换 %s",
+	"%d item(s) would replace files that were not applied from the manifest, rename them or replace the files with --force:\n%s": "%d 个项目将替换并非由清单应用的文件，请重命名这些项目，或使用 --force 替换这些文件：\n%s",
+	"item ID %q would be written as %q, which is not a file name, change its title":                                              "项目 ID %q 将被写为 %q，这不是有效的文件名，请修改其标题",
+	"this build has no release public key to verify updates with, download the release by hand":                                  "此版本没有用于验证更新的发布公钥，请手动下载新版本",
+	"unexpected length %d": "长度 %d 不符合预期",
+	"invalid %s: %w":       "无效的 %s：%w",
+	"warning: ":            "警告：",
+	"title contains %U, left by text that was not valid Unicode":        "标题含有 %U，来自不是有效 Unicode 的文本",
+	"title contains control character %U":                               "标题含有控制字符 %U",
+	"title has bidirectional formatting characters that are not closed": "标题中的双向文本格式字符没有闭合",
+	"title has leading or trailing spaces, run fmt to remove them":      "标题开头或结尾有空格，运行 fmt 可以去掉",
+	"title is %d characters long, menus may cut it off after about %d":  "标题长 %d 个字符，菜单可能在约 %d 个字符后截断",
+	"iconIndex is set without iconPath":                                 "设置了 iconIndex 但没有 iconPath",
+	"targets are only used on top-level items":                          "targets 仅对顶层项目有效",
+	"command is empty":                    "命令为空",
+	"command for %s is empty":             "%s 的命令为空",
+	"items are ignored for type %q":       "类型 %q 会忽略 items",
+	"folder has no items":                 "文件夹中没有项目",
+	"command is ignored for type %q":      "类型 %q 会忽略 command",
+	"unknown type %q, expected one of %s": "未知类型 %q，应为以下之一：%s",
+	"manifest has no items":               "清单中没有项目",
+
+	// tray
+	"Enabled":           "启用",
+	"Re-apply manifest": "重新应用清单",
+	"Check for drift":   "检查偏离",
+	"Exit":              "退出",
+	"Toggle failed":     "切换失败",
+	"Apply failed":      "应用失败",
+	"Manifest applied":  "清单已应用",
+	"All context menu items were written to the registry.": "所有右键菜单项目已写入注册表。",
+	"Drift check failed":    "偏离检查失败",
+	"Context menus drifted": "右键菜单已偏离",
+	"%d registry differences from the manifest. Choose \"Re-apply manifest\" to fix them.": "注册表与清单存在 %d 处差异。请选择 \"重新应用清单\" 进行修复。",
+
+	// editor
+	"New manifest, press s to save it.": "新清单, 按 s 保存。",
+	"Discard unsaved changes?":          "放弃未保存的更改?",
+	"Only folders can have children.":   "只有文件夹可以包含子项。",
+	"New item ID: ":                     "新项目 ID: ",
+	"Type (item/folder): ":              "类型 (item/folder): ",
+	"Delete %q?":                        "删除 %q?",
+	"Edit: [t]itle [c]ommand [p] icon path [n] icon index [y]type [r]ename [x] extended [a]dmin": "编辑: [t]标题 [c]命令 [p]图标路径 [n]图标索引 [y]类型 [r]重命名 [x]扩展 [a]管理员",
+	"Title: ":                       "标题: ",
+	"ID: ":                          "ID: ",
+	"Command: ":                     "命令: ",
+	"Icon path: ":                   "图标路径: ",
+	"Icon index (empty for none): ": "图标索引 (留空表示无): ",
+	"Invalid icon index %q.":        "无效的图标索引 %q。",
+	" Pick an icon preset (Enter to choose, Esc to cancel)":  " 选择预设图标 (Enter 选择, Esc 取消)",
+	"Saved with %d problem(s).":                              "已保存, 存在 %d 个问题。",
+	"Saved.":                                                 "已保存。",
+	`IDs must be non-empty and must not contain "/" or "\".`: `ID 不能为空, 且不能包含 "/" 或 "\"。`,
+	"ID %q is already used at this level.":                   "此层级已存在 ID %q。",
+	" (modified)":                                            " (已修改)",
+	" (extended)":                                            " (扩展)",
+	" (admin)":                                               " (管理员)",
+	" No problems found.":                                    " 未发现问题。",
+	" %d problem(s): %s":                                     " %d 个问题: %s",
+	" arrows move/expand  a add  c add child  e edit  i icon  d delete  K/J reorder  s save  q quit": " 方向键 移动/展开  a 添加  c 添加子项  e 编辑  i 图标  d 删除  K/J 排序  s 保存  q 退出",
+	" No items, press a to add one.": " 没有项目, 按 a 添加。",
+	" Title:    ":                    " 标题:     ",
+	" Type:     ":                    " 类型:     ",
+	" Command:  ":                    " 命令:     ",
+	" Icon:     ":                    " 图标:     ",
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// userLanguages reads the locale environment variables the way gettext does, such as
+// "zh_CN.UTF-8" or the colon separated LANGUAGE list.
+func userLanguages() (languages []string) {
+	for _, name := range []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		for _, language := range strings.Split(os.Getenv(name), ":") {
+			if language = strings.SplitN(strings.SplitN(language, ".", 2)[0], "_", 2)[0]; language != "" && language != "C" && language != "POSIX" {
+				languages = append(languages, language)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// formatVerbs maps the arguments of a format to the verbs that print them, following explicit
+// argument indexes such as %[2]q.
+func formatVerbs(format string) (verbs map[int]string) {
+	var arg int
+	verbs = make(map[int]string)
+	for _, m := range formatVerb.FindAllStringSubmatch(format, -1) {
+		if m[3] == "%" {
+			continue
+		}
+		if m[1] != "" {
+			arg, _ = strconv.Atoi(m[1])
+		} else {
+			arg++
+		}
+		verbs[arg] = m[2] + m[3]
+	}
+	return
+}
+
+var formatVerb = regexp.MustCompile(`%(?:\[(\d+)\])?([-+# 0]*\d*(?:\.\d+)?)([vTtbcdqxXUeEfFgGspw%])`)
+
+// TestCatalogVerbs checks that every translation prints the arguments of its message with the same
+// verbs, so that they still match.
+func TestCatalogVerbs(t *testing.T) {
+	for language, catalog := range catalogs {
+		for message, translated := range catalog {
+			if want, got := formatVerbs(message), formatVerbs(translated); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s translation of %q prints %v, expected %v", language, message, got, want)
+			}
+		}
+	}
+}
+
+// TestCatalogComplete checks that the messages passed to tr, sprintf, errorf and logf, and the
+// descriptions of flags, have a translation in every catalog.
+func TestCatalogComplete(t *testing.T) {
+	var (
+		fset     = token.NewFileSet()
+		messages = make(map[string]token.Position)
+		flagKind = map[string]bool{"String": true, "Bool": true, "Int": true, "Duration": true}
+	)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			arg := -1
+			switch fun := call.Fun.(type) {
+			case *ast.Ident:
+				switch fun.Name {
+				case "tr", "sprintf", "errorf":
+					arg = 0
+				case "logf":
+					arg = 1
+				}
+			case *ast.SelectorExpr:
+				if id, ok := fun.X.(*ast.Ident); ok && id.Name == "flags" && flagKind[fun.Sel.Name] {
+					arg = 2
+				}
+			}
+			if arg < 0 || arg >= len(call.Args) {
+				return true
+			}
+			if lit, ok := call.Args[arg].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				// Messages that are only verbs and punctuation, such as "%s: %s", need no translation.
+				if message, err := strconv.Unquote(lit.Value); err == nil && strings.IndexFunc(formatVerb.ReplaceAllString(message, ""), unicode.IsLetter) >= 0 {
+					messages[message] = fset.Position(lit.Pos())
+				}
+			}
+			return true
+		})
+	}
+	for language, catalog := range catalogs {
+		if language == "en" {
+			continue
+		}
+		for message, pos := range messages {
+			if _, ok := catalog[message]; !ok {
+				t.Errorf("%s: %s has no translation of %q", pos, language, message)
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// userLanguages are the Windows UI languages in order of preference, such as "zh-CN".
+func userLanguages() []string {
+	languages, _ := windows.GetUserPreferredUILanguages(windows.MUI_LANGUAGE_NAME)
+	return languages
+}
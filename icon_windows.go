@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	gdi32 = windows.NewLazySystemDLL("gdi32.dll")
+
+	procExtractIconExW = shell32.NewProc("ExtractIconExW")
+	procDestroyIcon    = user32.NewProc("DestroyIcon")
+	procGetIconInfo    = user32.NewProc("GetIconInfo")
+	procGetDC          = user32.NewProc("GetDC")
+	procReleaseDC      = user32.NewProc("ReleaseDC")
+	procGetObjectW     = gdi32.NewProc("GetObjectW")
+	procGetDIBits      = gdi32.NewProc("GetDIBits")
+	procDeleteObject   = gdi32.NewProc("DeleteObject")
+)
+
+type iconInfo struct {
+	IsIcon   int32
+	XHotspot uint32
+	YHotspot uint32
+	Mask     windows.Handle
+	Color    windows.Handle
+}
+
+type bitmap struct {
+	Type       int32
+	Width      int32
+	Height     int32
+	WidthBytes int32
+	Planes     uint16
+	BitsPixel  uint16
+	Bits       uintptr
+}
+
+type bitmapInfoHeader struct {
+	Size          uint32
+	Width         int32
+	Height        int32
+	Planes        uint16
+	BitCount      uint16
+	Compression   uint32
+	SizeImage     uint32
+	XPelsPerMeter int32
+	YPelsPerMeter int32
+	ClrUsed       uint32
+	ClrImportant  uint32
+}
+
+// resolveIconFile expands environment variables and finds bare file names the way the shell would.
+func resolveIconFile(iconFile string) (resolved string, err error) {
+	var (
+		buf [windows.MAX_LONG_PATH]uint16
+		n   uint32
+	)
+	if n, err = windows.ExpandEnvironmentStrings(windows.StringToUTF16Ptr(iconFile), &buf[0], uint32(len(buf))); err != nil {
+		err = errorf("failed to expand %q: %w", iconFile, err)
+		return
+	}
+	resolved = windows.UTF16ToString(buf[:n])
+	if filepath.IsAbs(resolved) {
+		return
+	}
+	if system, sysErr := windows.GetSystemDirectory(); sysErr == nil {
+		if _, statErr := os.Stat(filepath.Join(system, resolved)); statErr == nil {
+			resolved = filepath.Join(system, resolved)
+			return
+		}
+	}
+	if path, lookErr := exec.LookPath(resolved); lookErr == nil {
+		resolved = path
+	}
+	return
+}
+
+// iconFileExists tells whether the shell finds iconFile, for picking among icon fallbacks.
+func iconFileExists(iconFile string) bool {
+	var resolved, err = resolveIconFile(iconFile)
+	if err == nil {
+		_, err = os.Stat(resolved)
+	}
+	return err == nil
+}
+
+// extractIconPNG renders the small icon at index of iconFile as PNG. Negative indexes are
+// resource IDs, as in the registry Icon value.
+func extractIconPNG(iconFile string, index int) (data []byte, err error) {
+	var (
+		icon   uintptr
+		info   iconInfo
+		bm     bitmap
+		pixels []byte
+		mask   []byte
+		img    *image.NRGBA
+		buf    bytes.Buffer
+	)
+	if iconFile, err = resolveIconFile(iconFile); err != nil {
+		return
+	}
+	if n, _, _ := procExtractIconExW.Call(uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(iconFile))), uintptr(index), 0, uintptr(unsafe.Pointer(&icon)), 1); n == 0 || icon == 0 {
+		err = errorf("no icon %d in %q", index, iconFile)
+		return
+	}
+	defer procDestroyIcon.Call(icon)
+	if ok, _, callErr := procGetIconInfo.Call(icon, uintptr(unsafe.Pointer(&info))); ok == 0 {
+		err = errorf("failed to get icon info: %w", callErr)
+		return
+	}
+	defer procDeleteObject.Call(uintptr(info.Mask))
+	defer procDeleteObject.Call(uintptr(info.Color))
+	if info.Color == 0 {
+		err = errorf("monochrome icon %d in %q is not supported", index, iconFile)
+		return
+	}
+	procGetObjectW.Call(uintptr(info.Color), unsafe.Sizeof(bm), uintptr(unsafe.Pointer(&bm)))
+	if pixels, err = bitmapPixels(info.Color, int(bm.Width), int(bm.Height)); err != nil {
+		return
+	}
+	img = image.NewNRGBA(image.Rect(0, 0, int(bm.Width), int(bm.Height)))
+	hasAlpha := false
+	for i := 3; i < len(pixels); i += 4 {
+		if pixels[i] != 0 {
+			hasAlpha = true
+			break
+		}
+	}
+	if !hasAlpha {
+		if mask, err = bitmapPixels(info.Mask, int(bm.Width), int(bm.Height)); err != nil {
+			return
+		}
+	}
+	for i := 0; i < len(pixels); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = pixels[i+2], pixels[i+1], pixels[i], pixels[i+3]
+		if !hasAlpha {
+			img.Pix[i+3] = 0xff
+			if mask[i] != 0 {
+				img.Pix[i+3] = 0
+			}
+		}
+	}
+	if err = png.Encode(&buf, img); err != nil {
+		return
+	}
+	data = buf.Bytes()
+	return
+}
+
+// bitmapPixels reads a bitmap as top-down 32-bit BGRA.
+func bitmapPixels(bmp windows.Handle, width, height int) (pixels []byte, err error) {
+	var header = bitmapInfoHeader{Width: int32(width), Height: -int32(height), Planes: 1, BitCount: 32}
+	header.Size = uint32(unsafe.Sizeof(header))
+	pixels = make([]byte, width*height*4)
+	dc, _, _ := procGetDC.Call(0)
+	defer procReleaseDC.Call(0, dc)
+	if lines, _, callErr := procGetDIBits.Call(dc, uintptr(bmp), 0, uintptr(height), uintptr(unsafe.Pointer(&pixels[0])), uintptr(unsafe.Pointer(&header)), 0); lines == 0 {
+		err = errorf("failed to read icon bitmap: %w", callErr)
+	}
+	return
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// importer converts another tool's export into top-level manifest items, each with its own targets.
+type importer struct {
+	Name        string
+	Description string
+	Parse       func(data []byte) (items ContextMenus, skipped int, err error)
+}
+
+var importers = []importer{
+	{"shellmenuview", "NirSoft ShellMenuView or ShellExView export (text report, or CSV/tab delimited with a header line)", parseShellMenuView},
+	{"ecm", "Easy Context Menu list (.ecm)", parseEasyContextMenu},
+	{"reg", "registry export (.reg), such as a Right Click Enhancer backup", parseRegFile},
+	{"nilesoft", "Nilesoft Shell config (.nss), static menu and item definitions only", parseNilesoft},
+	{"filemenutools", "FileMenu Tools command list export (.xml)", parseFileMenuTools},
+}
+
+func runImport(args []string) (err error) {
+	var (
+		flags        = newFlagSet("import")
+		manifestPath = flags.String("manifest", "", "manifest to add the items to (default: the manifest found by apply)")
+		imp          *importer
+		source       string
+		data         []byte
+		items        ContextMenus
+		skipped      int
+		manifest     *Manifest
+	)
+	for _, i := range importers {
+		flags.String("from-"+i.Name, "", i.Description)
+	}
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	flags.Visit(func(f *flag.Flag) {
+		for i := range importers {
+			if f.Name == "from-"+importers[i].Name && imp == nil {
+				imp, source = &importers[i], f.Value.String()
+			}
+		}
+	})
+	if imp == nil {
+		flags.Usage()
+		err = errorf("import expects one --from-... option")
+		return
+	}
+	if data, err = os.ReadFile(source); err != nil {
+		err = errorf("failed to read %s: %w", source, err)
+		return
+	}
+	if items, skipped, err = imp.Parse(decodeText(data)); err != nil {
+		err = errorf("failed to parse %s: %w", source, err)
+		return
+	}
+	if *manifestPath, manifest, _, err = openManifest(*manifestPath); err != nil {
+		return
+	}
+	for _, entry := range items {
+		entry.ID = uniqueID(manifest.Items, entry.ID)
+		manifest.Items = append(manifest.Items, entry)
+	}
+	if err = writeManifest(*manifestPath, manifest); err != nil {
+		return
+	}
+	fmt.Printf(tr("Imported %d items into %s.\n"), len(items), *manifestPath)
+	if skipped > 0 {
+		fmt.Printf(tr("Skipped %d entries that cannot be imported, such as shell extensions and entries without a command.\n"), skipped)
+	}
+	return
+}
+
+// decodeText converts UTF-16 exports, which NirSoft tools and regedit write by default, to UTF-8.
+func decodeText(data []byte) []byte {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xfe {
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	}
+	var units = make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+func uniqueID(menus ContextMenus, id string) string {
+	var unique = id
+	for n := 2; menus.Get(unique) != nil; n++ {
+		unique = fmt.Sprintf("%s-%d", id, n)
+	}
+	return unique
+}
+
+// addImported appends an item for target, or adds target to an identical item imported before,
+// since the same verb is often registered for several classes.
+func addImported(items *ContextMenus, id string, item *ContextMenu, target string) {
+	for _, entry := range *items {
+		if sameMenu(entry.Menu, item) {
+			for _, t := range entry.Menu.Targets {
+				if strings.EqualFold(t, target) {
+					return
+				}
+			}
+			entry.Menu.Targets = append(entry.Menu.Targets, target)
+			return
+		}
+	}
+	item.Targets = []string{target}
+	id = strings.NewReplacer("/", "-", `\`, "-").Replace(id)
+	*items = append(*items, ContextMenuEntry{ID: uniqueID(*items, id), Menu: item})
+}
+
+// parseShellMenuView reads the "Save Selected Items" text report, whose records are "Field : value"
+// lines separated by "=====" rules, or a CSV or tab delimited export with a header line.
+func parseShellMenuView(data []byte) (items ContextMenus, skipped int, err error) {
+	var records []map[string]string
+	if firstLine, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n"); strings.Contains(firstLine, "Menu Name") && !strings.Contains(firstLine, " : ") {
+		if records, err = readDelimited(data, firstLine); err != nil {
+			return
+		}
+	} else {
+		records = readReport(data)
+	}
+	for _, record := range records {
+		var (
+			class, verb = shellMenuViewKey(record)
+			item        = &ContextMenu{
+				Type:     ContextMenuType_Item,
+				Title:    record["menu name"],
+				Command:  splitCommandLine(record["command"]),
+				Extended: strings.EqualFold(record["extended"], "yes"),
+			}
+		)
+		if len(item.Command) == 0 || class == "" {
+			skipped++
+			continue
+		}
+		if verb == "" {
+			verb = strings.ReplaceAll(item.Title, "&", "")
+		}
+		if item.Title == "" {
+			item.Title = verb
+		}
+		addImported(&items, verb, item, targetForClass(class))
+	}
+	return
+}
+
+func readReport(data []byte) (records []map[string]string) {
+	var (
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+		record  map[string]string
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "=====") {
+			if len(record) > 0 {
+				records = append(records, record)
+			}
+			record = nil
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			if record == nil {
+				record = make(map[string]string)
+			}
+			record[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+	if len(record) > 0 {
+		records = append(records, record)
+	}
+	return
+}
+
+func readDelimited(data []byte, header string) (records []map[string]string, err error) {
+	var (
+		r    = csv.NewReader(bytes.NewReader(data))
+		rows [][]string
+	)
+	if strings.Contains(header, "\t") {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.FieldsPerRecord = -1
+	if rows, err = r.ReadAll(); err != nil {
+		return
+	}
+	for _, row := range rows[1:] {
+		var record = make(map[string]string)
+		for i, name := range rows[0] {
+			if i < len(row) {
+				record[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(row[i])
+			}
+		}
+		records = append(records, record)
+	}
+	return
+}
+
+// shellMenuViewKey finds the class and verb of a record, from its registry key when exported, or
+// from its extension or file type and menu key columns otherwise.
+func shellMenuViewKey(record map[string]string) (class, verb string) {
+	if key := record["registry key"]; key != "" {
+		key, _ = stripClassesRoot(key)
+		if i := strings.LastIndex(strings.ToLower(key), `\shell\`); i >= 0 {
+			class, verb = key[:i], key[i+len(`\shell\`):]
+			return
+		}
+	}
+	for _, name := range []string{"extension/type", "extension", "file type"} {
+		if class = record[name]; class != "" {
+			break
+		}
+	}
+	verb = record["menu key"]
+	return
+}
+
+// ecmLocations maps the menus Easy Context Menu adds entries to onto targets.
+var ecmLocations = map[string]string{
+	"desktop":    "desktop",
+	"background": "background",
+	"computer":   "drive",
+	"mycomputer": "drive",
+	"drive":      "drive",
+	"drives":     "drive",
+	"folder":     "directory",
+	"folders":    "directory",
+	"directory":  "directory",
+	"file":       "file",
+	"files":      "file",
+	"exe":        ".exe",
+}
+
+// parseEasyContextMenu reads an Easy Context Menu list, an INI file with one section per entry
+// holding its name, program, parameters, icon and the menu it is shown in.
+func parseEasyContextMenu(data []byte) (items ContextMenus, skipped int, err error) {
+	for _, section := range readINI(data) {
+		var (
+			value = func(names ...string) string {
+				for _, name := range names {
+					if v := section.values[name]; v != "" {
+						return v
+					}
+				}
+				return ""
+			}
+			item = &ContextMenu{
+				Type:     ContextMenuType_Item,
+				Title:    value("name", "title", "text", "menuname"),
+				Command:  splitCommandLine(value("command", "cmd")),
+				Extended: value("extended", "shift") == "1" || strings.EqualFold(value("extended", "shift"), "true"),
+				Admin:    value("admin", "runas", "runasadmin") == "1" || strings.EqualFold(value("admin", "runas", "runasadmin"), "true"),
+			}
+			location = strings.ToLower(strings.ReplaceAll(value("location", "menu", "key", "type"), " ", ""))
+			target   = ecmLocations[location]
+		)
+		if program := value("path", "program", "file", "exe"); program != "" {
+			item.Command = append([]string{strings.Trim(program, `"`)}, splitCommandLine(value("parameters", "params", "arguments", "args"))...)
+		}
+		if icon := value("icon", "iconpath"); icon != "" {
+			item.IconPath, item.IconIndex = splitIconLocation(icon)
+		}
+		if target == "" && location != "" {
+			target = location
+		} else if target == "" {
+			target = "desktop"
+		}
+		if len(item.Command) == 0 {
+			skipped++
+			continue
+		}
+		if item.Title == "" {
+			item.Title = section.name
+		}
+		addImported(&items, section.name, item, target)
+	}
+	return
+}
+
+type iniSection struct {
+	name   string
+	values map[string]string
+}
+
+// readINI returns the sections in file order, with lower case keys.
+func readINI(data []byte) (sections []iniSection) {
+	var scanner = bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, ";"), strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sections = append(sections, iniSection{name: strings.TrimSpace(line[1 : len(line)-1]), values: make(map[string]string)})
+		case len(sections) > 0:
+			if name, value, ok := strings.Cut(line, "="); ok {
+				sections[len(sections)-1].values[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return
+}
+
+// sameMenu compares two imported items regardless of their targets.
+func sameMenu(a, b *ContextMenu) bool {
+	var ca, cb = *a, *b
+	ca.Targets, cb.Targets = nil, nil
+	da, errA := marshalJSON(&ca, "")
+	db, errB := marshalJSON(&cb, "")
+	return errA == nil && errB == nil && bytes.Equal(da, db)
+}
+
+// stripClassesRoot removes the hive and classes key from a registry key path, as found in exports.
+func stripClassesRoot(key string) (rest string, ok bool) {
+	for _, prefix := range []string{`HKEY_CLASSES_ROOT\`, `HKCR\`, `HKEY_CURRENT_USER\Software\Classes\`, `HKCU\Software\Classes\`, `HKEY_LOCAL_MACHINE\SOFTWARE\Classes\`, `HKLM\SOFTWARE\Classes\`} {
+		if len(key) > len(prefix) && strings.EqualFold(key[:len(prefix)], prefix) {
+			return key[len(prefix):], true
+		}
+	}
+	return key, false
+}
+
+// parseRegFile converts the static verbs of a regedit export, which is also what Right Click
+// Enhancer and similar tools back up to. Verbs with a shell subkey become folders.
+func parseRegFile(data []byte) (items ContextMenus, skipped int, err error) {
+	type verbKey struct {
+		class string
+		path  []string
+	}
+	var (
+		menus   = make(map[string]*ContextMenu)
+		muiVerb = make(map[*ContextMenu]bool)
+		order   []verbKey
+		current *ContextMenu
+		command bool
+		menuFor = func(class string, path []string) *ContextMenu {
+			var key = strings.ToLower(class + `\` + strings.Join(path, `\`))
+			if menus[key] == nil {
+				menus[key] = &ContextMenu{Type: ContextMenuType_Item, Title: path[len(path)-1]}
+				order = append(order, verbKey{class, path})
+			}
+			return menus[key]
+		}
+	)
+	for _, line := range regLines(data) {
+		if strings.HasPrefix(line, "[") {
+			var (
+				class string
+				path  []string
+				ok    bool
+			)
+			current = nil
+			if class, path, command, ok = regVerbPath(strings.Trim(line, "[]")); !ok || strings.HasPrefix(line, "[-") {
+				continue
+			}
+			for i := 1; i < len(path); i++ {
+				menuFor(class, path[:i]).Type = ContextMenuType_Folder
+			}
+			current = menuFor(class, path)
+			continue
+		}
+		name, value, ok := parseRegValue(line)
+		switch {
+		case current == nil || !ok:
+		case command:
+			if name == "" {
+				current.Command = splitCommandLine(value)
+			}
+		case strings.EqualFold(name, "MUIVerb"):
+			current.Title, muiVerb[current] = value, true
+		case name == "":
+			if !muiVerb[current] && value != "" {
+				current.Title = value
+			}
+		case strings.EqualFold(name, "Icon"):
+			current.IconPath, current.IconIndex = splitIconLocation(value)
+		case strings.EqualFold(name, "Extended"):
+			current.Extended = true
+		case strings.EqualFold(name, "HasLUAShield"):
+			current.Admin = true
+		case strings.EqualFold(name, "SeparatorBefore"):
+			current.SeparatorBefore = true
+		case strings.EqualFold(name, "SeparatorAfter"):
+			current.SeparatorAfter = true
+		case strings.EqualFold(name, "SubCommands"):
+			current.Type = ContextMenuType_Folder
+		}
+	}
+	for _, key := range order {
+		if len(key.path) > 1 {
+			parent := menus[strings.ToLower(key.class+`\`+strings.Join(key.path[:len(key.path)-1], `\`))]
+			parent.Items = append(parent.Items, ContextMenuEntry{ID: key.path[len(key.path)-1], Menu: menuFor(key.class, key.path)})
+		}
+	}
+	for _, key := range order {
+		var item = menuFor(key.class, key.path)
+		switch {
+		case len(key.path) > 1:
+		case item.Type == ContextMenuType_Item && len(item.Command) == 0, item.Type == ContextMenuType_Folder && len(item.Items) == 0:
+			skipped++
+		default:
+			addImported(&items, key.path[0], item, targetForClass(key.class))
+		}
+	}
+	return
+}
+
+// regVerbPath splits a key such as HKEY_CLASSES_ROOT\Directory\shell\tools\shell\cmd\command into
+// its class, the path of verbs, and whether it is the command key of the last one.
+func regVerbPath(key string) (class string, path []string, command, ok bool) {
+	var (
+		parts []string
+		start = -1
+	)
+	if key, ok = stripClassesRoot(key); !ok {
+		return
+	}
+	parts = strings.Split(key, `\`)
+	for i := 1; i < len(parts) && start < 0; i++ {
+		if strings.EqualFold(parts[i], "shell") {
+			start = i
+		}
+	}
+	if ok = start > 0; !ok {
+		return
+	}
+	class = strings.Join(parts[:start], `\`)
+	for i := start; i < len(parts); i += 2 {
+		switch {
+		case strings.EqualFold(parts[i], "command") && i == len(parts)-1 && len(path) > 0:
+			command = true
+		case !strings.EqualFold(parts[i], "shell") || i+1 == len(parts):
+			ok = false
+			return
+		default:
+			path = append(path, parts[i+1])
+		}
+	}
+	return
+}
+
+// regLines joins the continuation lines of a .reg file and drops comments and blank lines.
+func regLines(data []byte) (lines []string) {
+	var pending string
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasSuffix(line, `\`) && !strings.HasPrefix(line, "[") {
+			pending += strings.TrimSuffix(line, `\`)
+			continue
+		}
+		line, pending = pending+line, ""
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return
+}
+
+// parseRegValue reads a string value line; other value types are reported as not ok, except
+// REG_EXPAND_SZ, which regedit writes as hex(2). So are lines deleting a value.
+func parseRegValue(line string) (name, value string, ok bool) {
+	var rest string
+	if strings.HasPrefix(line, "@=") {
+		rest = line[2:]
+	} else if name, rest, ok = cutRegString(line); !ok || !strings.HasPrefix(rest, "=") {
+		return "", "", false
+	} else {
+		rest = rest[1:]
+	}
+	switch {
+	case rest == "-":
+		return "", "", false
+	case strings.HasPrefix(rest, `"`):
+		value, _, ok = cutRegString(rest)
+	case strings.HasPrefix(strings.ToLower(rest), "hex(2):"):
+		var units []uint16
+		for i, part := range strings.Split(rest[len("hex(2):"):], ",") {
+			var b byte
+			if _, err := fmt.Sscanf(strings.TrimSpace(part), "%02x", &b); err != nil {
+				return "", "", false
+			}
+			if i%2 == 0 {
+				units = append(units, uint16(b))
+			} else {
+				units[len(units)-1] |= uint16(b) << 8
+			}
+		}
+		for len(units) > 0 && units[len(units)-1] == 0 {
+			units = units[:len(units)-1]
+		}
+		value, ok = string(utf16.Decode(units)), true
+	default:
+		value, ok = rest, true
+	}
+	return
+}
+
+// cutRegString reads a quoted .reg string at the start of s, undoing its \\ and \" escapes.
+func cutRegString(s string) (value, rest string, ok bool) {
+	var b strings.Builder
+	if !strings.HasPrefix(s, `"`) {
+		return
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return
+}
+
+// splitIconLocation splits an "path,index" icon location as used by the Icon value.
+func splitIconLocation(location string) (path string, index *int) {
+	path = location
+	if i := strings.LastIndex(location, ","); i > 0 {
+		if n, err := strconv.Atoi(strings.TrimSpace(location[i+1:])); err == nil {
+			path, index = location[:i], &n
+		}
+	}
+	path = strings.Trim(strings.TrimSpace(path), `"`)
+	return
+}
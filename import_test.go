@@ -0,0 +1,223 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "UTF-8", data: "[Tools]", want: "[Tools]"},
+		{name: "UTF-8 with a byte order mark", data: "\xef\xbb\xbf[Tools]", want: "[Tools]"},
+		{name: "UTF-16", data: "\xff\xfe[\x00\x53\x62\x00\x5f]\x00", want: "[打开]"},
+		{name: "UTF-16 with an odd byte", data: "\xff\xfeA\x00B", want: "A"},
+		{name: "empty", data: "", want: ""},
+	} {
+		if got := string(decodeText([]byte(test.data))); got != test.want {
+			t.Errorf("%s: decoded %q, expected %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseRegValue(t *testing.T) {
+	for _, test := range []struct {
+		line      string
+		wantName  string
+		wantValue string
+		wantOK    bool
+	}{
+		{line: `@="Open here"`, wantValue: "Open here", wantOK: true},
+		{line: `"MUIVerb"="Say \"hi\""`, wantName: "MUIVerb", wantValue: `Say "hi"`, wantOK: true},
+		{line: `"Icon"="C:\\Tools\\app.exe,0"`, wantName: "Icon", wantValue: `C:\Tools\app.exe,0`, wantOK: true},
+		{line: `"Extended"=""`, wantName: "Extended", wantOK: true},
+		{line: `"Position"=dword:00000001`, wantName: "Position", wantValue: "dword:00000001", wantOK: true},
+		{line: `@=hex(2):25,00,56,00,00,00`, wantValue: "%V", wantOK: true},
+		{line: `@=hex(2):zz`},
+		{line: `"Extended"=-`},
+		{line: `"Unterminated=""`},
+		{line: `Open=""`},
+	} {
+		name, value, ok := parseRegValue(test.line)
+		if name != test.wantName || value != test.wantValue || ok != test.wantOK {
+			t.Errorf("parseRegValue(%q) = %q, %q, %v, expected %q, %q, %v", test.line, name, value, ok, test.wantName, test.wantValue, test.wantOK)
+		}
+	}
+}
+
+func TestRegVerbPath(t *testing.T) {
+	for _, test := range []struct {
+		key         string
+		wantClass   string
+		wantPath    []string
+		wantCommand bool
+		wantOK      bool
+	}{
+		{key: `HKEY_CLASSES_ROOT\Directory\shell\tools`, wantClass: "Directory", wantPath: []string{"tools"}, wantOK: true},
+		{key: `HKEY_CLASSES_ROOT\Directory\shell\tools\command`, wantClass: "Directory", wantPath: []string{"tools"}, wantCommand: true, wantOK: true},
+		{key: `HKCU\Software\Classes\Directory\Background\shell\tools\shell\cmd`, wantClass: `Directory\Background`, wantPath: []string{"tools", "cmd"}, wantOK: true},
+		{key: `HKEY_LOCAL_MACHINE\SOFTWARE\Classes\SystemFileAssociations\.txt\Shell\Edit\Command`, wantClass: `SystemFileAssociations\.txt`, wantPath: []string{"Edit"}, wantCommand: true, wantOK: true},
+		{key: `HKEY_CLASSES_ROOT\Directory\shell`, wantClass: "Directory"},
+		{key: `HKEY_CLASSES_ROOT\Directory\shell\tools\ddeexec`, wantClass: "Directory", wantPath: []string{"tools"}},
+		{key: `HKEY_CLASSES_ROOT\Directory\shellex\ContextMenuHandlers\x`},
+		{key: `HKEY_CURRENT_USER\Software\Tools\shell\x`},
+	} {
+		class, path, command, ok := regVerbPath(test.key)
+		if ok != test.wantOK || ok && (class != test.wantClass || !reflect.DeepEqual(path, test.wantPath) || command != test.wantCommand) {
+			t.Errorf("regVerbPath(%q) = %q, %q, %v, %v, expected %q, %q, %v, %v", test.key, class, path, command, ok, test.wantClass, test.wantPath, test.wantCommand, test.wantOK)
+		}
+	}
+}
+
+func TestSplitIconLocation(t *testing.T) {
+	var index = func(i int) *int { return &i }
+	for _, test := range []struct {
+		location  string
+		wantPath  string
+		wantIndex *int
+	}{
+		{location: `C:\Tools\app.exe`, wantPath: `C:\Tools\app.exe`},
+		{location: `C:\Tools\app.exe,2`, wantPath: `C:\Tools\app.exe`, wantIndex: index(2)},
+		{location: `"C:\Program Files\App\app.exe", -101`, wantPath: `C:\Program Files\App\app.exe`, wantIndex: index(-101)},
+		{location: `C:\a,b\app.ico`, wantPath: `C:\a,b\app.ico`},
+		{location: ",1", wantPath: ",1"},
+	} {
+		path, i := splitIconLocation(test.location)
+		if path != test.wantPath || !reflect.DeepEqual(i, test.wantIndex) {
+			t.Errorf("splitIconLocation(%q) = %q, %v, expected %q, %v", test.location, path, i, test.wantPath, test.wantIndex)
+		}
+	}
+}
+
+// TestImporters checks the items each importer makes of an export: their titles, commands and
+// targets, verbs registered for several classes merged into one item, and what is skipped.
+func TestImporters(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		parse       func(data []byte) (ContextMenus, int, error)
+		data        string
+		want        string
+		wantSkipped int
+	}{
+		{
+			name:  "reg",
+			parse: parseRegFile,
+			data: "Windows Registry Editor Version 5.00\r\n\r\n" +
+				"[HKEY_CLASSES_ROOT\\Directory\\Background\\shell\\terminal]\r\n" +
+				"@=\"Terminal\"\r\n" +
+				"\"MUIVerb\"=\"Open Terminal\"\r\n" +
+				"\"Icon\"=\"C:\\\\Tools\\\\wt.exe,0\"\r\n" +
+				"\"Extended\"=\"\"\r\n\r\n" +
+				"[HKEY_CLASSES_ROOT\\Directory\\Background\\shell\\terminal\\command]\r\n" +
+				"@=\"wt.exe -d \\\"%V\\\"\"\r\n\r\n" +
+				"[HKEY_CLASSES_ROOT\\Directory\\shell\\terminal\\command]\r\n" +
+				"@=hex(2):77,00,74,00,2e,00,65,00,78,00,65,00,20,00,2d,00,64,00,20,00,22,00,25,00,\\\r\n" +
+				"  56,00,22,00,00,00\r\n\r\n" +
+				"[HKEY_CLASSES_ROOT\\Directory\\shell\\terminal]\r\n" +
+				"\"MUIVerb\"=\"Open Terminal\"\r\n" +
+				"\"Icon\"=\"C:\\\\Tools\\\\wt.exe,0\"\r\n" +
+				"\"Extended\"=\"\"\r\n\r\n" +
+				"; A folder of two items\r\n" +
+				"[HKEY_CURRENT_USER\\Software\\Classes\\*\\shell\\tools]\r\n" +
+				"\"MUIVerb\"=\"Tools\"\r\n" +
+				"\"SubCommands\"=\"\"\r\n\r\n" +
+				"[HKEY_CURRENT_USER\\Software\\Classes\\*\\shell\\tools\\shell\\hash]\r\n" +
+				"@=\"Hash\"\r\n" +
+				"\"HasLUAShield\"=\"\"\r\n\r\n" +
+				"[HKEY_CURRENT_USER\\Software\\Classes\\*\\shell\\tools\\shell\\hash\\command]\r\n" +
+				"@=\"certutil.exe -hashfile \\\"%1\\\"\"\r\n\r\n" +
+				"[HKEY_CURRENT_USER\\Software\\Classes\\*\\shell\\tools\\shell\\copy\\command]\r\n" +
+				"@=\"clip.exe\"\r\n\r\n" +
+				"[HKEY_CLASSES_ROOT\\Drive\\shell\\empty]\r\n" +
+				"@=\"No command\"\r\n\r\n" +
+				"[-HKEY_CLASSES_ROOT\\Drive\\shell\\removed]\r\n",
+			want: `{
+				"terminal": {"type": "item", "title": "Open Terminal", "iconPath": "C:\\Tools\\wt.exe", "iconIndex": 0, "extended": true, "command": ["wt.exe", "-d", "%V"], "targets": ["background", "directory"]},
+				"tools": {"type": "folder", "title": "Tools", "targets": ["file"], "items": {
+					"hash": {"type": "item", "title": "Hash", "admin": true, "command": ["certutil.exe", "-hashfile", "%1"]},
+					"copy": {"type": "item", "title": "copy", "command": ["clip.exe"]}
+				}}
+			}`,
+			wantSkipped: 1,
+		},
+		{
+			name:  "ShellMenuView report",
+			parse: parseShellMenuView,
+			data: "==================================================\r\n" +
+				"Menu Name         : &Open with Code\r\n" +
+				"Command           : \"C:\\Code\\code.exe\" \"%1\"\r\n" +
+				"Extension/Type    : txtfile\r\n" +
+				"Menu Key          : code\r\n" +
+				"Extended          : Yes\r\n" +
+				"==================================================\r\n\r\n" +
+				"==================================================\r\n" +
+				"Menu Name         : Scan\r\n" +
+				"Command           : \r\n" +
+				"Registry Key      : HKEY_CLASSES_ROOT\\*\\shell\\scan\r\n" +
+				"==================================================\r\n" +
+				"Menu Name         : Open here\r\n" +
+				"Command           : cmd.exe /k cd \"%V\"\r\n" +
+				"Registry Key      : HKEY_CLASSES_ROOT\\Directory\\shell\\cmdhere\r\n" +
+				"==================================================\r\n",
+			want: `{
+				"code": {"type": "item", "title": "&Open with Code", "extended": true, "command": ["C:\\Code\\code.exe", "%1"], "targets": ["txtfile"]},
+				"cmdhere": {"type": "item", "title": "Open here", "command": ["cmd.exe", "/k", "cd", "%V"], "targets": ["directory"]}
+			}`,
+			wantSkipped: 1,
+		},
+		{
+			name:  "ShellMenuView CSV",
+			parse: parseShellMenuView,
+			data: "Menu Name,Command,Extension/Type,Menu Key\r\n" +
+				"Open with Code,\"\"\"C:\\Code\\code.exe\"\" \"\"%1\"\"\",*,code\r\n" +
+				"Open with Code,\"\"\"C:\\Code\\code.exe\"\" \"\"%1\"\"\",Directory,code\r\n",
+			want: `{"code": {"type": "item", "title": "Open with Code", "command": ["C:\\Code\\code.exe", "%1"], "targets": ["file", "directory"]}}`,
+		},
+		{
+			name:  "ShellMenuView tab delimited",
+			parse: parseShellMenuView,
+			data:  "Menu Name\tCommand\tExtension/Type\r\nEdit &Notes\tnotepad.exe \"%1\"\t.md\r\n",
+			want:  `{"Edit Notes": {"type": "item", "title": "Edit &Notes", "command": ["notepad.exe", "%1"], "targets": [".md"]}}`,
+		},
+		{
+			name:  "Easy Context Menu",
+			parse: parseEasyContextMenu,
+			data: "; exported list\r\n" +
+				"[Notepad]\r\n" +
+				"Name=Edit in Notepad\r\n" +
+				"Path=\"C:\\Windows\\notepad.exe\"\r\n" +
+				"Parameters=\"%1\"\r\n" +
+				"Icon=C:\\Windows\\notepad.exe,0\r\n" +
+				"Location=Files\r\n" +
+				"Admin=1\r\n\r\n" +
+				"[Restart Explorer]\r\n" +
+				"Command=taskkill.exe /f /im explorer.exe\r\n" +
+				"Location=Desktop\r\n" +
+				"Shift=true\r\n\r\n" +
+				"[Nothing]\r\n" +
+				"Name=No program\r\n",
+			want: `{
+				"Notepad": {"type": "item", "title": "Edit in Notepad", "iconPath": "C:\\Windows\\notepad.exe", "iconIndex": 0, "admin": true, "command": ["C:\\Windows\\notepad.exe", "%1"], "targets": ["file"]},
+				"Restart Explorer": {"type": "item", "title": "Restart Explorer", "extended": true, "command": ["taskkill.exe", "/f", "/im", "explorer.exe"], "targets": ["desktop"]}
+			}`,
+			wantSkipped: 1,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			items, skipped, err := test.parse([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := testMenus(t, test.want); !sameMenus(t, items, want) {
+				got, _ := marshalJSON(items, "")
+				t.Errorf("imported %s, expected %s", got, test.want)
+			}
+			if skipped != test.wantSkipped {
+				t.Errorf("skipped %d entries, expected %d", skipped, test.wantSkipped)
+			}
+		})
+	}
+}
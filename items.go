@@ -0,0 +1,34 @@
+package main
+
+type ItemStatus struct {
+	ID        string          `json:"id"`
+	Title     string          `json:"title"`
+	Type      ContextMenuType `json:"type"`
+	Installed bool            `json:"installed"`
+	Enabled   bool            `json:"enabled"`
+}
+
+// listItems reports the state of every item, for the List of backends.
+func listItems(manifest *Manifest, itemState func(manifest *Manifest, id string) (installed, enabled bool, err error)) (items []ItemStatus, err error) {
+	var walk func(prefix string, menus ContextMenus) error
+	walk = func(prefix string, menus ContextMenus) (err error) {
+		for _, entry := range menus {
+			var (
+				item   = entry.Menu
+				status = ItemStatus{ID: prefix + entry.ID, Title: item.Title, Type: item.Type}
+			)
+			if status.Installed, status.Enabled, err = itemState(manifest, status.ID); err != nil {
+				return
+			}
+			items = append(items, status)
+			if item.Type == ContextMenuType_Folder {
+				if err = walk(status.ID+"/", item.Items); err != nil {
+					return
+				}
+			}
+		}
+		return
+	}
+	err = walk("", manifest.Items)
+	return
+}
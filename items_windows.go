@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// registryBackend applies the manifest to the registry keys of its targets in the configured hive.
+type registryBackend struct{}
+
+func platformBackend() Backend {
+	return registryBackend{}
+}
+
+func (b registryBackend) List(manifest *Manifest) ([]ItemStatus, error) {
+	return listItems(manifest, b.itemState)
+}
+
+// Remove deletes the keys of every item applied before. They are recorded in undo.reg like those
+// of a prune.
+func (b registryBackend) Remove(ctx context.Context) error {
+	return removeApplied(ctx, b)
+}
+
+// itemState reports an item as installed when any of its targets has it, and as enabled unless one
+// of them has LegacyDisable set.
+func (registryBackend) itemState(manifest *Manifest, id string) (installed, enabled bool, err error) {
+	var targetInstalled, targetEnabled bool
+	enabled = true
+	for _, target := range manifest.RegistryTargets(id) {
+		if targetInstalled, targetEnabled, err = keyState(itemKeyPath(target, id)); err != nil {
+			return
+		}
+		installed = installed || targetInstalled
+		enabled = enabled && (!targetInstalled || targetEnabled)
+	}
+	return
+}
+
+func keyState(keyPath string) (installed, enabled bool, err error) {
+	var key registry.Key
+	if key, err = registry.OpenKey(config.Hive.Root(), keyPath, registry.QUERY_VALUE); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			err = nil
+			return
+		}
+		err = errorf("failed to open registry key %q: %w", keyPath, err)
+		return
+	}
+	defer key.Close()
+	installed = true
+	if _, _, err = key.GetValue("LegacyDisable", nil); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			err = nil
+			enabled = true
+			return
+		}
+		err = errorf("failed to read LegacyDisable of registry key %q: %w", keyPath, err)
+		return
+	}
+	return
+}
+
+// Toggle flips the LegacyDisable flag of an applied item, or sets it when enable is not nil.
+func (b registryBackend) Toggle(manifest *Manifest, id string, enable *bool) (enabled bool, err error) {
+	var (
+		key       registry.Key
+		installed bool
+	)
+	if manifest.Find(id) == nil {
+		err = errorf("item ID %q not found in manifest", id)
+		return
+	}
+	if installed, enabled, err = b.itemState(manifest, id); err != nil {
+		return
+	}
+	if !installed {
+		err = errorf("item ID %q is not applied", id)
+		return
+	}
+	if enable != nil && *enable == enabled {
+		return
+	}
+	enabled = !enabled
+	for _, target := range manifest.RegistryTargets(id) {
+		var keyPath = itemKeyPath(target, id)
+		if key, err = registry.OpenKey(config.Hive.Root(), keyPath, registry.SET_VALUE); err != nil {
+			if errors.Is(err, syscall.ENOENT) {
+				err = nil
+				continue
+			}
+			err = errorf("failed to open registry key %q: %w", keyPath, err)
+			return
+		}
+		if enabled {
+			err = key.DeleteValue("LegacyDisable")
+			if errors.Is(err, syscall.ENOENT) {
+				err = nil
+			}
+		} else {
+			err = key.SetStringValue("LegacyDisable", "")
+		}
+		key.Close()
+		if err != nil {
+			err = errorf("failed to update LegacyDisable of registry key %q: %w", keyPath, err)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// TestRegistryConformance writes its items to HKEY_CURRENT_USER and removes them again at the end.
+func TestRegistryConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes to HKEY_CURRENT_USER")
+	}
+	isolateState(t)
+	testConformance(t, registryBackend{})
+}
+
+// tamper retitles the item under each of its targets, as editing the registry by hand would.
+func (registryBackend) tamper(manifest *Manifest, id string) (err error) {
+	for _, target := range manifest.RegistryTargets(id) {
+		var key registry.Key
+		if key, err = registry.OpenKey(config.Hive.Root(), itemKeyPath(target, id), registry.SET_VALUE); err != nil {
+			return
+		}
+		err = key.SetStringValue("MUIVerb", "Changed behind its back")
+		key.Close()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// pathError is an error in a value of the manifest, with the path to that value, e.g.
+// items.openTerminal.command[2]. Path segments are object keys as strings and list indexes as ints.
+// Errors in a JSON manifest also have the line and column the value starts at.
+type pathError struct {
+	path   []interface{}
+	err    error
+	line   int
+	column int
+}
+
+func (e *pathError) Error() string {
+	var message = e.err.Error()
+	if len(e.path) > 0 {
+		message = e.Path() + ": " + message
+	}
+	if e.line > 0 {
+		message = sprintf("line %d, column %d: %s", e.line, e.column, message)
+	}
+	return message
+}
+
+func (e *pathError) Unwrap() error {
+	return e.err
+}
+
+func (e *pathError) Path() string {
+	var b strings.Builder
+	for _, segment := range e.path {
+		switch segment := segment.(type) {
+		case int:
+			b.WriteString("[" + strconv.Itoa(segment) + "]")
+		case string:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(segment)
+		}
+	}
+	return b.String()
+}
+
+// atPath puts segments in front of the path of err. Unknown fields and type errors of the JSON
+// decoder get the name of the field added, since they only mention it in their message.
+func atPath(err error, segments ...interface{}) error {
+	var (
+		pathErr  *pathError
+		typeErr  *json.UnmarshalTypeError
+		unknown  string
+		inner    []interface{}
+		hasField bool
+	)
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.As(err, &pathErr):
+		inner, err = pathErr.path, pathErr.err
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		for _, field := range strings.Split(typeErr.Field, ".") {
+			inner = append(inner, field)
+		}
+	default:
+		if _, unknown, hasField = strings.Cut(err.Error(), "json: unknown field "); hasField {
+			if field, unquoteErr := strconv.Unquote(unknown); unquoteErr == nil {
+				inner = append(inner, field)
+			}
+		}
+	}
+	return &pathError{path: append(append([]interface{}(nil), segments...), inner...), err: err}
+}
+
+// jsonPathOffset finds the value at path in a JSON document by reading it token by token, and
+// returns the offset it starts at. A key given for a list matches the item with that "id", so
+// item IDs find items in either form of the manifest.
+func jsonPathOffset(data []byte, path []interface{}) (offset int64, ok bool) {
+	var (
+		dec = json.NewDecoder(bytes.NewReader(data))
+		tok json.Token
+		err error
+	)
+	if len(path) == 0 {
+		return valueStart(data, 0), true
+	}
+	if tok, err = dec.Token(); err != nil {
+		return
+	}
+	for i := 0; dec.More(); i++ {
+		var (
+			key   interface{} = i
+			value json.RawMessage
+			start int64
+		)
+		if tok == json.Delim('{') {
+			if key, err = dec.Token(); err != nil {
+				return
+			}
+		}
+		start = dec.InputOffset()
+		if err = dec.Decode(&value); err != nil {
+			return
+		}
+		if key == path[0] || tok == json.Delim('[') && hasID(value, path[0]) {
+			offset, ok = jsonPathOffset(value, path[1:])
+			offset += valueStart(data, start)
+			return
+		}
+	}
+	return
+}
+
+// valueStart skips the separators in front of a value that starts at or after offset.
+func valueStart(data []byte, offset int64) int64 {
+	return offset + int64(len(data[offset:])-len(bytes.TrimLeft(data[offset:], " \t\r\n:,")))
+}
+
+func hasID(data json.RawMessage, id interface{}) bool {
+	var item struct {
+		ID *string `json:"id"`
+	}
+	return isJSONObject(data) && json.Unmarshal(data, &item) == nil && item.ID != nil && *item.ID == id
+}
+
+// lineColumn turns an offset in data into a line and a column, both counted from 1, with the
+// column in characters.
+func lineColumn(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return
+}
+
+// positionReader counts the lines and columns of what a JSON decoder reads through it. The
+// decoder reads ahead, so the bytes it has not moved past yet are kept until it does.
+type positionReader struct {
+	r       io.Reader
+	pending []byte
+	offset  int64
+	line    int
+	column  int
+}
+
+func (p *positionReader) Read(b []byte) (n int, err error) {
+	n, err = p.r.Read(b)
+	p.pending = append(p.pending, b[:n]...)
+	return
+}
+
+// at returns the line and column at offset, or with value set those of the value that starts
+// after the separators there. Offsets are asked for in order, so the bytes before are let go.
+func (p *positionReader) at(offset int64, value bool) (line, column int) {
+	var n = offset - p.offset
+	if n < 0 {
+		n = 0
+	} else if n > int64(len(p.pending)) {
+		n = int64(len(p.pending))
+	}
+	for value && n < int64(len(p.pending)) && strings.IndexByte(" \t\r\n:,", p.pending[n]) >= 0 {
+		n++
+	}
+	counted := p.pending[:n]
+	if i := bytes.LastIndexByte(counted, '\n'); i >= 0 {
+		p.line += bytes.Count(counted, []byte("\n"))
+		p.column, counted = 1, counted[i+1:]
+	}
+	p.column += utf8.RuneCount(counted)
+	p.pending, p.offset = p.pending[n:], p.offset+n
+	return p.line, p.column
+}
+
+// jsonDecoder decodes a manifest in a single pass over its tokens. It keeps the path of the value
+// it is at, and when it reads JSON as written the line and column of the value, which its errors
+// carry. Those of YAML and TOML manifests would be of the JSON they are converted to, so their
+// errors only have the path.
+type jsonDecoder struct {
+	dec    *json.Decoder
+	pos    *positionReader
+	path   []interface{}
+	depth  int
+	line   int
+	column int
+}
+
+func newJSONDecoder(r io.Reader, located bool) (d *jsonDecoder) {
+	d = new(jsonDecoder)
+	if located {
+		d.pos = &positionReader{r: r, line: 1, column: 1}
+		r = d.pos
+	}
+	d.dec = json.NewDecoder(r)
+	return
+}
+
+// locate moves the line and column on to the value that starts after offset.
+func (d *jsonDecoder) locate(offset int64) {
+	if d.pos != nil {
+		d.line, d.column = d.pos.at(offset, true)
+	}
+}
+
+// token reads the next token. Lists and objects may nest maxManifestDepth levels deep, which keeps
+// the items of a manifest within what a menu can show and tools that walk them can handle.
+func (d *jsonDecoder) token() (tok json.Token, err error) {
+	var offset = d.dec.InputOffset()
+	if tok, err = d.dec.Token(); err != nil {
+		err = d.fail(err)
+		return
+	}
+	d.locate(offset)
+	switch tok {
+	case json.Delim('{'), json.Delim('['):
+		if d.depth++; d.depth > maxManifestDepth {
+			err = d.fail(errorf("manifest nests more than %d levels deep", maxManifestDepth))
+		}
+	case json.Delim('}'), json.Delim(']'):
+		d.depth--
+	}
+	return
+}
+
+// key reads the key of the next field of an object.
+func (d *jsonDecoder) key() (key string, err error) {
+	var tok json.Token
+	if tok, err = d.token(); err != nil {
+		return
+	}
+	key = tok.(string)
+	return
+}
+
+// decode decodes the next value into v as a whole.
+func (d *jsonDecoder) decode(v interface{}) (err error) {
+	var offset = d.dec.InputOffset()
+	if err = d.dec.Decode(v); err != nil {
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			d.locate(offset)
+		}
+		err = d.fail(err)
+		return
+	}
+	d.locate(offset)
+	return
+}
+
+// skip reads on until the values open deeper than depth end, so that decoding can go on after an
+// error in them.
+func (d *jsonDecoder) skip(depth int) (err error) {
+	for d.depth > depth {
+		var tok json.Token
+		if tok, err = d.dec.Token(); err != nil {
+			err = d.fail(err)
+			return
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			d.depth++
+		case json.Delim('}'), json.Delim(']'):
+			d.depth--
+		}
+		if d.pos != nil {
+			d.pos.at(d.dec.InputOffset(), false)
+		}
+	}
+	return
+}
+
+// end checks that nothing but space follows the value decoded.
+func (d *jsonDecoder) end() (err error) {
+	var (
+		offset = d.dec.InputOffset()
+		tok    json.Token
+	)
+	if tok, err = d.dec.Token(); err == io.EOF {
+		err = nil
+		return
+	}
+	if err == nil {
+		d.locate(offset)
+		err = errorf("unexpected %v after the end of the manifest", tok)
+	}
+	err = d.fail(err)
+	return
+}
+
+// fail puts the path of the value the decoder is at, and its line and column, on err. Syntax
+// errors are at the offset where the JSON goes wrong.
+func (d *jsonDecoder) fail(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		pathErr   *pathError
+	)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	switch {
+	case d.pos == nil:
+	case errors.As(err, &syntaxErr):
+		d.line, d.column = d.pos.at(syntaxErr.Offset, false)
+	case err == io.ErrUnexpectedEOF:
+		d.line, d.column = d.pos.at(d.pos.offset+int64(len(d.pos.pending)), false)
+	}
+	errors.As(atPath(err, d.path...), &pathErr)
+	pathErr.line, pathErr.column = d.line, d.column
+	return pathErr
+}
+
+// isSyntaxError tells whether decoding cannot go on after err.
+func isSyntaxError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	return errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// unknownField reports the field key, which the value it is at belongs to, after reading past the
+// value so that decoding can go on.
+func (d *jsonDecoder) unknownField(key string) (err error) {
+	var (
+		line, column = d.line, d.column
+		value        json.RawMessage
+	)
+	if err = d.decode(&value); err != nil {
+		return
+	}
+	d.line, d.column = line, column
+	return d.fail(errorf("json: unknown field %q", key))
+}
+
+// jsonFields maps the JSON names of the fields of the struct v points to, to pointers to them.
+func jsonFields(v interface{}) (fields map[string]interface{}) {
+	var value = reflect.ValueOf(v).Elem()
+	fields = make(map[string]interface{})
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = value.Field(i).Addr().Interface()
+		}
+	}
+	return
+}
+
+// jsonField finds the field key names in fields, ignoring case if it has to, as encoding/json
+// does.
+func jsonField(fields map[string]interface{}, key string) (name string, field interface{}) {
+	var ok bool
+	if field, ok = fields[key]; ok {
+		return key, field
+	}
+	for name, field = range fields {
+		if strings.EqualFold(name, key) {
+			return
+		}
+	}
+	return "", nil
+}
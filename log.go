@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type LogLevel string
+
+const (
+	LogLevel_Error LogLevel = "error"
+	LogLevel_Warn  LogLevel = "warn"
+	LogLevel_Info  LogLevel = "info"
+	LogLevel_Debug LogLevel = "debug"
+)
+
+var logLevels = map[LogLevel]int{LogLevel_Error: 0, LogLevel_Warn: 1, LogLevel_Info: 2, LogLevel_Debug: 3}
+
+func (l LogLevel) Valid() bool {
+	_, ok := logLevels[l]
+	return ok
+}
+
+// applyLog receives every message of the running apply regardless of the log level, untranslated,
+// so that the last apply can be attached to bug reports.
+var applyLog io.Writer
+
+func logf(level LogLevel, format string, args ...interface{}) {
+	if logLevels[level] <= logLevels[config.LogLevel] {
+		log.Printf("%s: %s", level, sprintf(format, args...))
+	}
+	if applyLog != nil {
+		fmt.Fprintf(applyLog, "%s %s: %s\n", time.Now().Format(time.RFC3339), level, fmt.Sprintf(format, args...))
+	}
+}
+
+func openApplyLog() (f *os.File, err error) {
+	var dir string
+	if dir, err = stateDir(); err != nil {
+		return
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		err = errorf("failed to create state folder: %w", err)
+		return
+	}
+	if f, err = os.Create(filepath.Join(dir, "apply.log")); err != nil {
+		err = errorf("failed to create apply log: %w", err)
+		return
+	}
+	applyLog = f
+	fmt.Fprintf(f, "context-menu-manager %s, hive %s, targets %v\n", version, config.Hive, config.Targets)
+	return
+}
+
+func closeApplyLog(f *os.File, err error) {
+	if err != nil {
+		fmt.Fprintf(f, "%s %s: %v\n", time.Now().Format(time.RFC3339), LogLevel_Error, err)
+	}
+	applyLog = nil
+	f.Close()
+}
@@ -1,139 +1,76 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
-	"syscall"
-
-	"golang.org/x/sys/windows/registry"
+	"sync"
 )
 
-func createContextMenu(parent string, id string, item *ContextMenu, manifestDir string) (err error) {
-	var (
-		key     registry.Key
-		keyPath = `Software\Classes\Directory\Background\shell` + parent + `\` + id
-	)
-	if err = deleteRegKeyRecursive(registry.CURRENT_USER, keyPath); err != nil {
-		err = fmt.Errorf("failed to delete registry key %q: %w", keyPath, err)
-		return
-	}
-	if key, _, err = registry.CreateKey(registry.CURRENT_USER, keyPath, registry.ALL_ACCESS); err != nil {
-		err = fmt.Errorf("failed to create registry key %q: %w", keyPath, err)
-		return
-	}
-	if err = key.SetStringValue("MUIVerb", item.Title); err != nil {
-		err = fmt.Errorf("failed to set MUIVerb: %w", err)
-		return
-	}
-	if icon := item.Icon(manifestDir); icon != "" {
-		if err = key.SetStringValue("Icon", icon); err != nil {
-			err = fmt.Errorf("failed to set Icon: %w", err)
-			return
-		}
-	}
-	if item.Extended {
-		if err = key.SetStringValue("Extended", ""); err != nil {
-			err = fmt.Errorf("failed to set Extended: %w", err)
-			return
-		}
-	}
-	if item.Admin {
-		if err = key.SetStringValue("HasLUAShield", ""); err != nil {
-			err = fmt.Errorf("failed to set HasLUAShield: %w", err)
-			return
-		}
-	}
-	if item.Type == ContextMenuType_Folder {
-		if err = key.SetStringValue("SubCommands", ""); err != nil {
-			err = fmt.Errorf("failed to set SubCommands: %w", err)
-			return
-		}
-		if _, _, err = registry.CreateKey(registry.CURRENT_USER, keyPath+`\shell`, registry.ALL_ACCESS); err != nil {
-			err = fmt.Errorf("failed to create registry key %q: %w", keyPath, err)
-			return
-		}
-		for subID, subItem := range item.Items {
-			if err = createContextMenu(parent+`\`+id+`\shell`, subID, subItem, manifestDir); err != nil {
-				err = fmt.Errorf("failed to create context menu ID %q: %w", subID, err)
-				return
-			}
-		}
-	} else {
-		keyPath += `\command`
-		if err = deleteRegKeyRecursive(registry.CURRENT_USER, keyPath); err != nil {
-			err = fmt.Errorf("failed to delete registry key %q: %w", keyPath, err)
-			return
-		}
-		if key, _, err = registry.CreateKey(registry.CURRENT_USER, keyPath, registry.ALL_ACCESS); err != nil {
-			err = fmt.Errorf("failed to create registry key %q: %w", keyPath, err)
-			return
-		}
-		if err = key.SetExpandStringValue("", item.CommandString(manifestDir)); err != nil {
-			err = fmt.Errorf("failed to set command string: %w", err)
-			return
-		}
+func cutPrefixFold(s, prefix string) (rest string, ok bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
 	}
-	return
+	return s[len(prefix):], true
 }
 
-func run() (err error) {
-	var (
-		manifestPath, manifestDir string
-		manifestData              []byte
-		manifest                  Manifest
-	)
+func loadManifest() (manifest *Manifest, manifestDir string, err error) {
+	var manifestPath string
 	if manifestPath, err = findManifest(); err != nil {
 		return
 	}
 	manifestDir = filepath.Dir(manifestPath)
-	if manifestData, err = os.ReadFile(manifestPath); err != nil {
-		err = fmt.Errorf("failed to read manifest.json: %w", err)
+	if manifest, err = readManifest(manifestPath); err != nil {
 		return
 	}
-	if err = json.Unmarshal(manifestData, &manifest); err != nil {
-		err = fmt.Errorf("failed to parse manifest.json: %w", err)
-		return
+	manifest.Items = manifest.Items.forPlatform(runtime.GOOS).expandGenerated(runtime.GOOS, manifestDir)
+	if config.SplitFolders {
+		manifest.Items = manifest.Items.splitFolders()
 	}
-	for id, item := range manifest.Items {
-		if err = createContextMenu("", id, item, manifestDir); err != nil {
-			err = fmt.Errorf("failed to create context menu ID %q: %w", id, err)
-			return
-		}
+	if config.Accelerators {
+		manifest.Items = manifest.Items.accelerators()
 	}
 	return
 }
 
 func findManifest() (manifestPath string, err error) {
-	const manifestFilename = "manifest.json"
 	var (
+		dirs []string
 		fi   fs.FileInfo
 		fp   string
 		terr error
 	)
 	if fp, terr = os.Getwd(); terr == nil {
-		manifestPath = filepath.Join(fp, manifestFilename)
-		if fi, terr = os.Stat(manifestPath); terr == nil && !fi.IsDir() {
-			return
-		}
+		dirs = append(dirs, fp)
 	}
 	if fp, terr = os.Executable(); terr == nil {
-		manifestPath = filepath.Join(filepath.Dir(fp), manifestFilename)
-		if fi, terr = os.Stat(manifestPath); terr == nil && !fi.IsDir() {
-			return
+		dirs = append(dirs, filepath.Dir(fp))
+	}
+	for _, dir := range dirs {
+		for _, name := range manifestFilenames {
+			manifestPath = filepath.Join(dir, name)
+			if fi, terr = os.Stat(manifestPath); terr == nil && !fi.IsDir() {
+				return
+			}
 		}
 	}
-	err = fmt.Errorf("manifest.json not found: %w", os.ErrNotExist)
+	err = errorf("manifest.json not found: %w", os.ErrNotExist)
 	return
 }
 
-var _nircmdPath string
+var (
+	_nircmdPath string
+	// nircmdMu guards _nircmdPath, since apply plans items in parallel.
+	nircmdMu sync.Mutex
+	// nircmdFallback stands in for nircmd.exe when it is not found, for planning keys that are
+	// not written here, as validate does on any platform.
+	nircmdFallback string
+)
 
 func findNircmd() (nircmdPath string, err error) {
 	const nircmdFilename = "nircmd.exe"
@@ -142,6 +79,8 @@ func findNircmd() (nircmdPath string, err error) {
 		fp   string
 		terr error
 	)
+	nircmdMu.Lock()
+	defer nircmdMu.Unlock()
 	if _nircmdPath != "" {
 		nircmdPath = _nircmdPath
 		return
@@ -172,115 +111,185 @@ func findNircmd() (nircmdPath string, err error) {
 	if nircmdPath, terr = exec.LookPath(nircmdFilename); terr == nil {
 		return
 	}
-	err = fmt.Errorf("nircmd.exe not found: %w", os.ErrNotExist)
+	err = errorf("nircmd.exe not found: %w", os.ErrNotExist)
 	return
 }
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
 }
 
 type ContextMenu struct {
-	Type      ContextMenuType         `json:"type"`
-	Title     string                  `json:"title"`
-	IconPath  string                  `json:"iconPath"`
-	IconIndex *int                    `json:"iconIndex,omitempty"`
-	Extended  bool                    `json:"extended"`
-	Admin     bool                    `json:"admin"`
-	Command   []string                `json:"command,omitempty"`
-	Items     map[string]*ContextMenu `json:"items,omitempty"`
+	Type        ContextMenuType `json:"type"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	IconPath    string          `json:"iconPath,omitempty"`
+	IconIndex   *int            `json:"iconIndex,omitempty"`
+	Extended    bool            `json:"extended,omitempty"`
+	Admin       bool            `json:"admin,omitempty"`
+	Command     []string        `json:"command,omitempty"`
+	Items       ContextMenus    `json:"items,omitempty"`
+	Targets     []string        `json:"targets,omitempty"`
+	Source      RecentSource    `json:"source,omitempty"`
+	Limit       int             `json:"limit,omitempty"`
+	Path        string          `json:"path,omitempty"`
+	PathFormat  PathFormat      `json:"pathFormat,omitempty"`
+
+	SeparatorBefore bool `json:"separatorBefore,omitempty"`
+	SeparatorAfter  bool `json:"separatorAfter,omitempty"`
+
+	// IconFallbacks follow IconPath when iconPath is a list, and are tried in turn when it does not exist.
+	IconFallbacks []string `json:"-"`
+	// Generated is set on the folders expandGenerated filled, for refreshing them.
+	Generated bool             `json:"-"`
+	Variants  platformVariants `json:"-"`
 }
 
 type ContextMenuType string
 
 const (
-	ContextMenuType_Item   ContextMenuType = "item"
-	ContextMenuType_Folder ContextMenuType = "folder"
+	ContextMenuType_Item             ContextMenuType = "item"
+	ContextMenuType_Folder           ContextMenuType = "folder"
+	ContextMenuType_Recent           ContextMenuType = "recent"
+	ContextMenuType_ScriptsFolder    ContextMenuType = "scriptsFolder"
+	ContextMenuType_TerminalProfiles ContextMenuType = "terminalProfiles"
+	ContextMenuType_WSLDistros       ContextMenuType = "wslDistros"
+	ContextMenuType_CopyPath         ContextMenuType = "copyPath"
 )
 
 type Manifest struct {
-	Items map[string]*ContextMenu `json:"items"`
+	Schema        string       `json:"$schema,omitempty"`
+	SchemaVersion int          `json:"schemaVersion,omitempty"`
+	Items         ContextMenus `json:"items"`
+}
+
+// IconFile is IconPath with ${manifestFolder} expanded, or when it does not exist the first of the
+// fallbacks that does. The last fallback is used when none exists.
+func (c ContextMenu) IconFile(manifestDir string) (iconFile string) {
+	for _, iconPath := range append([]string{c.IconPath}, c.IconFallbacks...) {
+		iconFile = strings.ReplaceAll(iconPath, "${manifestFolder}", manifestDir)
+		if len(c.IconFallbacks) == 0 || iconExists(iconFile) {
+			return
+		}
+	}
+	return
 }
 
 func (c ContextMenu) Icon(manifestDir string) string {
-	iconPath := c.IconPath
-	if iconPath == "" {
+	iconFile, iconIndex := c.IconLocation(manifestDir)
+	if iconFile == "" {
 		return ""
 	}
-	iconPath = strings.ReplaceAll(iconPath, "${manifestFolder}", manifestDir)
-	iconPath = quoteWindowsPath(iconPath)
-	if c.IconIndex != nil {
-		iconPath = fmt.Sprintf("%s,%d", iconPath, *c.IconIndex)
+	iconPath := quoteWindowsPath(uncBackslashes(iconFile))
+	if iconIndex != nil {
+		iconPath = fmt.Sprintf("%s,%d", iconPath, *iconIndex)
 	}
 	return iconPath
 }
 
-func (c ContextMenu) CommandString(manifestDir string) string {
+func (c ContextMenu) CommandString(manifestDir string) (commandString string, err error) {
 	var (
-		err        error
 		nircmdPath string
 		command    []string
 	)
-	if c.Admin {
-		if nircmdPath, err = findNircmd(); err != nil {
-			log.Fatal(err)
-		} else {
-			command = append(command, quoteWindowsPath(nircmdPath), "elevate")
+	if c.Admin && config.Elevation == ElevationBackend_RunAs {
+		if command, err = runElevatedCommand(); err != nil {
+			return
+		}
+	} else if c.Admin {
+		if nircmdPath, err = findNircmd(); err != nil && nircmdFallback != "" {
+			nircmdPath, err = nircmdFallback, nil
+		} else if err != nil {
+			return
 		}
+		command = append(command, quoteWindowsArg(extendedLengthPath(nircmdPath)), "elevate")
 	}
-	for _, part := range c.Command {
+	for i, part := range c.Command {
 		part = strings.ReplaceAll(part, "${manifestFolder}", manifestDir)
-		if strings.ContainsAny(part, " %") {
-			part = quoteWindowsPath(part)
+		if i == 0 {
+			part = extendedLengthPath(uncBackslashes(part))
+		}
+		if part == "" || strings.ContainsAny(part, " \t\"%") {
+			part = quoteWindowsArg(part)
 		}
 		command = append(command, part)
 	}
-	return strings.Join(command, " ")
+	commandString = strings.Join(command, " ")
+	return
 }
 
-func deleteRegKeyRecursive(k registry.Key, path string) (err error) {
-	var (
-		key, emptyKey registry.Key
-		subKeyNames   []string
-	)
-	if key, err = registry.OpenKey(k, path, registry.ALL_ACCESS); err != nil {
-		if errors.Is(err, syscall.ENOENT) {
-			err = nil
-			return
-		}
-		err = fmt.Errorf("deleteRegKeyRecursive failed to open key path %q: %w", path, err)
-		return
-	}
-	defer func() {
-		if key != emptyKey {
-			key.Close()
-		}
-	}()
-	if subKeyNames, err = key.ReadSubKeyNames(0); err != nil {
-		err = fmt.Errorf("deleteRegKeyRecursive failed to get subkeys of path %q: %w", path, err)
-		return
-	}
-	for _, subKeyName := range subKeyNames {
-		if err = deleteRegKeyRecursive(key, subKeyName); err != nil {
-			err = fmt.Errorf("deleteRegKeyRecursive failed to delete subkey %q of path %q: %w", subKeyName, path, err)
-			return
-		}
-	}
-	key.Close()
-	key = emptyKey
-	if err = registry.DeleteKey(k, path); err != nil {
-		if errors.Is(err, syscall.ENOENT) {
-			err = nil
-			return
-		}
-		err = fmt.Errorf("deleteRegKeyRecursive failed to delete key path %q: %w", path, err)
+// runElevatedCommand starts the command of an admin item through the run-elevated command of this
+// executable. The paths Explorer fills in when the item is clicked reach it as arguments, never as
+// part of a script, so whatever a file is called it is only ever passed on.
+func runElevatedCommand() (command []string, err error) {
+	var self string
+	if self, err = os.Executable(); err != nil {
+		err = errorf("failed to find this executable for run-elevated: %w", err)
 		return
 	}
+	command = []string{quoteWindowsArg(extendedLengthPath(self)), "run-elevated", "--"}
 	return
 }
 
 func quoteWindowsPath(path string) string {
 	return `"` + path + `"`
 }
+
+// quoteWindowsArg quotes an argument the way CommandLineToArgvW reads it back: backslashes before a
+// quote are doubled and quotes are escaped, so that e.g. a share root `\\server\share\` keeps its
+// closing quote.
+func quoteWindowsArg(arg string) string {
+	var (
+		b           strings.Builder
+		backslashes int
+	)
+	b.WriteByte('"')
+	for _, r := range arg {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			b.WriteString(strings.Repeat(`\`, backslashes*2+1))
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		b.WriteRune(r)
+	}
+	b.WriteString(strings.Repeat(`\`, backslashes*2))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// maxPath is MAX_PATH, the longest path most Windows programs and APIs accept without the \\?\
+// prefix.
+const maxPath = 260
+
+// uncBackslashes writes a UNC path given with forward slashes, "//server/share/...", with
+// backslashes, which is the only form Explorer reads in registry values.
+func uncBackslashes(path string) string {
+	if strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "///") {
+		return strings.ReplaceAll(path, "/", `\`)
+	}
+	return path
+}
+
+// extendedLengthPath prefixes an absolute path of MAX_PATH or more with \\?\ (\\?\UNC\ for a share), so
+// that programs deep in synced or network folders can still be started. Relative paths and those
+// with "." or ".." parts cannot take the prefix and are left alone.
+func extendedLengthPath(path string) string {
+	var clean = strings.ReplaceAll(path, "/", `\`)
+	if len(path) < maxPath || strings.HasPrefix(clean, `\\?\`) || strings.Contains(clean+`\`, `\.\`) || strings.Contains(clean+`\`, `\..\`) {
+		return path
+	}
+	switch {
+	case strings.HasPrefix(clean, `\\`):
+		return `\\?\UNC\` + clean[2:]
+	case len(clean) > 2 && clean[1] == ':' && clean[2] == '\\':
+		return `\\?\` + clean
+	}
+	return path
+}
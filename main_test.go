@@ -0,0 +1,242 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// FuzzCommandString checks that Windows reads back the arguments of a command as the manifest has
+// them, however they are quoted, once CommandString writes it to the registry and Explorer fills in
+// the path clicked on. Admin items have to pass the path on as an argument, through nircmd.exe or
+// run-elevated, whatever it is called.
+func FuzzCommandString(f *testing.F) {
+	f.Add(`C:\Program Files\App\app.exe`, "%V", `C:\path with space\`, `D:\x';calc;'`, false, false)
+	f.Add(`\\server\share\tool.exe`, `say "hi"`, `trailing\\`, `C:\a b\‘c’`, true, false)
+	f.Add("${manifestFolder}/scripts/run.cmd", "", "100%", `C:\$(calc)`, true, true)
+	f.Add("打开.exe", "\tтаб", `a\\\"b`, `C:\x & calc & %USERNAME%`, false, true)
+	f.Add("powershell.exe", "-File", "%1", `C:\x'; Start-Process calc; '.ps1`, true, true)
+	f.Fuzz(func(t *testing.T, program, arg1, arg2, clicked string, admin, runas bool) {
+		var (
+			manifestDir = `C:\Users\me\菜单`
+			item        = ContextMenu{Command: []string{program, arg1, arg2}, Admin: admin}
+			elevation   = config.Elevation
+			want        []string
+			command     string
+			err         error
+		)
+		// Paths cannot contain quotes, and those of programs cannot end in a backslash; a clicked
+		// path that does is cut short by the quotes around it. Manifests decode to valid UTF-8, and
+		// a registry string ends at NUL.
+		if program == "" || strings.ContainsAny(program+clicked, `"`) || strings.HasSuffix(program, `\`) || strings.HasSuffix(clicked, `\`) {
+			return
+		}
+		for i, part := range append(item.Command, clicked) {
+			if !utf8.ValidString(part) || strings.Contains(part, "\x00") {
+				return
+			}
+			if i == len(item.Command) {
+				break
+			}
+			part = strings.ReplaceAll(part, "${manifestFolder}", manifestDir)
+			if i == 0 {
+				part = extendedLengthPath(uncBackslashes(part))
+			}
+			want = append(want, clickedPath(part, clicked))
+		}
+		config.Elevation, nircmdFallback = ElevationBackend_Nircmd, "nircmd.exe"
+		if runas {
+			config.Elevation = ElevationBackend_RunAs
+		}
+		defer func() {
+			config.Elevation, nircmdFallback = elevation, ""
+		}()
+		if command, err = item.CommandString(manifestDir); err != nil {
+			t.Fatal(err)
+		}
+		got := windowsArgs(clickedPath(command, clicked))
+		switch {
+		case admin && runas:
+			if len(got) < 3 || got[1] != "run-elevated" || got[2] != "--" {
+				t.Fatalf("command %q does not start with run-elevated", command)
+			}
+			// run-elevated passes on what comes after "--" as the arguments of the program.
+			got = append(got[3:4], windowsArgs("program " + elevatedArgs(got[4:]))[1:]...)
+		case admin:
+			if len(got) < 2 || got[1] != "elevate" {
+				t.Fatalf("command %q does not start with nircmd.exe elevate", command)
+			}
+			got = got[2:]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("command %q reads back as %q, expected %q", command, got, want)
+		}
+	})
+}
+
+// clickedPath fills in the placeholders of the path clicked on, as Explorer does.
+func clickedPath(s, clicked string) string {
+	return strings.NewReplacer("%V", clicked, "%1", clicked, "%L", clicked).Replace(s)
+}
+
+// windowsArgs splits a command line the way CommandLineToArgvW and the C runtime do: the program
+// ends at the next quote if it starts with one, and in the arguments backslashes only escape
+// quotes.
+func windowsArgs(line string) (args []string) {
+	var (
+		arg     strings.Builder
+		quoted  bool
+		started bool
+	)
+	if strings.HasPrefix(line, `"`) {
+		program, rest, _ := strings.Cut(line[1:], `"`)
+		args, line = append(args, program), rest
+	} else if end := strings.IndexAny(line, " \t"); end >= 0 {
+		args, line = append(args, line[:end]), line[end+1:]
+	} else {
+		args, line = append(args, line), ""
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\':
+			n := 1
+			for i+n < len(line) && line[i+n] == '\\' {
+				n++
+			}
+			if i+n < len(line) && line[i+n] == '"' {
+				arg.WriteString(strings.Repeat(`\`, n/2))
+				if n%2 == 1 {
+					arg.WriteByte('"')
+				} else {
+					quoted = !quoted
+				}
+				i += n
+			} else {
+				arg.WriteString(strings.Repeat(`\`, n))
+				i += n - 1
+			}
+			started = true
+		case c == '"':
+			quoted, started = !quoted, true
+		case (c == ' ' || c == '\t') && !quoted:
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteByte(c)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return
+}
+
+func TestCutPrefixFold(t *testing.T) {
+	for _, test := range []struct {
+		s, prefix string
+		want      string
+		wantOK    bool
+	}{
+		{s: `HKCU\Software\Classes`, prefix: `HKCU\`, want: `Software\Classes`, wantOK: true},
+		{s: `HKU\s-1-5-21-1001\Software`, prefix: `HKU\S-1-5-21-1001\`, want: "Software", wantOK: true},
+		{s: `HKU\S-1-5-21-10011\Software`, prefix: `HKU\S-1-5-21-1001\`, want: `HKU\S-1-5-21-10011\Software`},
+		{s: `HKLM\Software`, prefix: `HKCU\`, want: `HKLM\Software`},
+		{s: "HK", prefix: `HKCU\`, want: "HK"},
+	} {
+		if got, ok := cutPrefixFold(test.s, test.prefix); got != test.want || ok != test.wantOK {
+			t.Errorf("cutPrefixFold(%q, %q) = %q, %t, expected %q, %t", test.s, test.prefix, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+// TestIconFile checks that the first icon of a list that exists is used, with presets counting as
+// there, and the last one when none exists.
+func TestIconFile(t *testing.T) {
+	var dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "b.ico"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		iconPaths []string
+		want      string
+	}{
+		{iconPaths: []string{"${manifestFolder}/a.ico"}, want: dir + "/a.ico"},
+		{iconPaths: []string{filepath.Join(dir, "a.ico"), "${manifestFolder}/b.ico", filepath.Join(dir, "c.ico")}, want: dir + "/b.ico"},
+		{iconPaths: []string{filepath.Join(dir, "a.ico"), "shell:bogus", "shell:folder"}, want: "shell:folder"},
+		{iconPaths: []string{filepath.Join(dir, "a.ico"), filepath.Join(dir, "c.ico")}, want: filepath.Join(dir, "c.ico")},
+	} {
+		item := ContextMenu{IconPath: test.iconPaths[0], IconFallbacks: test.iconPaths[1:]}
+		if got := item.IconFile(dir); got != test.want {
+			t.Errorf("icon of %q is %q, expected %q", test.iconPaths, got, test.want)
+		}
+	}
+}
+
+func TestQuoteWindowsArg(t *testing.T) {
+	for _, test := range []struct {
+		arg  string
+		want string
+	}{
+		{arg: "", want: `""`},
+		{arg: `C:\Program Files\app.exe`, want: `"C:\Program Files\app.exe"`},
+		{arg: `\\server\share\`, want: `"\\server\share\\"`},
+		{arg: `say "hi"`, want: `"say \"hi\""`},
+		{arg: `a\"b`, want: `"a\\\"b"`},
+		{arg: `a\\b`, want: `"a\\b"`},
+		{arg: "100%", want: `"100%"`},
+	} {
+		if got := quoteWindowsArg(test.arg); got != test.want {
+			t.Errorf("quoteWindowsArg(%q) = %s, expected %s", test.arg, got, test.want)
+		}
+		if got := windowsArgs("program " + quoteWindowsArg(test.arg)); len(got) != 2 || got[1] != test.arg {
+			t.Errorf("quoteWindowsArg(%q) reads back as %q", test.arg, got)
+		}
+	}
+}
+
+// TestExtendedLengthPath checks that only absolute paths of MAX_PATH or more take the \\?\ prefix.
+func TestExtendedLengthPath(t *testing.T) {
+	var long = strings.Repeat("a", maxPath)
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{path: `C:\Tools\app.exe`, want: `C:\Tools\app.exe`},
+		{path: `C:\` + long + `\app.exe`, want: `\\?\C:\` + long + `\app.exe`},
+		{path: "C:/" + long + "/app.exe", want: `\\?\C:\` + long + `\app.exe`},
+		{path: `\\server\share\` + long, want: `\\?\UNC\server\share\` + long},
+		{path: `\\?\C:\` + long, want: `\\?\C:\` + long},
+		{path: `C:\` + long + `\..\app.exe`, want: `C:\` + long + `\..\app.exe`},
+		{path: `C:\` + long + `\.`, want: `C:\` + long + `\.`},
+		{path: long + `\app.exe`, want: long + `\app.exe`},
+		{path: `C:` + long, want: `C:` + long},
+	} {
+		if got := extendedLengthPath(test.path); got != test.want {
+			t.Errorf("extendedLengthPath(%q) = %q, expected %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestUNCBackslashes(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{path: "//server/share/tool.exe", want: `\\server\share\tool.exe`},
+		{path: `\\server\share\tool.exe`, want: `\\server\share\tool.exe`},
+		{path: "///server/share", want: "///server/share"},
+		{path: "C:/Tools/app.exe", want: "C:/Tools/app.exe"},
+		{path: "/usr/bin/app", want: "/usr/bin/app"},
+	} {
+		if got := uncBackslashes(test.path); got != test.want {
+			t.Errorf("uncBackslashes(%q) = %q, expected %q", test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestSchemaVersion is the newest manifest schema this build understands. Manifests without a
+// schemaVersion are version 1, where items are an object keyed by ID. From version 2 items are a
+// list, each carrying its ID, so that their order survives any JSON tooling.
+const manifestSchemaVersion = 2
+
+type ContextMenuEntry struct {
+	ID   string
+	Menu *ContextMenu
+}
+
+// ContextMenus keeps menu items in the order they appear in the manifest.
+type ContextMenus []ContextMenuEntry
+
+func (c ContextMenus) Get(id string) *ContextMenu {
+	for _, entry := range c {
+		if entry.ID == id {
+			return entry.Menu
+		}
+	}
+	return nil
+}
+
+func (c ContextMenus) Index(id string) int {
+	for i, entry := range c {
+		if entry.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// UnmarshalJSON reads the items one by one. Errors have the path of the value they are about,
+// starting with "items", since that is where lists of items are.
+func (c *ContextMenus) UnmarshalJSON(data []byte) (err error) {
+	var d = newJSONDecoder(bytes.NewReader(data), false)
+	d.path = []interface{}{"items"}
+	return d.decodeMenus(c)
+}
+
+// decodeMenus decodes a list of items, each with its ID, or as in version 1 an object of items
+// keyed by their ID. The ID of an item in a list takes the place of its index in the path once it
+// is read.
+func (d *jsonDecoder) decodeMenus(c *ContextMenus) (err error) {
+	var (
+		tok   json.Token
+		menus ContextMenus
+		seen  = make(map[string]bool)
+	)
+	if tok, err = d.token(); err != nil {
+		return
+	}
+	switch tok {
+	case nil:
+		*c = nil
+		return
+	case json.Delim('{'):
+		for d.dec.More() {
+			var entry = ContextMenuEntry{Menu: new(ContextMenu)}
+			if entry.ID, err = d.key(); err != nil {
+				return
+			}
+			d.path = append(d.path, entry.ID)
+			if seen[entry.ID] {
+				return d.fail(errorf("duplicate item ID %q", entry.ID))
+			}
+			if err = d.decodeMenu(entry.Menu, nil, nil); err != nil {
+				return
+			}
+			d.path = d.path[:len(d.path)-1]
+			seen[entry.ID] = true
+			menus = append(menus, entry)
+		}
+	case json.Delim('['):
+		for d.dec.More() {
+			var entry = ContextMenuEntry{Menu: new(ContextMenu)}
+			d.path = append(d.path, len(menus))
+			if err = d.decodeMenu(entry.Menu, &entry.ID, seen); err != nil {
+				return
+			}
+			d.path = d.path[:len(d.path)-1]
+			seen[entry.ID] = true
+			menus = append(menus, entry)
+		}
+	default:
+		return d.fail(errorf("items must be a list or an object, got %v", tok))
+	}
+	if _, err = d.token(); err != nil {
+		return
+	}
+	*c = menus
+	return
+}
+
+func (c ContextMenus) MarshalJSON() (data []byte, err error) {
+	var (
+		buf  bytes.Buffer
+		part []byte
+	)
+	buf.WriteByte('{')
+	for i, entry := range c {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if part, err = marshalJSON(entry.ID, ""); err != nil {
+			return
+		}
+		buf.Write(part)
+		buf.WriteByte(':')
+		if part, err = marshalJSON(entry.Menu, ""); err != nil {
+			return
+		}
+		buf.Write(part)
+	}
+	buf.WriteByte('}')
+	data = buf.Bytes()
+	return
+}
+
+// listedItem is how an item is written in the items list of a version 2 manifest.
+type listedItem struct {
+	ID string `json:"id"`
+	*ContextMenu
+	Items []listedItem `json:"items,omitempty"`
+}
+
+// MarshalJSON writes the ID first and the items last, around the fields of the item.
+func (l listedItem) MarshalJSON() (data []byte, err error) {
+	var (
+		buf    bytes.Buffer
+		part   []byte
+		fields = *l.ContextMenu
+	)
+	fields.Items = nil
+	if part, err = marshalJSON(l.ID, ""); err != nil {
+		return
+	}
+	buf.WriteString(`{"id":`)
+	buf.Write(part)
+	if part, err = marshalJSON(fields, ""); err != nil {
+		return
+	}
+	buf.WriteByte(',')
+	buf.Write(part[1 : len(part)-1])
+	if len(l.Items) > 0 {
+		if part, err = marshalJSON(l.Items, ""); err != nil {
+			return
+		}
+		buf.WriteString(`,"items":`)
+		buf.Write(part)
+	}
+	buf.WriteByte('}')
+	data = buf.Bytes()
+	return
+}
+
+func listedItems(menus ContextMenus) (items []listedItem) {
+	items = []listedItem{}
+	for _, entry := range menus {
+		items = append(items, listedItem{ID: entry.ID, ContextMenu: entry.Menu, Items: listedItems(entry.Menu.Items)})
+	}
+	return
+}
+
+// MarshalJSON writes items in the layout of the manifest's schema version.
+func (m Manifest) MarshalJSON() (data []byte, err error) {
+	type manifest Manifest
+	if m.SchemaVersion < 2 {
+		data, err = marshalJSON(manifest(m), "")
+		return
+	}
+	data, err = marshalJSON(struct {
+		manifest
+		Items []listedItem `json:"items"`
+	}{manifest(m), listedItems(m.Items)}, "")
+	return
+}
+
+func (m Manifest) Find(id string) (item *ContextMenu) {
+	var items = m.Items
+	for _, part := range strings.Split(id, "/") {
+		if item = items.Get(part); item == nil {
+			return
+		}
+		items = item.Items
+	}
+	return
+}
+
+// Limits that keep a malformed or malicious manifest from hanging the tool or using up its memory.
+// Real manifests are a few kilobytes, and Explorer shows four levels of submenus, each of which
+// nests two levels of JSON.
+const (
+	maxManifestSize  = 16 << 20
+	maxManifestDepth = 64
+)
+
+// readManifest refuses manifests from a newer schema and unknown fields, rather than applying a
+// partial understanding of them. A JSON manifest is decoded as it is read.
+func readManifest(manifestPath string) (manifest *Manifest, err error) {
+	var (
+		file     *os.File
+		fileInfo os.FileInfo
+		data     []byte
+		d        *jsonDecoder
+	)
+	if file, err = os.Open(manifestPath); err != nil {
+		err = errorf("failed to read manifest.json: %w", err)
+		return
+	}
+	defer file.Close()
+	if fileInfo, err = file.Stat(); err != nil {
+		err = errorf("failed to read manifest.json: %w", err)
+		return
+	}
+	if fileInfo.Size() > maxManifestSize {
+		err = errorf("manifest is %d bytes, more than the limit of %d", fileInfo.Size(), maxManifestSize)
+		return
+	}
+	if format := manifestFormat(manifestPath); format == "json" {
+		d = newJSONDecoder(io.LimitReader(file, maxManifestSize), true)
+	} else {
+		if data, err = io.ReadAll(io.LimitReader(file, maxManifestSize)); err != nil {
+			err = errorf("failed to read manifest.json: %w", err)
+			return
+		}
+		if data, err = toJSON(format, data); err != nil {
+			err = errorf("failed to parse manifest.json: %w", err)
+			return
+		}
+		d = newJSONDecoder(bytes.NewReader(data), false)
+	}
+	manifest = new(Manifest)
+	if err = d.decodeManifest(manifest); err != nil {
+		if manifest.SchemaVersion <= manifestSchemaVersion {
+			err = errorf("failed to parse manifest.json: %w", err)
+		}
+		manifest = nil
+		return
+	}
+	return
+}
+
+// decodeManifest decodes a whole manifest. Errors wait until schemaVersion is read, wherever it
+// is, so that a manifest of a newer schema is reported as such rather than by what this build does
+// not understand of it.
+func (d *jsonDecoder) decodeManifest(manifest *Manifest) (err error) {
+	var (
+		tok       json.Token
+		fields    = jsonFields(manifest)
+		deferred  error
+		versioned bool
+	)
+	if tok, err = d.token(); err != nil || tok == nil {
+		return
+	}
+	if tok != json.Delim('{') {
+		return d.fail(errorf("manifest must be an object, got %v", tok))
+	}
+	for d.dec.More() {
+		var key string
+		if key, err = d.key(); err != nil {
+			return
+		}
+		switch name, field := jsonField(fields, key); name {
+		case "":
+			err = d.unknownField(key)
+		case "items":
+			d.path = append(d.path, key)
+			err = d.decodeMenus(&manifest.Items)
+			d.path = d.path[:0]
+		default:
+			d.path = append(d.path, key)
+			if err = d.decode(field); err == nil && name == "schemaVersion" {
+				versioned = true
+			}
+			d.path = d.path[:0]
+		}
+		if manifest.SchemaVersion > manifestSchemaVersion {
+			return errorf("manifest.json uses schema version %d, but this build supports up to version %d; run \"context-menu-manager self-update\" to upgrade", manifest.SchemaVersion, manifestSchemaVersion)
+		}
+		if err != nil {
+			if deferred == nil {
+				deferred = err
+			}
+			if versioned || isSyntaxError(err) || d.skip(1) != nil {
+				return deferred
+			}
+		}
+	}
+	if deferred != nil {
+		return deferred
+	}
+	if _, err = d.token(); err != nil {
+		return
+	}
+	return d.end()
+}
+
+// openManifest reads the manifest at manifestPath, or the one findManifest locates when it is empty.
+// A manifest that does not exist yet is returned empty, to be created in the working folder.
+func openManifest(manifestPath string) (path string, manifest *Manifest, created bool, err error) {
+	if path = manifestPath; path == "" {
+		if path, err = findManifest(); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return
+			}
+			path = "manifest.json"
+		}
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	if manifest, err = readManifest(path); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		manifest, created, err = &Manifest{SchemaVersion: manifestSchemaVersion}, true, nil
+	}
+	return
+}
+
+func writeManifest(manifestPath string, manifest *Manifest) (err error) {
+	var manifestData []byte
+	if manifestData, err = encodeManifest(manifestPath, manifest); err != nil {
+		return
+	}
+	if err = os.WriteFile(manifestPath, manifestData, 0o644); err != nil {
+		err = errorf("failed to write manifest.json: %w", err)
+		return
+	}
+	return
+}
+
+// encodeManifest renders manifest in the format that the extension of manifestPath selects.
+func encodeManifest(manifestPath string, manifest *Manifest) (data []byte, err error) {
+	if data, err = marshalJSON(manifest, "    "); err != nil {
+		err = errorf("failed to encode manifest.json: %w", err)
+		return
+	}
+	if data, err = fromJSON(manifestFormat(manifestPath), append(data, '\n')); err != nil {
+		err = errorf("failed to encode manifest.json: %w", err)
+		return
+	}
+	return
+}
+
+// marshalJSON is json.MarshalIndent without escaping "&", "<" and ">", which are common in titles and commands.
+func marshalJSON(v interface{}, indent string) (data []byte, err error) {
+	var (
+		buf bytes.Buffer
+		enc = json.NewEncoder(&buf)
+	)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err = enc.Encode(v); err != nil {
+		return
+	}
+	data = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// FuzzReadManifest reads arbitrary documents as manifests in each format. Whatever is accepted has
+// to stay within the nesting limit, and has to read back the same once written.
+func FuzzReadManifest(f *testing.F) {
+	var formats = []string{"manifest.json", "manifest.yaml", "manifest.toml"}
+	f.Add([]byte(conformanceManifest), uint8(0))
+	f.Add([]byte(`{"schemaVersion": 2, "items": [{"id": "a", "type": "folder", "title": "A", "items": [{"id": "b", "type": "item", "title": "B", "command": ["echo"]}]}]}`), uint8(0))
+	f.Add([]byte(strings.Repeat(`{"items": [`, maxManifestDepth)), uint8(0))
+	f.Add([]byte("schemaVersion: 2\nitems:\n  - id: a\n    type: item\n    title: 打开\n    command: [echo, \"%V\"]\n"), uint8(1))
+	f.Add([]byte("a: &a [x, x, x, x, x, x, x, x, x]\nb: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a]\nc: [*b, *b, *b, *b, *b, *b, *b, *b, *b]\n"), uint8(1))
+	f.Add([]byte("b: &b [*b, x]\n"), uint8(1))
+	f.Add([]byte("schemaVersion = 2\n\n[[items]]\nid = \"a\"\ntype = \"item\"\ntitle = \"A\"\ncommand = [\"echo\"]\n"), uint8(2))
+	f.Fuzz(func(t *testing.T, data []byte, format uint8) {
+		var (
+			dir          = t.TempDir()
+			manifestPath = filepath.Join(dir, formats[int(format)%len(formats)])
+			manifest     *Manifest
+			reread       *Manifest
+			written      []byte
+			rewritten    []byte
+			err          error
+		)
+		if err = os.WriteFile(manifestPath, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if manifest, err = readManifest(manifestPath); err != nil {
+			return
+		}
+		if depth := menuDepth(manifest.Items); depth > maxManifestDepth {
+			t.Fatalf("accepted items nested %d levels deep", depth)
+		}
+		if written, err = marshalJSON(manifest, ""); err != nil {
+			return
+		}
+		if err = writeManifest(manifestPath, manifest); err != nil {
+			return
+		}
+		if reread, err = readManifest(manifestPath); err != nil {
+			t.Fatalf("failed to read back a manifest it wrote: %v", err)
+		}
+		if rewritten, err = marshalJSON(reread, ""); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, rewritten) {
+			t.Fatalf("read back\n%s\nexpected\n%s", rewritten, written)
+		}
+	})
+}
+
+// TestReadManifestErrors checks that errors in a manifest name the value they are about by its
+// path, and in JSON by the line and column it starts at.
+func TestReadManifestErrors(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		filename string
+		data     string
+		want     string
+	}{
+		{
+			name:     "argument of a nested item",
+			filename: "manifest.json",
+			data: `{
+    "schemaVersion": 2,
+    "items": [
+        {"id": "tools", "type": "folder", "title": "工具", "items": [
+            {
+                "id": "openTerminal",
+                "type": "item",
+                "title": "Открыть терминал",
+                "command": ["wt.exe", "-d", 5]
+            }
+        ]}
+    ]
+}
+`,
+			want: "line 9, column 45: items.tools.items.openTerminal.command[2]: ",
+		},
+		{
+			name:     "item keyed by ID",
+			filename: "manifest.json",
+			data:     "{\"items\": {\"openTerminal\": {\"type\": \"item\",\n  \"title\": true}}}",
+			want:     "line 2, column 12: items.openTerminal.title: ",
+		},
+		{
+			name:     "item before its ID",
+			filename: "manifest.json",
+			data:     `{"schemaVersion": 2, "items": [{"id": "a"}, {"type": "item", "bogus": 1, "id": "b"}]}`,
+			want:     `line 1, column 62: items[1].bogus: json: unknown field "bogus"`,
+		},
+		{
+			name:     "duplicate ID",
+			filename: "manifest.json",
+			data:     "{\"schemaVersion\": 2, \"items\": [\n{\"id\": \"a\"},\n{\"id\": \"a\"}]}",
+			want:     `line 3, column 8: items[1]: duplicate item ID "a"`,
+		},
+		{
+			name:     "unknown platform",
+			filename: "manifest.json",
+			data:     `{"items": {"a": {"command": {"windows": ["cmd"], "beos": ["sh"]}}}}`,
+			want:     `line 1, column 50: items.a.command.beos: unknown platform "beos"`,
+		},
+		{
+			name:     "syntax",
+			filename: "manifest.json",
+			data:     "{\"items\": {\"a\": {\"command\": [\"cmd\"\n  \"/c\"]}}}",
+			want:     "line 2, column 4: items.a.command[1]: invalid character",
+		},
+		{
+			name:     "nesting",
+			filename: "manifest.json",
+			data:     strings.Repeat(`{"items": [`, maxManifestDepth),
+			want:     "manifest nests more than 64 levels deep",
+		},
+		{
+			name:     "newer schema after the items",
+			filename: "manifest.json",
+			data:     `{"items": [{"id": "a", "visibleWhen": "x"}], "schemaVersion": 99}`,
+			want:     "manifest.json uses schema version 99",
+		},
+		{
+			name:     "trailing data",
+			filename: "manifest.json",
+			data:     "{\"items\": {}}\n{}",
+			want:     "line 2, column 1: unexpected { after the end of the manifest",
+		},
+		{
+			name:     "YAML has no position",
+			filename: "manifest.yaml",
+			data:     "items:\n  openTerminal:\n    command: [wt.exe, -d, [5]]\n",
+			want:     "failed to parse manifest.json: items.openTerminal.command[2]: ",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var manifestPath = filepath.Join(t.TempDir(), test.filename)
+			if err := os.WriteFile(manifestPath, []byte(test.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readManifest(manifestPath); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("read with error %v, expected %q", err, test.want)
+			}
+		})
+	}
+}
+
+// menuDepth is how many levels of folders menus nest, counting its own.
+func menuDepth(menus ContextMenus) (depth int) {
+	for _, entry := range menus {
+		var sub int
+		if entry.Menu != nil {
+			sub = menuDepth(entry.Menu.Items)
+		}
+		if sub+1 > depth {
+			depth = sub + 1
+		}
+	}
+	return
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// ItemChange is a difference between the items of two manifests, with one detail line per field
+// that changed.
+type ItemChange struct {
+	Kind    ChangeKind `json:"kind"`
+	ID      string     `json:"id"`
+	Title   string     `json:"title,omitempty"`
+	Details []string   `json:"details,omitempty"`
+}
+
+func (c ItemChange) String() string {
+	var prefix = map[ChangeKind]string{ChangeKind_Add: "+", ChangeKind_Remove: "-", ChangeKind_Modify: "~"}[c.Kind]
+	if c.Kind != ChangeKind_Modify {
+		return fmt.Sprintf("%s %s %s", prefix, c.ID, quoteWindowsPath(c.Title))
+	}
+	return fmt.Sprintf("%s %s\n    %s", prefix, c.ID, strings.Join(c.Details, "\n    "))
+}
+
+// diffManifestFiles compares two manifests item by item. Both are formatted first, so layout,
+// field order and path separators do not show up as changes.
+func diffManifestFiles(pathA, pathB string) (changes []ItemChange, err error) {
+	var a, b *Manifest
+	if a, err = readManifest(pathA); err != nil {
+		return
+	}
+	if b, err = readManifest(pathB); err != nil {
+		return
+	}
+	formatItems(a.Items)
+	formatItems(b.Items)
+	changes = diffItems("", a.Items, b.Items)
+	return
+}
+
+// diffItems lists the changes in the order of b, with removed items where they were in a. An item
+// whose position among its siblings changed is reported as moved.
+func diffItems(prefix string, a, b ContextMenus) (changes []ItemChange) {
+	var idsA, idsB []string
+	for _, entry := range a {
+		idsA = append(idsA, entry.ID)
+	}
+	for _, entry := range b {
+		idsB = append(idsB, entry.ID)
+	}
+	for _, op := range diffOps(idsA, idsB) {
+		var (
+			id           = prefix + op.value
+			itemA, itemB = a.Get(op.value), b.Get(op.value)
+			details      []string
+		)
+		switch {
+		case op.kind == '-' && itemB == nil:
+			changes = append(changes, ItemChange{Kind: ChangeKind_Remove, ID: id, Title: itemA.Title})
+			continue
+		case op.kind == '+' && itemA == nil:
+			changes = append(changes, ItemChange{Kind: ChangeKind_Add, ID: id, Title: itemB.Title})
+			continue
+		case op.kind == '-':
+			// Reported where it moved to.
+			continue
+		case op.kind == '+':
+			details = append(details, fmt.Sprintf("moved from position %d to %d", a.Index(op.value)+1, b.Index(op.value)+1))
+		}
+		details = append(details, diffItem(itemA, itemB)...)
+		if len(details) > 0 {
+			changes = append(changes, ItemChange{Kind: ChangeKind_Modify, ID: id, Title: itemB.Title, Details: details})
+		}
+		changes = append(changes, diffItems(id+"/", itemA.Items, itemB.Items)...)
+	}
+	return
+}
+
+func diffItem(a, b *ContextMenu) (details []string) {
+	var (
+		str = func(name, x, y string) {
+			if x != y {
+				details = append(details, fmt.Sprintf("%s: %s -> %s", name, quoteWindowsPath(x), quoteWindowsPath(y)))
+			}
+		}
+		val = func(name string, x, y interface{}) {
+			if fmt.Sprint(x) != fmt.Sprint(y) {
+				details = append(details, fmt.Sprintf("%s: %v -> %v", name, x, y))
+			}
+		}
+		iconIndex = func(i *int) string {
+			if i == nil {
+				return "none"
+			}
+			return fmt.Sprint(*i)
+		}
+	)
+	str("type", string(a.Type), string(b.Type))
+	str("title", a.Title, b.Title)
+	str("description", a.Description, b.Description)
+	if a.Variants.IconPath == nil && b.Variants.IconPath == nil {
+		str("iconPath", a.iconPathTextOn(runtime.GOOS), b.iconPathTextOn(runtime.GOOS))
+	} else {
+		for _, platform := range platforms {
+			str("iconPath ("+platform+")", a.iconPathTextOn(platform), b.iconPathTextOn(platform))
+		}
+	}
+	val("iconIndex", iconIndex(a.IconIndex), iconIndex(b.IconIndex))
+	val("extended", a.Extended, b.Extended)
+	val("admin", a.Admin, b.Admin)
+	if a.Variants.Command == nil && b.Variants.Command == nil {
+		if args := diffArgs(a.Command, b.Command); args != "" {
+			details = append(details, "command: "+args)
+		}
+	} else {
+		for _, platform := range platforms {
+			if args := diffArgs(a.commandOn(platform), b.commandOn(platform)); args != "" {
+				details = append(details, "command ("+platform+"): "+args)
+			}
+		}
+	}
+	if args := diffArgs(a.Targets, b.Targets); args != "" {
+		details = append(details, "targets: "+args)
+	}
+	val("separatorBefore", a.SeparatorBefore, b.SeparatorBefore)
+	val("separatorAfter", a.SeparatorAfter, b.SeparatorAfter)
+	return
+}
+
+// diffArgs shows the arguments of both lists in order, marking those only in a with "-" and those
+// only in b with "+". It returns "" if the lists are equal.
+func diffArgs(a, b []string) string {
+	var (
+		parts   []string
+		changed bool
+	)
+	for _, op := range diffOps(a, b) {
+		if op.kind == ' ' {
+			parts = append(parts, quoteWindowsPath(op.value))
+			continue
+		}
+		changed = true
+		parts = append(parts, string(op.kind)+quoteWindowsPath(op.value))
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDiffItems checks the changes listed between the items of two manifests: added and removed
+// items, moves among siblings, a detail per changed field, and changes inside folders.
+func TestDiffItems(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		a    string
+		b    string
+		want []ItemChange
+	}{
+		{
+			name: "equal",
+			a:    `{"a": {"title": "A", "command": ["a.exe", "%V"]}}`,
+			b:    `{"a": {"title": "A", "command": ["a.exe", "%V"]}}`,
+		},
+		{
+			name: "added and removed",
+			a:    `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			b:    `{"a": {"title": "A"}, "c": {"title": "C"}}`,
+			want: []ItemChange{
+				{Kind: ChangeKind_Remove, ID: "b", Title: "B"},
+				{Kind: ChangeKind_Add, ID: "c", Title: "C"},
+			},
+		},
+		{
+			name: "fields changed",
+			a:    `{"a": {"title": "A", "command": ["a.exe", "%V"], "extended": false}}`,
+			b:    `{"a": {"title": "New", "command": ["a.exe", "--here", "%V"], "extended": true, "iconIndex": 2}}`,
+			want: []ItemChange{{Kind: ChangeKind_Modify, ID: "a", Title: "New", Details: []string{
+				`title: "A" -> "New"`,
+				"iconIndex: none -> 2",
+				"extended: false -> true",
+				`command: "a.exe" +"--here" "%V"`,
+			}}},
+		},
+		{
+			name: "moved",
+			a:    `{"a": {"title": "A"}, "b": {"title": "B"}, "c": {"title": "C"}}`,
+			b:    `{"c": {"title": "C"}, "a": {"title": "A"}, "b": {"title": "B"}}`,
+			want: []ItemChange{{Kind: ChangeKind_Modify, ID: "c", Title: "C", Details: []string{"moved from position 3 to 1"}}},
+		},
+		{
+			name: "command per platform",
+			a:    `{"a": {"title": "A", "command": ["a.exe"]}}`,
+			b:    `{"a": {"title": "A", "command": {"windows": ["a.exe"], "linux": ["a"], "darwin": ["a"]}}}`,
+			want: []ItemChange{{Kind: ChangeKind_Modify, ID: "a", Title: "A", Details: []string{
+				`command (linux): -"a.exe" +"a"`,
+				`command (darwin): -"a.exe" +"a"`,
+			}}},
+		},
+		{
+			name: "inside a folder",
+			a:    `{"f": {"type": "folder", "title": "F", "items": {"x": {"title": "X"}}}}`,
+			b:    `{"f": {"type": "folder", "title": "F", "items": {"x": {"title": "X2"}, "y": {"title": "Y"}}}}`,
+			want: []ItemChange{
+				{Kind: ChangeKind_Modify, ID: "f/x", Title: "X2", Details: []string{`title: "X" -> "X2"`}},
+				{Kind: ChangeKind_Add, ID: "f/y", Title: "Y"},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := diffItems("", testMenus(t, test.a), testMenus(t, test.b)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("listed changes %+v, expected %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestDiffArgs checks how the arguments of two commands are shown side by side.
+func TestDiffArgs(t *testing.T) {
+	for _, test := range []struct {
+		a, b []string
+		want string
+	}{
+		{a: []string{"a.exe", "%V"}, b: []string{"a.exe", "%V"}, want: ""},
+		{a: nil, b: nil, want: ""},
+		{a: nil, b: []string{"a.exe"}, want: `+"a.exe"`},
+		{a: []string{"a.exe", "-x", "%V"}, b: []string{"a.exe", "%V"}, want: `"a.exe" -"-x" "%V"`},
+		{a: []string{"a.exe", "%1"}, b: []string{"a.exe", "%V"}, want: `"a.exe" -"%1" +"%V"`},
+	} {
+		if got := diffArgs(test.a, test.b); got != test.want {
+			t.Errorf("diffArgs(%q, %q) = %q, expected %q", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// memoryBackend keeps what was applied in memory only, to try out the commands, the HTTP API and
+// the UI without changing anything. It forgets everything when the process exits.
+type memoryBackend struct {
+	mu       sync.Mutex
+	applied  map[string]string // item ID to the item as applied, without its children
+	disabled map[string]bool
+}
+
+var memory = &memoryBackend{applied: make(map[string]string), disabled: make(map[string]bool)}
+
+// memoryItems encodes every item of the manifest with the targets it applies to, in manifest order.
+func memoryItems(manifest *Manifest) (ids []string, items map[string]string, err error) {
+	var walk func(prefix string, menus ContextMenus) error
+	items = make(map[string]string)
+	walk = func(prefix string, menus ContextMenus) (err error) {
+		for _, entry := range menus {
+			var (
+				id   = prefix + entry.ID
+				item = *entry.Menu
+				data []byte
+			)
+			item.Items, item.Targets = nil, manifest.Targets(id)
+			if data, err = json.Marshal(item); err != nil {
+				return errorf("item ID %q: %w", id, err)
+			}
+			ids = append(ids, id)
+			items[id] = string(data)
+			if err = walk(id+"/", entry.Menu.Items); err != nil {
+				return
+			}
+		}
+		return
+	}
+	err = walk("", manifest.Items)
+	return
+}
+
+func (b *memoryBackend) Apply(ctx context.Context, manifest *Manifest, manifestDir string) (err error) {
+	var items map[string]string
+	if _, items, err = memoryItems(manifest); err != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id := range b.applied {
+		if _, ok := items[id]; !ok && config.Prune {
+			delete(b.applied, id)
+			delete(b.disabled, id)
+			logf(LogLevel_Info, "pruned %s", id)
+		}
+	}
+	for id, item := range items {
+		b.applied[id] = item
+	}
+	for _, entry := range manifest.Items {
+		logf(LogLevel_Info, "applied %s to %s", entry.ID, Backend_Memory)
+	}
+	return
+}
+
+func (b *memoryBackend) Remove(ctx context.Context) (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.applied = make(map[string]string)
+	b.disabled = make(map[string]bool)
+	return
+}
+
+func (b *memoryBackend) List(manifest *Manifest) ([]ItemStatus, error) {
+	return listItems(manifest, b.itemState)
+}
+
+// Diff reports items that Apply would add or change, and with prune enabled those it would remove.
+func (b *memoryBackend) Diff(manifest *Manifest, manifestDir string) (changes []Change, err error) {
+	var (
+		ids     []string
+		items   map[string]string
+		removed []string
+	)
+	if ids, items, err = memoryItems(manifest); err != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, id := range ids {
+		if have, ok := b.applied[id]; !ok {
+			changes = append(changes, Change{Kind: ChangeKind_Add, Key: id})
+		} else if have != items[id] {
+			changes = append(changes, Change{Kind: ChangeKind_Modify, Key: id, Want: items[id], Have: have})
+		}
+	}
+	for id := range b.applied {
+		if _, ok := items[id]; !ok && config.Prune {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		changes = append(changes, Change{Kind: ChangeKind_Remove, Key: id})
+	}
+	return
+}
+
+func (b *memoryBackend) itemState(manifest *Manifest, id string) (installed, enabled bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, installed = b.applied[id]
+	enabled = installed && !b.disabled[id]
+	return
+}
+
+func (b *memoryBackend) Toggle(manifest *Manifest, id string, enable *bool) (enabled bool, err error) {
+	var installed bool
+	if manifest.Find(id) == nil {
+		err = errorf("item ID %q not found in manifest", id)
+		return
+	}
+	if installed, enabled, err = b.itemState(manifest, id); err != nil {
+		return
+	}
+	if !installed {
+		err = errorf("item ID %q is not applied", id)
+		return
+	}
+	if enable != nil && *enable == enabled {
+		return
+	}
+	enabled = !enabled
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.disabled[id] = !enabled
+	return
+}
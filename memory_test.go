@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestMemoryBackendConformance(t *testing.T) {
+	testConformance(t, memory)
+}
+
+func (b *memoryBackend) tamper(manifest *Manifest, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.applied[id] = `{"title":"Changed behind its back"}`
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// itemFields are the fields of an item that merge takes from one side or the other. Values are
+// compared as shown in the conflict report.
+var itemFields = []struct {
+	name  string
+	value func(c *ContextMenu) string
+	copy  func(dst, src *ContextMenu)
+}{
+	{"type", func(c *ContextMenu) string { return string(c.Type) }, func(dst, src *ContextMenu) { dst.Type = src.Type }},
+	{"title", func(c *ContextMenu) string { return c.Title }, func(dst, src *ContextMenu) { dst.Title = src.Title }},
+	{"description", func(c *ContextMenu) string { return c.Description }, func(dst, src *ContextMenu) { dst.Description = src.Description }},
+	{"iconPath", func(c *ContextMenu) string {
+		return platformText(c.Variants.IconPath != nil, c.iconPathTextOn)
+	}, func(dst, src *ContextMenu) {
+		dst.IconPath, dst.IconFallbacks = src.IconPath, src.IconFallbacks
+		dst.Variants.IconPath, dst.Variants.IconFallbacks = src.Variants.IconPath, src.Variants.IconFallbacks
+	}},
+	{"iconIndex", func(c *ContextMenu) string {
+		if c.IconIndex == nil {
+			return ""
+		}
+		return fmt.Sprint(*c.IconIndex)
+	}, func(dst, src *ContextMenu) { dst.IconIndex = src.IconIndex }},
+	{"extended", func(c *ContextMenu) string { return fmt.Sprint(c.Extended) }, func(dst, src *ContextMenu) { dst.Extended = src.Extended }},
+	{"admin", func(c *ContextMenu) string { return fmt.Sprint(c.Admin) }, func(dst, src *ContextMenu) { dst.Admin = src.Admin }},
+	{"command", func(c *ContextMenu) string {
+		return platformText(c.Variants.Command != nil, func(platform string) string { return joinCommandLine(c.commandOn(platform)) })
+	}, func(dst, src *ContextMenu) { dst.Command, dst.Variants.Command = src.Command, src.Variants.Command }},
+	{"targets", func(c *ContextMenu) string { return strings.Join(c.Targets, ",") }, func(dst, src *ContextMenu) { dst.Targets = src.Targets }},
+	{"separatorBefore", func(c *ContextMenu) string { return fmt.Sprint(c.SeparatorBefore) }, func(dst, src *ContextMenu) { dst.SeparatorBefore = src.SeparatorBefore }},
+	{"separatorAfter", func(c *ContextMenu) string { return fmt.Sprint(c.SeparatorAfter) }, func(dst, src *ContextMenu) { dst.SeparatorAfter = src.SeparatorAfter }},
+}
+
+// runMerge combines the items of two manifests. With a base manifest, the one both were changed
+// from, a change on one side is taken over an unchanged field of the other; without it, fields
+// that differ conflict. Conflicts keep mine and are reported, and the merged manifest is written
+// either way so it can be fixed by hand.
+func runMerge(args []string) (err error) {
+	var (
+		flags     = newFlagSet("merge")
+		output    = flags.String("output", "", "file to write the merged manifest to (default: standard output)")
+		base      = &Manifest{}
+		mine      *Manifest
+		theirs    *Manifest
+		merged    *Manifest
+		conflicts []string
+		data      []byte
+		paths     []string
+		format    string
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if paths = flags.Args(); len(paths) != 2 && len(paths) != 3 {
+		err = errorf("merge expects [BASE] MINE THEIRS")
+		return
+	}
+	if len(paths) == 3 {
+		if base, err = readManifest(paths[0]); err != nil {
+			return
+		}
+		paths = paths[1:]
+	}
+	if mine, err = readManifest(paths[0]); err != nil {
+		return
+	}
+	if theirs, err = readManifest(paths[1]); err != nil {
+		return
+	}
+	formatItems(base.Items)
+	formatItems(mine.Items)
+	formatItems(theirs.Items)
+	merged = &Manifest{Schema: mine.Schema, SchemaVersion: mine.SchemaVersion}
+	if theirs.SchemaVersion > merged.SchemaVersion {
+		merged.SchemaVersion = theirs.SchemaVersion
+	}
+	merged.Items = mergeItems("", base.Items, mine.Items, theirs.Items, &conflicts)
+	// Written to standard output, the merged manifest is in the format of mine.
+	if format = *output; format == "" {
+		format = paths[0]
+	}
+	if data, err = encodeManifest(format, merged); err != nil {
+		return
+	}
+	if err = writeOutput(*output, string(data)); err != nil {
+		return
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintln(os.Stderr, conflict)
+	}
+	if len(conflicts) > 0 {
+		err = errorf("%d conflicts, see above; mine was kept for each", len(conflicts))
+	}
+	return
+}
+
+// mergeItems merges the siblings of one folder, in the order of mine with the items added by
+// theirs after the item they follow there.
+func mergeItems(prefix string, base, mine, theirs ContextMenus, conflicts *[]string) (merged ContextMenus) {
+	for _, entry := range mine {
+		var (
+			id            = prefix + entry.ID
+			baseItem      = base.Get(entry.ID)
+			theirItem     = theirs.Get(entry.ID)
+			changedInMine = baseItem != nil && !sameItem(baseItem, entry.Menu)
+		)
+		switch {
+		case theirItem != nil:
+			merged = append(merged, ContextMenuEntry{ID: entry.ID, Menu: mergeItem(id, baseItem, entry.Menu, theirItem, conflicts)})
+		case baseItem == nil:
+			merged = append(merged, entry)
+		case changedInMine:
+			*conflicts = append(*conflicts, sprintf("%s: changed in mine but removed in theirs, kept it", id))
+			merged = append(merged, entry)
+		}
+	}
+	for i, entry := range theirs {
+		var (
+			id       = prefix + entry.ID
+			baseItem = base.Get(entry.ID)
+			at       = 0
+		)
+		if mine.Get(entry.ID) != nil {
+			continue
+		}
+		if baseItem != nil {
+			if sameItem(baseItem, entry.Menu) {
+				continue
+			}
+			*conflicts = append(*conflicts, sprintf("%s: removed in mine but changed in theirs, kept it", id))
+		}
+		for j := i - 1; j >= 0; j-- {
+			if k := merged.Index(theirs[j].ID); k >= 0 {
+				at = k + 1
+				break
+			}
+		}
+		merged = append(merged[:at], append(ContextMenus{entry}, merged[at:]...)...)
+	}
+	// Items that share a title and a target look the same in the menu. Those already in mine are
+	// left alone.
+	for i, a := range merged {
+		for _, b := range merged[i+1:] {
+			if mine.Get(a.ID) != nil && mine.Get(b.ID) != nil {
+				continue
+			}
+			if a.Menu.Title != "" && strings.EqualFold(a.Menu.Title, b.Menu.Title) && shareTarget(a.Menu, b.Menu) {
+				*conflicts = append(*conflicts, sprintf("%s and %s have the same title %s", prefix+a.ID, prefix+b.ID, quoteWindowsPath(a.Menu.Title)))
+			}
+		}
+	}
+	return
+}
+
+// mergeItem merges the fields of an item that both sides have. Without a base, for items added
+// on both sides, any field that differs conflicts.
+func mergeItem(id string, base, mine, theirs *ContextMenu, conflicts *[]string) *ContextMenu {
+	var (
+		merged = *mine
+		added  = base == nil
+	)
+	if added {
+		base = &ContextMenu{}
+	}
+	for _, field := range itemFields {
+		var (
+			baseValue  = field.value(base)
+			mineValue  = field.value(mine)
+			theirValue = field.value(theirs)
+		)
+		switch {
+		case mineValue == theirValue:
+		case added:
+			*conflicts = append(*conflicts, sprintf("%s: %s is %s in mine and %s in theirs", id, field.name, quoteWindowsPath(mineValue), quoteWindowsPath(theirValue)))
+		case mineValue == baseValue:
+			field.copy(&merged, theirs)
+		case theirValue != baseValue:
+			*conflicts = append(*conflicts, sprintf("%s: %s is %s in mine and %s in theirs", id, field.name, quoteWindowsPath(mineValue), quoteWindowsPath(theirValue)))
+		}
+	}
+	merged.Items = mergeItems(id+"/", base.Items, mine.Items, theirs.Items, conflicts)
+	return &merged
+}
+
+func sameItem(a, b *ContextMenu) bool {
+	return len(diffItem(a, b)) == 0 && len(diffItems("", a.Items, b.Items)) == 0
+}
+
+func shareTarget(a, b *ContextMenu) bool {
+	var targetsA, targetsB = a.Targets, b.Targets
+	if len(targetsA) == 0 {
+		targetsA = config.Targets
+	}
+	if len(targetsB) == 0 {
+		targetsB = config.Targets
+	}
+	for _, target := range targetsA {
+		if containsFold(targetsB, target) {
+			return true
+		}
+	}
+	return false
+}
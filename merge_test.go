@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testMenus decodes items written as in a manifest, a list or an object keyed by ID.
+func testMenus(t *testing.T, items string) (menus ContextMenus) {
+	t.Helper()
+	if items == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(items), &menus); err != nil {
+		t.Fatalf("failed to decode %s: %v", items, err)
+	}
+	return
+}
+
+// sameMenus compares items by the manifest they would be written as.
+func sameMenus(t *testing.T, got, want ContextMenus) bool {
+	t.Helper()
+	gotJSON, err := marshalJSON(got, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, err := marshalJSON(want, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(gotJSON) == string(wantJSON)
+}
+
+// TestMergeItems checks three-way merges of items: what each side changed is taken, what both
+// changed differently conflicts and keeps mine, and items added by theirs go after the item they
+// follow there.
+func TestMergeItems(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		base      string
+		mine      string
+		theirs    string
+		want      string
+		conflicts []string
+	}{
+		{
+			name:   "changed in theirs",
+			base:   `{"a": {"type": "item", "title": "A", "command": ["a.exe"]}}`,
+			mine:   `{"a": {"type": "item", "title": "A", "command": ["a.exe"]}}`,
+			theirs: `{"a": {"type": "item", "title": "A", "command": ["a.exe", "%V"]}}`,
+			want:   `{"a": {"type": "item", "title": "A", "command": ["a.exe", "%V"]}}`,
+		},
+		{
+			name:   "different fields changed on each side",
+			base:   `{"a": {"type": "item", "title": "A", "command": ["a.exe"]}}`,
+			mine:   `{"a": {"type": "item", "title": "Mine", "command": ["a.exe"]}}`,
+			theirs: `{"a": {"type": "item", "title": "A", "command": ["b.exe"], "extended": true}}`,
+			want:   `{"a": {"type": "item", "title": "Mine", "command": ["b.exe"], "extended": true}}`,
+		},
+		{
+			name:      "same field changed differently",
+			base:      `{"a": {"type": "item", "title": "A"}}`,
+			mine:      `{"a": {"type": "item", "title": "Mine"}}`,
+			theirs:    `{"a": {"type": "item", "title": "Theirs"}}`,
+			want:      `{"a": {"type": "item", "title": "Mine"}}`,
+			conflicts: []string{`a: title is "Mine" in mine and "Theirs" in theirs`},
+		},
+		{
+			name:   "same change on both sides",
+			base:   `{"a": {"type": "item", "title": "A"}}`,
+			mine:   `{"a": {"type": "item", "title": "B"}}`,
+			theirs: `{"a": {"type": "item", "title": "B"}}`,
+			want:   `{"a": {"type": "item", "title": "B"}}`,
+		},
+		{
+			name:   "added in theirs after the item it follows",
+			base:   `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			mine:   `{"d": {"title": "D"}, "a": {"title": "A"}, "b": {"title": "B"}}`,
+			theirs: `{"a": {"title": "A"}, "c": {"title": "C"}, "b": {"title": "B"}}`,
+			want:   `{"d": {"title": "D"}, "a": {"title": "A"}, "c": {"title": "C"}, "b": {"title": "B"}}`,
+		},
+		{
+			name:   "added first in theirs",
+			base:   `{"a": {"title": "A"}}`,
+			mine:   `{"a": {"title": "A"}}`,
+			theirs: `{"c": {"title": "C"}, "a": {"title": "A"}}`,
+			want:   `{"c": {"title": "C"}, "a": {"title": "A"}}`,
+		},
+		{
+			name:   "removed in theirs",
+			base:   `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			mine:   `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			theirs: `{"a": {"title": "A"}}`,
+			want:   `{"a": {"title": "A"}}`,
+		},
+		{
+			name:   "removed in mine",
+			base:   `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			mine:   `{"b": {"title": "B"}}`,
+			theirs: `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			want:   `{"b": {"title": "B"}}`,
+		},
+		{
+			name:      "changed in mine, removed in theirs",
+			base:      `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			mine:      `{"a": {"title": "A"}, "b": {"title": "Mine"}}`,
+			theirs:    `{"a": {"title": "A"}}`,
+			want:      `{"a": {"title": "A"}, "b": {"title": "Mine"}}`,
+			conflicts: []string{"b: changed in mine but removed in theirs, kept it"},
+		},
+		{
+			name:      "removed in mine, changed in theirs",
+			base:      `{"a": {"title": "A"}, "b": {"title": "B"}}`,
+			mine:      `{"a": {"title": "A"}}`,
+			theirs:    `{"a": {"title": "A"}, "b": {"title": "Theirs"}}`,
+			want:      `{"a": {"title": "A"}, "b": {"title": "Theirs"}}`,
+			conflicts: []string{"b: removed in mine but changed in theirs, kept it"},
+		},
+		{
+			name:   "changed in a folder",
+			base:   `{"f": {"type": "folder", "title": "F", "items": {"x": {"title": "X"}}}}`,
+			mine:   `{"f": {"type": "folder", "title": "Folder", "items": {"x": {"title": "X"}}}}`,
+			theirs: `{"f": {"type": "folder", "title": "F", "items": {"x": {"title": "X2"}, "y": {"title": "Y"}}}}`,
+			want:   `{"f": {"type": "folder", "title": "Folder", "items": {"x": {"title": "X2"}, "y": {"title": "Y"}}}}`,
+		},
+		{
+			name:      "added on both sides without a base",
+			mine:      `{"a": {"type": "item", "title": "A", "admin": true}}`,
+			theirs:    `{"a": {"type": "item", "title": "A", "admin": false}}`,
+			want:      `{"a": {"type": "item", "title": "A", "admin": true}}`,
+			conflicts: []string{`a: admin is "true" in mine and "false" in theirs`},
+		},
+		{
+			name:      "added with the title of another item on its target",
+			mine:      `{"a": {"title": "Open", "targets": ["background"]}}`,
+			theirs:    `{"a": {"title": "Open", "targets": ["background"]}, "b": {"title": "open", "targets": ["directory", "background"]}}`,
+			want:      `{"a": {"title": "Open", "targets": ["background"]}, "b": {"title": "open", "targets": ["directory", "background"]}}`,
+			conflicts: []string{`a and b have the same title "Open"`},
+		},
+		{
+			name:   "added first with the title of another item on another target",
+			mine:   `{"a": {"title": "Open", "targets": ["background"]}}`,
+			theirs: `{"b": {"title": "Open", "targets": ["directory"]}}`,
+			want:   `{"b": {"title": "Open", "targets": ["directory"]}, "a": {"title": "Open", "targets": ["background"]}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				conflicts []string
+				merged    = mergeItems("", testMenus(t, test.base), testMenus(t, test.mine), testMenus(t, test.theirs), &conflicts)
+			)
+			if want := testMenus(t, test.want); !sameMenus(t, merged, want) {
+				got, _ := marshalJSON(merged, "")
+				t.Errorf("merged into %s, expected %s", got, test.want)
+			}
+			if !reflect.DeepEqual(conflicts, test.conflicts) {
+				t.Errorf("reported conflicts %q, expected %q", conflicts, test.conflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runMigrate rewrites a manifest in the layout of the newest schema version. It only shows the
+// change unless --write is given, so it can be reviewed first.
+func runMigrate(args []string) (err error) {
+	var (
+		flags        = newFlagSet("migrate")
+		manifestPath = flags.String("manifest", "", "manifest to migrate (default: the manifest found by apply)")
+		write        = flags.Bool("write", false, "rewrite the manifest instead of only showing the changes")
+		manifest     *Manifest
+		before       []byte
+		after        []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if *manifestPath == "" {
+		if *manifestPath, err = findManifest(); err != nil {
+			return
+		}
+	}
+	if manifest, err = readManifest(*manifestPath); err != nil {
+		return
+	}
+	if manifest.SchemaVersion >= manifestSchemaVersion {
+		fmt.Printf(tr("%s is already at schema version %d.\n"), *manifestPath, manifestSchemaVersion)
+		return
+	}
+	if before, err = os.ReadFile(*manifestPath); err != nil {
+		err = errorf("failed to read manifest.json: %w", err)
+		return
+	}
+	manifest.SchemaVersion = manifestSchemaVersion
+	if after, err = encodeManifest(*manifestPath, manifest); err != nil {
+		return
+	}
+	fmt.Print(unifiedDiff(*manifestPath, string(decodeText(before)), string(after)))
+	if !*write {
+		fmt.Fprintln(os.Stderr, tr("Run again with --write to save these changes."))
+		return
+	}
+	if err = writeManifest(*manifestPath, manifest); err != nil {
+		return
+	}
+	fmt.Printf(tr("Migrated %s to schema version %d.\n"), *manifestPath, manifestSchemaVersion)
+	return
+}
+
+// unifiedDiff compares two texts line by line and shows the changes with three lines of context,
+// like diff -u.
+func unifiedDiff(name, a, b string) string {
+	const context = 3
+	var (
+		ops = diffOps(splitLines(a), splitLines(b))
+		out strings.Builder
+	)
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+	for start := 0; start < len(ops); {
+		var (
+			first, last = -1, -1
+			oldLine     = 1
+			newLine     = 1
+			oldCount    int
+			newCount    int
+		)
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind == ' ' {
+				continue
+			}
+			if first < 0 {
+				first = i
+			} else if i-last-1 > 2*context {
+				break
+			}
+			last = i
+		}
+		if first < 0 {
+			break
+		}
+		lo, hi := first-context, last+context+1
+		if lo < start {
+			lo = start
+		}
+		if hi > len(ops) {
+			hi = len(ops)
+		}
+		for _, o := range ops[:lo] {
+			if o.kind != '+' {
+				oldLine++
+			}
+			if o.kind != '-' {
+				newLine++
+			}
+		}
+		for _, o := range ops[lo:hi] {
+			if o.kind != '+' {
+				oldCount++
+			}
+			if o.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, o := range ops[lo:hi] {
+			fmt.Fprintf(&out, "%c%s\n", o.kind, o.value)
+		}
+		start = hi
+	}
+	return out.String()
+}
+
+// hunkRange writes the lines of a hunk as diff -u does: an empty range starts at the line before
+// it, and a range of one line has no count.
+func hunkRange(line, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", line-1)
+	case 1:
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+type diffOp struct {
+	kind  byte // ' ' if value is in both, '-' if only in x, '+' if only in y
+	value string
+}
+
+// diffOps returns the shortest edit script turning x into y, found through their longest common
+// subsequence.
+func diffOps(x, y []string) (ops []diffOp) {
+	var lcs = make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, diffOp{' ', x[i]})
+			i, j = i+1, j+1
+		case j == len(y) || i < len(x) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', x[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', y[j]})
+			j++
+		}
+	}
+	return
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestUnifiedDiff checks that the changes migrate shows read as diff -u writes them: three lines
+// of context, hunks merged when their context would touch, and the ranges of empty and one-line
+// hunks.
+func TestUnifiedDiff(t *testing.T) {
+	var lines = func(from, to int, replace map[int]string) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			if line, ok := replace[i]; ok {
+				b.WriteString(line + "\n")
+			} else {
+				fmt.Fprintf(&b, "l%d\n", i)
+			}
+		}
+		return b.String()
+	}
+	for _, test := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    lines(1, 5, nil),
+			b:    lines(1, 5, nil),
+			want: "",
+		},
+		{
+			name: "line changed",
+			a:    lines(1, 10, nil),
+			b:    lines(1, 10, map[int]string{5: "X"}),
+			want: "@@ -2,7 +2,7 @@\n l2\n l3\n l4\n-l5\n+X\n l6\n l7\n l8\n",
+		},
+		{
+			name: "far apart changes",
+			a:    lines(1, 20, nil),
+			b:    lines(1, 20, map[int]string{2: "A", 18: "B"}),
+			want: "@@ -1,5 +1,5 @@\n l1\n-l2\n+A\n l3\n l4\n l5\n" +
+				"@@ -15,6 +15,6 @@\n l15\n l16\n l17\n-l18\n+B\n l19\n l20\n",
+		},
+		{
+			name: "changes six lines apart",
+			a:    lines(1, 10, nil),
+			b:    lines(1, 10, map[int]string{2: "A", 9: "B"}),
+			want: "@@ -1,10 +1,10 @@\n l1\n-l2\n+A\n l3\n l4\n l5\n l6\n l7\n l8\n-l9\n+B\n l10\n",
+		},
+		{
+			name: "added to an empty file",
+			a:    "",
+			b:    "x\ny\n",
+			want: "@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "only line removed",
+			a:    "x\n",
+			b:    "",
+			want: "@@ -1 +0,0 @@\n-x\n",
+		},
+		{
+			name: "CRLF against LF",
+			a:    "a\r\nb\r\n",
+			b:    "a\nc\n",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			want := "--- m.json\n+++ m.json\n" + test.want
+			if got := unifiedDiff("m.json", test.a, test.b); got != want {
+				t.Errorf("diff is\n%s\nexpected\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// planNautilus renders the manifest into scripts under Nautilus' scripts folder, parents before
+// children. Nautilus names the entries after the files and sorts them, so titles become file
+// names and the manifest order is lost; it has no icons, separators or extended items either.
+func planNautilus(manifest *Manifest, manifestDir string) (files []managedFile, err error) {
+	var (
+		dir  string
+		plan func(dir, prefix string, menus ContextMenus, targets []string)
+	)
+	if dir, err = dataHome(); err != nil {
+		return
+	}
+	plan = func(dir, prefix string, menus ContextMenus, targets []string) {
+		var names = make(map[string]bool)
+		for _, entry := range menus {
+			var (
+				item = entry.Menu
+				id   = prefix + entry.ID
+				name = strings.ReplaceAll(plainTitle(item.Title), "/", "-")
+			)
+			if prefix == "" {
+				targets = manifest.Targets(id)
+			}
+			if name == "" || names[strings.ToLower(name)] {
+				name = strings.TrimSpace(name + " (" + entry.ID + ")")
+			}
+			if nameErr := checkFileName(id, name); nameErr != nil {
+				if err == nil {
+					err = nameErr
+				}
+				continue
+			}
+			names[strings.ToLower(name)] = true
+			file := managedFile{ID: id, Path: filepath.Join(dir, name)}
+			if item.Type == ContextMenuType_Folder {
+				files = append(files, file)
+				plan(file.Path, id+"/", item.Items, targets)
+				continue
+			}
+			file.Data = nautilusScript(id, item, targets, manifestDir)
+			files = append(files, file)
+		}
+	}
+	plan(filepath.Join(dir, "nautilus", "scripts"), "", manifest.Items, nil)
+	return
+}
+
+// nautilusScript runs the command once for each selected file, or for the open folder when the
+// menu is opened on its background, like Explorer does. Nautilus passes names relative to the
+// open folder, which are made absolute as Explorer's are.
+func nautilusScript(id string, item *ContextMenu, targets []string, manifestDir string) string {
+	var (
+		b       strings.Builder
+		command = posixCommand(item.Command, manifestDir)
+	)
+	if item.Admin {
+		command = posixElevated(command)
+	}
+	fmt.Fprintf(&b, "#!/bin/sh\n# Written by context-menu-manager for %q, changes are lost on the next apply.\n", id)
+	b.WriteString("[ $# -gt 0 ] || set -- \"$PWD\"\n")
+	b.WriteString("for f in \"$@\"; do\n")
+	b.WriteString("\tcase \"$f\" in /*) ;; *) f=\"$PWD/$f\" ;; esac\n")
+	if guard := posixTargetGuard(targets); guard != "" {
+		fmt.Fprintf(&b, "\t%s\n", guard)
+	}
+	fmt.Fprintf(&b, "\t%s &\n", command)
+	b.WriteString("done\n")
+	return b.String()
+}
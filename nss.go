@@ -0,0 +1,462 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// nssTypes maps the type property of Nilesoft Shell items to targets.
+var nssTypes = map[string]string{
+	"file":           "file",
+	"dir":            "directory",
+	"directory":      "directory",
+	"back":           "background",
+	"back.dir":       "background",
+	"back.directory": "background",
+	"desktop":        "desktop",
+	"drive":          "drive",
+}
+
+// nssVariables are the Nilesoft Shell expressions with an equivalent in registry verbs.
+var nssVariables = strings.NewReplacer(
+	"@sel.path.quote", `"%V"`,
+	"@sel.path", "%V",
+	"@sel.dir", "%V",
+	"@sel.parent", "%W",
+	"@sel", "%V",
+	"@sys.bin", `%SystemRoot%\System32`,
+	"@sys.dir", "%SystemRoot%",
+)
+
+// nssScanner reads the tokens of a Nilesoft Shell config: names, quoted strings and punctuation.
+type nssScanner struct {
+	src []rune
+	pos int
+}
+
+func (s *nssScanner) more() bool {
+	s.skipSpace()
+	return s.pos < len(s.src)
+}
+
+func (s *nssScanner) peek() rune {
+	s.skipSpace()
+	if s.pos < len(s.src) {
+		return s.src[s.pos]
+	}
+	return 0
+}
+
+// skip moves past the next occurrence of end, or to the end of the file.
+func (s *nssScanner) skip(end string) {
+	for s.pos < len(s.src) && !s.at(end) {
+		s.pos++
+	}
+	s.pos += len([]rune(end))
+	if s.pos > len(s.src) {
+		s.pos = len(s.src)
+	}
+}
+
+func (s *nssScanner) at(prefix string) bool {
+	var i = s.pos
+	for _, r := range prefix {
+		if i >= len(s.src) || s.src[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func (s *nssScanner) skipSpace() {
+	for s.pos < len(s.src) {
+		switch {
+		case unicode.IsSpace(s.src[s.pos]):
+			s.pos++
+		case s.at("//"):
+			s.skip("\n")
+		case s.at("/*"):
+			s.pos += 2
+			s.skip("*/")
+		default:
+			return
+		}
+	}
+}
+
+func nssNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-$@", r)
+}
+
+func (s *nssScanner) name() string {
+	var start = s.pos
+	for s.pos < len(s.src) && nssNameRune(s.src[s.pos]) {
+		s.pos++
+	}
+	return string(s.src[start:s.pos])
+}
+
+// str reads a single or double quoted string. Backslashes are path separators, not escapes.
+func (s *nssScanner) str() string {
+	var start = s.pos + 1
+	s.pos++
+	s.skip(string(s.src[start-1]))
+	return strings.TrimSuffix(string(s.src[start:s.pos]), string(s.src[start-1]))
+}
+
+// value reads a property value: a string, or an expression up to the next space outside brackets.
+func (s *nssScanner) value() string {
+	var start, depth int
+	if r := s.peek(); r == '\'' || r == '"' {
+		return s.str()
+	}
+	start = s.pos
+	for ; s.pos < len(s.src); s.pos++ {
+		switch r := s.src[s.pos]; {
+		case r == '(' || r == '[':
+			depth++
+		case (r == ')' || r == ']') && depth > 0:
+			depth--
+		case r == ')' || depth == 0 && unicode.IsSpace(r):
+			return string(s.src[start:s.pos])
+		case r == '\'' || r == '"':
+			s.str()
+			s.pos--
+		}
+	}
+	return string(s.src[start:s.pos])
+}
+
+// props reads a parenthesized property list into lower case names. Flags without a value, such
+// as admin, are "true".
+func (s *nssScanner) props() (props map[string]string) {
+	props = make(map[string]string)
+	if s.peek() != '(' {
+		return
+	}
+	s.pos++
+	for s.more() {
+		switch r := s.peek(); {
+		case r == ')':
+			s.pos++
+			return
+		case nssNameRune(r):
+			name := strings.ToLower(s.name())
+			if s.peek() == '=' {
+				s.pos++
+				props[name] = s.value()
+			} else {
+				props[name] = "true"
+			}
+		case r == '\'' || r == '"':
+			s.str()
+		default:
+			s.pos++
+		}
+	}
+	return
+}
+
+func (s *nssScanner) skipBlock() {
+	var depth int
+	for s.more() {
+		switch r := s.peek(); {
+		case r == '\'' || r == '"':
+			s.str()
+			continue
+		case r == '{':
+			depth++
+		case r == '}':
+			if depth--; depth == 0 {
+				s.pos++
+				return
+			}
+		}
+		s.pos++
+	}
+}
+
+// parseNilesoft converts the static menu and item definitions of a Nilesoft Shell config, such as
+// shell.nss. Changes to existing menus (modify and remove) and imports of other files are skipped.
+func parseNilesoft(data []byte) (items ContextMenus, skipped int, err error) {
+	var s = &nssScanner{src: []rune(string(data))}
+	for _, entry := range s.block(&skipped) {
+		if len(entry.Menu.Targets) == 0 {
+			entry.ID = uniqueID(items, entry.ID)
+			items = append(items, entry)
+			continue
+		}
+		for _, target := range entry.Menu.Targets {
+			addImported(&items, entry.ID, entry.Menu, target)
+		}
+	}
+	return
+}
+
+// block reads statements up to the closing brace of the block, or the end of the file.
+func (s *nssScanner) block(skipped *int) (menus ContextMenus) {
+	var separator bool
+	for s.more() {
+		var r = s.peek()
+		switch {
+		case r == '}':
+			s.pos++
+			return
+		case r == '{':
+			s.skipBlock()
+		case r == '\'' || r == '"':
+			s.str()
+		case !nssNameRune(r):
+			s.pos++
+		default:
+			switch keyword := strings.ToLower(s.name()); keyword {
+			case "item", "menu":
+				var (
+					props    = s.props()
+					children ContextMenus
+				)
+				if s.peek() == '{' {
+					s.pos++
+					children = s.block(skipped)
+				}
+				item, id, ok := nssItem(keyword, props, children)
+				if !ok {
+					*skipped++
+					continue
+				}
+				item.SeparatorBefore = item.SeparatorBefore || separator
+				separator = false
+				menus = append(menus, ContextMenuEntry{ID: uniqueID(menus, id), Menu: item})
+			case "separator", "sep":
+				s.props()
+				separator = true
+			case "shell", "static", "dynamic":
+				if s.peek() == '{' {
+					s.pos++
+					menus = append(menus, s.block(skipped)...)
+				}
+			default:
+				if keyword == "modify" || keyword == "remove" {
+					*skipped++
+				}
+				s.props()
+				switch s.peek() {
+				case '=':
+					s.pos++
+					s.value()
+				case '{':
+					s.skipBlock()
+				}
+			}
+		}
+	}
+	if separator && len(menus) > 0 {
+		menus[len(menus)-1].Menu.SeparatorAfter = true
+	}
+	return
+}
+
+func nssItem(keyword string, props map[string]string, children ContextMenus) (item *ContextMenu, id string, ok bool) {
+	var (
+		visibility = strings.ToLower(props["vis"] + " " + props["where"])
+		args       = nssVariables.Replace(props["args"])
+	)
+	item = &ContextMenu{
+		Type:     ContextMenuType_Item,
+		Title:    nssVariables.Replace(props["title"]),
+		Admin:    props["admin"] != "" && props["admin"] != "false",
+		Extended: strings.Contains(visibility, "key.shift()"),
+	}
+	if strings.Contains(visibility, "hidden") || props["vis"] == "0" {
+		return
+	}
+	switch {
+	case keyword == "menu":
+		if len(children) == 0 {
+			return
+		}
+		item.Type, item.Items = ContextMenuType_Folder, children
+		for _, child := range children {
+			child.Menu.Targets = nil
+		}
+	case strings.HasPrefix(props["cmd"], "command."):
+		// Built-in commands such as command.copy_to_clipboard have no executable to run.
+		return
+	case props["cmd"] != "":
+		item.Command = append([]string{nssVariables.Replace(props["cmd"])}, splitCommandLine(args)...)
+	case props["cmd-line"] != "":
+		item.Command = append([]string{"cmd.exe"}, splitCommandLine(nssVariables.Replace(props["cmd-line"]))...)
+	case props["cmd-ps"] != "":
+		item.Command = append([]string{"powershell.exe"}, splitCommandLine(nssVariables.Replace(props["cmd-ps"]))...)
+	case props["cmd-pwsh"] != "":
+		item.Command = append([]string{"pwsh.exe"}, splitCommandLine(nssVariables.Replace(props["cmd-pwsh"]))...)
+	default:
+		return
+	}
+	if image := nssVariables.Replace(props["image"]); strings.Contains(image, ".") && !strings.HasPrefix(image, "icon.") && !strings.HasPrefix(image, "[") {
+		item.IconPath, item.IconIndex = splitIconLocation(image)
+	}
+	switch strings.ToLower(props["sep"]) {
+	case "before", "top":
+		item.SeparatorBefore = true
+	case "after", "bottom":
+		item.SeparatorAfter = true
+	case "both", "true":
+		item.SeparatorBefore, item.SeparatorAfter = true, true
+	}
+	for _, t := range strings.Split(strings.ToLower(props["type"]), "|") {
+		if target, found := nssTypes[strings.TrimSpace(t)]; found {
+			item.Targets = append(item.Targets, target)
+		}
+	}
+	if find := strings.Split(props["find"], "|"); props["find"] != "" {
+		var extensions []string
+		for _, f := range find {
+			if f = strings.TrimSpace(f); strings.HasPrefix(f, ".") && !strings.ContainsAny(f, "*? ") {
+				extensions = append(extensions, strings.ToLower(f))
+			}
+		}
+		if len(extensions) == len(find) {
+			item.Targets = extensions
+		}
+	}
+	if strings.HasPrefix(item.Title, "title.") {
+		// Built-in, translated titles such as title.copy_path.
+		item.Title = strings.ReplaceAll(strings.TrimPrefix(item.Title, "title."), "_", " ")
+	}
+	if item.Title == "" {
+		item.Title = keyword
+		if len(item.Command) > 0 {
+			item.Title = item.Command[0]
+		}
+	}
+	id = strings.NewReplacer("&", "", "/", "-", `\`, "-").Replace(strings.TrimSpace(item.Title))
+	ok = true
+	return
+}
+
+// nssTargetTypes maps targets to the type property of Nilesoft Shell items.
+var nssTargetTypes = map[string]string{
+	"file":       "file",
+	"directory":  "dir",
+	"folder":     "dir",
+	"background": "back",
+	"desktop":    "desktop",
+	"drive":      "drive",
+}
+
+// nssExpressions are the Nilesoft Shell equivalents of common environment variables and of verb
+// placeholders. Variables come first, since earlier replacements win and %L would match %LOCALAPPDATA%.
+var nssExpressions = strings.NewReplacer(
+	"%LOCALAPPDATA%", "@user.localappdata",
+	"%APPDATA%", "@user.appdata",
+	"%USERPROFILE%", "@user.directory",
+	"%SystemRoot%", "@sys.dir",
+	"%windir%", "@sys.dir",
+	"%ProgramFiles%", "@sys.prog",
+	"%ProgramFiles(x86)%", "@sys.prog32",
+	"%V", "@sel.path",
+	"%v", "@sel.path",
+	"%1", "@sel.path",
+	"%L", "@sel.path",
+	"%W", "@sel.parent",
+	"%w", "@sel.parent",
+)
+
+// nssConfig renders the manifest as item and menu definitions for shell.nss to import. Targets
+// that are neither aliases nor extensions have no Nilesoft Shell type and are left out.
+func nssConfig(manifest *Manifest, manifestDir string) (text string, err error) {
+	var b strings.Builder
+	b.WriteString("// Generated by context-menu-manager. Import it from shell.nss, e.g. import 'imports/context-menu-manager.nss'\n")
+	for _, entry := range manifest.Items {
+		var (
+			types      []string
+			extensions []string
+		)
+		for _, target := range manifest.Targets(entry.ID) {
+			switch t, ok := nssTargetTypes[strings.ToLower(target)]; {
+			case ok:
+				types = append(types, t)
+			case strings.HasPrefix(target, "."):
+				extensions = append(extensions, target)
+			default:
+				fmt.Fprintf(&b, "// %s: target %s has no equivalent\n", entry.ID, target)
+			}
+		}
+		if len(types) > 0 {
+			writeNssItem(&b, "", entry.Menu, manifestDir, fmt.Sprintf("type=%s", nssQuote(strings.Join(types, "|"))))
+		}
+		if len(extensions) > 0 {
+			writeNssItem(&b, "", entry.Menu, manifestDir, fmt.Sprintf("type='file' find=%s", nssQuote(strings.Join(extensions, "|"))))
+		}
+	}
+	text = b.String()
+	return
+}
+
+func writeNssItem(b *strings.Builder, indent string, item *ContextMenu, manifestDir string, where string) {
+	var (
+		keyword = "item"
+		props   = []string{"title=" + nssQuote(item.Title)}
+		expand  = func(s string) string {
+			return nssExpressions.Replace(strings.ReplaceAll(s, "${manifestFolder}", manifestDir))
+		}
+	)
+	if item.Type == ContextMenuType_Folder {
+		keyword = "menu"
+	}
+	if icon, index := item.IconLocation(manifestDir); icon != "" {
+		if index != nil {
+			icon = fmt.Sprintf("%s,%d", icon, *index)
+		}
+		props = append(props, "image="+nssQuote(expand(icon)))
+	}
+	if len(item.Command) > 0 && item.Type != ContextMenuType_Folder {
+		var args []string
+		for _, arg := range item.Command[1:] {
+			if arg == "" || strings.ContainsAny(arg, " %") {
+				arg = `"` + arg + `"`
+			}
+			args = append(args, expand(arg))
+		}
+		props = append(props, "cmd="+nssQuote(expand(item.Command[0])))
+		if len(args) > 0 {
+			props = append(props, "args="+nssQuote(strings.Join(args, " ")))
+		}
+	}
+	if item.Admin && item.Type != ContextMenuType_Folder {
+		props = append(props, "admin")
+	}
+	if item.Extended {
+		props = append(props, "vis=key.shift()")
+	}
+	switch {
+	case item.SeparatorBefore && item.SeparatorAfter:
+		props = append(props, "sep='both'")
+	case item.SeparatorBefore:
+		props = append(props, "sep='before'")
+	case item.SeparatorAfter:
+		props = append(props, "sep='after'")
+	}
+	if where != "" {
+		props = append(props, where)
+	}
+	fmt.Fprintf(b, "%s%s(%s)\n", indent, keyword, strings.Join(props, " "))
+	if item.Type == ContextMenuType_Folder {
+		fmt.Fprintf(b, "%s{\n", indent)
+		for _, entry := range item.Items {
+			writeNssItem(b, indent+"\t", entry.Menu, manifestDir, "")
+		}
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+// nssQuote quotes s in single quotes, or double quotes when it contains single quotes.
+func nssQuote(s string) string {
+	if strings.Contains(s, "'") {
+		return `"` + s + `"`
+	}
+	return "'" + s + "'"
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseNilesoft checks the items made of the static definitions in a Nilesoft Shell config,
+// and what is skipped: changes to existing menus, built-in commands and hidden or empty items.
+func TestParseNilesoft(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		config      string
+		want        string
+		wantSkipped int
+	}{
+		{
+			name: "items",
+			config: `// shell.nss
+				shell
+				{
+					static
+					{
+						item(title='Open in Terminal' type='back|dir' cmd='wt.exe' args='-d @sel.path.quote' image='C:\Tools\wt.exe,0')
+						item(title="Edit with Notepad" find='.txt|.MD' cmd='notepad.exe' args=@sel.path.quote admin vis=key.shift())
+						/* a built-in command */
+						item(title=title.copy_path cmd=command.copy_to_clipboard('@sel.path'))
+					}
+				}`,
+			want: `{
+				"Open in Terminal": {"type": "item", "title": "Open in Terminal", "iconPath": "C:\\Tools\\wt.exe", "iconIndex": 0, "command": ["wt.exe", "-d", "%V"], "targets": ["background", "directory"]},
+				"Edit with Notepad": {"type": "item", "title": "Edit with Notepad", "admin": true, "extended": true, "command": ["notepad.exe", "%V"], "targets": [".txt", ".md"]}
+			}`,
+			wantSkipped: 1,
+		},
+		{
+			name: "menu",
+			config: `menu(title='&Tools' type='file' image=icon.settings)
+				{
+					item(title='Hash' cmd-ps='Get-FileHash @sel.path.quote')
+					separator
+					item(cmd-line='/k dir @sel.parent')
+					item(title='Hidden' cmd='x.exe' vis=hidden)
+				}`,
+			want: `{"Tools": {"type": "folder", "title": "&Tools", "targets": ["file"], "items": {
+				"Hash": {"type": "item", "title": "Hash", "command": ["powershell.exe", "Get-FileHash", "%V"]},
+				"cmd.exe": {"type": "item", "title": "cmd.exe", "separatorBefore": true, "command": ["cmd.exe", "/k", "dir", "%W"]}
+			}}}`,
+			wantSkipped: 1,
+		},
+		{
+			name: "changes to existing menus",
+			config: `modify(find='Pin to Start' vis=hidden)
+				remove(find='Send to')
+				$color = '#fff'
+				import 'imports/theme.nss'
+				settings { priority = 1 }
+				item(title='Run' cmd='run.exe' sep='both')`,
+			want:        `{"Run": {"type": "item", "title": "Run", "separatorBefore": true, "separatorAfter": true, "command": ["run.exe"]}}`,
+			wantSkipped: 2,
+		},
+		{
+			name:        "menu left empty",
+			config:      `menu(title='Empty') { item(title='Copy' cmd=command.copy) }`,
+			wantSkipped: 2,
+		},
+		{
+			name: "same item for two types",
+			config: `item(title='Open' type='file' cmd='open.exe' args='@sel')
+				item(title='Open' type='drive' cmd='open.exe' args='@sel')`,
+			want: `{"Open": {"type": "item", "title": "Open", "command": ["open.exe", "%V"], "targets": ["file", "drive"]}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			items, skipped, err := parseNilesoft([]byte(test.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := testMenus(t, test.want); !sameMenus(t, items, want) {
+				got, _ := marshalJSON(items, "")
+				t.Errorf("imported %s, expected %s", got, test.want)
+			}
+			if skipped != test.wantSkipped {
+				t.Errorf("skipped %d definitions, expected %d", skipped, test.wantSkipped)
+			}
+		})
+	}
+}
+
+// TestNssConfig checks the definitions exported for Nilesoft Shell, and that importing them gives
+// back the items.
+func TestNssConfig(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		items    string
+		want     string
+		imported string
+	}{
+		{
+			name:     "item",
+			items:    `{"terminal": {"type": "item", "title": "Open Terminal", "command": ["wt.exe", "-d", "%V"], "extended": true, "targets": ["background", "directory"]}}`,
+			want:     `item(title='Open Terminal' cmd='wt.exe' args='-d "@sel.path"' vis=key.shift() type='back|dir')` + "\n",
+			imported: `{"Open Terminal": {"type": "item", "title": "Open Terminal", "command": ["wt.exe", "-d", "%V"], "extended": true, "targets": ["background", "directory"]}}`,
+		},
+		{
+			name:  "extensions and targets without a type",
+			items: `{"edit": {"type": "item", "title": "Edit", "command": ["%SystemRoot%\\notepad.exe", "%1"], "admin": true, "targets": ["file", ".txt", "Unknown.Class"]}}`,
+			want: "// edit: target Unknown.Class has no equivalent\n" +
+				`item(title='Edit' cmd='@sys.dir\notepad.exe' args='"@sel.path"' admin type='file')` + "\n" +
+				`item(title='Edit' cmd='@sys.dir\notepad.exe' args='"@sel.path"' admin type='file' find='.txt')` + "\n",
+			imported: `{"Edit": {"type": "item", "title": "Edit", "command": ["%SystemRoot%\\notepad.exe", "%V"], "admin": true, "targets": ["file", ".txt"]}}`,
+		},
+		{
+			name: "folder",
+			items: `{"tools": {"type": "folder", "title": "Tool's", "targets": ["drive"], "separatorBefore": true, "items": {
+				"hash": {"type": "item", "title": "Hash", "command": ["certutil.exe", "-hashfile", "%V", ""], "separatorBefore": true, "separatorAfter": true},
+				"copy": {"type": "item", "title": "Copy", "command": ["clip.exe"], "separatorAfter": true}
+			}}}`,
+			want: `menu(title="Tool's" sep='before' type='drive')` + "\n{\n" +
+				"\t" + `item(title='Hash' cmd='certutil.exe' args='-hashfile "@sel.path" ""' sep='both')` + "\n" +
+				"\t" + `item(title='Copy' cmd='clip.exe' sep='after')` + "\n" +
+				"}\n",
+			imported: `{"Tool's": {"type": "folder", "title": "Tool's", "targets": ["drive"], "separatorBefore": true, "items": {
+				"Hash": {"type": "item", "title": "Hash", "command": ["certutil.exe", "-hashfile", "%V", ""], "separatorBefore": true, "separatorAfter": true},
+				"Copy": {"type": "item", "title": "Copy", "command": ["clip.exe"], "separatorAfter": true}
+			}}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var manifest = &Manifest{Items: testMenus(t, test.items)}
+			text, err := nssConfig(manifest, `C:\menus`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, text, _ = strings.Cut(text, "\n")
+			if text != test.want {
+				t.Errorf("exported\n%s\nexpected\n%s", text, test.want)
+			}
+			items, _, err := parseNilesoft([]byte(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := testMenus(t, test.imported); !sameMenus(t, items, want) {
+				got, _ := marshalJSON(items, "")
+				t.Errorf("imported back as %s, expected %s", got, test.imported)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// classesKeyPath is where classes live in the hive, which differs for offline hive files.
+var classesKeyPath = `Software\Classes`
+
+// targetAliases are short names for the classes a menu can be attached to. Other targets are used as
+// class key names as is, except extensions such as ".txt" which go under SystemFileAssociations.
+var targetAliases = map[string]string{
+	"background": `Directory\Background`,
+	"directory":  `Directory`,
+	"desktop":    `DesktopBackground`,
+	"drive":      `Drive`,
+	"file":       `*`,
+	"folder":     `Folder`,
+}
+
+type RegistryValueType string
+
+const (
+	RegistryValueType_String       RegistryValueType = "REG_SZ"
+	RegistryValueType_ExpandString RegistryValueType = "REG_EXPAND_SZ"
+)
+
+type RegistryValue struct {
+	Name string            `json:"name"`
+	Type RegistryValueType `json:"type"`
+	Data string            `json:"data"`
+}
+
+type RegistryKey struct {
+	Path   string          `json:"path"`
+	Values []RegistryValue `json:"values,omitempty"`
+}
+
+func targetKeyPath(target string) string {
+	if alias, ok := targetAliases[strings.ToLower(target)]; ok {
+		target = alias
+	} else if strings.Contains(target, ";") {
+		target = "*"
+	} else if strings.HasPrefix(target, ".") {
+		target = `SystemFileAssociations\` + target
+	}
+	if classesKeyPath != "" {
+		target = classesKeyPath + `\` + target
+	}
+	return target + `\shell`
+}
+
+// targetForClass is the inverse of targetKeyPath, for class key names found in other tools' exports.
+func targetForClass(class string) string {
+	const associations = `SystemFileAssociations\`
+	for alias, aliasClass := range targetAliases {
+		if strings.EqualFold(class, aliasClass) {
+			return alias
+		}
+	}
+	if len(class) > len(associations) && strings.EqualFold(class[:len(associations)], associations) {
+		return class[len(associations):]
+	}
+	return class
+}
+
+// itemKeyPath maps a slash separated item ID such as "open-msvc/VS2022 MSVC 17 COM x86"
+// to the registry key holding that item under target.
+func itemKeyPath(target, id string) string {
+	return targetKeyPath(target) + `\` + strings.ReplaceAll(id, "/", `\shell\`)
+}
+
+// Targets returns the targets of the top-level item that id belongs to.
+func (m Manifest) Targets(id string) []string {
+	id, _, _ = strings.Cut(id, "/")
+	if item := m.Items.Get(id); item != nil && len(item.Targets) > 0 {
+		return item.Targets
+	}
+	return config.Targets
+}
+
+// RegistryTargets are the targets of the top-level item that id belongs to as the registry has
+// them. With bulkExtensions, the extensions among them are merged into one target such as
+// ".txt;.md", a single key under "*" limited to them by AppliesTo, so that an item for dozens of
+// extensions is written and updated once rather than for each of them.
+func (m Manifest) RegistryTargets(id string) (targets []string) {
+	var (
+		extensions []string
+		allFiles   bool
+	)
+	if !config.BulkExtensions {
+		return m.Targets(id)
+	}
+	for _, target := range m.Targets(id) {
+		if strings.HasPrefix(target, ".") {
+			extensions = append(extensions, target)
+			continue
+		}
+		allFiles = allFiles || target == "*" || strings.EqualFold(target, "file")
+		targets = append(targets, target)
+	}
+	switch {
+	case len(extensions) == 1:
+		targets = append(targets, extensions[0])
+	case len(extensions) > 1 && !allFiles:
+		// An item on all files already shows for these, and would have the same key.
+		targets = append(targets, strings.Join(extensions, ";"))
+	}
+	return
+}
+
+// planTarget renders item for one of its registry targets, limited to the extensions of a merged
+// one.
+func planTarget(target, id string, item *ContextMenu, manifestDir string) (keys []RegistryKey, err error) {
+	var conditions []string
+	if keys, err = planContextMenu(itemKeyPath(target, id), item, manifestDir); err != nil || !strings.Contains(target, ";") {
+		return
+	}
+	for _, extension := range strings.Split(target, ";") {
+		conditions = append(conditions, `System.FileExtension:="`+extension+`"`)
+	}
+	keys[0].Values = append(keys[0].Values, RegistryValue{Name: "AppliesTo", Type: RegistryValueType_String, Data: strings.Join(conditions, " OR ")})
+	return
+}
+
+// planContextMenu renders item into the registry keys that represent it, parents before children.
+func planContextMenu(keyPath string, item *ContextMenu, manifestDir string) (keys []RegistryKey, err error) {
+	var (
+		key     = RegistryKey{Path: keyPath}
+		subKeys []RegistryKey
+		command string
+	)
+	key.Values = append(key.Values, RegistryValue{Name: "MUIVerb", Type: RegistryValueType_String, Data: item.Title})
+	if icon := item.Icon(manifestDir); icon != "" {
+		key.Values = append(key.Values, RegistryValue{Name: "Icon", Type: RegistryValueType_String, Data: icon})
+	}
+	if item.Extended {
+		key.Values = append(key.Values, RegistryValue{Name: "Extended", Type: RegistryValueType_String})
+	}
+	if item.Admin {
+		key.Values = append(key.Values, RegistryValue{Name: "HasLUAShield", Type: RegistryValueType_String})
+	}
+	if item.SeparatorBefore {
+		key.Values = append(key.Values, RegistryValue{Name: "SeparatorBefore", Type: RegistryValueType_String})
+	}
+	if item.SeparatorAfter {
+		key.Values = append(key.Values, RegistryValue{Name: "SeparatorAfter", Type: RegistryValueType_String})
+	}
+	if item.Type == ContextMenuType_Folder {
+		key.Values = append(key.Values, RegistryValue{Name: "SubCommands", Type: RegistryValueType_String})
+		keys = append(keys, key, RegistryKey{Path: keyPath + `\shell`})
+		for _, entry := range item.Items {
+			if subKeys, err = planContextMenu(keyPath+`\shell\`+entry.ID, entry.Menu, manifestDir); err != nil {
+				err = errorf("failed to plan context menu ID %q: %w", entry.ID, err)
+				return
+			}
+			keys = append(keys, subKeys...)
+		}
+	} else {
+		if command, err = item.CommandString(manifestDir); err != nil {
+			return
+		}
+		keys = append(keys, key, RegistryKey{
+			Path:   keyPath + `\command`,
+			Values: []RegistryValue{{Name: "", Type: RegistryValueType_ExpandString, Data: command}},
+		})
+	}
+	return
+}
+
+// planHash identifies the keys planned for an item, so an apply can skip items that are unchanged
+// since the last one.
+func planHash(keys []RegistryKey) (hash string, err error) {
+	var data []byte
+	if data, err = marshalJSON(keys, ""); err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash = hex.EncodeToString(sum[:])
+	return
+}
+
+// unchangedSinceApply tells whether the item whose keys start at key, planned as hash, can be
+// skipped: the last apply planned it the same, unless forced, and inRegistry, which reads the
+// registry and so is asked last, finds its keys as planned. Items the state keeps without a hash
+// are always written.
+func unchangedSinceApply(state State, key, hash string, inRegistry func() bool) bool {
+	return !config.Force && hash != "" && state.Hashes[key] == hash && inRegistry()
+}
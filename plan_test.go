@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// unicodeTitles are titles in scripts a menu has to show as they are: CJK, Cyrillic, right-to-left
+// Arabic, emoji outside the Basic Multilingual Plane, and a combining accent.
+var unicodeTitles = []string{"打开终端", "Открыть здесь", "افتح هنا", "🚀 Launch", "Cafe\u0301 — ñ"}
+
+// unicodeManifest has an item for each of unicodeTitles, and a folder titled with the first whose
+// command starts a program in the manifest folder.
+func unicodeManifest() *Manifest {
+	var (
+		manifest = &Manifest{SchemaVersion: manifestSchemaVersion}
+		folder   = &ContextMenu{Type: ContextMenuType_Folder, Title: unicodeTitles[0]}
+	)
+	for i, title := range unicodeTitles {
+		folder.Items = append(folder.Items, ContextMenuEntry{ID: fmt.Sprintf("item%d", i), Menu: &ContextMenu{
+			Type:    ContextMenuType_Item,
+			Title:   title,
+			Command: []string{`${manifestFolder}\工具\编辑器.exe`, "--title", title, "%V"},
+		}})
+	}
+	manifest.Items = append(manifest.Items, ContextMenuEntry{ID: "unicode", Menu: folder})
+	return manifest
+}
+
+// TestUnicodeRoundTrip writes a manifest with non-ASCII titles to a folder with a non-ASCII name in
+// each format, reads it back, plans it, and imports the planned keys from a .reg file, checking
+// that titles and paths come out as they went in.
+func TestUnicodeRoundTrip(t *testing.T) {
+	var manifestDir = filepath.Join(t.TempDir(), "右键菜单 Ménu")
+	if err := os.Mkdir(manifestDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"manifest.json", "manifest.yaml", "manifest.toml"} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				manifestPath = filepath.Join(manifestDir, name)
+				manifest     *Manifest
+				keys         []RegistryKey
+				reg          = newRegFile()
+				imported     ContextMenus
+				err          error
+			)
+			if err = writeManifest(manifestPath, unicodeManifest()); err != nil {
+				t.Fatal(err)
+			}
+			if manifest, err = readManifest(manifestPath); err != nil {
+				t.Fatal(err)
+			}
+			folder := manifest.Items.Get("unicode")
+			if folder == nil || folder.Title != unicodeTitles[0] || len(folder.Items) != len(unicodeTitles) {
+				t.Fatalf("read back %+v", manifest.Items)
+			}
+			for i, entry := range folder.Items {
+				if entry.Menu.Title != unicodeTitles[i] {
+					t.Errorf("read back title %q, expected %q", entry.Menu.Title, unicodeTitles[i])
+				}
+			}
+			if keys, err = planContextMenu(itemKeyPath("background", "unicode"), folder, manifestDir); err != nil {
+				t.Fatal(err)
+			}
+			for i, entry := range folder.Items {
+				var (
+					path    = itemKeyPath("background", "unicode/"+entry.ID)
+					title   = registryValue(keys, path, "MUIVerb")
+					command = registryValue(keys, path+`\command`, "")
+					want    = []string{manifestDir + `\工具\编辑器.exe`, "--title", unicodeTitles[i], "%V"}
+				)
+				if title != unicodeTitles[i] {
+					t.Errorf("planned MUIVerb %q for %s, expected %q", title, path, unicodeTitles[i])
+				}
+				if got := splitCommandLine(command); strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+					t.Errorf("planned command %q for %s, expected %q", got, path, want)
+				}
+			}
+			for _, key := range keys {
+				fmt.Fprintf(reg, "\r\n[HKEY_CURRENT_USER\\%s]\r\n", key.Path)
+				for _, value := range key.Values {
+					if value.Name == "" {
+						reg.WriteString("@=")
+					} else {
+						fmt.Fprintf(reg, "%s=", regQuote(value.Name))
+					}
+					reg.WriteString(regQuote(value.Data) + "\r\n")
+				}
+			}
+			if imported, _, err = parseRegFile(decodeText(reg.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			if got := imported.Get("unicode"); got == nil || got.Title != unicodeTitles[0] || len(got.Items) != len(unicodeTitles) {
+				t.Fatalf("imported %+v from .reg", imported)
+			} else {
+				for i, entry := range got.Items {
+					var want = folder.Items[i].Menu
+					if entry.Menu.Title != want.Title {
+						t.Errorf("imported title %q from .reg, expected %q", entry.Menu.Title, want.Title)
+					}
+					if command, _ := want.CommandString(manifestDir); joinCommandLine(entry.Menu.Command) != joinCommandLine(splitCommandLine(command)) {
+						t.Errorf("imported command %q from .reg, expected %q", entry.Menu.Command, command)
+					}
+				}
+			}
+		})
+	}
+}
+
+// registryValue returns the data of the value called name in the planned key at path.
+func registryValue(keys []RegistryKey, path, name string) string {
+	for _, key := range keys {
+		if key.Path != path {
+			continue
+		}
+		for _, value := range key.Values {
+			if value.Name == name {
+				return value.Data
+			}
+		}
+	}
+	return ""
+}
+
+// TestPlanHash checks that the hash of the keys planned for an item changes with anything that is
+// written for it, and only then.
+func TestPlanHash(t *testing.T) {
+	var (
+		path = itemKeyPath("background", "terminal")
+		base = []RegistryKey{
+			{Path: path, Values: []RegistryValue{{Name: "MUIVerb", Type: RegistryValueType_String, Data: "Terminal"}}},
+			{Path: path + `\command`, Values: []RegistryValue{{Name: "", Type: RegistryValueType_String, Data: `wt.exe -d "%V"`}}},
+		}
+		baseHash string
+		err      error
+	)
+	if baseHash, err = planHash(base); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name    string
+		change  func(keys []RegistryKey) []RegistryKey
+		changed bool
+	}{
+		{"same keys", func(keys []RegistryKey) []RegistryKey { return keys }, false},
+		{"title", func(keys []RegistryKey) []RegistryKey { keys[0].Values[0].Data = "Terminal here"; return keys }, true},
+		{"value type", func(keys []RegistryKey) []RegistryKey {
+			keys[1].Values[0].Type = RegistryValueType_ExpandString
+			return keys
+		}, true},
+		{"value name", func(keys []RegistryKey) []RegistryKey { keys[0].Values[0].Name = "muiverb"; return keys }, true},
+		{"added value", func(keys []RegistryKey) []RegistryKey {
+			keys[0].Values = append(keys[0].Values, RegistryValue{Name: "Extended", Type: RegistryValueType_String})
+			return keys
+		}, true},
+		{"key order", func(keys []RegistryKey) []RegistryKey { return []RegistryKey{keys[1], keys[0]} }, true},
+		{"moved to another target", func(keys []RegistryKey) []RegistryKey {
+			keys[0].Path = itemKeyPath("directory", "terminal")
+			return keys
+		}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var keys []RegistryKey
+			for _, key := range base {
+				key.Values = append([]RegistryValue(nil), key.Values...)
+				keys = append(keys, key)
+			}
+			hash, err := planHash(test.change(keys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := hash != baseHash; changed != test.changed {
+				t.Errorf("hash changed: %v, expected %v", changed, test.changed)
+			}
+		})
+	}
+}
+
+// TestUnchangedSinceApply checks which items an apply skips, and that the registry is only read
+// for items planned the same as in the last apply.
+func TestUnchangedSinceApply(t *testing.T) {
+	const key = `HKCU\Software\Classes\Directory\Background\shell\terminal`
+	var state = State{Hashes: map[string]string{key: "1234"}}
+	for _, test := range []struct {
+		name       string
+		key        string
+		hash       string
+		force      bool
+		inRegistry bool
+		want       bool
+		wantRead   bool
+	}{
+		{name: "unchanged", key: key, hash: "1234", inRegistry: true, want: true, wantRead: true},
+		{name: "changed in the registry", key: key, hash: "1234", inRegistry: false, want: false, wantRead: true},
+		{name: "changed in the manifest", key: key, hash: "5678", inRegistry: true, want: false},
+		{name: "not applied before", key: key + "2", hash: "1234", inRegistry: true, want: false},
+		{name: "not applied before, with no hash", key: key + "2", hash: "", inRegistry: true, want: false},
+		{name: "forced", key: key, hash: "1234", force: true, inRegistry: true, want: false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				force = config.Force
+				read  bool
+			)
+			config.Force = test.force
+			defer func() {
+				config.Force = force
+			}()
+			got := unchangedSinceApply(state, test.key, test.hash, func() bool {
+				read = true
+				return test.inRegistry
+			})
+			if got != test.want || read != test.wantRead {
+				t.Errorf("skipped %v after reading the registry %v, expected %v after %v", got, read, test.want, test.wantRead)
+			}
+		})
+	}
+}
+
+// TestTargetKeyPath checks the keys targets are attached under, in a hive's Software\Classes and
+// in the offline hives --hive-file loads, which keep classes elsewhere.
+func TestTargetKeyPath(t *testing.T) {
+	defer func(saved string) {
+		classesKeyPath = saved
+	}(classesKeyPath)
+	for _, test := range []struct {
+		classes string
+		target  string
+		want    string
+	}{
+		{classes: `Software\Classes`, target: "background", want: `Software\Classes\Directory\Background\shell`},
+		{classes: `Software\Classes`, target: "File", want: `Software\Classes\*\shell`},
+		{classes: `Software\Classes`, target: ".txt", want: `Software\Classes\SystemFileAssociations\.txt\shell`},
+		{classes: `Software\Classes`, target: "txtfile", want: `Software\Classes\txtfile\shell`},
+		{classes: `Software\Classes`, target: ".txt;.md", want: `Software\Classes\*\shell`},
+		{classes: "Classes", target: "directory", want: `Classes\Directory\shell`},
+		{classes: "", target: "drive", want: `Drive\shell`},
+		{classes: "", target: ".md", want: `SystemFileAssociations\.md\shell`},
+	} {
+		classesKeyPath = test.classes
+		if got := targetKeyPath(test.target); got != test.want {
+			t.Errorf("targetKeyPath(%q) in %q = %q, expected %q", test.target, test.classes, got, test.want)
+		}
+	}
+}
+
+// TestRegistryTargets checks that bulkExtensions merges the extensions of an item into one target,
+// unless the item is on all files anyway, and that the merged key applies only to them.
+func TestRegistryTargets(t *testing.T) {
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.Targets = []string{"background"}
+	var manifest = Manifest{Items: testMenus(t, `{
+		"edit": {"type": "item", "title": "Edit", "command": ["code", "%V"], "targets": ["directory", ".txt", ".md", ".json"]},
+		"view": {"type": "item", "title": "View", "command": ["less", "%V"], "targets": [".log"]},
+		"hash": {"type": "item", "title": "Hash", "command": ["sha256sum", "%V"], "targets": ["file", ".iso", ".img"]},
+		"open": {"type": "item", "title": "Open", "command": ["open", "%V"]}
+	}`)}
+	for _, test := range []struct {
+		id   string
+		bulk bool
+		want []string
+	}{
+		{id: "edit", want: []string{"directory", ".txt", ".md", ".json"}},
+		{id: "edit", bulk: true, want: []string{"directory", ".txt;.md;.json"}},
+		{id: "view", bulk: true, want: []string{".log"}},
+		{id: "hash", bulk: true, want: []string{"file"}},
+		{id: "open", bulk: true, want: []string{"background"}},
+	} {
+		config.BulkExtensions = test.bulk
+		if got := manifest.RegistryTargets(test.id); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("targets of %s with bulkExtensions %t are %q, expected %q", test.id, test.bulk, got, test.want)
+		}
+	}
+	keys, err := planTarget(".txt;.md", "edit", manifest.Items.Get("edit"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RegistryValue{Name: "AppliesTo", Type: RegistryValueType_String, Data: `System.FileExtension:=".txt" OR System.FileExtension:=".md"`}); keys[0].Path != itemKeyPath("file", "edit") || !reflect.DeepEqual(keys[0].Values[len(keys[0].Values)-1], want) {
+		t.Errorf("planned %+v for the merged target", keys[0])
+	}
+}
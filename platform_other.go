@@ -0,0 +1,62 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Features built on the registry or other Windows APIs fail with errWindowsOnly elsewhere.
+func errWindowsOnly(feature string) error {
+	return errorf("%s is only available on Windows", feature)
+}
+
+func openUserHive(user string) error {
+	return errWindowsOnly("--user")
+}
+
+func closeUserHive() {}
+
+func loadHiveFile(path string) error {
+	return errWindowsOnly("--hive-file")
+}
+
+func unloadHiveFile() {}
+
+func runTray(args []string) error {
+	return errWindowsOnly("tray")
+}
+
+func runAsAdmin(program string, args []string) error {
+	return errWindowsOnly("run-elevated")
+}
+
+func (f *regFile) exportKey(key string) error {
+	return errWindowsOnly("reading the registry")
+}
+
+func extractIconPNG(iconFile string, index int) ([]byte, error) {
+	return nil, errWindowsOnly("extracting icons")
+}
+
+// iconFileExists tells whether iconFile is there, for picking among icon fallbacks. Icon theme
+// names, and Windows paths that can only be checked on Windows, are taken to be.
+func iconFileExists(iconFile string) bool {
+	if !strings.Contains(iconFile, "/") || strings.ContainsAny(iconFile, `\%`) ||
+		strings.HasPrefix(iconFile, "//") || len(iconFile) > 1 && iconFile[1] == ':' {
+		return true
+	}
+	_, err := os.Stat(iconFile)
+	return err == nil
+}
+
+// appPath is only used on Windows, where editors register in App Paths.
+func appPath(exe string) string {
+	return ""
+}
+
+// policyProblems is only used on Windows, where policies can hide the menus.
+func policyProblems() []Problem {
+	return nil
+}
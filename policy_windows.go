@@ -0,0 +1,78 @@
+package main
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	explorerPoliciesKey  = `Software\Microsoft\Windows\CurrentVersion\Policies\Explorer`
+	blockedExtensionsKey = `Software\Microsoft\Windows\CurrentVersion\Shell Extensions\Blocked`
+)
+
+// policyProblems finds what keeps applied items from showing: Explorer policies of the user and
+// the machine, and permissions, usually set by policy on managed devices, that deny creating the
+// keys of the configured targets. Problems stop the items from showing; warnings only concern
+// shell extensions of other software, as the items applied here are static verbs. An offline hive
+// has no policies in effect, so nothing is checked for one.
+func policyProblems() (problems []Problem) {
+	var (
+		roots = []struct {
+			name string
+			key  registry.Key
+		}{
+			{Hive_User.String(), Hive_User.Root()},
+			{"HKLM", registry.LOCAL_MACHINE},
+		}
+		checked = make(map[string]error)
+		denied  = make(map[string]bool)
+	)
+	if config.HiveFile != "" {
+		return
+	}
+	for _, root := range roots {
+		if policyEnabled(root.key, explorerPoliciesKey, "NoViewContextMenu") {
+			problems = append(problems, Problem{
+				ID:      root.name + `\` + explorerPoliciesKey + `\NoViewContextMenu`,
+				Message: tr(`Explorer shows no context menus at all, by the policy "Remove File Explorer's default context menu"`),
+			})
+		}
+		if policyEnabled(root.key, explorerPoliciesKey, "EnforceShellExtensionSecurity") {
+			problems = append(problems, Problem{
+				ID:      root.name + `\` + explorerPoliciesKey + `\EnforceShellExtensionSecurity`,
+				Message: tr(`only approved shell extensions load, by the policy "Allow only per user or approved shell extensions"; static verbs, which are what is applied here, still show`),
+				Warning: true,
+			})
+		}
+		if key, err := registry.OpenKey(root.key, blockedExtensionsKey, registry.QUERY_VALUE); err == nil {
+			names, _ := key.ReadValueNames(-1)
+			key.Close()
+			if len(names) > 0 {
+				problems = append(problems, Problem{
+					ID:      root.name + `\` + blockedExtensionsKey,
+					Message: sprintf("%d shell extension(s) are blocked, which hides their menus; static verbs, which are what is applied here, still show", len(names)),
+					Warning: true,
+				})
+			}
+		}
+	}
+	for _, target := range config.Targets {
+		if err := keyAccess(targetKeyPath(target), createAccess, checked); err != nil && !denied[err.Error()] {
+			denied[err.Error()] = true
+			problems = append(problems, Problem{
+				Message: sprintf("%v; items cannot be written there. On managed devices a policy usually sets these permissions, ask an administrator or apply to the other hive", err),
+			})
+		}
+	}
+	return
+}
+
+// policyEnabled tells whether the DWORD policy value name below keyPath is set to a non-zero value.
+func policyEnabled(root registry.Key, keyPath, name string) bool {
+	key, err := registry.OpenKey(root, keyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	value, _, err := key.GetIntegerValue(name)
+	return err == nil && value != 0
+}
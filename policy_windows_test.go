@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// TestPolicyEnabled writes policy values below a key of its own in HKEY_CURRENT_USER and removes
+// it again at the end.
+func TestPolicyEnabled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes to HKEY_CURRENT_USER")
+	}
+	const keyPath = `Software\context-menu-manager-test\Policies`
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, keyPath, registry.SET_VALUE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		registry.DeleteKey(registry.CURRENT_USER, keyPath)
+		registry.DeleteKey(registry.CURRENT_USER, `Software\context-menu-manager-test`)
+	})
+	for _, err := range []error{
+		key.SetDWordValue("On", 1),
+		key.SetDWordValue("Off", 0),
+		key.SetQWordValue("Wide", 2),
+		key.SetStringValue("Text", "1"),
+	} {
+		if err != nil {
+			key.Close()
+			t.Fatal(err)
+		}
+	}
+	key.Close()
+	for _, test := range []struct {
+		keyPath string
+		name    string
+		want    bool
+	}{
+		{keyPath: keyPath, name: "On", want: true},
+		{keyPath: keyPath, name: "Off", want: false},
+		{keyPath: keyPath, name: "Wide", want: true},
+		{keyPath: keyPath, name: "Text", want: false},
+		{keyPath: keyPath, name: "Missing", want: false},
+		{keyPath: keyPath + `\Missing`, name: "On", want: false},
+	} {
+		if got := policyEnabled(registry.CURRENT_USER, test.keyPath, test.name); got != test.want {
+			t.Errorf("policyEnabled(%q, %q) = %v, expected %v", test.keyPath, test.name, got, test.want)
+		}
+	}
+}
+
+// TestPolicyProblemsHiveFile checks that nothing is checked for an offline hive, which has no
+// policies in effect.
+func TestPolicyProblemsHiveFile(t *testing.T) {
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.HiveFile = `C:\Windows\System32\config\DEFAULT`
+	config.Targets = []string{"directory"}
+	if problems := policyProblems(); len(problems) != 0 {
+		t.Errorf("found %v for a hive file, expected nothing", problems)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// explorerPlaceholder matches the environment variables and placeholders Explorer expands in
+// commands. Variables come first, so "%LOCALAPPDATA%" is not read as "%L".
+var explorerPlaceholder = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_]*)%|%([1LVW*])`)
+
+// posixVariables are environment variables with a different name outside Windows.
+var posixVariables = map[string]string{
+	"USERPROFILE": "HOME",
+}
+
+// posixCommand renders command as a POSIX shell command line for the scripts written for Linux
+// file managers. The placeholders for the selected item become "$f", which the script sets to its
+// path, and environment variables become shell variables.
+func posixCommand(command []string, manifestDir string) string {
+	var args = make([]string, len(command))
+	for i, arg := range command {
+		args[i] = posixArg(strings.ReplaceAll(arg, "${manifestFolder}", manifestDir))
+	}
+	return strings.Join(args, " ")
+}
+
+func posixArg(arg string) string {
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, m := range explorerPlaceholder.FindAllStringSubmatchIndex(arg, -1) {
+		if m[0] > last {
+			b.WriteString(shellQuote(arg[last:m[0]]))
+		}
+		switch {
+		case m[2] >= 0:
+			var name = strings.ToUpper(arg[m[2]:m[3]])
+			if alias, ok := posixVariables[name]; ok {
+				name = alias
+			}
+			b.WriteString(`"${` + name + `}"`)
+		case arg[m[4]:m[5]] == "W":
+			b.WriteString(`"${f%/*}"`)
+		default:
+			b.WriteString(`"$f"`)
+		}
+		last = m[1]
+	}
+	if last < len(arg) || arg == "" {
+		b.WriteString(shellQuote(arg[last:]))
+	}
+	return b.String()
+}
+
+// posixTargetGuard is a statement that skips "$f" in a loop over the selection unless it is of a
+// kind targets include, or empty when it targets both files and folders. File managers offer
+// scripts for any selection, so selections of a kind the item does not target are skipped here.
+func posixTargetGuard(targets []string) string {
+	var (
+		folders   bool
+		files     bool
+		patterns  []string
+		onFolders = "continue"
+		onFiles   = "continue"
+	)
+	for _, target := range targets {
+		switch target = strings.ToLower(target); {
+		case target == "file":
+			files = true
+		case strings.HasPrefix(target, "."):
+			patterns = append(patterns, "*"+shellQuote(target))
+		default:
+			folders = true
+		}
+	}
+	if folders {
+		onFolders = ":"
+	}
+	switch {
+	case folders && files:
+		return ""
+	case files || len(patterns) == 0:
+		if files {
+			onFiles = ":"
+		}
+		return fmt.Sprintf(`if [ -d "$f" ]; then %s; else %s; fi`, onFolders, onFiles)
+	default:
+		return fmt.Sprintf(`if [ -d "$f" ]; then %s; else case "$f" in %s) ;; *) continue ;; esac; fi`, onFolders, strings.Join(patterns, "|"))
+	}
+}
+
+// selectionScript is a one-line sh script that runs the command for each file passed to it. It
+// skips selections of a kind the item does not target, as file managers that take MIME types or
+// patterns only narrow down where it is offered.
+func selectionScript(item *ContextMenu, targets []string, manifestDir string) string {
+	var (
+		script  strings.Builder
+		command = posixCommand(item.Command, manifestDir)
+	)
+	if item.Admin {
+		command = posixElevated(command)
+	}
+	script.WriteString(`for f in "$@"; do `)
+	if guard := posixTargetGuard(targets); guard != "" {
+		script.WriteString(guard + "; ")
+	}
+	script.WriteString(command + " & done")
+	return script.String()
+}
+
+// posixElevated runs command as root: through pkexec on Linux, and on macOS through AppleScript,
+// which asks for an administrator's password. The root shell of the latter gets "$f" as well.
+func posixElevated(command string) string {
+	if runtime.GOOS == "darwin" {
+		return `osascript -e 'on run argv' -e 'do shell script "f=" & quoted form of item 1 of argv & "; " & item 2 of argv with administrator privileges' -e 'end run' "$f" ` + shellQuote(command)
+	}
+	return "pkexec " + command
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// plainTitle drops the "&" that marks the access key of a menu title in Explorer, keeping "&&"
+// as "&".
+func plainTitle(title string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(title, "&&", "\x00"), "&", ""), "\x00", "&")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPosixArg(t *testing.T) {
+	for _, test := range []struct {
+		arg  string
+		want string
+	}{
+		{arg: "", want: "''"},
+		{arg: "--new-window", want: "'--new-window'"},
+		{arg: "%V", want: `"$f"`},
+		{arg: "--cd=%1", want: `'--cd='"$f"`},
+		{arg: "%W", want: `"${f%/*}"`},
+		{arg: `%USERPROFILE%\bin`, want: `"${HOME}"'\bin'`},
+		{arg: "%localappdata%", want: `"${LOCALAPPDATA}"`},
+		{arg: "100%", want: "'100%'"},
+		{arg: "it's", want: `'it'\''s'`},
+	} {
+		if got := posixArg(test.arg); got != test.want {
+			t.Errorf("posixArg(%q) = %s, expected %s", test.arg, got, test.want)
+		}
+	}
+}
+
+func TestPosixTargetGuard(t *testing.T) {
+	for _, test := range []struct {
+		targets []string
+		want    string
+	}{
+		{targets: []string{"file", "directory"}, want: ""},
+		{targets: []string{"File"}, want: `if [ -d "$f" ]; then continue; else :; fi`},
+		{targets: []string{"directory", "background"}, want: `if [ -d "$f" ]; then :; else continue; fi`},
+		{targets: []string{".TXT", ".md"}, want: `if [ -d "$f" ]; then continue; else case "$f" in *'.txt'|*'.md') ;; *) continue ;; esac; fi`},
+		{targets: []string{"directory", ".txt"}, want: `if [ -d "$f" ]; then :; else case "$f" in *'.txt') ;; *) continue ;; esac; fi`},
+	} {
+		if got := posixTargetGuard(test.targets); got != test.want {
+			t.Errorf("posixTargetGuard(%q) = %s, expected %s", test.targets, got, test.want)
+		}
+	}
+}
+
+func TestPlainTitle(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		want  string
+	}{
+		{title: "&Open", want: "Open"},
+		{title: "Copy && Paste", want: "Copy & Paste"},
+		{title: "&&&Tools", want: "&Tools"},
+		{title: "Plain", want: "Plain"},
+	} {
+		if got := plainTitle(test.title); got != test.want {
+			t.Errorf("plainTitle(%q) = %q, expected %q", test.title, got, test.want)
+		}
+	}
+}
+
+// TestSelectionScript runs the scripts written for Linux file managers with sh, checking that the
+// paths selected reach the command as they are and that selections of other kinds are skipped.
+func TestSelectionScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip(err)
+	}
+	var (
+		dir  = t.TempDir()
+		file = filepath.Join(dir, `it's "$(echo x)" 100%.txt`)
+		item = &ContextMenu{Command: []string{"printf", "%s|%s\n", "${manifestFolder}", "%V"}}
+	)
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		targets []string
+		path    string
+		want    string
+	}{
+		{targets: []string{".txt"}, path: file, want: "/menus|" + file + "\n"},
+		{targets: []string{"directory"}, path: dir, want: "/menus|" + dir + "\n"},
+		{targets: []string{"directory"}, path: file, want: ""},
+		{targets: []string{".md"}, path: file, want: ""},
+	} {
+		script := selectionScript(item, test.targets, "/menus")
+		out, err := exec.Command("sh", "-c", script, "sh", test.path).Output()
+		if err != nil {
+			t.Fatalf("%s: %v", script, err)
+		}
+		if string(out) != test.want {
+			t.Errorf("%s on %q printed %q, expected %q", script, test.path, out, test.want)
+		}
+	}
+}
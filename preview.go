@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// runPreview prints the menus as a tree, the way Explorer would show them for each target, from
+// the manifest alone. It needs no registry, so changes can be reviewed on any platform.
+func runPreview(args []string) (err error) {
+	var (
+		flags        = newFlagSet("preview")
+		manifestPath = flags.String("manifest", "", "manifest to preview (default: the manifest found by apply)")
+		platform     = flags.String("platform", "windows", `platform whose commands and icons to show, "windows", "linux" or "darwin"`)
+		manifest     *Manifest
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if !isPlatform(*platform) {
+		err = errorf("unknown platform %q, expected %q, %q or %q", *platform, platforms[0], platforms[1], platforms[2])
+		return
+	}
+	if *manifestPath == "" {
+		if *manifestPath, err = findManifest(); err != nil {
+			return
+		}
+	}
+	if *manifestPath, err = filepath.Abs(*manifestPath); err != nil {
+		return
+	}
+	if manifest, err = readManifest(*manifestPath); err != nil {
+		return
+	}
+	manifest.Items = manifest.Items.forPlatform(*platform).expandGenerated(*platform, filepath.Dir(*manifestPath))
+	if config.SplitFolders {
+		manifest.Items = manifest.Items.splitFolders()
+	}
+	if config.Accelerators {
+		manifest.Items = manifest.Items.accelerators()
+	}
+	fmt.Print(previewTree(docsSections(manifest, filepath.Dir(*manifestPath), *platform, false)))
+	return
+}
+
+// previewTree draws the items of each section with box-drawing lines, with icons, notes and the
+// resolved command under each item.
+func previewTree(sections []docsSection) string {
+	var (
+		b    strings.Builder
+		tree func(nodes []docsNode, indent string)
+	)
+	tree = func(nodes []docsNode, indent string) {
+		for i, node := range nodes {
+			var (
+				branch, inner = "├── ", "│   "
+				title         = node.Title
+			)
+			if i == len(nodes)-1 && !node.SeparatorAfter {
+				branch, inner = "└── ", "    "
+			}
+			if node.SeparatorBefore {
+				fmt.Fprintf(&b, "%s├── ────────\n", indent)
+			}
+			if len(node.Items) > 0 {
+				title += " ▸"
+			}
+			fmt.Fprintf(&b, "%s%s%s  (%s)\n", indent, branch, title, node.ID)
+			if len(node.Notes) > 0 {
+				fmt.Fprintf(&b, "%s%s  [%s]\n", indent, inner, strings.Join(node.Notes, ", "))
+			}
+			if node.Icon != "" {
+				fmt.Fprintf(&b, "%s%s  %s %s\n", indent, inner, tr("Icon:"), node.Icon)
+			}
+			if node.Command != "" {
+				fmt.Fprintf(&b, "%s%s  > %s\n", indent, inner, node.Command)
+			}
+			tree(node.Items, indent+inner)
+			if node.SeparatorAfter {
+				branch = "├── "
+				if i == len(nodes)-1 {
+					branch = "└── "
+				}
+				fmt.Fprintf(&b, "%s%s────────\n", indent, branch)
+			}
+		}
+	}
+	if len(sections) == 0 {
+		b.WriteString(tr("No items.") + "\n")
+	}
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(section.Target + "\n")
+		tree(section.Items, "")
+	}
+	return b.String()
+}
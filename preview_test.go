@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+// TestPreviewTree checks the tree preview draws: branches that end at the last item unless a
+// separator follows it, folders marked and nested, and notes, icons and commands under items.
+func TestPreviewTree(t *testing.T) {
+	var manifest = &Manifest{Items: testMenus(t, `{
+		"terminal": {"type": "item", "title": "Open Terminal", "command": {"windows": ["wt.exe", "-d", "%V"], "linux": ["gnome-terminal", "--working-directory", "%V"]}, "extended": true, "targets": ["background"]},
+		"tools": {"type": "folder", "title": "Tools", "iconPath": {"windows": "C:\\tools.ico", "linux": "${manifestFolder}/tools.png"}, "targets": ["background", "drive"], "items": {
+			"hash": {"type": "item", "title": "Hash", "command": ["sha256sum", "%V"], "separatorBefore": true},
+			"clean": {"type": "item", "title": "Clean", "command": ["clean", "%V"], "admin": true, "separatorAfter": true}
+		}}
+	}`)}
+	for _, test := range []struct {
+		platform string
+		want     string
+	}{
+		{
+			platform: "linux",
+			want: `background (Directory\Background)
+├── Open Terminal  (terminal)
+│     [only with Shift held]
+│     > gnome-terminal --working-directory %V
+└── Tools ▸  (tools)
+      Icon: /menus/tools.png
+    ├── ────────
+    ├── Hash  (hash)
+    │     > sha256sum %V
+    ├── Clean  (clean)
+    │     [runs as administrator]
+    │     > clean %V
+    └── ────────
+
+drive (Drive)
+└── Tools ▸  (tools)
+      Icon: /menus/tools.png
+    ├── ────────
+    ├── Hash  (hash)
+    │     > sha256sum %V
+    ├── Clean  (clean)
+    │     [runs as administrator]
+    │     > clean %V
+    └── ────────
+`,
+		},
+		{
+			platform: "darwin",
+			want: `background (Directory\Background)
+└── Tools ▸  (tools)
+    ├── ────────
+    ├── Hash  (hash)
+    │     > sha256sum %V
+    ├── Clean  (clean)
+    │     [runs as administrator]
+    │     > clean %V
+    └── ────────
+
+drive (Drive)
+└── Tools ▸  (tools)
+    ├── ────────
+    ├── Hash  (hash)
+    │     > sha256sum %V
+    ├── Clean  (clean)
+    │     [runs as administrator]
+    │     > clean %V
+    └── ────────
+`,
+		},
+	} {
+		t.Run(test.platform, func(t *testing.T) {
+			var items = &Manifest{Items: manifest.Items.forPlatform(test.platform)}
+			if got := previewTree(docsSections(items, "/menus", test.platform, false)); got != test.want {
+				t.Errorf("drew\n%s\nexpected\n%s", got, test.want)
+			}
+		})
+	}
+	if got := previewTree(nil); got != "No items.\n" {
+		t.Errorf("drew %q without items", got)
+	}
+}
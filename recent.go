@@ -0,0 +1,50 @@
+package main
+
+import "strings"
+
+// RecentSource is where the items of a recent folder come from. Recently run manifest commands are
+// not one yet: Explorer starts them without this tool, so nothing records when they ran.
+type RecentSource string
+
+const (
+	RecentSource_Files RecentSource = "files"
+)
+
+// recentFilePlaceholder is replaced in the command of a recent folder with the file of each item.
+const recentFilePlaceholder = "${recentFile}"
+
+const defaultRecentLimit = 10
+
+// recentFile is a recently used file, with the path its items open and the title they show.
+type recentFile struct {
+	path  string
+	title string
+}
+
+func recentItems(folder *ContextMenu) (items ContextMenus) {
+	var (
+		limit   = folder.Limit
+		command = folder.Command
+		files   []recentFile
+		err     error
+	)
+	if limit <= 0 {
+		limit = defaultRecentLimit
+	}
+	if len(command) == 0 {
+		command = append(append([]string(nil), recentOpener...), recentFilePlaceholder)
+	}
+	if files, err = recentFiles(limit); err != nil {
+		logf(LogLevel_Warn, "failed to list recent files: %v", err)
+		return
+	}
+	for _, file := range files {
+		var item = &ContextMenu{Type: ContextMenuType_Item, Title: strings.ReplaceAll(file.title, "&", "&&")}
+		for _, arg := range command {
+			item.Command = append(item.Command, strings.ReplaceAll(arg, recentFilePlaceholder, file.path))
+		}
+		id := strings.NewReplacer("/", "-", `\`, "-").Replace(file.title)
+		items = append(items, ContextMenuEntry{ID: uniqueID(items, id), Menu: item})
+	}
+	return
+}
@@ -0,0 +1,9 @@
+package main
+
+var recentOpener = []string{"open"}
+
+// recentFiles lists nothing on macOS, whose recent documents are kept per application in a
+// format of its own, so recent folders stay out of the menu there.
+func recentFiles(limit int) (files []recentFile, err error) {
+	return
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var recentOpener = []string{"xdg-open"}
+
+// recentFiles lists the local files in the recently-used.xbel of the desktop, newest first, leaving
+// out those that are gone.
+func recentFiles(limit int) (files []recentFile, err error) {
+	var (
+		dir  string
+		data []byte
+		xbel struct {
+			Bookmarks []struct {
+				Href     string `xml:"href,attr"`
+				Modified string `xml:"modified,attr"`
+			} `xml:"bookmark"`
+		}
+	)
+	if dir, err = dataHome(); err != nil {
+		return
+	}
+	if data, err = os.ReadFile(filepath.Join(dir, "recently-used.xbel")); errors.Is(err, fs.ErrNotExist) {
+		err = nil
+		return
+	} else if err != nil {
+		err = errorf("failed to read the recently used files: %w", err)
+		return
+	}
+	if err = xml.Unmarshal(data, &xbel); err != nil {
+		err = errorf("failed to parse the recently used files: %w", err)
+		return
+	}
+	sort.SliceStable(xbel.Bookmarks, func(i, j int) bool {
+		var a, _ = time.Parse(time.RFC3339Nano, xbel.Bookmarks[i].Modified)
+		var b, _ = time.Parse(time.RFC3339Nano, xbel.Bookmarks[j].Modified)
+		return a.After(b)
+	})
+	for _, bookmark := range xbel.Bookmarks {
+		var u, parseErr = url.Parse(bookmark.Href)
+		if len(files) == limit {
+			break
+		}
+		if parseErr != nil || u.Scheme != "file" {
+			continue
+		}
+		if _, statErr := os.Stat(u.Path); statErr != nil {
+			continue
+		}
+		files = append(files, recentFile{path: u.Path, title: path.Base(u.Path)})
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRecentItems checks the items of a recent folder on Linux: local files of recently-used.xbel
+// that still exist, newest first, with "&" in titles kept as it is and the same name told apart.
+func TestRecentItems(t *testing.T) {
+	isolateState(t)
+	var (
+		dir  = t.TempDir()
+		data = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	)
+	for _, name := range []string{"a b.txt", "R&D.odt", "sub/R&D.odt"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	xbel := `<?xml version="1.0" encoding="UTF-8"?>
+<xbel version="1.0">
+  <bookmark href="file://` + dir + `/a%20b.txt" modified="2026-01-01T10:00:00.000000Z"/>
+  <bookmark href="file://` + dir + `/gone.txt" modified="2026-01-05T10:00:00Z"/>
+  <bookmark href="https://example.com/" modified="2026-01-04T10:00:00Z"/>
+  <bookmark href="file://` + dir + `/R%26D.odt" modified="2026-01-03T10:00:00Z"/>
+  <bookmark href="file://` + dir + `/sub/R%26D.odt" modified="2026-01-02T10:00:00Z"/>
+</xbel>`
+	if err := os.WriteFile(filepath.Join(data, "recently-used.xbel"), []byte(xbel), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name   string
+		folder ContextMenu
+		want   string
+	}{
+		{
+			name:   "default command",
+			folder: ContextMenu{Type: ContextMenuType_Recent},
+			want: `[
+				{"id": "R&D.odt", "type": "item", "title": "R&&D.odt", "command": ["xdg-open", "` + dir + `/R&D.odt"]},
+				{"id": "R&D.odt-2", "type": "item", "title": "R&&D.odt", "command": ["xdg-open", "` + dir + `/sub/R&D.odt"]},
+				{"id": "a b.txt", "type": "item", "title": "a b.txt", "command": ["xdg-open", "` + dir + `/a b.txt"]}
+			]`,
+		},
+		{
+			name:   "limit and command",
+			folder: ContextMenu{Type: ContextMenuType_Recent, Limit: 1, Command: []string{"code", "--goto", "${recentFile}:1"}},
+			want:   `[{"id": "R&D.odt", "type": "item", "title": "R&&D.odt", "command": ["code", "--goto", "` + dir + `/R&D.odt:1"]}]`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := recentItems(&test.folder)
+			if want := testMenus(t, test.want); !sameMenus(t, got, want) {
+				data, _ := marshalJSON(got, "")
+				t.Errorf("listed %s", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// recentOpener opens a file the way double-clicking it does; the shortcuts of the Recent folder
+// open what they point to.
+var recentOpener = []string{"explorer.exe"}
+
+// recentFiles lists the shortcuts Windows keeps in the Recent folder of the user, newest first.
+func recentFiles(limit int) (files []recentFile, err error) {
+	type shortcut struct {
+		recentFile
+		modified int64
+	}
+	var (
+		dir       = filepath.Join(os.Getenv("APPDATA"), "Microsoft", "Windows", "Recent")
+		entries   []os.DirEntry
+		shortcuts []shortcut
+	)
+	if entries, err = os.ReadDir(dir); err != nil {
+		err = errorf("failed to read %s: %w", dir, err)
+		return
+	}
+	for _, entry := range entries {
+		var info, infoErr = entry.Info()
+		if infoErr != nil || entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".lnk") {
+			continue
+		}
+		shortcuts = append(shortcuts, shortcut{
+			recentFile{path: filepath.Join(dir, entry.Name()), title: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))},
+			info.ModTime().UnixNano(),
+		})
+	}
+	sort.SliceStable(shortcuts, func(i, j int) bool { return shortcuts[i].modified > shortcuts[j].modified })
+	for i := 0; i < len(shortcuts) && i < limit; i++ {
+		files = append(files, shortcuts[i].recentFile)
+	}
+	return
+}
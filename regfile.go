@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+var hiveNames = map[string]string{
+	"HKCU": "HKEY_CURRENT_USER",
+	"HKLM": "HKEY_LOCAL_MACHINE",
+	"HKU":  "HKEY_USERS",
+}
+
+// longKeyName spells out the hive of a key such as "HKCU\path" the way regedit does.
+func longKeyName(key string) string {
+	var hive, keyPath, _ = strings.Cut(key, `\`)
+	return hiveNames[hive] + `\` + keyPath
+}
+
+// regFile collects the text of a .reg file; Bytes encodes it as UTF-16 like regedit does.
+type regFile struct {
+	strings.Builder
+}
+
+func newRegFile() (f *regFile) {
+	f = new(regFile)
+	f.WriteString("Windows Registry Editor Version 5.00\r\n")
+	return
+}
+
+func (f *regFile) Bytes() []byte {
+	return utf16File(f.String())
+}
+
+// utf16File encodes s as UTF-16LE with a byte order mark.
+func utf16File(s string) []byte {
+	var (
+		units = utf16.Encode([]rune(s))
+		data  = []byte{0xff, 0xfe}
+	)
+	for _, u := range units {
+		data = append(data, byte(u), byte(u>>8))
+	}
+	return data
+}
+
+func (f *regFile) writeHex(prefix string, data []byte) {
+	var parts = make([]string, len(data))
+	for i, b := range data {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	f.WriteString(prefix + strings.Join(parts, ","))
+}
+
+// deleteKey appends an entry that removes key, given as "HKCU\path", when the file is imported.
+func (f *regFile) deleteKey(key string) {
+	fmt.Fprintf(f, "\r\n[-%s]\r\n", longKeyName(key))
+}
+
+// undoFile records keys before they are changed: importing it deletes each key and restores
+// what it held, if anything. Each key keeps the first capture, from before any apply changed it,
+// so undo.reg takes the registry back to how it was before the tool ran rather than to the
+// previous apply.
+type undoFile struct {
+	entries []undoEntry
+	seen    map[string]bool
+}
+
+// undoEntry is the capture of a key: the entry deleting it and those restoring it.
+type undoEntry struct {
+	key  string
+	text string
+}
+
+func newUndoFile() *undoFile {
+	return &undoFile{seen: make(map[string]bool)}
+}
+
+func (u *undoFile) capture(key string) (err error) {
+	var entry regFile
+	if u.seen[strings.ToLower(key)] {
+		return
+	}
+	u.seen[strings.ToLower(key)] = true
+	entry.deleteKey(key)
+	if err = entry.exportKey(key); err != nil {
+		return
+	}
+	u.entries = append(u.entries, undoEntry{key: key, text: entry.String()})
+	return
+}
+
+// undoDeletedKey matches the entries of undo.reg that delete a key, one per capture.
+var undoDeletedKey = regexp.MustCompile(`(?m)^\[-(.+)\]\r?$`)
+
+// save adds the keys captured for the first time to undo.reg in the state folder, unless there are
+// none.
+func (u *undoFile) save() (err error) {
+	var (
+		dir      string
+		path     string
+		data     []byte
+		reg      = newRegFile()
+		captured = make(map[string]bool)
+		added    int
+	)
+	if len(u.entries) == 0 {
+		return
+	}
+	if dir, err = stateDir(); err != nil {
+		return
+	}
+	path = filepath.Join(dir, "undo.reg")
+	if data, err = os.ReadFile(path); errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	} else if err != nil {
+		err = errorf("failed to read undo.reg: %w", err)
+		return
+	} else {
+		text := string(decodeText(data))
+		for _, match := range undoDeletedKey.FindAllStringSubmatch(text, -1) {
+			captured[strings.ToLower(match[1])] = true
+		}
+		reg.Reset()
+		reg.WriteString(strings.TrimRight(text, "\r\n") + "\r\n")
+	}
+	for _, entry := range u.entries {
+		if !captured[strings.ToLower(longKeyName(entry.key))] {
+			reg.WriteString(entry.text)
+			added++
+		}
+	}
+	if added == 0 {
+		return
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		err = errorf("failed to create state folder: %w", err)
+		return
+	}
+	if err = os.WriteFile(path, reg.Bytes(), 0o644); err != nil {
+		err = errorf("failed to write undo.reg: %w", err)
+		return
+	}
+	return
+}
+
+func regQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
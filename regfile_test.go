@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUndoFileSave checks how captures are merged into undo.reg: each key keeps the capture from
+// before the first apply that changed it, whatever the case of its name, and undo.reg is left as
+// it is when there is nothing new.
+func TestUndoFileSave(t *testing.T) {
+	var (
+		terminal = `HKCU\` + itemKeyPath("background", "terminal")
+		editor   = `HKCU\` + itemKeyPath("*", "editor")
+		capture  = func(key, title string) undoEntry {
+			text := "\r\n[-" + longKeyName(key) + "]\r\n"
+			if title != "" {
+				text += "\r\n[" + longKeyName(key) + "]\r\n\"MUIVerb\"=" + regQuote(title) + "\r\n"
+			}
+			return undoEntry{key: key, text: text}
+		}
+	)
+	for _, test := range []struct {
+		name     string
+		existing []undoEntry
+		entries  []undoEntry
+		want     []undoEntry
+		written  bool
+	}{
+		{
+			name:    "first apply",
+			entries: []undoEntry{capture(terminal, "Terminal"), capture(editor, "")},
+			want:    []undoEntry{capture(terminal, "Terminal"), capture(editor, "")},
+			written: true,
+		},
+		{
+			name:     "key captured before",
+			existing: []undoEntry{capture(terminal, "Terminal")},
+			entries:  []undoEntry{capture(terminal, "Terminal here"), capture(editor, "Editor")},
+			want:     []undoEntry{capture(terminal, "Terminal"), capture(editor, "Editor")},
+			written:  true,
+		},
+		{
+			name:     "key captured before in another case",
+			existing: []undoEntry{capture(strings.ToUpper(terminal), "")},
+			entries:  []undoEntry{capture(terminal, "Terminal here")},
+			want:     []undoEntry{capture(strings.ToUpper(terminal), "")},
+		},
+		{
+			name:     "nothing new",
+			existing: []undoEntry{capture(terminal, "Terminal"), capture(editor, "")},
+			entries:  []undoEntry{capture(editor, "Editor")},
+			want:     []undoEntry{capture(terminal, "Terminal"), capture(editor, "")},
+		},
+		{
+			name: "nothing captured",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				dir      string
+				path     string
+				existing []byte
+				data     []byte
+				undo     = newUndoFile()
+				err      error
+			)
+			isolateState(t)
+			if dir, err = stateDir(); err != nil {
+				t.Fatal(err)
+			}
+			path = filepath.Join(dir, "undo.reg")
+			if test.existing != nil {
+				reg := newRegFile()
+				for _, entry := range test.existing {
+					reg.WriteString(entry.text)
+				}
+				existing = reg.Bytes()
+				if err = os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(path, existing, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			undo.entries = test.entries
+			if err = undo.save(); err != nil {
+				t.Fatal(err)
+			}
+			if data, err = os.ReadFile(path); errors.Is(err, fs.ErrNotExist) && test.want == nil {
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if written := !bytes.Equal(data, existing); written != test.written {
+				t.Errorf("rewrote undo.reg: %v, expected %v", written, test.written)
+			}
+			text := string(decodeText(data))
+			if !strings.HasPrefix(text, "Windows Registry Editor Version 5.00\r\n") {
+				t.Errorf("undo.reg starts with %q", text)
+			}
+			var last int
+			for _, entry := range test.want {
+				if strings.Count(strings.ToLower(text), strings.ToLower("[-"+longKeyName(entry.key)+"]")) != 1 {
+					t.Errorf("undo.reg deletes %s other than once:\n%s", entry.key, text)
+				}
+				if i := strings.Index(text, entry.text); i < last {
+					t.Errorf("undo.reg does not have %q after the captures before it:\n%s", entry.text, text)
+				} else {
+					last = i
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+var hiveRoots = map[string]registry.Key{
+	"HKCU": registry.CURRENT_USER,
+	"HKLM": registry.LOCAL_MACHINE,
+	"HKU":  registry.USERS,
+}
+
+// exportKey appends key, given as "HKCU\path", and all of its subkeys. Missing keys are skipped.
+func (f *regFile) exportKey(key string) (err error) {
+	var (
+		hive, keyPath, _ = strings.Cut(key, `\`)
+		root, ok         = hiveRoots[hive]
+		k                registry.Key
+		names            []string
+	)
+	if !ok {
+		err = errorf("unknown registry hive %q", hive)
+		return
+	}
+	if k, err = registry.OpenKey(root, keyPath, registry.READ); err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			err = nil
+			return
+		}
+		err = errorf("failed to open registry key %q: %w", key, err)
+		return
+	}
+	defer k.Close()
+	fmt.Fprintf(f, "\r\n[%s]\r\n", longKeyName(key))
+	if names, err = k.ReadValueNames(-1); err != nil {
+		err = errorf("failed to read values of registry key %q: %w", key, err)
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err = f.exportValue(k, name); err != nil {
+			err = errorf("failed to read value %q of registry key %q: %w", name, key, err)
+			return
+		}
+	}
+	if names, err = k.ReadSubKeyNames(-1); err != nil {
+		err = errorf("failed to read subkeys of registry key %q: %w", key, err)
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err = f.exportKey(key + `\` + name); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (f *regFile) exportValue(k registry.Key, name string) (err error) {
+	var (
+		size      int
+		valueType uint32
+		buf       []byte
+	)
+	if size, valueType, err = k.GetValue(name, nil); err != nil {
+		return
+	}
+	buf = make([]byte, size)
+	if size, valueType, err = k.GetValue(name, buf); err != nil {
+		return
+	}
+	buf = buf[:size]
+	if name == "" {
+		f.WriteString("@=")
+	} else {
+		fmt.Fprintf(f, "%s=", regQuote(name))
+	}
+	switch valueType {
+	case registry.SZ:
+		s, _, _ := k.GetStringValue(name)
+		f.WriteString(regQuote(s))
+	case registry.DWORD:
+		d, _, _ := k.GetIntegerValue(name)
+		fmt.Fprintf(f, "dword:%08x", d)
+	case registry.BINARY:
+		f.writeHex("hex:", buf)
+	default:
+		f.writeHex(fmt.Sprintf("hex(%x):", valueType), buf)
+	}
+	f.WriteString("\r\n")
+	return
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var (
+	secretFlagPattern   = regexp.MustCompile(`(?i)^[-/]+(pw|pwd|pass|password|token|secret|api-?key)$`)
+	secretAssignPattern = regexp.MustCompile(`(?i)((?:password|passwd|pwd|token|secret|api_?key)[=:])\S+`)
+	secretInlinePattern = regexp.MustCompile(`(?i)((?:--?|/)(?:password|passwd|pwd|token|secret|api-?key)\s+)(\\?"[^"]*"|\S+)`)
+)
+
+func runReport(args []string) (err error) {
+	var (
+		flags  = newFlagSet("report")
+		output = flags.String("output", "context-menu-manager-report-"+time.Now().Format("20060102-150405")+".zip", "path of the zip file to write")
+		f      *os.File
+		zw     *zip.Writer
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if f, err = os.Create(*output); err != nil {
+		err = errorf("failed to create %s: %w", *output, err)
+		return
+	}
+	defer f.Close()
+	zw = zip.NewWriter(f)
+	if err = writeReport(zw); err != nil {
+		return
+	}
+	if err = zw.Close(); err != nil {
+		err = errorf("failed to write %s: %w", *output, err)
+		return
+	}
+	fmt.Printf(tr("Wrote %s. Please check it for anything private before attaching it to an issue.\n"), *output)
+	return
+}
+
+// writeReport adds what is needed to reproduce a problem. Parts that cannot be collected are
+// described in place of their content, so a broken setup still produces a report.
+func writeReport(zw *zip.Writer) (err error) {
+	var (
+		manifest    *Manifest
+		manifestDir string
+		manifestErr error
+		add         = func(name string, data []byte) {
+			if err != nil {
+				return
+			}
+			var w, createErr = zw.Create(name)
+			if err = createErr; err == nil {
+				_, err = w.Write(data)
+			}
+		}
+	)
+	add("system.txt", []byte(systemInfo()))
+	if data, configErr := marshalJSON(config, "    "); configErr == nil {
+		add("config.json", data)
+	}
+	if manifest, manifestDir, manifestErr = loadManifest(); manifestErr != nil {
+		add("manifest-error.txt", []byte(manifestErr.Error()))
+	} else if data, marshalErr := marshalJSON(redactManifest(manifest), "    "); marshalErr == nil {
+		add("manifest.json", data)
+	}
+	if dir, dirErr := stateDir(); dirErr == nil {
+		for _, name := range []string{"state.json", "apply.log"} {
+			if data, readErr := os.ReadFile(filepath.Join(dir, name)); readErr == nil {
+				add(name, []byte(redact(string(data))))
+			}
+		}
+	}
+	if reg, regErr := exportRelevantKeys(manifest); regErr != nil {
+		add("registry-error.txt", []byte(regErr.Error()))
+	} else {
+		add("registry.reg", utf16File(redact(reg.String())))
+	}
+	if manifest != nil {
+		var b strings.Builder
+		if changes, diffErr := currentBackend().Diff(manifest, manifestDir); diffErr != nil {
+			b.WriteString(diffErr.Error() + "\n")
+		} else {
+			for _, change := range changes {
+				b.WriteString(change.String() + "\n")
+			}
+		}
+		for _, problem := range validateManifest(manifest) {
+			b.WriteString("problem: " + problem.String() + "\n")
+		}
+		for _, problem := range policyProblems() {
+			b.WriteString("policy: " + problem.String() + "\n")
+		}
+		add("diff.txt", []byte(redact(b.String())))
+	}
+	return
+}
+
+func systemInfo() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "context-menu-manager %s (manifest schema %d)\n", version, manifestSchemaVersion)
+	fmt.Fprintf(&b, "%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "%s\n", osVersion())
+	fmt.Fprintf(&b, "language: %s\n", currentLanguage())
+	fmt.Fprintf(&b, "config file: %s\n", redact(configPath))
+	if manifestPath, err := findManifest(); err == nil {
+		fmt.Fprintf(&b, "manifest: %s\n", redact(manifestPath))
+	}
+	return b.String()
+}
+
+// exportRelevantKeys exports every key the manifest writes, plus the keys recorded in the state.
+func exportRelevantKeys(manifest *Manifest) (reg *regFile, err error) {
+	var (
+		state State
+		seen  = make(map[string]bool)
+		keys  []string
+	)
+	reg = newRegFile()
+	if manifest != nil {
+		for _, entry := range manifest.Items {
+			for _, target := range manifest.RegistryTargets(entry.ID) {
+				keys = append(keys, config.Hive.String()+`\`+itemKeyPath(target, entry.ID))
+			}
+		}
+	}
+	if state, err = loadState(); err != nil {
+		return
+	}
+	keys = append(keys, state.Keys...)
+	for _, key := range keys {
+		if seen[strings.ToLower(key)] {
+			continue
+		}
+		seen[strings.ToLower(key)] = true
+		if err = reg.exportKey(key); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// redactManifest returns a copy of manifest with likely secrets in commands removed.
+func redactManifest(manifest *Manifest) *Manifest {
+	var redactMenus func(menus ContextMenus) ContextMenus
+	redactMenus = func(menus ContextMenus) (redacted ContextMenus) {
+		for _, entry := range menus {
+			var menu = *entry.Menu
+			// Only the command and icon of this platform are kept.
+			menu.Variants = platformVariants{}
+			menu.IconPath = redact(menu.IconPath)
+			menu.IconFallbacks = nil
+			for _, fallback := range entry.Menu.IconFallbacks {
+				menu.IconFallbacks = append(menu.IconFallbacks, redact(fallback))
+			}
+			menu.Command = make([]string, len(entry.Menu.Command))
+			for i, arg := range entry.Menu.Command {
+				if i > 0 && secretFlagPattern.MatchString(entry.Menu.Command[i-1]) {
+					arg = "<redacted>"
+				}
+				menu.Command[i] = redact(arg)
+			}
+			menu.Items = redactMenus(menu.Items)
+			redacted = append(redacted, ContextMenuEntry{ID: entry.ID, Menu: &menu})
+		}
+		return
+	}
+	return &Manifest{SchemaVersion: manifest.SchemaVersion, Items: redactMenus(manifest.Items)}
+}
+
+// redact hides "password=..." style assignments and the user's profile folder, which contains
+// their account name.
+func redact(s string) string {
+	s = secretAssignPattern.ReplaceAllString(s, "${1}<redacted>")
+	s = secretInlinePattern.ReplaceAllString(s, "${1}<redacted>")
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		s = strings.ReplaceAll(s, home, "%USERPROFILE%")
+		s = strings.ReplaceAll(s, strings.ReplaceAll(home, `\`, `\\`), "%USERPROFILE%")
+	}
+	return s
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// osVersion is the PRETTY_NAME of os-release on Linux, or what sw_vers reports on macOS.
+func osVersion() string {
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if name, ok := cutPrefixFold(line, "PRETTY_NAME="); ok {
+				return strings.Trim(name, `"`)
+			}
+		}
+	}
+	if out, err := exec.Command("sw_vers", "-productVersion").Output(); err == nil {
+		return "macOS " + strings.TrimSpace(string(out))
+	}
+	return runtime.GOOS
+}
+
+// windowsBuild is 0 away from Windows, for the newest version.
+func windowsBuild() uint32 {
+	return 0
+}
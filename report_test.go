@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	isolateState(t)
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{s: "tool.exe --verbose %V", want: "tool.exe --verbose %V"},
+		{s: "curl https://x/?token=abc123&y=1", want: "curl https://x/?token=<redacted>"},
+		{s: "PASSWORD:hunter2 next", want: "PASSWORD:<redacted> next"},
+		{s: "backup.exe --password hunter2 %V", want: "backup.exe --password <redacted> %V"},
+		{s: `backup.exe /pwd "two words" %V`, want: "backup.exe /pwd <redacted> %V"},
+		{s: "upload -api-key k3y", want: "upload -api-key <redacted>"},
+		{s: home + "/tools/run.cmd", want: "%USERPROFILE%/tools/run.cmd"},
+		{s: strings.ReplaceAll(home, `\`, `\\`) + `\\run.cmd`, want: `%USERPROFILE%\\run.cmd`},
+	} {
+		if got := redact(test.s); got != test.want {
+			t.Errorf("redact(%q) = %q, expected %q", test.s, got, test.want)
+		}
+	}
+}
+
+// TestRedactManifest checks that the manifest in a report keeps its items but not the arguments
+// that follow secret flags, and leaves the manifest it was made from alone.
+func TestRedactManifest(t *testing.T) {
+	var manifest = &Manifest{SchemaVersion: manifestSchemaVersion, Items: testMenus(t, `{
+		"sync": {"type": "folder", "title": "Sync", "items": {
+			"push": {"type": "item", "title": "Push", "command": ["sync.exe", "--token", "s3cret", "--dir", "%V"]},
+			"login": {"type": "item", "title": "Log in", "command": ["sync.exe", "login", "password=hunter2"]}
+		}}
+	}`)}
+	redacted := redactManifest(manifest)
+	if want := testMenus(t, `{
+		"sync": {"type": "folder", "title": "Sync", "items": {
+			"push": {"type": "item", "title": "Push", "command": ["sync.exe", "--token", "<redacted>", "--dir", "%V"]},
+			"login": {"type": "item", "title": "Log in", "command": ["sync.exe", "login", "password=<redacted>"]}
+		}}
+	}`); !sameMenus(t, redacted.Items, want) {
+		got, _ := marshalJSON(redacted.Items, "")
+		t.Errorf("redacted into %s", got)
+	}
+	if redacted.SchemaVersion != manifestSchemaVersion {
+		t.Errorf("redacted manifest has schema version %d", redacted.SchemaVersion)
+	}
+	if command := manifest.Items.Get("sync").Items.Get("push").Command; command[2] != "s3cret" {
+		t.Errorf("redacting changed the manifest's command to %q", command)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+func osVersion() string {
+	var info = windows.RtlGetVersion()
+	return fmt.Sprintf("Windows %d.%d.%d", info.MajorVersion, info.MinorVersion, info.BuildNumber)
+}
+
+// windowsBuild is the build number of Windows, which icon presets are resolved for.
+func windowsBuild() uint32 {
+	return windows.RtlGetVersion().BuildNumber
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// jsonSchema is the part of JSON Schema draft-07 that describing the manifest needs.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Examples             []string               `json:"examples,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Maximum              *int                   `json:"maximum,omitempty"`
+	MinLength            int                    `json:"minLength,omitempty"`
+	MinItems             int                    `json:"minItems,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	PropertyNames        *jsonSchema            `json:"propertyNames,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	If                   *jsonSchema            `json:"if,omitempty"`
+	Then                 *jsonSchema            `json:"then,omitempty"`
+	Else                 *jsonSchema            `json:"else,omitempty"`
+	Definitions          map[string]*jsonSchema `json:"definitions,omitempty"`
+}
+
+// manifestSchema describes Manifest and ContextMenu. Keep it in step with their fields and with
+// validateManifest, since readManifest refuses anything it does not know.
+func manifestSchema() *jsonSchema {
+	var (
+		minimum                          = 1
+		zero                             = 0
+		maximum                          = manifestSchemaVersion
+		targets                          []string
+		str                              = func(description string) *jsonSchema { return &jsonSchema{Type: "string", Description: description} }
+		boolean                          = func(description string) *jsonSchema { return &jsonSchema{Type: "boolean", Description: description} }
+		itemsRef                         = &jsonSchema{Ref: "#/definitions/items"}
+		itemProperties, listedProperties map[string]*jsonSchema
+		perPlatform                      func(value *jsonSchema, description string) *jsonSchema
+		item                             func(properties map[string]*jsonSchema, required ...string) *jsonSchema
+	)
+	for alias := range targetAliases {
+		targets = append(targets, alias)
+	}
+	sort.Strings(targets)
+	targets = append(targets, ".txt", `Directory\Background`)
+	// perPlatform allows a value, or an object of values by platform.
+	perPlatform = func(value *jsonSchema, description string) *jsonSchema {
+		var properties = make(map[string]*jsonSchema)
+		for _, platform := range platforms {
+			properties[platform] = value
+		}
+		return &jsonSchema{
+			Description: description,
+			AnyOf:       []*jsonSchema{value, {Type: "object", Properties: properties, AdditionalProperties: false}},
+		}
+	}
+	itemProperties = map[string]*jsonSchema{
+		"type": {
+			Type:        "string",
+			Description: `"item" runs a command, "folder" opens a submenu of items, "recent" a submenu of the files used last, "scriptsFolder" one of the scripts in path, "terminalProfiles" one of the Windows Terminal profiles, "wslDistros" one of the WSL distributions, "copyPath" copies the path clicked on to the clipboard.`,
+			Enum:        []string{string(ContextMenuType_Item), string(ContextMenuType_Folder), string(ContextMenuType_Recent), string(ContextMenuType_ScriptsFolder), string(ContextMenuType_TerminalProfiles), string(ContextMenuType_WSLDistros), string(ContextMenuType_CopyPath)},
+		},
+		"title":       {Type: "string", Description: "Text shown in the menu; \"&\" marks the access key.", MinLength: 1, Pattern: `\S`},
+		"description": str("Notes for maintainers of the manifest; not shown in the menu."),
+		"iconPath": perPlatform(
+			&jsonSchema{AnyOf: []*jsonSchema{{Type: "string"}, {Type: "array", Items: &jsonSchema{Type: "string"}, MinItems: 1}}},
+			"Icon file, may use ${manifestFolder}, or a preset of the Windows icons like \"shell:folder\". A list is tried in order and the first icon that exists is used. May be an object by platform, like command.",
+		),
+		"iconIndex": {Type: "integer", Description: "Icon index within iconPath."},
+		"extended":  boolean("Only show the item when Shift is held."),
+		"admin":     boolean("Run the command elevated."),
+		"command": perPlatform(
+			&jsonSchema{Type: "array", Items: &jsonSchema{Type: "string"}, MinItems: 1},
+			"Program and arguments; %V is the folder or file clicked on. May use ${manifestFolder}. May be an object by platform (\"windows\", \"linux\", \"darwin\"); the item is left out on platforms without one. For a recent folder, what each item runs with ${recentFile}.",
+		),
+		"items": itemsRef,
+		"targets": {
+			Type:        "array",
+			Description: "Where a top-level item is added, instead of the configured targets.",
+			Items:       &jsonSchema{Type: "string", Examples: targets},
+		},
+		"source": {
+			Type:        "string",
+			Description: "Where the items of a recent folder come from.",
+			Enum:        []string{string(RecentSource_Files)},
+		},
+		"limit": {Type: "integer", Description: "How many items a recent folder shows, 10 by default.", Minimum: &zero},
+		"path":  str("Folder of the scripts of a scripts folder, may use ${manifestFolder}."),
+		"pathFormat": {
+			Type:        "string",
+			Description: "How a copyPath item writes the path: as it is on Windows, with forward slashes, as WSL sees it, or on its network or administrative share.",
+			Enum:        pathFormatNames(),
+		},
+		"separatorBefore": boolean("Draw a separator above the item inside a folder."),
+		"separatorAfter":  boolean("Draw a separator below the item inside a folder."),
+	}
+	listedProperties = map[string]*jsonSchema{"id": {Type: "string", Description: "Item ID.", MinLength: 1, Pattern: `^[^/\\]+$`}}
+	for name, property := range itemProperties {
+		listedProperties[name] = property
+	}
+	item = func(properties map[string]*jsonSchema, required ...string) *jsonSchema {
+		return &jsonSchema{
+			Type:                 "object",
+			Required:             append(required, "type", "title"),
+			Properties:           properties,
+			AdditionalProperties: false,
+			If: &jsonSchema{
+				Properties: map[string]*jsonSchema{"type": {Enum: []string{string(ContextMenuType_Folder)}}},
+			},
+			Then: &jsonSchema{Required: []string{"items"}},
+			Else: &jsonSchema{AnyOf: []*jsonSchema{
+				{Properties: map[string]*jsonSchema{"type": {Enum: []string{string(ContextMenuType_Recent), string(ContextMenuType_TerminalProfiles), string(ContextMenuType_WSLDistros), string(ContextMenuType_CopyPath)}}}},
+				{Properties: map[string]*jsonSchema{"type": {Enum: []string{string(ContextMenuType_ScriptsFolder)}}}, Required: []string{"path"}},
+				{Required: []string{"command"}},
+			}},
+		}
+	}
+	return &jsonSchema{
+		Schema:   "http://json-schema.org/draft-07/schema#",
+		Title:    "context-menu-manager manifest",
+		Type:     "object",
+		Required: []string{"items"},
+		Properties: map[string]*jsonSchema{
+			"$schema": str("Path or URL of this schema, for editors."),
+			"schemaVersion": {
+				Type:        "integer",
+				Description: "Manifest schema version the file was written for; newer versions are refused.",
+				Minimum:     &minimum,
+				Maximum:     &maximum,
+			},
+			"items": itemsRef,
+		},
+		AdditionalProperties: false,
+		Definitions: map[string]*jsonSchema{
+			"items": {
+				Description: "Menu items in menu order: a list from schema version 2, an object keyed by ID before.",
+				AnyOf: []*jsonSchema{
+					{
+						Type:  "array",
+						Items: &jsonSchema{Ref: "#/definitions/listedItem"},
+					},
+					{
+						Type: "object",
+						PropertyNames: &jsonSchema{
+							MinLength: 1,
+							Pattern:   `^[^/\\]+$`,
+						},
+						AdditionalProperties: &jsonSchema{Ref: "#/definitions/item"},
+					},
+				},
+			},
+			"item":       item(itemProperties),
+			"listedItem": item(listedProperties, "id"),
+		},
+	}
+}
+
+func runSchema(args []string) (err error) {
+	var (
+		flags  = newFlagSet("schema")
+		output = flags.String("output", "manifest.schema.json", `file to write, or "-" for standard output`)
+		data   []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if data, err = marshalJSON(manifestSchema(), "    "); err != nil {
+		return
+	}
+	if *output == "-" {
+		err = writeOutput("", string(data)+"\n")
+		return
+	}
+	if err = writeOutput(*output, string(data)+"\n"); err != nil {
+		return
+	}
+	fmt.Printf(tr("Wrote %s. Point \"$schema\" in the manifest at it for completion and validation in editors.\n"), *output)
+	return
+}
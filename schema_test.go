@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fieldNames lists the JSON names of the fields of the struct v points to, in order.
+func fieldNames(v interface{}) (names []string) {
+	for name := range jsonFields(v) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// TestManifestSchema checks that the schema describes every field of Manifest and ContextMenu and
+// nothing else, since editors would otherwise flag valid manifests or miss invalid ones.
+func TestManifestSchema(t *testing.T) {
+	var schema = manifestSchema()
+	for _, test := range []struct {
+		name   string
+		schema *jsonSchema
+		want   []string
+	}{
+		{name: "manifest", schema: schema, want: fieldNames(&Manifest{})},
+		{name: "item", schema: schema.Definitions["item"], want: fieldNames(&ContextMenu{})},
+		{name: "listedItem", schema: schema.Definitions["listedItem"], want: append(fieldNames(&ContextMenu{}), "id")},
+	} {
+		sort.Strings(test.want)
+		var got []string
+		for name := range test.schema.Properties {
+			got = append(got, name)
+		}
+		if sort.Strings(got); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s has properties %q, expected %q", test.name, got, test.want)
+		}
+	}
+	if got := *schema.Properties["schemaVersion"].Maximum; got != manifestSchemaVersion {
+		t.Errorf("schemaVersion is at most %d, expected %d", got, manifestSchemaVersion)
+	}
+	for _, ref := range []string{schema.Properties["items"].Ref, schema.Definitions["item"].Properties["items"].Ref} {
+		if name := strings.TrimPrefix(ref, "#/definitions/"); schema.Definitions[name] == nil {
+			t.Errorf("%q refers to no definition", ref)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// scriptInterpreters are the programs that run scripts by extension and platform. A script is
+// passed to them after these arguments, followed by %V.
+var scriptInterpreters = map[string]map[string][]string{
+	"windows": {
+		".ps1": {"powershell.exe", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File"},
+		".cmd": {"cmd.exe", "/c"},
+		".bat": {"cmd.exe", "/c"},
+		".py":  {"py.exe"},
+		".vbs": {"wscript.exe"},
+		".js":  {"wscript.exe"},
+		".exe": {},
+	},
+	"linux":  unixInterpreters,
+	"darwin": unixInterpreters,
+}
+
+var unixInterpreters = map[string][]string{
+	".sh":   {"sh"},
+	".bash": {"bash"},
+	".py":   {"python3"},
+	".ps1":  {"pwsh", "-NoProfile", "-File"},
+	".pl":   {"perl"},
+	".rb":   {"ruby"},
+}
+
+// scriptItems lists the scripts in the path of a scripts folder by name, each as an item titled
+// after its file that runs it with its interpreter. Files of other types are left out, except
+// executables outside Windows, which run by themselves.
+func scriptItems(folder *ContextMenu, platform, manifestDir string) (items ContextMenus) {
+	var (
+		dir       = strings.ReplaceAll(folder.Path, "${manifestFolder}", manifestDir)
+		base      = strings.TrimRight(folder.Path, `/\`)
+		separator = "/"
+		entries   []os.DirEntry
+		err       error
+	)
+	if folder.Path == "" {
+		return
+	}
+	if platform == "windows" {
+		// Commands for Windows use backslashes throughout, as fmt writes them.
+		base, separator = strings.ReplaceAll(base, "/", `\`), `\`
+	}
+	if entries, err = os.ReadDir(dir); err != nil {
+		logf(LogLevel_Warn, "failed to read scripts folder %s: %v", dir, err)
+		return
+	}
+	for _, entry := range entries {
+		var (
+			name            = entry.Name()
+			ext             = filepath.Ext(name)
+			interpreter, ok = scriptInterpreters[platform][strings.ToLower(ext)]
+			info, infoErr   = entry.Info()
+			script          = base + separator + name
+			title           = strings.TrimSuffix(name, ext)
+		)
+		if entry.IsDir() || strings.HasPrefix(name, ".") || infoErr != nil {
+			continue
+		}
+		if !ok && (platform == "windows" || info.Mode()&0111 == 0) {
+			continue
+		}
+		item := &ContextMenu{
+			Type:    ContextMenuType_Item,
+			Title:   strings.ReplaceAll(title, "&", "&&"),
+			Command: append(append(append([]string(nil), interpreter...), script), "%V"),
+		}
+		id := strings.NewReplacer("/", "-", `\`, "-").Replace(title)
+		items = append(items, ContextMenuEntry{ID: uniqueID(items, id), Menu: item})
+	}
+	return
+}
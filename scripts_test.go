@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestScriptItems checks the items of a scripts folder on each platform: scripts by name run with
+// their interpreter, executables by themselves outside Windows, and other files, hidden files and
+// folders left out.
+func TestScriptItems(t *testing.T) {
+	var dir = t.TempDir()
+	for name, mode := range map[string]os.FileMode{
+		"scripts/build.ps1":     0o644,
+		"scripts/Clean Up.cmd":  0o644,
+		"scripts/R&D.py":        0o644,
+		"scripts/deploy.sh":     0o644,
+		"scripts/notes.txt":     0o644,
+		"scripts/.hidden.sh":    0o755,
+		"scripts/run":           0o755,
+		"scripts/tool.EXE":      0o644,
+		"scripts/sub/nested.sh": 0o755,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		platform string
+		want     string
+	}{
+		{
+			platform: "windows",
+			want: `[
+				{"id": "Clean Up", "type": "item", "title": "Clean Up", "command": ["cmd.exe", "/c", "${manifestFolder}\\scripts\\Clean Up.cmd", "%V"]},
+				{"id": "R&D", "type": "item", "title": "R&&D", "command": ["py.exe", "${manifestFolder}\\scripts\\R&D.py", "%V"]},
+				{"id": "build", "type": "item", "title": "build", "command": ["powershell.exe", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", "${manifestFolder}\\scripts\\build.ps1", "%V"]},
+				{"id": "tool", "type": "item", "title": "tool", "command": ["${manifestFolder}\\scripts\\tool.EXE", "%V"]}
+			]`,
+		},
+		{
+			platform: "linux",
+			want: `[
+				{"id": "R&D", "type": "item", "title": "R&&D", "command": ["python3", "${manifestFolder}/scripts/R&D.py", "%V"]},
+				{"id": "build", "type": "item", "title": "build", "command": ["pwsh", "-NoProfile", "-File", "${manifestFolder}/scripts/build.ps1", "%V"]},
+				{"id": "deploy", "type": "item", "title": "deploy", "command": ["sh", "${manifestFolder}/scripts/deploy.sh", "%V"]},
+				{"id": "run", "type": "item", "title": "run", "command": ["${manifestFolder}/scripts/run", "%V"]}
+			]`,
+		},
+	} {
+		t.Run(test.platform, func(t *testing.T) {
+			if test.platform != "windows" && runtime.GOOS == "windows" {
+				t.Skip("files have no executable bit on Windows")
+			}
+			folder := &ContextMenu{Type: ContextMenuType_ScriptsFolder, Title: "Scripts", Path: "${manifestFolder}/scripts/"}
+			got := scriptItems(folder, test.platform, dir)
+			if want := testMenus(t, test.want); !sameMenus(t, got, want) {
+				data, _ := marshalJSON(got, "")
+				t.Errorf("listed %s", data)
+			}
+		})
+	}
+}
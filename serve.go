@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxRequestSize bounds request bodies, which only ever hold a few IDs.
+const maxRequestSize = 1 << 20
+
+type server struct {
+	token  string
+	origin string
+	static http.Handler
+	mu     sync.Mutex
+}
+
+type toggleRequest struct {
+	ID      string `json:"id"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+type toggleResponse struct {
+	ID      string `json:"id"`
+	Enabled bool   `json:"enabled"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func runServe(args []string) (err error) {
+	var (
+		flags  = newFlagSet("serve")
+		listen = flags.String("listen", "127.0.0.1:7230", "loopback address to listen on")
+		token  = flags.String("token", "", "access token clients must send as a bearer token (random if empty)")
+		origin = flags.String("allow-origin", "", "origin of a browser front-end allowed to call the API, e.g. http://localhost:3000")
+		ln     net.Listener
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if *token == "" {
+		if *token, err = randomToken(); err != nil {
+			return
+		}
+	}
+	if ln, err = listenLocal(*listen); err != nil {
+		return
+	}
+	fmt.Printf(tr("Listening on http://%s\nToken: %s\n"), ln.Addr(), *token)
+	err = http.Serve(ln, &server{token: *token, origin: *origin})
+	return
+}
+
+func listenLocal(listen string) (ln net.Listener, err error) {
+	var host string
+	if host, _, err = net.SplitHostPort(listen); err != nil {
+		err = errorf("invalid listen address %q: %w", listen, err)
+		return
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		err = errorf("refusing to listen on non-loopback address %q", listen)
+		return
+	}
+	ln, err = net.Listen("tcp", listen)
+	return
+}
+
+func randomToken() (token string, err error) {
+	var buf [16]byte
+	if _, err = rand.Read(buf[:]); err != nil {
+		err = errorf("failed to generate token: %w", err)
+		return
+	}
+	token = hex.EncodeToString(buf[:])
+	return
+}
+
+// ServeHTTP only answers requests for a loopback host, so that a web page cannot reach the server
+// through a name of its own that it rebinds to 127.0.0.1. Browsers may only call the API from the
+// origin given with --allow-origin.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !loopbackHost(r.Host) {
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: sprintf("host %q is not a loopback address", r.Host)})
+		return
+	}
+	if origin := r.Header.Get("Origin"); s.origin != "" && origin == s.origin {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if s.static != nil && r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") {
+		s.static.ServeHTTP(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: tr("missing or invalid token")})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch route := r.Method + " " + r.URL.Path; route {
+	case "GET /api/items":
+		s.handleItems(w, r)
+	case "GET /api/diff":
+		s.handleDiff(w, r)
+	case "POST /api/apply":
+		s.handleApply(w, r)
+	case "POST /api/toggle":
+		s.handleToggle(w, r)
+	case "GET /api/tree":
+		s.handleTree(w, r)
+	case "GET /api/validate":
+		s.handleValidate(w, r)
+	case "GET /api/icon":
+		s.handleIcon(w, r)
+	case "POST /api/reorder":
+		s.handleReorder(w, r)
+	default:
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: tr("no such endpoint: ") + route})
+	}
+}
+
+// authorized also accepts the token of icons as a query parameter, since images cannot send
+// headers. Other requests have to send it as a header, which keeps it out of logs and history.
+func (s *server) authorized(r *http.Request) bool {
+	var token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" && r.Method == http.MethodGet && r.URL.Path == "/api/icon" {
+		token = r.URL.Query().Get("token")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// loopbackHost tells whether the Host header of a request names this machine.
+func loopbackHost(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
+	}
+	return strings.EqualFold(host, "localhost")
+}
+
+func (s *server) handleItems(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		manifest *Manifest
+		items    []ItemStatus
+	)
+	if manifest, _, err = loadManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if items, err = currentBackend().List(manifest); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	var (
+		err         error
+		manifest    *Manifest
+		manifestDir string
+		changes     []Change
+	)
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if changes, err = currentBackend().Diff(manifest, manifestDir); err != nil {
+		writeError(w, err)
+		return
+	}
+	if changes == nil {
+		changes = []Change{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+func (s *server) handleApply(w http.ResponseWriter, r *http.Request) {
+	var (
+		err         error
+		manifest    *Manifest
+		manifestDir string
+	)
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	// A client that goes away stops the apply between items.
+	if err = currentBackend().Apply(r.Context(), manifest, manifestDir); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleToggle(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		manifest *Manifest
+		req      toggleRequest
+		resp     toggleResponse
+	)
+	if err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: tr("invalid request body: ") + err.Error()})
+		return
+	}
+	if manifest, _, err = loadManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if manifest.Find(req.ID) == nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: sprintf("item ID %q not found in manifest", req.ID)})
+		return
+	}
+	resp.ID = req.ID
+	if resp.Enabled, err = currentBackend().Toggle(manifest, req.ID, req.Enabled); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestServeAccess checks which requests the API lets through: those for a loopback host with the
+// token in a header, or in the query for icons, and cross-origin ones only from --allow-origin.
+func TestServeAccess(t *testing.T) {
+	var s = &server{token: "secret", origin: "http://localhost:3000"}
+	for _, test := range []struct {
+		name       string
+		method     string
+		target     string
+		host       string
+		origin     string
+		bearer     string
+		wantStatus int
+		wantCORS   bool
+	}{
+		{name: "header token", method: "GET", target: "/api/none", host: "127.0.0.1:7230", bearer: "secret", wantStatus: http.StatusNotFound},
+		{name: "localhost", method: "GET", target: "/api/none", host: "localhost:7230", bearer: "secret", wantStatus: http.StatusNotFound},
+		{name: "IPv6 loopback", method: "GET", target: "/api/none", host: "[::1]:7230", bearer: "secret", wantStatus: http.StatusNotFound},
+		{name: "no token", method: "GET", target: "/api/items", host: "127.0.0.1:7230", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: "GET", target: "/api/items", host: "127.0.0.1:7230", bearer: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "query token", method: "GET", target: "/api/items?token=secret", host: "127.0.0.1:7230", wantStatus: http.StatusUnauthorized},
+		{name: "query token of a POST to icon", method: "POST", target: "/api/icon?token=secret", host: "127.0.0.1:7230", wantStatus: http.StatusUnauthorized},
+		{name: "query token of an icon", method: "GET", target: "/api/icon?id=none&token=secret", host: "127.0.0.1:7230", wantStatus: http.StatusNotFound},
+		{name: "rebound DNS name", method: "GET", target: "/api/none", host: "attacker.example:7230", bearer: "secret", wantStatus: http.StatusForbidden},
+		{name: "LAN address", method: "GET", target: "/api/none", host: "192.168.1.2:7230", bearer: "secret", wantStatus: http.StatusForbidden},
+		{name: "preflight from the allowed origin", method: "OPTIONS", target: "/api/apply", host: "127.0.0.1:7230", origin: "http://localhost:3000", wantStatus: http.StatusNoContent, wantCORS: true},
+		{name: "preflight from another origin", method: "OPTIONS", target: "/api/apply", host: "127.0.0.1:7230", origin: "https://attacker.example", wantStatus: http.StatusUnauthorized},
+		{name: "request from the allowed origin", method: "GET", target: "/api/none", host: "127.0.0.1:7230", origin: "http://localhost:3000", bearer: "secret", wantStatus: http.StatusNotFound, wantCORS: true},
+		{name: "request from another origin", method: "GET", target: "/api/none", host: "127.0.0.1:7230", origin: "https://attacker.example", bearer: "secret", wantStatus: http.StatusNotFound},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				r = httptest.NewRequest(test.method, test.target, nil)
+				w = httptest.NewRecorder()
+			)
+			r.Host = test.host
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			if test.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+test.bearer)
+			}
+			s.ServeHTTP(w, r)
+			if w.Code != test.wantStatus {
+				t.Errorf("answered %d, expected %d: %s", w.Code, test.wantStatus, w.Body)
+			}
+			if cors := w.Header().Get("Access-Control-Allow-Origin"); (cors != "") != test.wantCORS || cors != "" && cors != test.origin {
+				t.Errorf("allowed origin %q", cors)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// shellIconPrefix starts the iconPath of a preset, e.g. "shell:folder", for the icons of Windows
+// whose files and indexes are otherwise looked up by hand.
+const shellIconPrefix = "shell:"
+
+// shellIconLocation is where Windows keeps an icon from build minBuild on.
+type shellIconLocation struct {
+	minBuild uint32
+	file     string
+	index    int
+}
+
+// shellIconPreset is an icon preset: a title for the icon picker of edit, its locations by Windows
+// version, newest first, and the freedesktop icon name Linux file managers show instead.
+type shellIconPreset struct {
+	title     string
+	locations []shellIconLocation
+	theme     string
+}
+
+// shellIconPresets are the icons of Windows that items are likely to want. Windows moved most of
+// them from shell32.dll to imageres.dll with Vista, where they are referred to by resource ID (a
+// negative index), which stays the same between versions while the indexes shift.
+var shellIconPresets = map[string]shellIconPreset{
+	"unknown":       {"Unknown file", []shellIconLocation{{0, "shell32.dll", 0}}, "unknown"},
+	"document":      {"Document", []shellIconLocation{{6000, "imageres.dll", -102}, {0, "shell32.dll", 1}}, "text-x-generic"},
+	"application":   {"Application", []shellIconLocation{{6000, "imageres.dll", -15}, {0, "shell32.dll", 2}}, "application-x-executable"},
+	"folder":        {"Folder", []shellIconLocation{{6000, "imageres.dll", -3}, {0, "shell32.dll", 3}}, "folder"},
+	"folder-open":   {"Open folder", []shellIconLocation{{0, "shell32.dll", 4}}, "folder-open"},
+	"removable":     {"Removable drive", []shellIconLocation{{0, "shell32.dll", 7}}, "drive-removable-media"},
+	"drive":         {"Hard drive", []shellIconLocation{{0, "shell32.dll", 8}}, "drive-harddisk"},
+	"network-drive": {"Network drive", []shellIconLocation{{0, "shell32.dll", 9}}, "folder-remote"},
+	"cd":            {"CD drive", []shellIconLocation{{0, "shell32.dll", 11}}, "drive-optical"},
+	"network":       {"Network", []shellIconLocation{{6000, "imageres.dll", -25}, {0, "shell32.dll", 13}}, "network-workgroup"},
+	"globe":         {"Globe", []shellIconLocation{{0, "shell32.dll", 14}}, "applications-internet"},
+	"computer":      {"Computer", []shellIconLocation{{6000, "imageres.dll", -109}, {0, "shell32.dll", 15}}, "computer"},
+	"printer":       {"Printer", []shellIconLocation{{0, "shell32.dll", 16}}, "printer"},
+	"programs":      {"Programs", []shellIconLocation{{0, "shell32.dll", 19}}, "applications-other"},
+	"recent":        {"Recent documents", []shellIconLocation{{0, "shell32.dll", 20}}, "document-open-recent"},
+	"settings":      {"Settings", []shellIconLocation{{0, "shell32.dll", 21}}, "preferences-system"},
+	"search":        {"Search", []shellIconLocation{{0, "shell32.dll", 22}}, "system-search"},
+	"help":          {"Help", []shellIconLocation{{0, "shell32.dll", 23}}, "help-browser"},
+	"run":           {"Run", []shellIconLocation{{0, "shell32.dll", 24}}, "system-run"},
+	"shutdown":      {"Shut down", []shellIconLocation{{0, "shell32.dll", 27}}, "system-shutdown"},
+	"recycle":       {"Recycle bin (empty)", []shellIconLocation{{6000, "imageres.dll", -55}, {0, "shell32.dll", 31}}, "user-trash"},
+	"recycle-full":  {"Recycle bin (full)", []shellIconLocation{{6000, "imageres.dll", -54}, {0, "shell32.dll", 32}}, "user-trash-full"},
+	"desktop":       {"Desktop", []shellIconLocation{{0, "shell32.dll", 34}}, "user-desktop"},
+	"control-panel": {"Control panel", []shellIconLocation{{6000, "imageres.dll", -27}, {0, "shell32.dll", 35}}, "preferences-system"},
+	"fonts":         {"Fonts", []shellIconLocation{{0, "shell32.dll", 38}}, "preferences-desktop-font"},
+	"favorites":     {"Favorites", []shellIconLocation{{0, "shell32.dll", 43}}, "emblem-favorite"},
+	"log-off":       {"Log off", []shellIconLocation{{0, "shell32.dll", 44}}, "system-log-out"},
+	"lock":          {"Lock", []shellIconLocation{{0, "shell32.dll", 47}}, "changes-prevent"},
+	"delete":        {"Delete", []shellIconLocation{{0, "shell32.dll", 131}}, "edit-delete"},
+	// The UAC shield came with Vista; the padlock of shell32.dll is the closest before it.
+	"shield":     {"Administrator shield", []shellIconLocation{{6000, "imageres.dll", -78}, {0, "shell32.dll", 47}}, "security-high"},
+	"terminal":   {"Command Prompt", []shellIconLocation{{0, "cmd.exe", 0}}, "utilities-terminal"},
+	"powershell": {"PowerShell", []shellIconLocation{{0, `%SystemRoot%\System32\WindowsPowerShell\v1.0\powershell.exe`, 0}}, "utilities-terminal"},
+	"explorer":   {"File Explorer", []shellIconLocation{{0, "explorer.exe", 0}}, "system-file-manager"},
+	"notepad":    {"Notepad", []shellIconLocation{{0, "notepad.exe", 0}}, "accessories-text-editor"},
+}
+
+// shellIconNames lists the preset names in order, for messages and the schema.
+func shellIconNames() (names []string) {
+	for name := range shellIconPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// lookupShellIcon finds the preset of an iconPath like "shell:folder". ok is false for other
+// icon paths, and preset is nil for an unknown preset name.
+func lookupShellIcon(iconPath string) (preset *shellIconPreset, ok bool) {
+	var name string
+	if name, ok = cutPrefixFold(iconPath, shellIconPrefix); !ok {
+		return
+	}
+	if p, known := shellIconPresets[strings.ToLower(name)]; known {
+		preset = &p
+	}
+	return
+}
+
+// location is where the icon is on the running version of Windows, or the newest one elsewhere,
+// e.g. when generating scripts for Windows on Linux.
+func (p *shellIconPreset) location() shellIconLocation {
+	var build = windowsBuild()
+	for _, location := range p.locations {
+		if build == 0 || build >= location.minBuild {
+			return location
+		}
+	}
+	return p.locations[len(p.locations)-1]
+}
+
+// IconLocation is the icon file of c and its index, with presets resolved. index is nil when the
+// file's first icon is meant.
+func (c ContextMenu) IconLocation(manifestDir string) (file string, index *int) {
+	file = c.IconFile(manifestDir)
+	if preset, ok := lookupShellIcon(file); ok {
+		if preset == nil {
+			return "", nil
+		}
+		location := preset.location()
+		return location.file, &location.index
+	}
+	return file, c.IconIndex
+}
+
+// iconExists tells whether an icon file, or a preset, is there to be shown.
+func iconExists(iconFile string) bool {
+	if preset, ok := lookupShellIcon(iconFile); ok {
+		return preset != nil
+	}
+	return iconFileExists(iconFile)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestShellIcons checks that presets are found by name in any case and resolve to their newest
+// location, and that every preset has what the icon picker and Linux file managers need.
+func TestShellIcons(t *testing.T) {
+	for _, test := range []struct {
+		iconPath string
+		want     string
+	}{
+		{iconPath: "shell:folder", want: `"imageres.dll",-3`},
+		{iconPath: "Shell:Terminal", want: `"cmd.exe",0`},
+		{iconPath: "shell:bogus", want: ""},
+		{iconPath: `C:\Tools\app.ico`, want: `"C:\Tools\app.ico"`},
+	} {
+		item := ContextMenu{IconPath: test.iconPath}
+		if got := item.Icon(`C:\menus`); got != test.want {
+			t.Errorf("icon of %q is %q, expected %q", test.iconPath, got, test.want)
+		}
+	}
+	for name, preset := range shellIconPresets {
+		if name != strings.ToLower(name) || preset.title == "" || preset.theme == "" || len(preset.locations) == 0 {
+			t.Errorf("preset %q is incomplete: %+v", name, preset)
+			continue
+		}
+		if last := preset.locations[len(preset.locations)-1]; last.minBuild != 0 {
+			t.Errorf("preset %q has no location for builds before %d", name, last.minBuild)
+		}
+		for i := 1; i < len(preset.locations); i++ {
+			if preset.locations[i].minBuild >= preset.locations[i-1].minBuild {
+				t.Errorf("locations of preset %q are not newest first", name)
+			}
+		}
+	}
+}
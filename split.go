@@ -0,0 +1,36 @@
+package main
+
+import "strconv"
+
+// maxFolderItems is how many items some versions of Windows show in a submenu of static verbs.
+// The items past it are left out without a trace.
+const maxFolderItems = 16
+
+// splitFolders moves the items past what a submenu shows into a "More…" folder at its end, which
+// is split again when it is still too long. It is used with splitFolders in the config, and leaves
+// the items of the menu itself alone, which Explorer does not cap.
+func (c ContextMenus) splitFolders() (menus ContextMenus) {
+	for _, entry := range c {
+		var item = *entry.Menu
+		if item.Type == ContextMenuType_Folder {
+			item.Items = splitFolderItems(item.Items.splitFolders())
+		}
+		menus = append(menus, ContextMenuEntry{ID: entry.ID, Menu: &item})
+	}
+	return
+}
+
+func splitFolderItems(items ContextMenus) ContextMenus {
+	var (
+		more = ContextMenu{Type: ContextMenuType_Folder, Title: tr("More…")}
+		id   = "more"
+	)
+	if len(items) <= maxFolderItems {
+		return items
+	}
+	for n := 2; items.Index(id) >= 0; n++ {
+		id = "more-" + strconv.Itoa(n)
+	}
+	more.Items = splitFolderItems(items[maxFolderItems-1:])
+	return append(items[:maxFolderItems-1:maxFolderItems-1], ContextMenuEntry{ID: id, Menu: &more})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSplitFolders checks that folders are split into "More…" folders of at most maxFolderItems
+// items, with IDs that do not clash with their items, and the menu itself is left alone.
+func TestSplitFolders(t *testing.T) {
+	var (
+		menu   ContextMenus
+		folder = &ContextMenu{Type: ContextMenuType_Folder, Title: "Tools"}
+		ids    = func(items ContextMenus) (ids []string) {
+			for _, entry := range items {
+				ids = append(ids, entry.ID)
+			}
+			return
+		}
+		numbered = func(from, to int) (ids []string) {
+			for i := from; i < to; i++ {
+				ids = append(ids, fmt.Sprintf("item%d", i))
+			}
+			return
+		}
+	)
+	for i := 0; i < 40; i++ {
+		id := fmt.Sprintf("item%d", i)
+		if i == 3 {
+			id = "more"
+		}
+		folder.Items = append(folder.Items, ContextMenuEntry{ID: id, Menu: &ContextMenu{Type: ContextMenuType_Item, Title: id, Command: []string{"a.exe"}}})
+	}
+	for i := 0; i < 20; i++ {
+		menu = append(menu, ContextMenuEntry{ID: fmt.Sprintf("top%d", i), Menu: &ContextMenu{Type: ContextMenuType_Item, Title: "Top", Command: []string{"a.exe"}}})
+	}
+	menu = append(menu, ContextMenuEntry{ID: "tools", Menu: folder})
+	split := menu.splitFolders()
+	if len(split) != 21 {
+		t.Fatalf("split the menu itself into %d items", len(split))
+	}
+	tools := split.Get("tools")
+	want := append(append(numbered(0, 3), "more"), numbered(4, 15)...)
+	if got := ids(tools.Items); !reflect.DeepEqual(got, append(want, "more-2")) {
+		t.Errorf("folder has %q", got)
+	}
+	more := tools.Items.Get("more-2")
+	if got := ids(more.Items); more.Type != ContextMenuType_Folder || !reflect.DeepEqual(got, append(numbered(15, 30), "more")) {
+		t.Errorf("first More… folder has %q", got)
+	}
+	if got := ids(more.Items.Get("more").Items); !reflect.DeepEqual(got, numbered(30, 40)) {
+		t.Errorf("second More… folder has %q", got)
+	}
+	if len(folder.Items) != 40 {
+		t.Errorf("splitting changed the folder to %d items", len(folder.Items))
+	}
+}
+
+// TestFolderItemsProblem checks that validate warns about folders with more items than submenus
+// show, unless splitFolders is set.
+func TestFolderItemsProblem(t *testing.T) {
+	defer func(saved bool) {
+		config.SplitFolders = saved
+	}(config.SplitFolders)
+	var items []string
+	for i := 0; i <= maxFolderItems; i++ {
+		items = append(items, fmt.Sprintf(`"i%d": {"type": "item", "title": "I", "command": ["a.exe"]}`, i))
+	}
+	manifest := &Manifest{Items: testMenus(t, `{"f": {"type": "folder", "title": "F", "items": {`+strings.Join(items, ",")+`}}}`)}
+	want := []Problem{{ID: "f", Message: `folder has 17 items, some Windows versions only show 16; splitFolders moves the rest into a "More…" folder`, Warning: true}}
+	if got := validateManifest(manifest); !reflect.DeepEqual(got, want) {
+		t.Errorf("found %+v, expected %+v", got, want)
+	}
+	config.SplitFolders = true
+	if got := validateManifest(manifest); len(got) > 0 {
+		t.Errorf("found %+v with splitFolders", got)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// State remembers what earlier applies wrote, so items removed from the manifest can be pruned.
+// Keys are registry keys on Windows, and Hashes the hash of the keys planned for each when it was
+// written; Paths map the files written elsewhere to their item IDs.
+type State struct {
+	Keys   []string          `json:"keys"`
+	Hashes map[string]string `json:"hashes,omitempty"`
+	Paths  map[string]string `json:"paths,omitempty"`
+}
+
+// stateDir is %LOCALAPPDATA%\context-menu-manager, or a state folder next to the executable when portable.
+func stateDir() (dir string, err error) {
+	if config.Portable {
+		if dir, err = os.Executable(); err != nil {
+			err = errorf("failed to locate the state folder: %w", err)
+			return
+		}
+		dir = filepath.Join(filepath.Dir(dir), "state")
+		return
+	}
+	if dir, err = os.UserCacheDir(); err != nil {
+		err = errorf("failed to locate the state folder: %w", err)
+		return
+	}
+	dir = filepath.Join(dir, "context-menu-manager")
+	return
+}
+
+func loadState() (state State, err error) {
+	var (
+		dir  string
+		data []byte
+	)
+	if dir, err = stateDir(); err != nil {
+		return
+	}
+	if data, err = os.ReadFile(filepath.Join(dir, "state.json")); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+			return
+		}
+		err = errorf("failed to read state: %w", err)
+		return
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		err = errorf("failed to parse state: %w", err)
+		return
+	}
+	return
+}
+
+func saveState(state State) (err error) {
+	var (
+		dir  string
+		data []byte
+	)
+	if dir, err = stateDir(); err != nil {
+		return
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		err = errorf("failed to create state folder: %w", err)
+		return
+	}
+	if data, err = marshalJSON(state, "    "); err != nil {
+		return
+	}
+	if err = os.WriteFile(filepath.Join(dir, "state.json"), data, 0o644); err != nil {
+		err = errorf("failed to write state: %w", err)
+		return
+	}
+	return
+}
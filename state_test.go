@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestStateUnicode saves keys and paths with non-ASCII item IDs and folder names, and checks that
+// they load back unchanged.
+func TestStateUnicode(t *testing.T) {
+	var (
+		home  = t.TempDir()
+		state = State{
+			Keys:   []string{`HKCU\` + itemKeyPath("*", "工具/Открыть")},
+			Hashes: map[string]string{"工具/Открыть": "0123456789abcdef"},
+			Paths:  map[string]string{filepath.Join(home, "Меню", "افتح هنا 🚀.desktop"): "工具/Открыть"},
+		}
+		loaded State
+		err    error
+	)
+	isolateState(t)
+	if err = saveState(state); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("loaded %+v, expected %+v", loaded, state)
+	}
+}
+
+// TestUndoFileUnicode saves captures of keys with non-ASCII names to undo.reg twice, and checks
+// that they read back from the UTF-16 file and that each key keeps its first capture.
+func TestUndoFileUnicode(t *testing.T) {
+	var (
+		key   = `HKCU\` + itemKeyPath("background", "菜单/Открыть")
+		first = "\r\n[-" + longKeyName(key) + "]\r\n\r\n[" + longKeyName(key) + "]\r\n\"MUIVerb\"=" + regQuote("افتح هنا 🚀") + "\r\n"
+		dir   string
+		data  []byte
+		err   error
+	)
+	isolateState(t)
+	for _, text := range []string{first, "\r\n[-" + longKeyName(key) + "]\r\n"} {
+		undo := newUndoFile()
+		undo.entries = append(undo.entries, undoEntry{key: key, text: text})
+		if err = undo.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dir, err = stateDir(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(filepath.Join(dir, "undo.reg")); err != nil {
+		t.Fatal(err)
+	}
+	if text := string(decodeText(data)); strings.Count(text, "[-"+longKeyName(key)+"]") != 1 || !strings.Contains(text, first) {
+		t.Errorf("undo.reg reads %q, expected the first capture %q once", text, first)
+	}
+}
+
+// TestStateDir checks that portable state is kept next to the executable rather than in the
+// user's cache folder.
+func TestStateDir(t *testing.T) {
+	defer func(saved bool) {
+		config.Portable = saved
+	}(config.Portable)
+	isolateState(t)
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		portable bool
+		want     string
+	}{
+		{portable: false, want: filepath.Join(cache, "context-menu-manager")},
+		{portable: true, want: filepath.Join(filepath.Dir(exe), "state")},
+	} {
+		config.Portable = test.portable
+		if dir, err := stateDir(); err != nil || dir != test.want {
+			t.Errorf("portable %t: state folder is %q (%v), expected %q", test.portable, dir, err, test.want)
+		}
+	}
+}
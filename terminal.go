@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// terminalSettingsPaths are where the settings.json of Windows Terminal is, below
+// %LOCALAPPDATA%: the Store release, its preview and the unpackaged build.
+var terminalSettingsPaths = []string{
+	`Packages\Microsoft.WindowsTerminal_8wekyb3d8bbwe\LocalState\settings.json`,
+	`Packages\Microsoft.WindowsTerminalPreview_8wekyb3d8bbwe\LocalState\settings.json`,
+	`Microsoft\Windows Terminal\settings.json`,
+}
+
+// terminalProfile is a profile in the settings of Windows Terminal.
+type terminalProfile struct {
+	GUID        string `json:"guid"`
+	Name        string `json:"name"`
+	Hidden      bool   `json:"hidden"`
+	Icon        string `json:"icon"`
+	Commandline string `json:"commandline"`
+	Source      string `json:"source"`
+}
+
+// terminalSourcePrograms are the programs of the profiles Windows Terminal generates, which have
+// no commandline in the settings, by their source.
+var terminalSourcePrograms = map[string]string{
+	"Windows.Terminal.Wsl":            "wsl.exe",
+	"Windows.Terminal.PowershellCore": "pwsh.exe",
+}
+
+// terminalProfileItems has an item per visible profile of Windows Terminal that opens it in the
+// folder clicked on, in the order of the settings. There are none without Windows Terminal.
+func terminalProfileItems() (items ContextMenus) {
+	var (
+		data     []byte
+		err      error
+		settings struct {
+			Profiles json.RawMessage `json:"profiles"`
+		}
+		profiles []terminalProfile
+	)
+	if data, err = readTerminalSettings(); err != nil || data == nil {
+		if err != nil {
+			logf(LogLevel_Warn, "failed to read the Windows Terminal settings: %v", err)
+		}
+		return
+	}
+	if err = json.Unmarshal(stripJSONComments(data), &settings); err == nil {
+		// The profiles are a list in old settings, and an object with the list and defaults since.
+		if isJSONObject(settings.Profiles) {
+			var object struct {
+				List []terminalProfile `json:"list"`
+			}
+			err = json.Unmarshal(settings.Profiles, &object)
+			profiles = object.List
+		} else if len(settings.Profiles) > 0 {
+			err = json.Unmarshal(settings.Profiles, &profiles)
+		}
+	}
+	if err != nil {
+		logf(LogLevel_Warn, "failed to parse the Windows Terminal settings: %v", err)
+		return
+	}
+	for _, profile := range profiles {
+		var profileID = profile.GUID
+		if profile.Hidden || profile.Name == "" {
+			continue
+		}
+		if profileID == "" {
+			profileID = profile.Name
+		}
+		// %V of a drive root ends in a backslash, which would escape the closing quote, so a slash
+		// follows it as in the manifest.
+		item := &ContextMenu{
+			Type:          ContextMenuType_Item,
+			Title:         sprintf("Open here with %s", strings.ReplaceAll(profile.Name, "&", "&&")),
+			IconPath:      profile.iconPath(),
+			IconFallbacks: []string{shellIconPrefix + "terminal"},
+			Command:       []string{"wt.exe", "-p", profileID, "-d", "%V/"},
+		}
+		id := strings.NewReplacer("/", "-", `\`, "-").Replace(profile.Name)
+		items = append(items, ContextMenuEntry{ID: uniqueID(items, id), Menu: item})
+	}
+	return
+}
+
+func readTerminalSettings() (data []byte, err error) {
+	var localAppData = os.Getenv("LOCALAPPDATA")
+	if localAppData == "" {
+		return
+	}
+	for _, path := range terminalSettingsPaths {
+		if data, err = os.ReadFile(filepath.Join(localAppData, path)); !os.IsNotExist(err) {
+			return
+		}
+	}
+	return nil, nil
+}
+
+// iconPath is the icon of the profile when it is a file the registry can show, or else the
+// program it runs.
+func (p terminalProfile) iconPath() string {
+	switch strings.ToLower(filepath.Ext(p.Icon)) {
+	case ".ico", ".exe", ".dll":
+		if !strings.Contains(p.Icon, "://") {
+			return p.Icon
+		}
+	}
+	if program := terminalSourcePrograms[p.Source]; program != "" {
+		return program
+	}
+	if args := splitCommandLine(p.Commandline); len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
+
+// stripJSONComments blanks out the comments and trailing commas that the settings of Windows
+// Terminal allow, so that they decode as JSON. Offsets stay the same.
+func stripJSONComments(data []byte) []byte {
+	var (
+		out      = append([]byte(nil), data...)
+		inString bool
+		comma    = -1
+	)
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString, comma = true, -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out) - i - 2
+			} else {
+				end += 2
+			}
+			for j := i; j < i+2+end && j < len(out); j++ {
+				if out[j] != '\n' {
+					out[j] = ' '
+				}
+			}
+			i += 1 + end
+		case c == ',':
+			comma = i
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma = -1
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			comma = -1
+		}
+	}
+	return out
+}
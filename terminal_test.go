@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStripJSONComments(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want string
+	}{
+		{data: `{"a": 1}`, want: `{"a": 1}`},
+		{data: "{\"a\": 1, // one\n}", want: "{\"a\": 1        \n}"},
+		{data: `{"a": [1, 2,], /* "b": 2, */}`, want: `{"a": [1, 2 ]               }`},
+		{data: `{"url": "http://x/*y*/", "c": "\"//\"",}`, want: `{"url": "http://x/*y*/", "c": "\"//\"" }`},
+		{data: `{"a": 1 /* open`, want: `{"a": 1        `},
+	} {
+		if got := string(stripJSONComments([]byte(test.data))); got != test.want {
+			t.Errorf("stripJSONComments(%q) = %q, expected %q", test.data, got, test.want)
+		}
+	}
+}
+
+// TestTerminalProfileItems checks the items of a terminalProfiles folder: one per visible profile
+// in the order of the settings, with the icon of the profile when the registry can show it.
+func TestTerminalProfileItems(t *testing.T) {
+	isolateState(t)
+	const settings = `// This file was initially generated by Windows Terminal
+{
+    "profiles": {
+        "defaults": {},
+        "list": [
+            {"guid": "{61c54bbd-c2c6-5271-96e7-009a87ff44bf}", "name": "Windows PowerShell", "commandline": "%SystemRoot%\\System32\\WindowsPowerShell\\v1.0\\powershell.exe", "hidden": false},
+            {"guid": "{0caa0dad-35be-5f56-a8ff-afceeeaa6101}", "name": "Command Prompt", "commandline": "cmd.exe", "hidden": true},
+            {"guid": "{2c4de342-38b7-51cf-b940-2309a097f518}", "name": "Ubuntu", "source": "Windows.Terminal.Wsl", "icon": "ms-appx:///ProfileIcons/ubuntu.png"},
+            {"name": "Dev & Ops", "commandline": "\"C:\\Program Files\\Git\\bin\\bash.exe\" -l", "icon": "C:\\Icons\\dev.ico"},
+        ]
+    }
+}`
+	path := filepath.Join(os.Getenv("LOCALAPPDATA"), terminalSettingsPaths[0])
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+		{"id": "Windows PowerShell", "type": "item", "title": "Open here with Windows PowerShell", "iconPath": ["%SystemRoot%\\System32\\WindowsPowerShell\\v1.0\\powershell.exe", "shell:terminal"], "command": ["wt.exe", "-p", "{61c54bbd-c2c6-5271-96e7-009a87ff44bf}", "-d", "%V/"]},
+		{"id": "Ubuntu", "type": "item", "title": "Open here with Ubuntu", "iconPath": ["wsl.exe", "shell:terminal"], "command": ["wt.exe", "-p", "{2c4de342-38b7-51cf-b940-2309a097f518}", "-d", "%V/"]},
+		{"id": "Dev & Ops", "type": "item", "title": "Open here with Dev && Ops", "iconPath": ["C:\\Icons\\dev.ico", "shell:terminal"], "command": ["wt.exe", "-p", "Dev & Ops", "-d", "%V/"]}
+	]`
+	if got := terminalProfileItems(); !sameMenus(t, got, testMenus(t, want)) {
+		data, _ := marshalJSON(got, "")
+		t.Errorf("listed %s", data)
+	}
+	// Settings from before profile defaults have the profiles as a list.
+	if err := os.WriteFile(path, []byte(`{"profiles": [{"guid": "{a}", "name": "cmd", "commandline": "cmd.exe"}, {"guid": "{b}", "name": "cmd", "commandline": "cmd.exe /k"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want = `[
+		{"id": "cmd", "type": "item", "title": "Open here with cmd", "iconPath": ["cmd.exe", "shell:terminal"], "command": ["wt.exe", "-p", "{a}", "-d", "%V/"]},
+		{"id": "cmd-2", "type": "item", "title": "Open here with cmd", "iconPath": ["cmd.exe", "shell:terminal"], "command": ["wt.exe", "-p", "{b}", "-d", "%V/"]}
+	]`
+	if got := terminalProfileItems(); !sameMenus(t, got, testMenus(t, want)) {
+		data, _ := marshalJSON(got, "")
+		t.Errorf("listed %s from a list of profiles", data)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// thunarIDPrefix starts the unique IDs of the custom actions written for items, which share
+// uca.xml with the actions the user made in Thunar.
+const thunarIDPrefix = "context-menu-manager-"
+
+// thunarAction is a custom action as Thunar saves it in uca.xml.
+type thunarAction struct {
+	XMLName     xml.Name  `xml:"action"`
+	Icon        string    `xml:"icon"`
+	Name        string    `xml:"name"`
+	Submenu     string    `xml:"submenu"`
+	UniqueID    string    `xml:"unique-id"`
+	Command     string    `xml:"command"`
+	Description string    `xml:"description"`
+	Patterns    string    `xml:"patterns"`
+	Directories *struct{} `xml:"directories"`
+	AudioFiles  *struct{} `xml:"audio-files"`
+	ImageFiles  *struct{} `xml:"image-files"`
+	OtherFiles  *struct{} `xml:"other-files"`
+	TextFiles   *struct{} `xml:"text-files"`
+	VideoFiles  *struct{} `xml:"video-files"`
+}
+
+// thunarEntry is an action read back from uca.xml, kept as it is.
+type thunarEntry struct {
+	UniqueID string `xml:"unique-id"`
+	XML      string `xml:",innerxml"`
+}
+
+func thunarFile() (path string, err error) {
+	var dir string
+	if dir, err = os.UserConfigDir(); err != nil {
+		err = errorf("failed to locate the config folder: %w", err)
+		return
+	}
+	path = filepath.Join(dir, "Thunar", "uca.xml")
+	return
+}
+
+func readThunarFile(path string) (entries []thunarEntry, err error) {
+	var (
+		data []byte
+		file struct {
+			Actions []thunarEntry `xml:"action"`
+		}
+	)
+	if data, err = os.ReadFile(path); errors.Is(err, os.ErrNotExist) {
+		err = nil
+		return
+	} else if err != nil {
+		err = errorf("failed to read %s: %w", path, err)
+		return
+	}
+	if err = xml.Unmarshal(data, &file); err != nil {
+		err = errorf("failed to read %s: %w", path, err)
+		return
+	}
+	entries = file.Actions
+	return
+}
+
+// thunarItemID is the ID of the item an action was written for, if it was.
+func thunarItemID(entry thunarEntry) (id string, ok bool) {
+	var err error
+	if id, ok = cutPrefixFold(entry.UniqueID, thunarIDPrefix); !ok {
+		return
+	}
+	if id, err = url.PathUnescape(id); err != nil {
+		ok = false
+	}
+	return
+}
+
+// planThunar merges the manifest into Thunar's custom actions, replacing the actions written for
+// it before and keeping the others. Folders become submenus, which Thunar shows since 4.18;
+// Thunar has no separators and shows the actions in the order of the file.
+func planThunar(manifest *Manifest, manifestDir string) (files []managedFile, err error) {
+	var (
+		path    string
+		entries []thunarEntry
+		b       strings.Builder
+		add     func(prefix, submenu string, menus ContextMenus, targets []string) error
+	)
+	if path, err = thunarFile(); err != nil {
+		return
+	}
+	if entries, err = readThunarFile(path); err != nil {
+		return
+	}
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<actions>\n")
+	for _, entry := range entries {
+		if id, ok := thunarItemID(entry); ok && (manifest.Find(id) != nil || config.Prune) {
+			continue
+		}
+		b.WriteString("<action>" + entry.XML + "</action>\n")
+	}
+	add = func(prefix, submenu string, menus ContextMenus, targets []string) (err error) {
+		for _, entry := range menus {
+			var (
+				item = entry.Menu
+				id   = prefix + entry.ID
+				data []byte
+			)
+			if prefix == "" {
+				targets = manifest.Targets(id)
+			}
+			if item.Type == ContextMenuType_Folder {
+				title := strings.ReplaceAll(plainTitle(item.Title), "/", "-")
+				if submenu != "" {
+					title = submenu + "/" + title
+				}
+				if err = add(id+"/", title, item.Items, targets); err != nil {
+					return
+				}
+				continue
+			}
+			if data, err = xml.MarshalIndent(thunarItemAction(id, submenu, item, targets, manifestDir), "", "\t"); err != nil {
+				return
+			}
+			b.Write(data)
+			b.WriteString("\n")
+		}
+		return
+	}
+	if err = add("", "", manifest.Items, nil); err != nil {
+		err = errorf("failed to write %s: %w", path, err)
+		return
+	}
+	b.WriteString("</actions>\n")
+	files = append(files, managedFile{Path: path, Data: b.String()})
+	return
+}
+
+func thunarItemAction(id, submenu string, item *ContextMenu, targets []string, manifestDir string) (action thunarAction) {
+	var (
+		patterns []string
+		files    bool
+	)
+	action = thunarAction{
+		Icon:        themeIcon(item, manifestDir),
+		Name:        plainTitle(item.Title),
+		Submenu:     submenu,
+		UniqueID:    thunarIDPrefix + url.PathEscape(id),
+		Command:     "sh -c " + strings.ReplaceAll(shellQuote(selectionScript(item, targets, manifestDir)), "%", "%%") + " sh %F",
+		Description: item.Description,
+		Patterns:    "*",
+	}
+	for _, target := range targets {
+		switch target = strings.ToLower(target); {
+		case target == "file":
+			files = true
+		case strings.HasPrefix(target, "."):
+			patterns = append(patterns, "*"+target)
+		default:
+			action.Directories = &struct{}{}
+		}
+	}
+	if !files && len(patterns) > 0 {
+		action.Patterns = strings.Join(patterns, ";")
+	}
+	if files || len(patterns) > 0 {
+		action.AudioFiles, action.ImageFiles, action.OtherFiles, action.TextFiles, action.VideoFiles = &struct{}{}, &struct{}{}, &struct{}{}, &struct{}{}, &struct{}{}
+	}
+	return
+}
+
+// thunarState reports an item as installed and enabled while uca.xml holds an action for it or,
+// for folders, one of its children.
+func thunarState(path, id string) (installed, enabled bool, err error) {
+	var entries []thunarEntry
+	if entries, err = readThunarFile(path); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if itemID, ok := thunarItemID(entry); ok && (itemID == id || strings.HasPrefix(itemID, id+"/")) {
+			installed, enabled = true, true
+			return
+		}
+	}
+	return
+}
+
+func thunarSetEnabled(path, id string, enabled bool) error {
+	return errorf("Thunar cannot hide single custom actions, remove item ID %q from the manifest instead", id)
+}
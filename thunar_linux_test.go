@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestThunarItemAction checks the files an action is offered for, which Thunar narrows down by
+// kind and by pattern, and that the % of commands are escaped from Thunar's field codes.
+func TestThunarItemAction(t *testing.T) {
+	var (
+		item = &ContextMenu{Title: "&Edit", Command: []string{"edit", "%V", "100%"}, Description: "Edit it"}
+		all  = &struct{}{}
+	)
+	for _, test := range []struct {
+		targets []string
+		want    thunarAction
+	}{
+		{
+			targets: []string{"directory", "background"},
+			want:    thunarAction{Patterns: "*", Directories: all},
+		},
+		{
+			targets: []string{"file", ".txt"},
+			want:    thunarAction{Patterns: "*", AudioFiles: all, ImageFiles: all, OtherFiles: all, TextFiles: all, VideoFiles: all},
+		},
+		{
+			targets: []string{".TXT", ".md", "directory"},
+			want:    thunarAction{Patterns: "*.txt;*.md", Directories: all, AudioFiles: all, ImageFiles: all, OtherFiles: all, TextFiles: all, VideoFiles: all},
+		},
+	} {
+		got := thunarItemAction("tools/edit it", "Tools", item, test.targets, "/menus")
+		if got.Name != "Edit" || got.Submenu != "Tools" || got.UniqueID != "context-menu-manager-tools%2Fedit%20it" || got.Description != "Edit it" {
+			t.Errorf("action for %q is named %q in %q with ID %q and description %q", test.targets, got.Name, got.Submenu, got.UniqueID, got.Description)
+		}
+		got.Name, got.Submenu, got.UniqueID, got.Description, got.Command = "", "", "", "", ""
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("action for %q is %+v, expected %+v", test.targets, got, test.want)
+		}
+	}
+	const want = `sh -c 'for f in "$@"; do if [ -d "$f" ]; then :; else continue; fi; '\''edit'\'' "$f" '\''100%%'\'' & done' sh %F`
+	if got := thunarItemAction("edit", "", item, []string{"directory"}, "/menus").Command; got != want {
+		t.Errorf("command is %s, expected %s", got, want)
+	}
+}
+
+// TestPlanThunar checks that the actions of the user in uca.xml are kept, those written for items
+// before replaced, and that folders become submenus.
+func TestPlanThunar(t *testing.T) {
+	isolateState(t)
+	defer func(saved Config) {
+		config = saved
+	}(config)
+	config.Prune, config.Targets = false, []string{"directory"}
+	var (
+		path     = filepath.Join(os.Getenv("HOME"), ".config", "Thunar", "uca.xml")
+		manifest = &Manifest{Items: testMenus(t, `{
+			"edit": {"type": "item", "title": "Edit", "command": ["edit", "%V"]},
+			"tools": {"type": "folder", "title": "&Tools/More", "items": {
+				"sub": {"type": "folder", "title": "Sub", "items": {"hash": {"type": "item", "title": "Hash", "command": ["sha256sum", "%V"]}}}
+			}}
+		}`)}
+		file struct {
+			Actions []thunarAction `xml:"action"`
+		}
+	)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<actions>
+<action><name>Mine</name><unique-id>1700000000-1</unique-id><command>mine %f</command></action>
+<action><name>Old edit</name><unique-id>context-menu-manager-edit</unique-id><command>old</command></action>
+<action><name>Removed</name><unique-id>context-menu-manager-gone</unique-id><command>gone</command></action>
+</actions>
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := planThunar(manifest, "/menus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != path {
+		t.Fatalf("planned %+v, expected %s", files, path)
+	}
+	if err = xml.Unmarshal([]byte(files[0].Data), &file); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		got  [][3]string
+		want = [][3]string{
+			{"1700000000-1", "Mine", ""},
+			{"context-menu-manager-gone", "Removed", ""},
+			{"context-menu-manager-edit", "Edit", ""},
+			{"context-menu-manager-tools%2Fsub%2Fhash", "Hash", "Tools-More/Sub"},
+		}
+	)
+	for _, action := range file.Actions {
+		got = append(got, [3]string{action.UniqueID, action.Name, action.Submenu})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("actions are %q, expected %q", got, want)
+	}
+	if err = os.WriteFile(path, []byte(files[0].Data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		id            string
+		wantInstalled bool
+	}{
+		{id: "edit", wantInstalled: true},
+		{id: "tools", wantInstalled: true},
+		{id: "tools/sub/hash", wantInstalled: true},
+		{id: "tool", wantInstalled: false},
+	} {
+		if installed, enabled, err := thunarState(path, test.id); err != nil || installed != test.wantInstalled || enabled != test.wantInstalled {
+			t.Errorf("thunarState(%q) = %t, %t, %v, expected %t", test.id, installed, enabled, err, test.wantInstalled)
+		}
+	}
+}
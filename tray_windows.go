@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32  = windows.NewLazySystemDLL("user32.dll")
+	shell32 = windows.NewLazySystemDLL("shell32.dll")
+
+	procRegisterClassExW    = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW     = user32.NewProc("CreateWindowExW")
+	procDefWindowProcW      = user32.NewProc("DefWindowProcW")
+	procGetMessageW         = user32.NewProc("GetMessageW")
+	procTranslateMessage    = user32.NewProc("TranslateMessage")
+	procDispatchMessageW    = user32.NewProc("DispatchMessageW")
+	procPostQuitMessage     = user32.NewProc("PostQuitMessage")
+	procDestroyWindow       = user32.NewProc("DestroyWindow")
+	procCreatePopupMenu     = user32.NewProc("CreatePopupMenu")
+	procAppendMenuW         = user32.NewProc("AppendMenuW")
+	procTrackPopupMenu      = user32.NewProc("TrackPopupMenu")
+	procDestroyMenu         = user32.NewProc("DestroyMenu")
+	procSetForegroundWindow = user32.NewProc("SetForegroundWindow")
+	procGetCursorPos        = user32.NewProc("GetCursorPos")
+	procLoadIconW           = user32.NewProc("LoadIconW")
+	procSetTimer            = user32.NewProc("SetTimer")
+	procShellNotifyIconW    = shell32.NewProc("Shell_NotifyIconW")
+	procFreeConsole         = windows.NewLazySystemDLL("kernel32.dll").NewProc("FreeConsole")
+)
+
+const (
+	wmDestroy     = 0x0002
+	wmTimer       = 0x0113
+	wmLButtonUp   = 0x0202
+	wmRButtonUp   = 0x0205
+	wmTrayIcon    = 0x8000 + 1
+	nimAdd        = 0
+	nimModify     = 1
+	nimDelete     = 2
+	nifMessage    = 0x1
+	nifIcon       = 0x2
+	nifTip        = 0x4
+	nifInfo       = 0x10
+	niifInfo      = 0x1
+	niifWarning   = 0x2
+	mfString      = 0x0
+	mfGrayed      = 0x1
+	mfChecked     = 0x8
+	mfPopup       = 0x10
+	mfSeparator   = 0x800
+	tpmRightAlign = 0x8
+	tpmReturnCmd  = 0x100
+	idiApp        = 32512
+	mbIconError   = 0x10
+
+	trayCommandReapply = 1
+	trayCommandCheck   = 2
+	trayCommandExit    = 3
+	trayCommandItem    = 100
+
+	driftTimer   = 1
+	refreshTimer = 2
+)
+
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   windows.Handle
+	Icon       windows.Handle
+	Cursor     windows.Handle
+	Background windows.Handle
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     windows.Handle
+}
+
+type point struct {
+	X, Y int32
+}
+
+type msg struct {
+	Hwnd    windows.HWND
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      point
+}
+
+type notifyIconData struct {
+	Size            uint32
+	Wnd             windows.HWND
+	ID              uint32
+	Flags           uint32
+	CallbackMessage uint32
+	Icon            windows.Handle
+	Tip             [128]uint16
+	State           uint32
+	StateMask       uint32
+	Info            [256]uint16
+	Version         uint32
+	InfoTitle       [64]uint16
+	InfoFlags       uint32
+	GUIDItem        windows.GUID
+	BalloonIcon     windows.Handle
+}
+
+type tray struct {
+	hwnd      windows.HWND
+	nid       notifyIconData
+	interval  time.Duration
+	refresh   time.Duration
+	commands  map[uintptr]string
+	drift     int
+	generated string
+}
+
+// theTray is reached from the window procedure, which cannot carry Go state.
+var theTray *tray
+
+func runTray(args []string) (err error) {
+	var (
+		flags    = newFlagSet("tray")
+		interval = flags.Duration("interval", 5*time.Minute, "how often to check the registry for drift from the manifest")
+		refresh  = flags.Duration("refresh", time.Minute, "how often to update the folders generated from recent files, scripts, terminal profiles and WSL distributions")
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	runtime.LockOSThread()
+	procFreeConsole.Call()
+	theTray = &tray{interval: *interval, refresh: *refresh}
+	if err = theTray.create(); err != nil {
+		showError(err)
+		return
+	}
+	theTray.refreshGenerated()
+	theTray.checkDrift()
+	theTray.loop()
+	return
+}
+
+func (t *tray) create() (err error) {
+	var (
+		className = windows.StringToUTF16Ptr("ContextMenuManagerTray")
+		instance  windows.Handle
+		icon      uintptr
+		hwnd      uintptr
+	)
+	if err = windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		err = errorf("failed to get module handle: %w", err)
+		return
+	}
+	wc := wndClassEx{
+		WndProc:   windows.NewCallback(trayWndProc),
+		Instance:  instance,
+		ClassName: className,
+	}
+	wc.Size = uint32(unsafe.Sizeof(wc))
+	if atom, _, callErr := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); atom == 0 {
+		err = errorf("failed to register window class: %w", callErr)
+		return
+	}
+	if hwnd, _, err = procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(className)), uintptr(unsafe.Pointer(className)), 0, 0, 0, 0, 0, 0, 0, uintptr(instance), 0); hwnd == 0 {
+		err = errorf("failed to create window: %w", err)
+		return
+	}
+	err = nil
+	t.hwnd = windows.HWND(hwnd)
+	icon, _, _ = procLoadIconW.Call(0, idiApp)
+	t.nid = notifyIconData{
+		Wnd:             t.hwnd,
+		ID:              1,
+		Flags:           nifMessage | nifIcon | nifTip,
+		CallbackMessage: wmTrayIcon,
+		Icon:            windows.Handle(icon),
+	}
+	t.nid.Size = uint32(unsafe.Sizeof(t.nid))
+	copyUTF16(t.nid.Tip[:], "Context Menu Manager")
+	if ok, _, callErr := procShellNotifyIconW.Call(nimAdd, uintptr(unsafe.Pointer(&t.nid))); ok == 0 {
+		err = errorf("failed to add tray icon: %w", callErr)
+		return
+	}
+	procSetTimer.Call(hwnd, driftTimer, uintptr(t.interval.Milliseconds()), 0)
+	procSetTimer.Call(hwnd, refreshTimer, uintptr(t.refresh.Milliseconds()), 0)
+	return
+}
+
+func (t *tray) loop() {
+	var m msg
+	for {
+		if ret, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0); int32(ret) <= 0 {
+			return
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&m)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
+
+func trayWndProc(hwnd windows.HWND, message uint32, wParam, lParam uintptr) uintptr {
+	switch message {
+	case wmTrayIcon:
+		if lParam == wmLButtonUp || lParam == wmRButtonUp {
+			theTray.showMenu()
+		}
+		return 0
+	case wmTimer:
+		if wParam == refreshTimer {
+			theTray.refreshGenerated()
+		} else {
+			theTray.checkDrift()
+		}
+		return 0
+	case wmDestroy:
+		procShellNotifyIconW.Call(nimDelete, uintptr(unsafe.Pointer(&theTray.nid)))
+		procPostQuitMessage.Call(0)
+		return 0
+	}
+	ret, _, _ := procDefWindowProcW.Call(uintptr(hwnd), uintptr(message), wParam, lParam)
+	return ret
+}
+
+func (t *tray) showMenu() {
+	var (
+		err      error
+		manifest *Manifest
+		items    []ItemStatus
+		menu     uintptr
+		pt       point
+		command  uintptr
+	)
+	if manifest, _, err = loadManifest(); err == nil {
+		items, err = currentBackend().List(manifest)
+	}
+	menu, _, _ = procCreatePopupMenu.Call()
+	defer procDestroyMenu.Call(menu)
+	t.commands = make(map[uintptr]string)
+	if err != nil {
+		appendMenu(menu, mfString|mfGrayed, 0, err.Error())
+	} else {
+		t.appendItems(menu, "", items)
+	}
+	appendMenu(menu, mfSeparator, 0, "")
+	appendMenu(menu, mfString, trayCommandReapply, tr("Re-apply manifest"))
+	appendMenu(menu, mfString, trayCommandCheck, tr("Check for drift"))
+	appendMenu(menu, mfString, trayCommandExit, tr("Exit"))
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&pt)))
+	procSetForegroundWindow.Call(uintptr(t.hwnd))
+	command, _, _ = procTrackPopupMenu.Call(menu, tpmRightAlign|tpmReturnCmd, uintptr(pt.X), uintptr(pt.Y), 0, uintptr(t.hwnd), 0)
+	switch command {
+	case 0:
+	case trayCommandReapply:
+		t.reapply()
+	case trayCommandCheck:
+		t.drift = 0
+		t.checkDrift()
+	case trayCommandExit:
+		procDestroyWindow.Call(uintptr(t.hwnd))
+	default:
+		t.toggle(t.commands[command])
+	}
+}
+
+// appendItems adds the direct children of prefix to menu. Folders become submenus whose
+// first entry toggles the folder itself.
+func (t *tray) appendItems(menu uintptr, prefix string, items []ItemStatus) {
+	for _, item := range items {
+		if !strings.HasPrefix(item.ID, prefix) || strings.Contains(strings.TrimPrefix(item.ID, prefix), "/") {
+			continue
+		}
+		var (
+			command = uintptr(trayCommandItem + len(t.commands))
+			flags   = uintptr(mfString)
+		)
+		t.commands[command] = item.ID
+		if !item.Installed {
+			flags |= mfGrayed
+		} else if item.Enabled {
+			flags |= mfChecked
+		}
+		if item.Type != ContextMenuType_Folder {
+			appendMenu(menu, flags, command, item.Title)
+			continue
+		}
+		sub, _, _ := procCreatePopupMenu.Call()
+		appendMenu(sub, flags, command, tr("Enabled"))
+		appendMenu(sub, mfSeparator, 0, "")
+		t.appendItems(sub, item.ID+"/", items)
+		appendMenu(menu, mfPopup, sub, item.Title)
+	}
+}
+
+func (t *tray) toggle(id string) {
+	var (
+		err      error
+		manifest *Manifest
+	)
+	if manifest, _, err = loadManifest(); err == nil {
+		_, err = currentBackend().Toggle(manifest, id, nil)
+	}
+	if err != nil {
+		t.notify(tr("Toggle failed"), err.Error(), niifWarning)
+	}
+}
+
+func (t *tray) reapply() {
+	var (
+		err         error
+		manifest    *Manifest
+		manifestDir string
+	)
+	if manifest, manifestDir, err = loadManifest(); err == nil {
+		err = currentBackend().Apply(context.Background(), manifest, manifestDir)
+	}
+	if err != nil {
+		t.notify(tr("Apply failed"), err.Error(), niifWarning)
+		return
+	}
+	t.drift = 0
+	t.notify(tr("Manifest applied"), tr("All context menu items were written to the registry."), niifInfo)
+}
+
+// refreshGenerated applies the manifest again when the items of its generated folders changed,
+// e.g. because other files were opened, scripts added, terminal profiles edited or distributions
+// installed since. Items that did not change are skipped by the apply.
+func (t *tray) refreshGenerated() {
+	var (
+		err         error
+		manifest    *Manifest
+		manifestDir string
+		snapshot    string
+	)
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		return
+	}
+	if snapshot = manifest.Items.generatedSnapshot(); snapshot == t.generated {
+		return
+	}
+	if err = currentBackend().Apply(context.Background(), manifest, manifestDir); err != nil {
+		t.notify(tr("Refreshing generated folders failed"), err.Error(), niifWarning)
+		return
+	}
+	t.generated = snapshot
+}
+
+// checkDrift notifies when the registry no longer matches the manifest. It stays quiet
+// while the number of differences is unchanged, so the same drift is reported only once.
+func (t *tray) checkDrift() {
+	var (
+		err         error
+		manifest    *Manifest
+		manifestDir string
+		changes     []Change
+	)
+	if manifest, manifestDir, err = loadManifest(); err == nil {
+		changes, err = currentBackend().Diff(manifest, manifestDir)
+	}
+	if err != nil {
+		t.notify(tr("Drift check failed"), err.Error(), niifWarning)
+		return
+	}
+	if len(changes) != t.drift && len(changes) > 0 {
+		t.notify(tr("Context menus drifted"), sprintf("%d registry differences from the manifest. Choose \"Re-apply manifest\" to fix them.", len(changes)), niifWarning)
+	}
+	t.drift = len(changes)
+}
+
+func (t *tray) notify(title, text string, infoFlags uint32) {
+	var nid = t.nid
+	nid.Flags = nifInfo
+	nid.InfoFlags = infoFlags
+	copyUTF16(nid.InfoTitle[:], title)
+	copyUTF16(nid.Info[:], text)
+	procShellNotifyIconW.Call(nimModify, uintptr(unsafe.Pointer(&nid)))
+}
+
+func appendMenu(menu, flags, id uintptr, text string) {
+	procAppendMenuW.Call(menu, flags, id, uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(text))))
+}
+
+// copyUTF16 copies s into a fixed-size field of a Windows struct, cut short to fit with its NUL,
+// and never between the two halves of a surrogate pair.
+func copyUTF16(dst []uint16, s string) {
+	var src = windows.StringToUTF16(s)
+	if len(src) > len(dst) {
+		src = src[:len(dst)-1]
+		if last := src[len(src)-1]; 0xD800 <= last && last < 0xDC00 {
+			src = src[:len(src)-1]
+		}
+		src = append(src, 0)
+	}
+	copy(dst, src)
+}
+
+func showError(err error) {
+	windows.MessageBox(0, windows.StringToUTF16Ptr(err.Error()), windows.StringToUTF16Ptr("Context Menu Manager"), mbIconError)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestCopyUTF16(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		size int
+		want string
+	}{
+		{s: "Manifest applied", size: 64, want: "Manifest applied"},
+		{s: "Manifest applied", size: 9, want: "Manifest"},
+		{s: "abcd", size: 5, want: "abcd"},
+		{s: "abc😀", size: 5, want: "abc"},
+		{s: "ab😀", size: 5, want: "ab😀"},
+	} {
+		var dst = make([]uint16, test.size)
+		copyUTF16(dst, test.s)
+		want := make([]uint16, test.size)
+		copy(want, append(utf16.Encode([]rune(test.want)), 0))
+		if !reflect.DeepEqual(dst, want) {
+			t.Errorf("copyUTF16 of %q into %d is %q, expected %q", test.s, test.size, string(utf16.Decode(dst)), test.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+type TreeNode struct {
+	ItemStatus
+	Extended        bool       `json:"extended"`
+	Admin           bool       `json:"admin"`
+	SeparatorBefore bool       `json:"separatorBefore"`
+	SeparatorAfter  bool       `json:"separatorAfter"`
+	HasIcon         bool       `json:"hasIcon"`
+	Items           []TreeNode `json:"items,omitempty"`
+}
+
+type reorderRequest struct {
+	Parent string   `json:"parent"`
+	Order  []string `json:"order"`
+}
+
+func runUI(args []string) (err error) {
+	var (
+		flags  = newFlagSet("ui")
+		listen = flags.String("listen", "127.0.0.1:7231", "loopback address to listen on")
+		open   = flags.Bool("open", true, "open the UI in the default browser")
+		token  string
+		static fs.FS
+		ln     net.Listener
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if token, err = randomToken(); err != nil {
+		return
+	}
+	if static, err = fs.Sub(uiFiles, "ui"); err != nil {
+		return
+	}
+	if ln, err = listenLocal(*listen); err != nil {
+		return
+	}
+	url := fmt.Sprintf("http://%s/#token=%s", ln.Addr(), token)
+	fmt.Printf(tr("Serving the UI on %s\n"), url)
+	if *open {
+		openBrowser(url)
+	}
+	err = http.Serve(ln, &server{token: token, static: http.FileServer(http.FS(static))})
+	return
+}
+
+func buildTree(prefix string, menus ContextMenus, statuses map[string]ItemStatus) (nodes []TreeNode) {
+	nodes = []TreeNode{}
+	for _, entry := range menus {
+		var (
+			item = entry.Menu
+			node = TreeNode{
+				ItemStatus:      statuses[prefix+entry.ID],
+				Extended:        item.Extended,
+				Admin:           item.Admin,
+				SeparatorBefore: item.SeparatorBefore,
+				SeparatorAfter:  item.SeparatorAfter,
+				HasIcon:         item.IconPath != "",
+			}
+		)
+		if item.Type == ContextMenuType_Folder {
+			node.Items = buildTree(prefix+entry.ID+"/", item.Items, statuses)
+		}
+		nodes = append(nodes, node)
+	}
+	return
+}
+
+func (s *server) handleTree(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		manifest *Manifest
+		items    []ItemStatus
+		statuses = make(map[string]ItemStatus)
+	)
+	if manifest, _, err = loadManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if items, err = currentBackend().List(manifest); err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, item := range items {
+		statuses[item.ID] = item
+	}
+	writeJSON(w, http.StatusOK, buildTree("", manifest.Items, statuses))
+}
+
+func (s *server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		manifest *Manifest
+		problems []Problem
+	)
+	if manifest, _, err = loadManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if problems = validateManifest(manifest); problems == nil {
+		problems = []Problem{}
+	}
+	writeJSON(w, http.StatusOK, problems)
+}
+
+func (s *server) handleIcon(w http.ResponseWriter, r *http.Request) {
+	var (
+		err         error
+		manifest    *Manifest
+		manifestDir string
+		item        *ContextMenu
+		iconFile    string
+		iconIndex   *int
+		index       int
+		data        []byte
+		id          = r.URL.Query().Get("id")
+	)
+	if manifest, manifestDir, err = loadManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if item = manifest.Find(id); item == nil || item.IconPath == "" {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: sprintf("item ID %q has no icon", id)})
+		return
+	}
+	if iconFile, iconIndex = item.IconLocation(manifestDir); iconIndex != nil {
+		index = *iconIndex
+	}
+	if data, err = extractIconPNG(iconFile, index); err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+// handleReorder rearranges the children of parent, which is empty for the top level, and saves the
+// manifest. Saving drops the comments of a YAML or TOML manifest, so one with comments is left for
+// its author to reorder.
+func (s *server) handleReorder(w http.ResponseWriter, r *http.Request) {
+	var (
+		err          error
+		manifestPath string
+		manifest     *Manifest
+		data         []byte
+		req          reorderRequest
+		list         *ContextMenus
+		reordered    ContextMenus
+		changed      bool
+	)
+	if err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: tr("invalid request body: ") + err.Error()})
+		return
+	}
+	if manifestPath, err = findManifest(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if manifest, err = readManifest(manifestPath); err != nil {
+		writeError(w, err)
+		return
+	}
+	list = &manifest.Items
+	if req.Parent != "" {
+		var parent = manifest.Find(req.Parent)
+		if parent == nil {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: sprintf("item ID %q not found in manifest", req.Parent)})
+			return
+		}
+		if parent.Type != ContextMenuType_Folder {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: sprintf("item ID %q is not a folder", req.Parent)})
+			return
+		}
+		list = &parent.Items
+	}
+	for i, id := range req.Order {
+		var menu = list.Get(id)
+		if menu == nil || reordered.Get(id) != nil {
+			break
+		}
+		reordered = append(reordered, ContextMenuEntry{ID: id, Menu: menu})
+		changed = changed || (*list)[i].ID != id
+	}
+	if len(reordered) != len(*list) || len(req.Order) != len(*list) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: sprintf("order must list every child of %q exactly once", req.Parent)})
+		return
+	}
+	if !changed {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if format := manifestFormat(manifestPath); format != "json" {
+		if data, err = os.ReadFile(manifestPath); err != nil {
+			writeError(w, err)
+			return
+		}
+		if hasComments(format, data) {
+			writeJSON(w, http.StatusConflict, errorResponse{Error: sprintf("%s has comments, which saving it would drop; reorder its items in the file instead", filepath.Base(manifestPath))})
+			return
+		}
+	}
+	*list = reordered
+	if err = writeManifest(manifestPath, manifest); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func init() {
+	// Windows may map .js to text/plain in the registry, which browsers refuse to execute.
+	mime.AddExtensionType(".js", "text/javascript; charset=utf-8")
+	mime.AddExtensionType(".css", "text/css; charset=utf-8")
+}
@@ -0,0 +1,174 @@
+"use strict";
+
+const token = new URLSearchParams(location.hash.slice(1)).get("token") || "";
+const status = document.getElementById("status");
+let tree = [];
+
+async function api(method, path, body) {
+    const res = await fetch(path, {
+        method,
+        headers: {"Authorization": "Bearer " + token, "Content-Type": "application/json"},
+        body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (res.status === 204) {
+        return null;
+    }
+    const data = await res.json();
+    if (!res.ok) {
+        throw new Error(data.error || res.statusText);
+    }
+    return data;
+}
+
+// renderTitle underlines "&" accelerators the way Explorer does.
+function renderTitle(title) {
+    const span = document.createElement("span");
+    for (let i = 0; i < title.length; i++) {
+        if (title[i] === "&" && i + 1 < title.length) {
+            i++;
+            if (title[i] !== "&") {
+                const u = document.createElement("u");
+                u.textContent = title[i];
+                span.append(u);
+                continue;
+            }
+        }
+        span.append(title[i]);
+    }
+    return span;
+}
+
+function separator() {
+    const li = document.createElement("li");
+    li.className = "separator";
+    return li;
+}
+
+function renderIcon(node) {
+    const icon = document.createElement("span");
+    icon.className = "icon";
+    if (node.hasIcon) {
+        const img = new Image(16, 16);
+        img.src = "/api/icon?id=" + encodeURIComponent(node.id) + "&token=" + encodeURIComponent(token);
+        img.onerror = () => img.remove();
+        icon.append(img);
+    } else if (node.admin) {
+        icon.textContent = "\u{1F6E1}";
+    }
+    return icon;
+}
+
+function renderMenu(nodes, parent) {
+    const menu = document.createElement("ul");
+    const showExtended = document.getElementById("extended").checked;
+    menu.className = "menu";
+    for (const node of nodes) {
+        if (node.extended && !showExtended) {
+            continue;
+        }
+        if (node.separatorBefore) {
+            menu.append(separator());
+        }
+        const li = document.createElement("li");
+        li.className = "entry";
+        li.title = node.id + (node.installed ? (node.enabled ? "" : " (disabled)") : " (not applied)");
+        li.classList.toggle("disabled", node.installed && !node.enabled);
+        li.draggable = true;
+        li.append(renderIcon(node), renderTitle(node.title));
+        li.addEventListener("dragstart", (e) => {
+            e.stopPropagation();
+            e.dataTransfer.setData("text/plain", JSON.stringify({parent, id: node.id}));
+        });
+        li.addEventListener("dragover", (e) => {
+            e.preventDefault();
+            e.stopPropagation();
+            li.classList.add("drop-target");
+        });
+        li.addEventListener("dragleave", () => li.classList.remove("drop-target"));
+        li.addEventListener("drop", (e) => {
+            e.preventDefault();
+            e.stopPropagation();
+            li.classList.remove("drop-target");
+            const dragged = JSON.parse(e.dataTransfer.getData("text/plain"));
+            if (dragged.parent === parent && dragged.id !== node.id) {
+                reorder(parent, nodes, dragged.id, node.id);
+            }
+        });
+        if (node.type === "folder") {
+            li.classList.add("folder");
+            li.append(renderMenu(node.items || [], node.id));
+        }
+        menu.append(li);
+        if (node.separatorAfter) {
+            menu.append(separator());
+        }
+    }
+    return menu;
+}
+
+function childID(parent, id) {
+    return id.slice(parent ? parent.length + 1 : 0);
+}
+
+// reorder moves the dragged item in front of the item it was dropped on.
+async function reorder(parent, nodes, draggedID, targetID) {
+    const order = nodes.map((node) => node.id).filter((id) => id !== draggedID);
+    order.splice(order.indexOf(targetID), 0, draggedID);
+    try {
+        await api("POST", "/api/reorder", {parent, order: order.map((id) => childID(parent, id))});
+        await refresh();
+    } catch (err) {
+        status.textContent = err.message;
+    }
+}
+
+function renderList(element, lines, empty) {
+    element.replaceChildren(...(lines.length ? lines : [empty]).map((line) => {
+        const li = document.createElement("li");
+        li.textContent = line;
+        return li;
+    }));
+}
+
+function describeChange(change) {
+    const prefix = {add: "+", remove: "-", modify: "~"}[change.kind];
+    if (change.value === undefined) {
+        return prefix + " " + change.key;
+    }
+    const value = "[" + (change.value || "(Default)") + "]";
+    return [prefix, change.key, value, change.kind === "remove" ? change.have : change.want].join(" ");
+}
+
+function render() {
+    document.getElementById("preview").replaceChildren(renderMenu(tree, ""));
+}
+
+async function refresh() {
+    try {
+        const [nodes, problems, changes] = await Promise.all([
+            api("GET", "/api/tree"),
+            api("GET", "/api/validate"),
+            api("GET", "/api/diff"),
+        ]);
+        tree = nodes;
+        render();
+        renderList(document.getElementById("problems"), problems.map((p) => (p.id ? p.id + ": " : "") + (p.warning ? "warning: " : "") + p.message), "None");
+        renderList(document.getElementById("changes"), changes.map(describeChange), "None, the registry matches the manifest");
+    } catch (err) {
+        status.textContent = err.message;
+    }
+}
+
+document.getElementById("extended").addEventListener("change", render);
+document.getElementById("apply").addEventListener("click", async () => {
+    status.textContent = "Applying…";
+    try {
+        await api("POST", "/api/apply");
+        status.textContent = "Applied";
+    } catch (err) {
+        status.textContent = err.message;
+    }
+    await refresh();
+});
+
+refresh();
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+func openBrowser(url string) {
+	var opener = "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	exec.Command(opener, url).Start()
+}
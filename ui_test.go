@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReorder checks which orders of the children of a folder the UI saves, and that the manifest
+// is only written when they change and nothing would be lost.
+func TestReorder(t *testing.T) {
+	const (
+		yamlManifest = "schemaVersion: 2\nitems:\n  - id: tools\n    type: folder\n    title: Tools\n    items:\n      - id: a\n        type: item\n        title: A\n        command: [a]\n      - id: b\n        type: item\n        title: B\n        command: [b]\n"
+		commented    = "# Menus of the team\n" + yamlManifest
+	)
+	var (
+		s   = &server{token: "secret"}
+		dir = t.TempDir()
+		cwd string
+		err error
+	)
+	if cwd, err = os.Getwd(); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	for _, test := range []struct {
+		name       string
+		manifest   string
+		body       string
+		wantStatus int
+		wantOrder  string
+	}{
+		{name: "reorder", manifest: yamlManifest, body: `{"parent": "tools", "order": ["b", "a"]}`, wantStatus: http.StatusNoContent, wantOrder: "b a"},
+		{name: "same order", manifest: commented, body: `{"parent": "tools", "order": ["a", "b"]}`, wantStatus: http.StatusNoContent},
+		{name: "comments", manifest: commented, body: `{"parent": "tools", "order": ["b", "a"]}`, wantStatus: http.StatusConflict},
+		{name: "item as parent", manifest: yamlManifest, body: `{"parent": "tools/a", "order": []}`, wantStatus: http.StatusBadRequest},
+		{name: "missing parent", manifest: yamlManifest, body: `{"parent": "none", "order": []}`, wantStatus: http.StatusNotFound},
+		{name: "child left out", manifest: yamlManifest, body: `{"parent": "tools", "order": ["b"]}`, wantStatus: http.StatusBadRequest},
+		{name: "child twice", manifest: yamlManifest, body: `{"parent": "tools", "order": ["b", "b"]}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown child", manifest: yamlManifest, body: `{"parent": "tools", "order": ["b", "a", "c"]}`, wantStatus: http.StatusBadRequest},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				manifestPath = filepath.Join(dir, "manifest.yaml")
+				r            = httptest.NewRequest("POST", "/api/reorder", strings.NewReader(test.body))
+				w            = httptest.NewRecorder()
+				data         []byte
+				manifest     *Manifest
+			)
+			if err := os.WriteFile(manifestPath, []byte(test.manifest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			r.Host = "127.0.0.1:7231"
+			r.Header.Set("Authorization", "Bearer secret")
+			s.ServeHTTP(w, r)
+			if w.Code != test.wantStatus {
+				t.Fatalf("answered %d, expected %d: %s", w.Code, test.wantStatus, w.Body)
+			}
+			if data, err = os.ReadFile(manifestPath); err != nil {
+				t.Fatal(err)
+			}
+			if test.wantOrder == "" {
+				if !bytes.Equal(data, []byte(test.manifest)) {
+					t.Errorf("rewrote the manifest as\n%s", data)
+				}
+				return
+			}
+			if manifest, err = readManifest(manifestPath); err != nil {
+				t.Fatal(err)
+			}
+			var order []string
+			for _, entry := range manifest.Find("tools").Items {
+				order = append(order, entry.ID)
+			}
+			if strings.Join(order, " ") != test.wantOrder {
+				t.Errorf("saved order %q, expected %q", order, test.wantOrder)
+			}
+		})
+	}
+}
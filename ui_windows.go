@@ -0,0 +1,7 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+func openBrowser(url string) {
+	windows.ShellExecute(0, windows.StringToUTF16Ptr("open"), windows.StringToUTF16Ptr(url), nil, nil, windows.SW_SHOWNORMAL)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version and releasePublicKey are set at build time:
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.releasePublicKey=<hex ed25519 public key>"
+var (
+	version          = "dev"
+	releasePublicKey = ""
+)
+
+const (
+	releasesURL       = "https://api.github.com/repos/rixtox/context-menu-manager/releases/latest"
+	checksumsAsset    = "SHA256SUMS"
+	checksumsSigAsset = "SHA256SUMS.sig"
+)
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r *release) asset(name string) *releaseAsset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+func runSelfUpdate(args []string) (err error) {
+	var (
+		flags     = newFlagSet("self-update")
+		check     = flags.Bool("check", false, "only report whether an update is available")
+		force     = flags.Bool("force", false, "install the latest release even if it is not newer")
+		exePath   string
+		latest    *release
+		asset     *releaseAsset
+		binary    []byte
+		checksums []byte
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if exePath, err = os.Executable(); err != nil {
+		err = errorf("failed to locate the executable: %w", err)
+		return
+	}
+	os.Remove(exePath + ".old")
+	if latest, err = fetchLatestRelease(); err != nil {
+		return
+	}
+	if !*force && !newerVersion(latest.TagName, version) {
+		fmt.Printf(tr("%s is up to date.\n"), version)
+		return
+	}
+	fmt.Printf(tr("Update available: %s -> %s\n"), version, latest.TagName)
+	if *check {
+		return
+	}
+	if _, err = releaseKey(); err != nil {
+		return
+	}
+	name := fmt.Sprintf("context-menu-manager_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if asset = latest.asset(name); asset == nil {
+		err = errorf("release %s has no %s", latest.TagName, name)
+		return
+	}
+	if checksums, err = downloadAsset(latest, checksumsAsset); err != nil {
+		return
+	}
+	if err = verifyChecksumsSignature(latest, checksums); err != nil {
+		return
+	}
+	if binary, err = download(asset.URL); err != nil {
+		return
+	}
+	if err = verifyChecksum(checksums, name, binary); err != nil {
+		return
+	}
+	if err = replaceExecutable(exePath, binary); err != nil {
+		return
+	}
+	fmt.Printf(tr("Updated to %s.\n"), latest.TagName)
+	return
+}
+
+func fetchLatestRelease() (latest *release, err error) {
+	var data []byte
+	if data, err = download(releasesURL); err != nil {
+		return
+	}
+	latest = new(release)
+	if err = json.Unmarshal(data, latest); err != nil {
+		err = errorf("failed to parse the releases feed: %w", err)
+		return
+	}
+	return
+}
+
+func downloadAsset(r *release, name string) (data []byte, err error) {
+	var asset = r.asset(name)
+	if asset == nil {
+		err = errorf("release %s has no %s", r.TagName, name)
+		return
+	}
+	data, err = download(asset.URL)
+	return
+}
+
+func download(url string) (data []byte, err error) {
+	var (
+		client = http.Client{Timeout: 5 * time.Minute}
+		resp   *http.Response
+	)
+	if resp, err = client.Get(url); err != nil {
+		err = errorf("failed to download %s: %w", url, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = errorf("failed to download %s: %s", url, resp.Status)
+		return
+	}
+	if data, err = io.ReadAll(resp.Body); err != nil {
+		err = errorf("failed to download %s: %w", url, err)
+		return
+	}
+	return
+}
+
+// verifyChecksumsSignature checks SHA256SUMS against releasePublicKey. The checksums come from the
+// same place as the binary, so they prove nothing without it, and builds without a public key
+// cannot update themselves.
+func verifyChecksumsSignature(r *release, checksums []byte) (err error) {
+	var (
+		publicKey []byte
+		data      []byte
+		signature []byte
+	)
+	if publicKey, err = releaseKey(); err != nil {
+		return
+	}
+	if data, err = downloadAsset(r, checksumsSigAsset); err != nil {
+		return
+	}
+	if signature, err = decodeSignature(data); err != nil {
+		return
+	}
+	if !ed25519.Verify(publicKey, checksums, signature) {
+		err = errorf("signature of %s does not match", checksumsAsset)
+		return
+	}
+	return
+}
+
+func releaseKey() (publicKey []byte, err error) {
+	if releasePublicKey == "" {
+		err = errorf("this build has no release public key to verify updates with, download the release by hand")
+		return
+	}
+	if publicKey, err = hex.DecodeString(releasePublicKey); err != nil || len(publicKey) != ed25519.PublicKeySize {
+		err = errorf("invalid release public key")
+	}
+	return
+}
+
+// decodeSignature reads SHA256SUMS.sig, which is the raw 64 byte signature, or the signature as hex
+// or base64 text. Raw signatures are taken as they are, since trimming could cut bytes off them.
+func decodeSignature(data []byte) (signature []byte, err error) {
+	if len(data) == ed25519.SignatureSize {
+		return data, nil
+	}
+	text := string(bytes.TrimSpace(data))
+	switch len(text) {
+	case hex.EncodedLen(ed25519.SignatureSize):
+		signature, err = hex.DecodeString(text)
+	case base64.StdEncoding.EncodedLen(ed25519.SignatureSize):
+		signature, err = base64.StdEncoding.DecodeString(text)
+	default:
+		err = errorf("unexpected length %d", len(data))
+	}
+	if err == nil && len(signature) != ed25519.SignatureSize {
+		err = errorf("unexpected length %d", len(signature))
+	}
+	if err != nil {
+		signature, err = nil, errorf("invalid %s: %w", checksumsSigAsset, err)
+	}
+	return
+}
+
+// verifyChecksum looks up name in a sha256sum style listing and compares it with data.
+func verifyChecksum(checksums []byte, name string, data []byte) (err error) {
+	var (
+		sum     = sha256.Sum256(data)
+		scanner = bufio.NewScanner(bytes.NewReader(checksums))
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+			err = errorf("checksum of %s does not match", name)
+		}
+		return
+	}
+	err = errorf("%s has no checksum for %s", checksumsAsset, name)
+	return
+}
+
+// replaceExecutable swaps the new binary in. A running executable cannot be overwritten on Windows,
+// but it can be renamed, so the old one is moved aside and removed by the next self-update.
+func replaceExecutable(exePath string, binary []byte) (err error) {
+	var (
+		newPath = exePath + ".new"
+		oldPath = exePath + ".old"
+	)
+	if err = os.WriteFile(newPath, binary, 0o755); err != nil {
+		err = errorf("failed to write %s: %w", newPath, err)
+		return
+	}
+	os.Remove(oldPath)
+	if err = os.Rename(exePath, oldPath); err != nil {
+		os.Remove(newPath)
+		err = errorf("failed to move %s aside: %w", filepath.Base(exePath), err)
+		return
+	}
+	if err = os.Rename(newPath, exePath); err != nil {
+		os.Rename(oldPath, exePath)
+		err = errorf("failed to replace %s: %w", filepath.Base(exePath), err)
+		return
+	}
+	return
+}
+
+// newerVersion compares "v1.2.3" style tags numerically. A pre-release such as v1.2.3-rc.1 comes
+// before its release. Development builds are always outdated.
+func newerVersion(latest, current string) bool {
+	var (
+		l, lPre, _ = strings.Cut(strings.TrimPrefix(latest, "v"), "-")
+		c, cPre, _ = strings.Cut(strings.TrimPrefix(current, "v"), "-")
+		ln         = strings.Split(l, ".")
+		cn         = strings.Split(c, ".")
+	)
+	if current == "dev" {
+		return true
+	}
+	for i := 0; i < len(ln) || i < len(cn); i++ {
+		var lv, cv int
+		if i < len(ln) {
+			lv, _ = strconv.Atoi(ln[i])
+		}
+		if i < len(cn) {
+			cv, _ = strconv.Atoi(cn[i])
+		}
+		if lv != cv {
+			return lv > cv
+		}
+	}
+	return cPre != "" && (lPre == "" || lPre > cPre)
+}
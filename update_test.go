@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	for _, test := range []struct {
+		latest, current string
+		want            bool
+	}{
+		{latest: "v1.2.3", current: "v1.2.3", want: false},
+		{latest: "v1.2.4", current: "v1.2.3", want: true},
+		{latest: "v1.10.0", current: "v1.9.9", want: true},
+		{latest: "v1.2", current: "v1.2.1", want: false},
+		{latest: "v1.2.1", current: "v1.2", want: true},
+		{latest: "v2.0.0", current: "v10.0.0", want: false},
+		{latest: "v1.2.3", current: "v1.2.3-rc.1", want: true},
+		{latest: "v1.2.3-rc.1", current: "v1.2.2", want: true},
+		{latest: "v1.2.3-rc.1", current: "v1.2.3", want: false},
+		{latest: "v1.2.3-rc.2", current: "v1.2.3-rc.1", want: true},
+		{latest: "v0.0.1", current: "dev", want: true},
+	} {
+		if got := newerVersion(test.latest, test.current); got != test.want {
+			t.Errorf("newerVersion(%q, %q) = %v, expected %v", test.latest, test.current, got, test.want)
+		}
+	}
+}
+
+func TestDecodeSignature(t *testing.T) {
+	var (
+		signature = make([]byte, ed25519.SignatureSize)
+		newline   = make([]byte, ed25519.SignatureSize)
+	)
+	for i := range signature {
+		signature[i] = byte(i * 7)
+	}
+	copy(newline, signature)
+	newline[len(newline)-1] = '\n'
+	for _, test := range []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{name: "raw", data: signature, want: signature},
+		{name: "raw ending in a newline byte", data: newline, want: newline},
+		{name: "hex", data: []byte(hex.EncodeToString(signature) + "\n"), want: signature},
+		{name: "base64", data: []byte(base64.StdEncoding.EncodeToString(signature) + "\r\n"), want: signature},
+		{name: "short", data: []byte(hex.EncodeToString(signature[:40]))},
+		{name: "not hex", data: []byte(strings.Repeat("zz", ed25519.SignatureSize))},
+	} {
+		got, err := decodeSignature(test.data)
+		if test.want == nil && err == nil || test.want != nil && (err != nil || !bytes.Equal(got, test.want)) {
+			t.Errorf("%s: decoded %x, %v, expected %x", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	var (
+		binary    = []byte("binary")
+		sum       = sha256.Sum256(binary)
+		hexSum    = hex.EncodeToString(sum[:])
+		checksums = fmt.Sprintf("%s  context-menu-manager_linux_amd64\n%s *context-menu-manager_windows_amd64.exe\n", strings.Repeat("0", 64), strings.ToUpper(hexSum))
+	)
+	for _, test := range []struct {
+		name    string
+		wantErr string
+	}{
+		{name: "context-menu-manager_windows_amd64.exe"},
+		{name: "context-menu-manager_linux_amd64", wantErr: "checksum of context-menu-manager_linux_amd64 does not match"},
+		{name: "context-menu-manager_darwin_arm64", wantErr: "SHA256SUMS has no checksum for context-menu-manager_darwin_arm64"},
+	} {
+		err := verifyChecksum([]byte(checksums), test.name, binary)
+		if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("%s: got error %v, expected %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+// TestVerifyChecksumsSignature checks SHA256SUMS against the signature published with it, and that
+// builds without a public key refuse to update.
+func TestVerifyChecksumsSignature(t *testing.T) {
+	var (
+		publicKey, privateKey, _ = ed25519.GenerateKey(nil)
+		otherKey, _, _           = ed25519.GenerateKey(nil)
+		checksums                = []byte("0000  context-menu-manager_linux_amd64\n")
+		signature                = ed25519.Sign(privateKey, checksums)
+		saved                    = releasePublicKey
+	)
+	defer func() {
+		releasePublicKey = saved
+	}()
+	for _, test := range []struct {
+		name      string
+		key       string
+		signature []byte
+		checksums []byte
+		wantErr   string
+	}{
+		{name: "signed", key: hex.EncodeToString(publicKey), signature: signature, checksums: checksums},
+		{name: "signed as hex", key: hex.EncodeToString(publicKey), signature: []byte(hex.EncodeToString(signature)), checksums: checksums},
+		{name: "changed", key: hex.EncodeToString(publicKey), signature: signature, checksums: []byte("1111  context-menu-manager_linux_amd64\n"), wantErr: "signature of SHA256SUMS does not match"},
+		{name: "signed with another key", key: hex.EncodeToString(otherKey), signature: signature, checksums: checksums, wantErr: "signature of SHA256SUMS does not match"},
+		{name: "no public key", signature: signature, checksums: checksums, wantErr: "this build has no release public key"},
+		{name: "invalid public key", key: "abcd", signature: signature, checksums: checksums, wantErr: "invalid release public key"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(test.signature)
+			}))
+			defer server.Close()
+			releasePublicKey = test.key
+			r := &release{TagName: "v1.0.0", Assets: []releaseAsset{{Name: checksumsSigAsset, URL: server.URL + "/" + checksumsSigAsset}}}
+			err := verifyChecksumsSignature(r, test.checksums)
+			if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Errorf("got error %v, expected %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	var exePath = filepath.Join(t.TempDir(), "context-menu-manager.exe")
+	if err := os.WriteFile(exePath, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceExecutable(exePath, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{exePath: "new", exePath + ".old": "old"} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("%s has %q, %v, expected %q", filepath.Base(path), data, err, want)
+		}
+	}
+	if _, err := os.Stat(exePath + ".new"); !os.IsNotExist(err) {
+		t.Errorf("left %s.new behind", filepath.Base(exePath))
+	}
+}
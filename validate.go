@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Problem is something wrong with the manifest. Warnings point at what probably shows differently
+// than intended, and do not fail validate.
+type Problem struct {
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+func (p Problem) String() string {
+	var message = p.Message
+	if p.Warning {
+		message = tr("warning: ") + message
+	}
+	if p.ID == "" {
+		return message
+	}
+	return fmt.Sprintf("%s: %s", p.ID, message)
+}
+
+// maxTitleLength is about where menus start to cut titles off with an ellipsis.
+const maxTitleLength = 64
+
+// titleProblems finds what Explorer renders badly in a title: control characters, which cut it off
+// or show as boxes, line separators, bidirectional formatting that is not closed again, which
+// garbles the items below, and text that was mangled by a wrong encoding before it got here.
+func titleProblems(title string) (problems, warnings []string) {
+	var (
+		embeddings, isolates int
+		length               int
+	)
+	for _, r := range title {
+		switch {
+		case r == '\uFFFD':
+			warnings = append(warnings, sprintf("title contains %U, left by text that was not valid Unicode", r))
+		case r >= '\u202A' && r <= '\u202E' && r != '\u202C':
+			embeddings++
+		case r == '\u202C' && embeddings > 0:
+			// A closing character with nothing open is ignored, and closes nothing opened after it.
+			embeddings--
+		case r >= '\u2066' && r <= '\u2068':
+			isolates++
+		case r == '\u2069' && isolates > 0:
+			isolates--
+		case r == '\u2028' || r == '\u2029' || unicode.IsControl(r):
+			problems = append(problems, sprintf("title contains control character %U", r))
+		}
+		if r != '&' && !unicode.Is(unicode.Mn, r) && !unicode.Is(unicode.Cf, r) && !unicode.Is(unicode.Variation_Selector, r) {
+			length++
+		}
+	}
+	if embeddings != 0 || isolates != 0 {
+		problems = append(problems, tr("title has bidirectional formatting characters that are not closed"))
+	}
+	if strings.TrimSpace(title) != title {
+		warnings = append(warnings, tr("title has leading or trailing spaces, run fmt to remove them"))
+	}
+	if length > maxTitleLength {
+		warnings = append(warnings, sprintf("title is %d characters long, menus may cut it off after about %d", length, maxTitleLength))
+	}
+	return
+}
+
+// Explorer draws submenus of static verbs only to a limited depth, and leaves out the items below
+// without a trace. Menus well above that are already hard to get through.
+const (
+	maxMenuDepth  = 4
+	deepMenuDepth = 3
+)
+
+func validateManifest(manifest *Manifest) (problems []Problem) {
+	var walk func(prefix string, menus ContextMenus)
+	walk = func(prefix string, menus ContextMenus) {
+		for _, entry := range menus {
+			var (
+				id      = prefix + entry.ID
+				item    = entry.Menu
+				problem = func(format string, args ...interface{}) {
+					problems = append(problems, Problem{ID: id, Message: sprintf(format, args...)})
+				}
+			)
+			if entry.ID == "" {
+				problem("ID is empty")
+			} else if strings.ContainsAny(entry.ID, `/\`) {
+				problem(`ID must not contain "/" or "\"`)
+			}
+			if strings.TrimSpace(item.Title) == "" {
+				problem("title is empty")
+			}
+			problems = append(problems, layoutProblems(id, item)...)
+			if item.IconIndex != nil && item.IconPath == "" && item.Variants.IconPath == nil {
+				problem("iconIndex is set without iconPath")
+			}
+			iconPaths := append([]string{item.IconPath}, item.IconFallbacks...)
+			for _, platform := range platforms {
+				if _, ok := item.Variants.IconPath[platform]; ok {
+					iconPaths = append(append(iconPaths, item.iconPathOn(platform)), item.iconFallbacksOn(platform)...)
+				}
+			}
+			seenIcons := make(map[string]bool)
+			for _, iconPath := range iconPaths {
+				if seenIcons[iconPath] {
+					continue
+				}
+				seenIcons[iconPath] = true
+				if preset, ok := lookupShellIcon(iconPath); ok && preset == nil {
+					problem("unknown icon preset %q, use one of %s", iconPath, strings.Join(shellIconNames(), ", "))
+				} else if ok && item.IconIndex != nil {
+					problem("iconIndex is set for the icon preset %q, which has an index of its own", iconPath)
+				}
+			}
+			if prefix != "" && len(item.Targets) > 0 {
+				problem("targets are only used on top-level items")
+			}
+			switch item.Type {
+			case ContextMenuType_Item:
+				if len(item.Command) == 0 && len(item.Variants.Command) == 0 {
+					problem("command is empty")
+				}
+				for _, platform := range platforms {
+					if _, ok := item.Variants.Command[platform]; ok && len(item.commandOn(platform)) == 0 {
+						problem("command for %s is empty", platform)
+					}
+				}
+				if len(item.Items) > 0 {
+					problem("items are ignored for type %q", item.Type)
+				}
+			case ContextMenuType_Folder:
+				if len(item.Items) == 0 {
+					problem("folder has no items")
+				}
+				if len(item.Command) > 0 || item.Variants.Command != nil {
+					problem("command is ignored for type %q", item.Type)
+				}
+				walk(id+"/", item.Items)
+			case ContextMenuType_Recent:
+				for _, platform := range platforms {
+					if command := item.commandOn(platform); len(command) > 0 && !strings.Contains(strings.Join(command, " "), recentFilePlaceholder) {
+						problem("command of a recent folder does not use %s", recentFilePlaceholder)
+						break
+					}
+				}
+				if item.Source != "" && item.Source != RecentSource_Files {
+					problem("unknown source %q, expected %q", item.Source, RecentSource_Files)
+				}
+				if item.Limit < 0 {
+					problem("limit must not be negative")
+				}
+				if len(item.Items) > 0 {
+					problem("items are ignored for type %q", item.Type)
+				}
+			case ContextMenuType_ScriptsFolder:
+				if strings.TrimSpace(item.Path) == "" {
+					problem("path is empty")
+				}
+				if len(item.Command) > 0 || item.Variants.Command != nil {
+					problem("command is ignored for type %q", item.Type)
+				}
+				if len(item.Items) > 0 {
+					problem("items are ignored for type %q", item.Type)
+				}
+			case ContextMenuType_CopyPath:
+				if item.PathFormat != "" && !item.PathFormat.valid() {
+					problem("unknown path format %q, expected one of %s", item.PathFormat, strings.Join(pathFormatNames(), ", "))
+				}
+				if len(item.Command) > 0 || item.Variants.Command != nil {
+					problem("command is ignored for type %q", item.Type)
+				}
+				if len(item.Items) > 0 {
+					problem("items are ignored for type %q", item.Type)
+				}
+			case ContextMenuType_TerminalProfiles, ContextMenuType_WSLDistros:
+				if len(item.Command) > 0 || item.Variants.Command != nil {
+					problem("command is ignored for type %q", item.Type)
+				}
+				if len(item.Items) > 0 {
+					problem("items are ignored for type %q", item.Type)
+				}
+			default:
+				problem("unknown type %q, expected one of %s", item.Type, strings.Join([]string{
+					string(ContextMenuType_Item), string(ContextMenuType_Folder), string(ContextMenuType_Recent),
+					string(ContextMenuType_ScriptsFolder), string(ContextMenuType_TerminalProfiles), string(ContextMenuType_WSLDistros),
+					string(ContextMenuType_CopyPath),
+				}, ", "))
+			}
+			if item.Type != ContextMenuType_Recent && (item.Source != "" || item.Limit != 0) {
+				problem("source and limit are only used for type %q", ContextMenuType_Recent)
+			}
+			if item.Type != ContextMenuType_ScriptsFolder && item.Path != "" {
+				problem("path is only used for type %q", ContextMenuType_ScriptsFolder)
+			}
+			if item.Type != ContextMenuType_CopyPath && item.PathFormat != "" {
+				problem("pathFormat is only used for type %q", ContextMenuType_CopyPath)
+			}
+		}
+	}
+	if len(manifest.Items) == 0 {
+		problems = append(problems, Problem{Message: tr("manifest has no items")})
+	}
+	walk("", manifest.Items)
+	return
+}
+
+// layoutProblems finds what Explorer shows differently than the manifest lays it out: titles it
+// renders badly, folders with more items than it shows, and items nested deeper than it draws
+// submenus.
+func layoutProblems(id string, item *ContextMenu) (problems []Problem) {
+	titleErrors, titleWarnings := titleProblems(item.Title)
+	for _, message := range titleErrors {
+		problems = append(problems, Problem{ID: id, Message: message})
+	}
+	for _, message := range titleWarnings {
+		problems = append(problems, Problem{ID: id, Message: message, Warning: true})
+	}
+	if item.Type != ContextMenuType_Folder || len(item.Items) == 0 {
+		return
+	}
+	if len(item.Items) > maxFolderItems && !config.SplitFolders {
+		problems = append(problems, Problem{ID: id, Message: sprintf("folder has %d items, some Windows versions only show %d; splitFolders moves the rest into a \"More…\" folder", len(item.Items), maxFolderItems), Warning: true})
+	}
+	// Only the folder whose items cross a limit is reported, rather than every item below.
+	switch depth := strings.Count(id, "/") + 1; depth {
+	case maxMenuDepth + 1:
+		problems = append(problems, Problem{ID: id, Message: sprintf("items are nested %d submenus deep, Explorer does not show submenus deeper than %d", depth, maxMenuDepth)})
+	case deepMenuDepth + 1:
+		problems = append(problems, Problem{ID: id, Message: sprintf("items are nested %d submenus deep, which is hard to reach", depth), Warning: true})
+	}
+	return
+}
+
+// warnLayout logs the layout problems of the items about to be applied. They are written anyway,
+// as validate is where a manifest is rejected for them.
+func warnLayout(items ContextMenus, prefix string) {
+	for _, entry := range items {
+		id := prefix + entry.ID
+		for _, problem := range layoutProblems(id, entry.Menu) {
+			logf(LogLevel_Warn, "%s: %s", problem.ID, problem.Message)
+		}
+		warnLayout(entry.Menu.Items, id+"/")
+	}
+}
+
+// runValidate checks a manifest and plans its registry keys for Windows without touching the
+// registry, so manifests can be checked on any platform, e.g. in CI.
+func runValidate(args []string) (err error) {
+	var (
+		flags        = newFlagSet("validate")
+		manifestPath = flags.String("manifest", "", "manifest to check (default: the manifest found by apply)")
+		manifest     *Manifest
+		manifestData []byte
+		problems     []Problem
+		failed       int
+	)
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if *manifestPath == "" {
+		if *manifestPath, err = findManifest(); err != nil {
+			return
+		}
+	}
+	if manifest, err = readManifest(*manifestPath); err != nil {
+		return
+	}
+	problems = append(validateManifest(manifest), planProblems(manifest, filepath.Dir(*manifestPath))...)
+	if len(problems) == 0 {
+		fmt.Println(tr("No problems found."))
+		return
+	}
+	if manifestFormat(*manifestPath) == "json" {
+		manifestData, _ = os.ReadFile(*manifestPath)
+	}
+	for _, problem := range problems {
+		var path []interface{}
+		for _, id := range strings.Split(problem.ID, "/") {
+			path = append(path, "items", id)
+		}
+		if offset, ok := jsonPathOffset(manifestData, path); ok && problem.ID != "" {
+			line, column := lineColumn(manifestData, offset)
+			fmt.Printf(tr("line %d, column %d: %s\n"), line, column, problem)
+		} else {
+			fmt.Println(problem)
+		}
+		if !problem.Warning {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err = errorf("%s has %d problem(s)", *manifestPath, failed)
+	}
+	return
+}
+
+// planProblems reports the items whose registry keys cannot be planned, with the commands and icons
+// given for Windows. nircmd.exe is only needed where the keys are applied, so a missing one is not
+// a problem of the manifest.
+func planProblems(manifest *Manifest, manifestDir string) (problems []Problem) {
+	var windows = &Manifest{SchemaVersion: manifest.SchemaVersion, Items: manifest.Items.forPlatform("windows")}
+	nircmdFallback = "nircmd.exe"
+	defer func() {
+		nircmdFallback = ""
+	}()
+	for _, entry := range windows.Items {
+		for _, target := range windows.Targets(entry.ID) {
+			if _, err := planContextMenu(itemKeyPath(target, entry.ID), entry.Menu, manifestDir); err != nil {
+				problems = append(problems, Problem{ID: entry.ID, Message: err.Error()})
+				break
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestValidateManifest checks the problems found in manifests, reported with the path of IDs of
+// the item they are about.
+func TestValidateManifest(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		items string
+		want  []Problem
+	}{
+		{
+			name:  "valid",
+			items: `{"a": {"type": "item", "title": "A", "command": ["a.exe", "%V"], "targets": ["directory"]}, "f": {"type": "folder", "title": "F", "items": {"b": {"type": "item", "title": "B", "command": ["b.exe"]}}}}`,
+		},
+		{
+			name: "no items",
+			want: []Problem{{Message: "manifest has no items"}},
+		},
+		{
+			name:  "item",
+			items: `{"a": {"type": "item", "title": " ", "iconIndex": 1, "items": {"b": {"type": "item", "title": "B", "command": ["b.exe"]}}}}`,
+			want: []Problem{
+				{ID: "a", Message: "title is empty"},
+				{ID: "a", Message: "title has leading or trailing spaces, run fmt to remove them", Warning: true},
+				{ID: "a", Message: "iconIndex is set without iconPath"},
+				{ID: "a", Message: "command is empty"},
+				{ID: "a", Message: `items are ignored for type "item"`},
+			},
+		},
+		{
+			name:  "folder",
+			items: `{"f": {"type": "folder", "title": "F", "command": ["f.exe"], "items": {"g": {"type": "folder", "title": "G", "targets": ["drive"]}}}}`,
+			want: []Problem{
+				{ID: "f", Message: `command is ignored for type "folder"`},
+				{ID: "f/g", Message: "targets are only used on top-level items"},
+				{ID: "f/g", Message: "folder has no items"},
+			},
+		},
+		{
+			name: "nested",
+			items: `{"a": {"type": "folder", "title": "A", "items": {"b": {"type": "folder", "title": "B", "items": {"c": {"type": "folder", "title": "C", "items": {
+				"d": {"type": "folder", "title": "D", "items": {"e": {"type": "folder", "title": "E", "items": {"f": {"type": "folder", "title": "F", "items": {
+					"g": {"type": "item", "title": "G", "command": ["g.exe"]}
+				}}}}}}}}}}}}}`,
+			want: []Problem{
+				{ID: "a/b/c/d", Message: "items are nested 4 submenus deep, which is hard to reach", Warning: true},
+				{ID: "a/b/c/d/e", Message: "items are nested 5 submenus deep, Explorer does not show submenus deeper than 4"},
+			},
+		},
+		{
+			name:  "icon presets",
+			items: `{"a": {"type": "item", "title": "A", "command": ["a.exe"], "iconPath": "shell:bogus"}, "b": {"type": "item", "title": "B", "command": ["b.exe"], "iconPath": "shell:folder", "iconIndex": 2}}`,
+			want: []Problem{
+				{ID: "a", Message: "unknown icon preset \"shell:bogus\", use one of " + strings.Join(shellIconNames(), ", ")},
+				{ID: "b", Message: `iconIndex is set for the icon preset "shell:folder", which has an index of its own`},
+			},
+		},
+		{
+			name: "recent",
+			items: `{"r": {"type": "recent", "title": "Recent", "command": {"windows": ["notepad.exe", "${recentFile}"], "linux": ["gedit"]}, "source": "commands", "limit": -1},
+				"ok": {"type": "recent", "title": "Recent", "limit": 5},
+				"i": {"type": "item", "title": "I", "command": ["i.exe"], "limit": 5}}`,
+			want: []Problem{
+				{ID: "r", Message: "command of a recent folder does not use ${recentFile}"},
+				{ID: "r", Message: `unknown source "commands", expected "files"`},
+				{ID: "r", Message: "limit must not be negative"},
+				{ID: "i", Message: `source and limit are only used for type "recent"`},
+			},
+		},
+		{
+			name: "scripts folder",
+			items: `{"s": {"type": "scriptsFolder", "title": "Scripts", "path": " ", "command": ["a.exe"]},
+				"i": {"type": "item", "title": "I", "command": ["i.exe"], "path": "scripts"}}`,
+			want: []Problem{
+				{ID: "s", Message: "path is empty"},
+				{ID: "s", Message: `command is ignored for type "scriptsFolder"`},
+				{ID: "i", Message: `path is only used for type "scriptsFolder"`},
+			},
+		},
+		{
+			name: "copy path",
+			items: `{"c": {"type": "copyPath", "title": "Copy", "pathFormat": "mac", "command": ["a.exe"]},
+				"i": {"type": "item", "title": "I", "command": ["i.exe"], "pathFormat": "wsl"}}`,
+			want: []Problem{
+				{ID: "c", Message: "unknown path format \"mac\", expected one of windows, forwardSlash, wsl, unc"},
+				{ID: "c", Message: `command is ignored for type "copyPath"`},
+				{ID: "i", Message: `pathFormat is only used for type "copyPath"`},
+			},
+		},
+		{
+			name:  "unknown type",
+			items: `{"a": {"type": "link", "title": "A"}}`,
+			want:  []Problem{{ID: "a", Message: `unknown type "link", expected one of item, folder, recent, scriptsFolder, terminalProfiles, wslDistros, copyPath`}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validateManifest(&Manifest{Items: testMenus(t, test.items)}); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("found %+v, expected %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestPlanProblems checks that validate plans the commands for Windows wherever it runs, and that
+// admin items are no problem without nircmd.exe, which only has to be where the keys are applied.
+func TestPlanProblems(t *testing.T) {
+	var manifest = &Manifest{SchemaVersion: manifestSchemaVersion, Items: testMenus(t, `{
+		"admin": {"type": "item", "title": "Admin", "command": {"windows": ["a.exe", "%V"], "linux": ["a"]}, "admin": true},
+		"linux": {"type": "item", "title": "Linux only", "command": {"linux": ["a"]}},
+		"tools": {"type": "folder", "title": "Tools", "items": {"b": {"type": "item", "title": "B", "command": ["b.exe"], "admin": true}}}
+	}`)}
+	t.Setenv("PATH", t.TempDir())
+	if problems := planProblems(manifest, `C:\menus`); len(problems) > 0 {
+		t.Errorf("found %+v", problems)
+	}
+	if nircmdFallback != "" {
+		t.Errorf("left nircmd.exe fallback %q", nircmdFallback)
+	}
+}
+
+func TestTitleProblems(t *testing.T) {
+	for _, test := range []struct {
+		title        string
+		wantProblems []string
+		wantWarnings []string
+	}{
+		{title: "Open &Terminal"},
+		{title: "打开终端 🚀"},
+		{title: "\u202Bافتح هنا\u202C and \u2067עברית\u2069"},
+		{title: "Tab\there", wantProblems: []string{"title contains control character U+0009"}},
+		{title: "Line\u2028break", wantProblems: []string{"title contains control character U+2028"}},
+		{title: "\u202Bافتح هنا", wantProblems: []string{"title has bidirectional formatting characters that are not closed"}},
+		{title: "\u2069x\u2067y", wantProblems: []string{"title has bidirectional formatting characters that are not closed"}},
+		{title: "Caf\uFFFD", wantWarnings: []string{"title contains U+FFFD, left by text that was not valid Unicode"}},
+		{title: " Open ", wantWarnings: []string{"title has leading or trailing spaces, run fmt to remove them"}},
+		{title: strings.Repeat("a", 63) + "&e\u0301"},
+		{title: strings.Repeat("é", 65), wantWarnings: []string{"title is 65 characters long, menus may cut it off after about 64"}},
+	} {
+		problems, warnings := titleProblems(test.title)
+		if !reflect.DeepEqual(problems, test.wantProblems) || !reflect.DeepEqual(warnings, test.wantWarnings) {
+			t.Errorf("titleProblems(%q) = %q, %q, expected %q, %q", test.title, problems, warnings, test.wantProblems, test.wantWarnings)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+)
+
+// platforms are the keys of a "command" or "iconPath" given per platform, as in runtime.GOOS.
+var platforms = []string{"windows", "linux", "darwin"}
+
+// platformVariants holds the commands and icons of an item that are given per platform. Command
+// and IconPath of the item are those of the platform it runs on, and edits to them are written
+// back to its entry here.
+type platformVariants struct {
+	Command       map[string][]string
+	IconPath      map[string]string
+	IconFallbacks map[string][]string
+}
+
+func (c *ContextMenu) UnmarshalJSON(data []byte) error {
+	return newJSONDecoder(bytes.NewReader(data), false).decodeMenu(c, nil, nil)
+}
+
+// decodeMenu decodes an item, along with its ID into id for an item of a list, which must not be
+// one of those seen before it. A per-platform command or icon is resolved for the platform it runs
+// on.
+func (d *jsonDecoder) decodeMenu(menu *ContextMenu, id *string, seen map[string]bool) (err error) {
+	var (
+		tok    json.Token
+		fields = jsonFields(menu)
+	)
+	if tok, err = d.token(); err != nil {
+		return
+	}
+	if tok != json.Delim('{') {
+		return d.fail(errorf("an item must be an object, got %v", tok))
+	}
+	if id != nil {
+		fields["id"] = id
+	}
+	menu.Command, menu.IconPath, menu.IconFallbacks, menu.Variants = nil, "", nil, platformVariants{}
+	for d.dec.More() {
+		var key string
+		if key, err = d.key(); err != nil {
+			return
+		}
+		name, field := jsonField(fields, key)
+		if field == nil {
+			return d.unknownField(key)
+		}
+		d.path = append(d.path, key)
+		switch name {
+		case "id":
+			if err = d.decode(id); err != nil {
+				return
+			}
+			if d.path = d.path[:len(d.path)-1]; seen[*id] {
+				return d.fail(errorf("duplicate item ID %q", *id))
+			}
+			// The item goes by its ID from here on.
+			d.path[len(d.path)-1] = *id
+			continue
+		case "command":
+			err = d.decodeCommandVariants(menu)
+		case "iconPath":
+			err = d.decodeIconPathVariants(menu)
+		case "items":
+			err = d.decodeMenus(&menu.Items)
+		default:
+			err = d.decode(field)
+		}
+		if err != nil {
+			return
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+	if menu.Variants.Command != nil {
+		menu.Command = menu.Variants.Command[runtime.GOOS]
+	}
+	if menu.Variants.IconPath != nil {
+		menu.IconPath, menu.IconFallbacks = menu.Variants.IconPath[runtime.GOOS], menu.Variants.IconFallbacks[runtime.GOOS]
+	}
+	_, err = d.token()
+	return
+}
+
+// decodeVariants decodes a field that may be given per platform. each is called for the first
+// token of the value of each platform, or once with no platform for a value of all of them.
+func (d *jsonDecoder) decodeVariants(each func(platform string, tok json.Token) error) (perPlatform bool, err error) {
+	var (
+		field = d.path[len(d.path)-1]
+		tok   json.Token
+	)
+	if tok, err = d.token(); err != nil {
+		return
+	}
+	if tok != json.Delim('{') {
+		err = each("", tok)
+		return
+	}
+	perPlatform = true
+	for d.dec.More() {
+		var platform string
+		if platform, err = d.key(); err != nil {
+			return
+		}
+		d.path = append(d.path, platform)
+		if !isPlatform(platform) {
+			err = d.fail(errorf("unknown platform %q in %s, expected %q, %q or %q", platform, field, platforms[0], platforms[1], platforms[2]))
+			return
+		}
+		if tok, err = d.token(); err != nil {
+			return
+		}
+		if err = each(platform, tok); err != nil {
+			return
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+	_, err = d.token()
+	return
+}
+
+func (d *jsonDecoder) decodeCommandVariants(menu *ContextMenu) (err error) {
+	var perPlatform bool
+	perPlatform, err = d.decodeVariants(func(platform string, tok json.Token) (err error) {
+		var command []string
+		if command, err = d.decodeCommand(tok); err != nil {
+			return
+		}
+		if platform == "" {
+			menu.Command = command
+			return
+		}
+		if menu.Variants.Command == nil {
+			menu.Variants.Command = make(map[string][]string)
+		}
+		menu.Variants.Command[platform] = command
+		return
+	})
+	if perPlatform && menu.Variants.Command == nil {
+		menu.Variants.Command = make(map[string][]string)
+	}
+	return
+}
+
+func (d *jsonDecoder) decodeIconPathVariants(menu *ContextMenu) (err error) {
+	var perPlatform bool
+	perPlatform, err = d.decodeVariants(func(platform string, tok json.Token) (err error) {
+		var (
+			iconPath  string
+			fallbacks []string
+		)
+		if iconPath, fallbacks, err = d.decodeIconPath(tok); err != nil {
+			return
+		}
+		if platform == "" {
+			menu.IconPath, menu.IconFallbacks = iconPath, fallbacks
+			return
+		}
+		if menu.Variants.IconPath == nil {
+			menu.Variants.IconPath = make(map[string]string)
+		}
+		menu.Variants.IconPath[platform] = iconPath
+		if fallbacks != nil {
+			if menu.Variants.IconFallbacks == nil {
+				menu.Variants.IconFallbacks = make(map[string][]string)
+			}
+			menu.Variants.IconFallbacks[platform] = fallbacks
+		}
+		return
+	})
+	if perPlatform && menu.Variants.IconPath == nil {
+		menu.Variants.IconPath = make(map[string]string)
+	}
+	return
+}
+
+// decodeCommand decodes the arguments of a command that starts with tok one by one, so that an
+// error names the argument it is about.
+func (d *jsonDecoder) decodeCommand(tok json.Token) (command []string, err error) {
+	switch tok {
+	case nil:
+	case json.Delim('['):
+		command, err = d.decodeStrings()
+	default:
+		err = d.fail(errorf("command must be a list of arguments, got %v", tok))
+	}
+	return
+}
+
+// decodeIconPath decodes an icon that starts with tok, or a list of icons to try in turn into the
+// first one and the fallbacks after it.
+func (d *jsonDecoder) decodeIconPath(tok json.Token) (iconPath string, fallbacks []string, err error) {
+	var iconPaths []string
+	switch tok := tok.(type) {
+	case nil:
+		return
+	case string:
+		iconPath = tok
+		return
+	case json.Delim:
+		if tok == json.Delim('[') {
+			if iconPaths, err = d.decodeStrings(); err != nil || len(iconPaths) == 0 {
+				return
+			}
+			iconPath = iconPaths[0]
+			if len(iconPaths) > 1 {
+				fallbacks = iconPaths[1:]
+			}
+			return
+		}
+	}
+	err = d.fail(errorf("iconPath must be a path or a list of paths, got %v", tok))
+	return
+}
+
+// decodeStrings decodes the strings of a list whose "[" was read one by one, with the index of each
+// in the path.
+func (d *jsonDecoder) decodeStrings() (list []string, err error) {
+	for d.dec.More() {
+		var s string
+		d.path = append(d.path, len(list))
+		if err = d.decode(&s); err != nil {
+			return
+		}
+		d.path = d.path[:len(d.path)-1]
+		list = append(list, s)
+	}
+	_, err = d.token()
+	return
+}
+
+func isJSONObject(data json.RawMessage) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+func isPlatform(platform string) bool {
+	for _, p := range platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON writes a command or icon given per platform back as an object, keeping the fields
+// in their usual order.
+func (c ContextMenu) MarshalJSON() (data []byte, err error) {
+	type plain ContextMenu
+	var (
+		item     = plain(c)
+		replaced = make(map[string]interface{})
+	)
+	if c.Variants.Command != nil {
+		var commands = make(map[string][]string)
+		for _, platform := range platforms {
+			if _, ok := c.Variants.Command[platform]; ok || platform == runtime.GOOS && len(c.Command) > 0 {
+				commands[platform] = c.commandOn(platform)
+			}
+		}
+		// Any value keeps the field in place until it is replaced below.
+		item.Command, replaced["command"] = []string{""}, commands
+	}
+	if c.Variants.IconPath != nil {
+		var iconPaths = make(map[string]interface{})
+		for _, platform := range platforms {
+			if _, ok := c.Variants.IconPath[platform]; ok || platform == runtime.GOOS && c.IconPath != "" {
+				iconPaths[platform] = iconPathValue(c.iconPathOn(platform), c.iconFallbacksOn(platform))
+			}
+		}
+		item.IconPath, replaced["iconPath"] = " ", iconPaths
+	} else if len(c.IconFallbacks) > 0 {
+		item.IconPath, replaced["iconPath"] = " ", iconPathValue(c.IconPath, c.IconFallbacks)
+	}
+	if data, err = marshalJSON(item, ""); err != nil || len(replaced) == 0 {
+		return
+	}
+	data, err = replaceJSONFields(data, replaced)
+	return
+}
+
+// replaceJSONFields rewrites the fields of a JSON object named in values with their new value.
+func replaceJSONFields(data []byte, values map[string]interface{}) (replaced []byte, err error) {
+	var (
+		dec = json.NewDecoder(bytes.NewReader(data))
+		buf bytes.Buffer
+		tok json.Token
+	)
+	if _, err = dec.Token(); err != nil {
+		return
+	}
+	buf.WriteByte('{')
+	for dec.More() {
+		var (
+			value json.RawMessage
+			key   []byte
+		)
+		if tok, err = dec.Token(); err != nil {
+			return
+		}
+		if err = dec.Decode(&value); err != nil {
+			return
+		}
+		if v, ok := values[tok.(string)]; ok {
+			if value, err = marshalJSON(v, ""); err != nil {
+				return
+			}
+		}
+		if key, err = marshalJSON(tok, ""); err != nil {
+			return
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	replaced = buf.Bytes()
+	return
+}
+
+// commandOn is the command of the item on platform, which is the same on all unless the command
+// is given per platform.
+func (c *ContextMenu) commandOn(platform string) []string {
+	if platform == runtime.GOOS || c.Variants.Command == nil {
+		return c.Command
+	}
+	return c.Variants.Command[platform]
+}
+
+func (c *ContextMenu) iconPathOn(platform string) string {
+	if platform == runtime.GOOS || c.Variants.IconPath == nil {
+		return c.IconPath
+	}
+	return c.Variants.IconPath[platform]
+}
+
+func (c *ContextMenu) iconFallbacksOn(platform string) []string {
+	if platform == runtime.GOOS || c.Variants.IconPath == nil {
+		return c.IconFallbacks
+	}
+	return c.Variants.IconFallbacks[platform]
+}
+
+// iconPathTextOn is the icon of the item on platform with its fallbacks, for comparing and showing.
+func (c *ContextMenu) iconPathTextOn(platform string) string {
+	return strings.Join(append([]string{c.iconPathOn(platform)}, c.iconFallbacksOn(platform)...), ", ")
+}
+
+// iconPathValue is how an icon is written to the manifest: a string, or a list with fallbacks.
+func iconPathValue(iconPath string, fallbacks []string) interface{} {
+	if len(fallbacks) == 0 {
+		return iconPath
+	}
+	return append([]string{iconPath}, fallbacks...)
+}
+
+// setIconPathOn changes the icon of the item on platform, if it has one there.
+func (c *ContextMenu) setIconPathOn(platform, iconPath string) {
+	if platform == runtime.GOOS || c.Variants.IconPath == nil {
+		c.IconPath = iconPath
+	} else if _, ok := c.Variants.IconPath[platform]; ok {
+		c.Variants.IconPath[platform] = iconPath
+	}
+}
+
+// platformText shows the value of a field on each platform, or just the value when it is the
+// same on all.
+func platformText(perPlatform bool, value func(platform string) string) string {
+	var parts []string
+	if !perPlatform {
+		return value(runtime.GOOS)
+	}
+	for _, platform := range platforms {
+		parts = append(parts, platform+": "+value(platform))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// forPlatform resolves the commands and icons of the items for platform. It leaves out the items
+// whose command is only given for other platforms, and the folders that are left empty by that.
+func (c ContextMenus) forPlatform(platform string) (menus ContextMenus) {
+	for _, entry := range c {
+		var item = *entry.Menu
+		if item.Variants.Command != nil {
+			if _, ok := item.Variants.Command[platform]; !ok {
+				continue
+			}
+		}
+		item.Command, item.IconPath = entry.Menu.commandOn(platform), entry.Menu.iconPathOn(platform)
+		item.IconFallbacks = entry.Menu.iconFallbacksOn(platform)
+		item.Variants = platformVariants{}
+		if item.Type == ContextMenuType_Folder && len(item.Items) > 0 {
+			if item.Items = item.Items.forPlatform(platform); len(item.Items) == 0 {
+				continue
+			}
+		}
+		menus = append(menus, ContextMenuEntry{ID: entry.ID, Menu: &item})
+	}
+	return
+}
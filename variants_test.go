@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+// TestForPlatform checks the items resolved for each platform: commands and icons given per
+// platform are replaced by those of the platform, and items and folders without a command there
+// are left out.
+func TestForPlatform(t *testing.T) {
+	const items = `[
+		{"id": "open", "type": "item", "title": "Open", "command": {"windows": ["explorer.exe", "%V"], "linux": ["xdg-open", "%V"]}, "iconPath": {"windows": ["a.ico", "b.ico"], "darwin": "a.icns"}},
+		{"id": "edit", "type": "item", "title": "Edit", "command": ["code", "%V"], "iconPath": "code.png"},
+		{"id": "tools", "type": "folder", "title": "Tools", "items": [
+			{"id": "hash", "type": "item", "title": "Hash", "command": {"windows": ["certutil.exe", "-hashfile", "%V"]}}
+		]},
+		{"id": "empty", "type": "folder", "title": "Empty", "items": []}
+	]`
+	for _, test := range []struct {
+		platform string
+		want     string
+	}{
+		{
+			platform: "windows",
+			want: `[
+				{"id": "open", "type": "item", "title": "Open", "command": ["explorer.exe", "%V"], "iconPath": ["a.ico", "b.ico"]},
+				{"id": "edit", "type": "item", "title": "Edit", "command": ["code", "%V"], "iconPath": "code.png"},
+				{"id": "tools", "type": "folder", "title": "Tools", "items": [
+					{"id": "hash", "type": "item", "title": "Hash", "command": ["certutil.exe", "-hashfile", "%V"]}
+				]},
+				{"id": "empty", "type": "folder", "title": "Empty"}
+			]`,
+		},
+		{
+			platform: "linux",
+			want: `[
+				{"id": "open", "type": "item", "title": "Open", "command": ["xdg-open", "%V"]},
+				{"id": "edit", "type": "item", "title": "Edit", "command": ["code", "%V"], "iconPath": "code.png"},
+				{"id": "empty", "type": "folder", "title": "Empty"}
+			]`,
+		},
+		{
+			platform: "darwin",
+			want: `[
+				{"id": "edit", "type": "item", "title": "Edit", "command": ["code", "%V"], "iconPath": "code.png"},
+				{"id": "empty", "type": "folder", "title": "Empty"}
+			]`,
+		},
+	} {
+		t.Run(test.platform, func(t *testing.T) {
+			menus := testMenus(t, items)
+			got := menus.forPlatform(test.platform)
+			if want := testMenus(t, test.want); !sameMenus(t, got, want) {
+				data, _ := marshalJSON(got, "")
+				t.Errorf("resolved into %s", data)
+			}
+			if again := testMenus(t, items); !sameMenus(t, menus, again) {
+				t.Error("resolving changed the items")
+			}
+		})
+	}
+}
+
+// TestVariantsRoundTrip checks that commands and icons given per platform are written back as
+// they were read, with platforms sorted, whichever platform reads them.
+func TestVariantsRoundTrip(t *testing.T) {
+	for _, items := range []string{
+		`{"a":{"type":"item","title":"A","command":{"linux":["a"],"windows":["a.exe"]}}}`,
+		`{"a":{"type":"item","title":"A","command":{"darwin":["open","-a","A"]}}}`,
+		`{"a":{"type":"item","title":"A","iconPath":{"linux":"a.png","windows":["a.ico","b.ico"]},"command":["a"]}}`,
+		`{"a":{"type":"item","title":"A","iconPath":["a.ico","b.ico"],"command":["a"]}}`,
+	} {
+		data, err := marshalJSON(testMenus(t, items), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != items {
+			t.Errorf("read %s, wrote back %s", items, data)
+		}
+	}
+}
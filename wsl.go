@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const Backend_WSL = "wsl"
+
+// wslExecutable is the Windows build that the wsl backend runs through WSL interop.
+const wslExecutable = "context-menu-manager.exe"
+
+// inWSL reports whether this runs in a WSL distribution that can start Windows programs.
+func inWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") == "" {
+		return false
+	}
+	_, err := os.Stat("/proc/sys/fs/binfmt_misc/WSLInterop")
+	return err == nil
+}
+
+// wslBackend applies the manifest to the Windows registry from inside WSL, by running the Windows
+// build with the effective options. It finds the same manifest, since it starts in its folder.
+type wslBackend struct{}
+
+// Apply and Remove leave Ctrl+C to the Windows build, which gets it as well and stops cleanly.
+func (b wslBackend) Apply(ctx context.Context, manifest *Manifest, manifestDir string) error {
+	if config.Force {
+		return b.run(os.Stdout, "apply", "--force")
+	}
+	return b.run(os.Stdout, "apply")
+}
+
+func (b wslBackend) Remove(ctx context.Context) error {
+	return b.run(os.Stdout, "remove")
+}
+
+func (b wslBackend) List(manifest *Manifest) (items []ItemStatus, err error) {
+	err = b.runJSON(&items, "list", "--json")
+	return
+}
+
+func (b wslBackend) Diff(manifest *Manifest, manifestDir string) (changes []Change, err error) {
+	err = b.runJSON(&changes, "diff", "--json")
+	return
+}
+
+// Toggle runs toggle when the item is not in the state asked for, and reports the state it is
+// left in.
+func (b wslBackend) Toggle(manifest *Manifest, id string, enable *bool) (enabled bool, err error) {
+	var (
+		items  []ItemStatus
+		status *ItemStatus
+	)
+	if items, err = b.List(manifest); err != nil {
+		return
+	}
+	for i := range items {
+		if items[i].ID == id {
+			status = &items[i]
+		}
+	}
+	if status == nil {
+		err = errorf("item ID %q not found in manifest", id)
+		return
+	}
+	if !status.Installed {
+		err = errorf("item ID %q is not applied", id)
+		return
+	}
+	if enabled = status.Enabled; enable != nil && *enable == enabled {
+		return
+	}
+	if err = b.run(io.Discard, "toggle", id); err != nil {
+		return
+	}
+	enabled = !enabled
+	return
+}
+
+func (b wslBackend) runJSON(v interface{}, args ...string) (err error) {
+	var out bytes.Buffer
+	if err = b.run(&out, args...); err != nil {
+		return
+	}
+	if err = json.Unmarshal(out.Bytes(), v); err != nil {
+		err = errorf("failed to read the output of %s: %w", wslExecutable, err)
+	}
+	return
+}
+
+func (wslBackend) run(stdout io.Writer, args ...string) (err error) {
+	var (
+		executable   string
+		manifestPath string
+		options      []string
+		cmd          *exec.Cmd
+	)
+	if executable, err = findWSLExecutable(); err != nil {
+		return
+	}
+	if options, err = wslOptions(); err != nil {
+		return
+	}
+	cmd = exec.Command(executable, append(options, args...)...)
+	if manifestPath, err = findManifest(); err == nil {
+		// Interop passes the folder on as a \\wsl.localhost path, so ${manifestFolder} resolves
+		// to one as well.
+		cmd.Dir = filepath.Dir(manifestPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	cmd.Stdout, cmd.Stderr = stdout, os.Stderr
+	logf(LogLevel_Debug, "running %s %s", executable, strings.Join(cmd.Args[1:], " "))
+	if err = cmd.Run(); err != nil {
+		err = errorf("%s failed: %w", wslExecutable, err)
+	}
+	return
+}
+
+// findWSLExecutable looks for the Windows build next to this executable, then on the PATH, which
+// WSL extends with the Windows one.
+func findWSLExecutable() (path string, err error) {
+	if self, selfErr := os.Executable(); selfErr == nil {
+		path = filepath.Join(filepath.Dir(self), wslExecutable)
+		if fi, statErr := os.Stat(path); statErr == nil && !fi.IsDir() {
+			return
+		}
+	}
+	if path, err = exec.LookPath(wslExecutable); err != nil {
+		err = errorf("%s not found next to this executable or on PATH: %w", wslExecutable, err)
+	}
+	return
+}
+
+// wslOptions passes the effective config on, so that the config in WSL applies rather than the
+// one on Windows. Paths are translated with wslpath.
+func wslOptions() (options []string, err error) {
+	options = []string{
+		"--hive", string(config.Hive),
+		"--targets", strings.Join(config.Targets, ","),
+		"--elevation", string(config.Elevation),
+		"--log-level", string(config.LogLevel),
+		"--prune=" + strconv.FormatBool(config.Prune),
+		"--split-folders=" + strconv.FormatBool(config.SplitFolders),
+		"--bulk-extensions=" + strconv.FormatBool(config.BulkExtensions),
+		"--accelerators=" + strconv.FormatBool(config.Accelerators),
+	}
+	if config.Language != "" {
+		options = append(options, "--language", config.Language)
+	}
+	if config.User != "" {
+		options = append(options, "--user", config.User)
+	}
+	// A portable Windows build next to this one keeps its state beside it as well.
+	if config.Portable {
+		options = append(options, "--portable")
+	}
+	if config.HiveFile != "" {
+		var out []byte
+		if out, err = exec.Command("wslpath", "-w", config.HiveFile).Output(); err != nil {
+			err = errorf("failed to translate %s to a Windows path: %w", config.HiveFile, err)
+			return
+		}
+		options = append(options, "--hive-file", strings.TrimSpace(string(out)))
+	}
+	return
+}
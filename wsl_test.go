@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestWSLConformance runs the Windows build, whose state is that of the Windows user, so it removes
+// what was applied there; it only runs with CMM_TEST_WSL=1.
+func TestWSLConformance(t *testing.T) {
+	if !inWSL() || os.Getenv("CMM_TEST_WSL") != "1" {
+		t.Skip("set CMM_TEST_WSL=1 in WSL to run it")
+	}
+	if _, err := findWSLExecutable(); err != nil {
+		t.Skip(err)
+	}
+	testConformance(t, wslBackend{})
+}
+
+// TestWSLOptions checks that the options passed on to the Windows build give it the config in
+// effect in WSL, over the defaults it starts from.
+func TestWSLOptions(t *testing.T) {
+	isolateState(t)
+	t.Setenv("WSL_DISTRO_NAME", "")
+	defer func(saved Config, stdout *os.File) {
+		config, os.Stdout = saved, stdout
+	}(config, os.Stdout)
+	var (
+		options []string
+		err     error
+	)
+	if os.Stdout, err = os.OpenFile(os.DevNull, os.O_WRONLY, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Stdout.Close()
+	for _, want := range []Config{
+		defaultConfig,
+		{
+			Hive:         Hive_Machine,
+			Targets:      []string{"directory", ".txt"},
+			Elevation:    ElevationBackend_RunAs,
+			LogLevel:     LogLevel_Debug,
+			Prune:        true,
+			SplitFolders: true,
+			Accelerators: true,
+			Language:     "zh",
+		},
+		{Hive: Hive_User, Targets: []string{"background"}, Elevation: ElevationBackend_Nircmd, LogLevel: LogLevel_Error, BulkExtensions: true},
+	} {
+		config = want
+		if options, err = wslOptions(); err != nil {
+			t.Fatal(err)
+		}
+		config = defaultConfig
+		if err = run(append(options, "version")); err != nil {
+			t.Fatalf("%q: %v", options, err)
+		}
+		if !reflect.DeepEqual(config, want) {
+			t.Errorf("%q give %+v, expected %+v", options, config, want)
+		}
+	}
+}
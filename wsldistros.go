@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// wslListTimeout bounds wsl.exe --list, which waits for the WSL service to start.
+const wslListTimeout = 10 * time.Second
+
+// wslDistroItems has an item per installed WSL distribution that opens its shell in the folder
+// clicked on, which wsl.exe translates to the Linux path. A slash follows %V like in terminal items,
+// so that the quote after a drive root is not escaped. Distributions of Docker Desktop, which
+// have no shell to speak of, are left out.
+func wslDistroItems(platform string) (items ContextMenus) {
+	var (
+		distros []string
+		err     error
+	)
+	if platform != "windows" {
+		return
+	}
+	if distros, err = wslDistros(); err != nil {
+		if !errors.Is(err, exec.ErrNotFound) {
+			logf(LogLevel_Warn, "failed to list the WSL distributions: %v", err)
+		}
+		return
+	}
+	for _, distro := range distros {
+		if strings.HasPrefix(distro, "docker-desktop") {
+			continue
+		}
+		item := &ContextMenu{
+			Type:          ContextMenuType_Item,
+			Title:         sprintf("Open %s shell here", strings.ReplaceAll(distro, "&", "&&")),
+			IconPath:      "wsl.exe",
+			IconFallbacks: []string{shellIconPrefix + "terminal"},
+			Command:       []string{"wsl.exe", "--distribution", distro, "--cd", "%V/"},
+		}
+		items = append(items, ContextMenuEntry{ID: uniqueID(items, distro), Menu: item})
+	}
+	return
+}
+
+// wslDistros runs wsl.exe --list --quiet, which writes UTF-16 unless WSL_UTF8 asks for UTF-8 and
+// the version of WSL knows it.
+func wslDistros() (distros []string, err error) {
+	var (
+		ctx, cancel = context.WithTimeout(context.Background(), wslListTimeout)
+		cmd         = exec.CommandContext(ctx, "wsl.exe", "--list", "--quiet")
+		out         []byte
+	)
+	defer cancel()
+	cmd.Env = append(os.Environ(), "WSL_UTF8=1")
+	if out, err = cmd.Output(); err != nil {
+		return
+	}
+	distros = parseWSLList(out)
+	return
+}
+
+// parseWSLList reads the names in the output of wsl.exe --list --quiet, in UTF-16 with or without
+// a byte order mark, or in UTF-8.
+func parseWSLList(out []byte) (distros []string) {
+	if bytes.IndexByte(out, 0) >= 0 && !bytes.HasPrefix(out, []byte{0xff, 0xfe}) {
+		out = append([]byte{0xff, 0xfe}, out...)
+	}
+	for _, line := range strings.Split(string(decodeText(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			distros = append(distros, line)
+		}
+	}
+	return
+}
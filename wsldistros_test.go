@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestParseWSLList(t *testing.T) {
+	var utf16LE = func(s string) (data []byte) {
+		for _, unit := range utf16.Encode([]rune(s)) {
+			data = append(data, byte(unit), byte(unit>>8))
+		}
+		return
+	}
+	for _, test := range []struct {
+		name string
+		out  []byte
+		want []string
+	}{
+		{name: "UTF-16", out: utf16LE("Ubuntu-22.04\r\nDebian\r\n"), want: []string{"Ubuntu-22.04", "Debian"}},
+		{name: "UTF-16 with a byte order mark", out: utf16LE("\uFEFFUbuntu\r\n"), want: []string{"Ubuntu"}},
+		{name: "UTF-8", out: []byte("Ubuntu\nArch & Co\n\n"), want: []string{"Ubuntu", "Arch & Co"}},
+		{name: "none", out: nil, want: nil},
+	} {
+		if got := parseWSLList(test.out); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: listed %q, expected %q", test.name, got, test.want)
+		}
+	}
+	if items := wslDistroItems("linux"); items != nil {
+		t.Errorf("listed %d distributions for Linux", len(items))
+	}
+}